@@ -0,0 +1,85 @@
+package main
+
+import (
+        "encoding/base64"
+        "errors"
+        "net/http"
+        "strings"
+        "time"
+)
+
+// encodeBetCursor renders the position after bet as an opaque next_cursor token
+func encodeBetCursor(bet *Bet) string {
+        raw := bet.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + bet.BetID
+        return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeBetCursor parses a token made by encodeBetCursor
+func decodeBetCursor(token string) (*BetCursor, error) {
+        raw, err := base64.RawURLEncoding.DecodeString(token)
+        if err != nil {
+                return nil, ErrInvalidBetCursor
+        }
+        createdAt, betID, ok := strings.Cut(string(raw), ",")
+        if !ok || betID == "" {
+                return nil, ErrInvalidBetCursor
+        }
+        parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+        if err != nil {
+                return nil, ErrInvalidBetCursor
+        }
+        return &BetCursor{CreatedAt: parsed, BetID: betID}, nil
+}
+
+// betPageParams parses ?limit=, ?offset= and ?cursor= for a bet history; writes 400 and returns false if invalid
+// Without any of them the whole history is returned, as before paging existed
+func (h *Handler) betPageParams(w http.ResponseWriter, r *http.Request) (BetPage, bool) {
+        query := r.URL.Query()
+        if !query.Has("limit") && !query.Has("offset") && !query.Has("cursor") {
+                return BetPage{}, true
+        }
+
+        limit, offset := h.pageParams(r)
+        page := BetPage{Limit: limit, Offset: offset}
+        if token := query.Get("cursor"); token != "" {
+                if query.Has("offset") {
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Use either cursor or offset, not both")
+                        return BetPage{}, false
+                }
+                cursor, err := decodeBetCursor(token)
+                if err != nil {
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid cursor")
+                        return BetPage{}, false
+                }
+                page.After = cursor
+        }
+        return page, true
+}
+
+// userBetPage loads one page of bet history and the cursor of the page after it ("" on the last page)
+// One extra bet is read to tell whether another page exists
+func (h *Handler) userBetPage(r *http.Request, userID, playerNickname string, page BetPage) ([]Bet, string, error) {
+        if page.Limit == 0 {
+                bets, err := h.db.GetUserBets(r.Context(), userID, playerNickname, page)
+                return bets, "", err
+        }
+
+        probe := page
+        probe.Limit++
+        bets, err := h.db.GetUserBets(r.Context(), userID, playerNickname, probe)
+        if err != nil || len(bets) <= page.Limit {
+                return bets, "", err
+        }
+        bets = bets[:page.Limit]
+        return bets, encodeBetCursor(&bets[len(bets)-1]), nil
+}
+
+// writeBetPageError answers a failed userBetPage
+func (h *Handler) writeBetPageError(w http.ResponseWriter, r *http.Request, err error) {
+        if errors.Is(err, ErrInvalidBetCursor) {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid cursor")
+                return
+        }
+        h.logger.LogError("Failed to get bets: %s", err.Error())
+        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get bets")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+        "fmt"
+        "net"
+        "net/http"
+        "net/netip"
+        "strings"
+)
+
+// CIDR is a trusted proxy range; single addresses are full-length prefixes (/32, /128)
+type CIDR = netip.Prefix
+
+// ParseCIDR parses a TRUSTED_PROXIES entry: a CIDR ("10.0.0.0/8") or a single IP address
+func ParseCIDR(value string) (CIDR, error) {
+        if strings.Contains(value, "/") {
+                prefix, err := netip.ParsePrefix(value)
+                if err != nil {
+                        return CIDR{}, err
+                }
+                return prefix.Masked(), nil
+        }
+        addr, err := netip.ParseAddr(value)
+        if err != nil {
+                return CIDR{}, fmt.Errorf("invalid IP address %q", value)
+        }
+        return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ClientIP returns the client IP of a request; it is the only place forwarded headers are read
+// They are honored only when the direct peer (RemoteAddr) is a trusted proxy, anyone else
+// could set them to spoof their address. Precedence: X-Forwarded-For, X-Real-IP,
+// CF-Connecting-IP, X-Client-IP, then RemoteAddr
+func ClientIP(r *http.Request, trustedProxies []CIDR) string {
+        // Fallback to RemoteAddr (remove port if present)
+        remoteAddr := r.RemoteAddr
+        if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+                remoteAddr = host
+        }
+        if !isTrustedProxy(remoteAddr, trustedProxies) {
+                return remoteAddr
+        }
+
+        // Check X-Forwarded-For header (can contain multiple IPs)
+        // Each proxy appends its peer, so walk from the right and skip our own proxies;
+        // everything left of the first untrusted hop was supplied by the client
+        if xForwardedFor := r.Header.Get("X-Forwarded-For"); xForwardedFor != "" {
+                ips := strings.Split(xForwardedFor, ",")
+                for i := len(ips) - 1; i >= 0; i-- {
+                        ip := strings.TrimSpace(ips[i])
+                        if ip == "" || ip == "unknown" {
+                                break
+                        }
+                        if i == 0 || !isTrustedProxy(ip, trustedProxies) {
+                                return ip
+                        }
+                }
+        }
+
+        // Single-value headers, in order of preference (CF-Connecting-IP is set by Cloudflare)
+        for _, header := range []string{"X-Real-IP", "CF-Connecting-IP", "X-Client-IP"} {
+                if ip := strings.TrimSpace(r.Header.Get(header)); ip != "" && ip != "unknown" {
+                        return ip
+                }
+        }
+
+        return remoteAddr
+}
+
+// isTrustedProxy reports whether ip falls within one of the trusted proxy ranges
+func isTrustedProxy(ip string, trustedProxies []CIDR) bool {
+        addr, err := netip.ParseAddr(ip)
+        if err != nil {
+                return false
+        }
+        addr = addr.Unmap() // ::ffff:10.0.0.1 matches 10.0.0.0/8
+        for _, cidr := range trustedProxies {
+                if cidr.Contains(addr) {
+                        return true
+                }
+        }
+        return false
+}
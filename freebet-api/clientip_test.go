@@ -0,0 +1,70 @@
+package main
+
+import (
+        "net/http/httptest"
+        "testing"
+)
+
+func TestClientIP(t *testing.T) {
+        trusted := []CIDR{mustParseCIDR(t, "10.0.0.0/8"), mustParseCIDR(t, "192.0.2.1")}
+
+        tests := []struct {
+                name       string
+                remoteAddr string
+                headers    map[string]string
+                want       string
+        }{
+                {"no headers", "10.0.0.5:1234", nil, "10.0.0.5"},
+                {"remote address without port", "198.51.100.7", nil, "198.51.100.7"},
+                {"untrusted peer's headers are ignored", "198.51.100.7:1234", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4"}, "198.51.100.7"},
+                {"X-Forwarded-For single hop", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+                {"X-Forwarded-For skips trusted hops from the right", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9, 10.0.0.9, 192.0.2.1"}, "203.0.113.9"},
+                {"X-Forwarded-For all trusted takes the leftmost", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "10.0.0.8, 10.0.0.9"}, "10.0.0.8"},
+                {"X-Forwarded-For beats X-Real-IP", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "203.0.113.9", "X-Real-IP": "203.0.113.10"}, "203.0.113.9"},
+                {"X-Real-IP beats CF-Connecting-IP", "10.0.0.5:1234", map[string]string{"X-Real-IP": "203.0.113.10", "CF-Connecting-IP": "203.0.113.11"}, "203.0.113.10"},
+                {"CF-Connecting-IP beats X-Client-IP", "10.0.0.5:1234", map[string]string{"CF-Connecting-IP": "203.0.113.11", "X-Client-IP": "203.0.113.12"}, "203.0.113.11"},
+                {"X-Client-IP", "10.0.0.5:1234", map[string]string{"X-Client-IP": "203.0.113.12"}, "203.0.113.12"},
+                {"unknown values fall through", "10.0.0.5:1234", map[string]string{"X-Forwarded-For": "unknown", "X-Real-IP": "unknown", "X-Client-IP": "203.0.113.12"}, "203.0.113.12"},
+                {"IPv4-mapped trusted peer", "[::ffff:10.0.0.5]:1234", map[string]string{"X-Real-IP": "203.0.113.10"}, "203.0.113.10"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        req := httptest.NewRequest("GET", "/", nil)
+                        req.RemoteAddr = tt.remoteAddr
+                        for header, value := range tt.headers {
+                                req.Header.Set(header, value)
+                        }
+                        if got := ClientIP(req, trusted); got != tt.want {
+                                t.Errorf("ClientIP = %q, want %q", got, tt.want)
+                        }
+                })
+        }
+}
+
+func TestParseCIDR(t *testing.T) {
+        for value, want := range map[string]string{
+                "10.1.2.3/8": "10.0.0.0/8",
+                "192.0.2.1":  "192.0.2.1/32",
+                "::1":        "::1/128",
+        } {
+                if got := mustParseCIDR(t, value).String(); got != want {
+                        t.Errorf("ParseCIDR(%q) = %s, want %s", value, got, want)
+                }
+        }
+        for _, value := range []string{"", "10.0.0.0/33", "not-an-ip"} {
+                if _, err := ParseCIDR(value); err == nil {
+                        t.Errorf("ParseCIDR(%q) succeeded, want an error", value)
+                }
+        }
+}
+
+// mustParseCIDR parses a trusted proxy entry or fails the test
+func mustParseCIDR(t *testing.T, value string) CIDR {
+        t.Helper()
+
+        cidr, err := ParseCIDR(value)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return cidr
+}
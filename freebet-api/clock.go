@@ -0,0 +1,52 @@
+package main
+
+import (
+        "sync"
+        "time"
+)
+
+// Clock abstracts the current time so time-based logic (topup cooldown,
+// bet cutoff, token and OAuth state expiry) can be tested deterministically
+type Clock interface {
+        Now() time.Time
+}
+
+// RealClock returns the system time
+type RealClock struct{}
+
+// Now returns time.Now()
+func (RealClock) Now() time.Time {
+        return time.Now()
+}
+
+// FakeClock is a manually controlled clock for tests
+type FakeClock struct {
+        mu  sync.Mutex
+        now time.Time
+}
+
+// NewFakeClock creates a fake clock fixed at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+        return &FakeClock{now: now}
+}
+
+// Now returns the fake clock's current time
+func (c *FakeClock) Now() time.Time {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        return c.now
+}
+
+// Set moves the fake clock to the given time
+func (c *FakeClock) Set(now time.Time) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        c.now = now
+}
+
+// Advance moves the fake clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        c.now = c.now.Add(d)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+        "bufio"
+        "context"
+        "errors"
+        "flag"
+        "fmt"
+        "io"
+        "os"
+        "strings"
+
+        "golang.org/x/crypto/bcrypt"
+        "golang.org/x/term"
+)
+
+// runCommand dispatches a command-line subcommand and returns the process exit code
+func runCommand(name string, args []string) int {
+        switch name {
+        case "migrate":
+                return migrateCommand(args)
+        case "create-admin":
+                return createAdminCommand(args)
+        default:
+                fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+                printUsage()
+                return 2
+        }
+}
+
+// printUsage lists the available subcommands
+func printUsage() {
+        fmt.Fprintln(os.Stderr, "Usage: freebet-api [command]")
+        fmt.Fprintln(os.Stderr, "")
+        fmt.Fprintln(os.Stderr, "Commands:")
+        fmt.Fprintln(os.Stderr, "  (none)          Start the API server")
+        fmt.Fprintln(os.Stderr, "  migrate         Apply pending database migrations")
+        fmt.Fprintln(os.Stderr, "  create-admin    Create an admin account (prompts for username, email, password)")
+}
+
+// migrateCommand applies pending migrations and exits
+func migrateCommand(args []string) int {
+        if len(args) > 0 {
+                fmt.Fprintf(os.Stderr, "migrate takes no arguments\n")
+                return 2
+        }
+
+        config, err := loadConfig()
+        if err != nil {
+                fmt.Printf("[ERROR] Failed to load configuration: %v\n", err)
+                return 1
+        }
+        logger := NewLogger(config.LogLevel, os.Stdout)
+        logger.SetSQLOptions(config.LogSQLText, config.SlowQueryThreshold)
+
+        db, err := NewPostgresDB(config.DatabaseURL, config, logger)
+        if err != nil {
+                logger.LogError("Failed to connect to database: %s", err.Error())
+                return 1
+        }
+        defer db.Close()
+
+        applied, err := db.RunMigrations()
+        if err != nil {
+                logger.LogError("Database migration failed: %s", err.Error())
+                return 1
+        }
+
+        logger.LogSuccess("Database migrations complete: %d applied", applied)
+        return 0
+}
+
+// createAdminCommand creates the admin account used for /api/admin/login and Basic Auth
+// Missing values are prompted for on stdin
+func createAdminCommand(args []string) int {
+        flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+        username := flags.String("username", "", "admin username")
+        email := flags.String("email", "", "admin email (optional)")
+        if err := flags.Parse(args); err != nil {
+                return 2
+        }
+
+        config, err := loadConfig()
+        if err != nil {
+                fmt.Printf("[ERROR] Failed to load configuration: %v\n", err)
+                return 1
+        }
+        logger := NewLogger(config.LogLevel, os.Stdout)
+        logger.SetSQLOptions(config.LogSQLText, config.SlowQueryThreshold)
+
+        reader := bufio.NewReader(os.Stdin)
+        if *username == "" {
+                *username = prompt(reader, "Username: ")
+        }
+        if *email == "" {
+                *email = prompt(reader, "Email (optional): ")
+        }
+        password := promptPassword(reader, "Password: ")
+        confirm := promptPassword(reader, "Confirm password: ")
+
+        if *username == "" {
+                logger.LogError("Username is required")
+                return 1
+        }
+        if len(*username) > 50 {
+                logger.LogError("Username must be at most 50 characters")
+                return 1
+        }
+        if password != confirm {
+                logger.LogError("Passwords do not match")
+                return 1
+        }
+        if err := ValidatePassword(password, config); err != nil {
+                logger.LogError("Invalid password: %s", err.Error())
+                return 1
+        }
+
+        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost)
+        if err != nil {
+                logger.LogError("Password hashing failed: %s", err.Error())
+                return 1
+        }
+
+        db, err := NewPostgresDB(config.DatabaseURL, config, logger)
+        if err != nil {
+                logger.LogError("Failed to connect to database: %s", err.Error())
+                return 1
+        }
+        defer db.Close()
+
+        admin, err := db.CreateAdmin(context.Background(), *username, *email, string(hashedPassword))
+        if errors.Is(err, ErrAdminExists) {
+                logger.LogError("Admin %q already exists", *username)
+                return 1
+        }
+        if err != nil {
+                logger.LogError("Failed to create admin: %s", err.Error())
+                return 1
+        }
+
+        logger.LogSuccess("Admin created: %s (id %s)", admin.Username, admin.ID)
+        return 0
+}
+
+// prompt prints label and reads one trimmed line from reader
+func prompt(reader *bufio.Reader, label string) string {
+        fmt.Fprint(os.Stderr, label)
+        line, err := reader.ReadString('\n')
+        if err != nil && err != io.EOF {
+                return ""
+        }
+        return strings.TrimSpace(line)
+}
+
+// promptPassword is prompt without echo when stdin is a terminal, so the password stays out of scrollback
+// Piped input (scripts, CI) is read as a plain line
+func promptPassword(reader *bufio.Reader, label string) string {
+        fd := int(os.Stdin.Fd())
+        if !term.IsTerminal(fd) {
+                return prompt(reader, label)
+        }
+        fmt.Fprint(os.Stderr, label)
+        password, err := term.ReadPassword(fd)
+        fmt.Fprintln(os.Stderr)
+        if err != nil {
+                return ""
+        }
+        return strings.TrimSpace(string(password))
+}
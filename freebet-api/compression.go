@@ -0,0 +1,174 @@
+package main
+
+import (
+        "compress/gzip"
+        "net/http"
+        "strconv"
+        "strings"
+        "sync"
+)
+
+// gzipWriterPool reuses gzip writers across responses
+var gzipWriterPool = sync.Pool{
+        New: func() interface{} {
+                return gzip.NewWriter(nil)
+        },
+}
+
+// Gzip compression middleware - compresses responses for clients sending Accept-Encoding: gzip
+// Bodies smaller than CompressionMinSize are sent uncompressed
+func compressionMiddleware(config *Config) func(http.Handler) http.Handler {
+        return func(next http.Handler) http.Handler {
+                return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        if !config.CompressionEnabled {
+                                next.ServeHTTP(w, r)
+                                return
+                        }
+
+                        w.Header().Add("Vary", "Accept-Encoding")
+                        if r.Method == http.MethodHead || !acceptsGzip(r) {
+                                next.ServeHTTP(w, r)
+                                return
+                        }
+
+                        gw := &gzipResponseWriter{
+                                ResponseWriter: w,
+                                minSize:        config.CompressionMinSize,
+                                statusCode:     http.StatusOK,
+                        }
+                        defer gw.Close()
+
+                        next.ServeHTTP(gw, r)
+                })
+        }
+}
+
+// acceptsGzip reports whether the client accepts gzip (and did not send q=0)
+func acceptsGzip(r *http.Request) bool {
+        for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+                name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+                if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+                        continue
+                }
+                if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+                        if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+                                return false
+                        }
+                }
+                return true
+        }
+        return false
+}
+
+// gzipResponseWriter buffers the first minSize bytes to decide whether to compress
+// Once decided, writes go straight to the gzip stream or the underlying writer
+type gzipResponseWriter struct {
+        http.ResponseWriter
+        minSize     int
+        statusCode  int
+        wroteHeader bool
+        decided     bool
+        buf         []byte
+        gz          *gzip.Writer
+}
+
+// WriteHeader records the status; it is sent once the encoding is decided
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+        if gw.wroteHeader {
+                return
+        }
+        gw.wroteHeader = true
+        gw.statusCode = code
+
+        // Bodyless responses are passed through untouched
+        if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+                gw.decided = true
+                gw.ResponseWriter.WriteHeader(code)
+        }
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+        if !gw.wroteHeader {
+                gw.WriteHeader(http.StatusOK)
+        }
+
+        if gw.decided {
+                if gw.gz != nil {
+                        return gw.gz.Write(b)
+                }
+                return gw.ResponseWriter.Write(b)
+        }
+
+        gw.buf = append(gw.buf, b...)
+        if len(gw.buf) >= gw.minSize {
+                if err := gw.decide(true); err != nil {
+                        return 0, err
+                }
+        }
+        return len(b), nil
+}
+
+// decide sends the headers and flushes the buffered bytes, compressed or not
+func (gw *gzipResponseWriter) decide(compress bool) error {
+        gw.decided = true
+
+        // Handler already encoded the body itself
+        if gw.Header().Get("Content-Encoding") != "" {
+                compress = false
+        }
+
+        if compress {
+                gw.Header().Set("Content-Encoding", "gzip")
+                gw.Header().Del("Content-Length")
+                gw.gz = gzipWriterPool.Get().(*gzip.Writer)
+                gw.gz.Reset(gw.ResponseWriter)
+        }
+
+        gw.ResponseWriter.WriteHeader(gw.statusCode)
+
+        buf := gw.buf
+        gw.buf = nil
+        if len(buf) == 0 {
+                return nil
+        }
+        if gw.gz != nil {
+                _, err := gw.gz.Write(buf)
+                return err
+        }
+        _, err := gw.ResponseWriter.Write(buf)
+        return err
+}
+
+// Flush sends buffered data immediately so streaming handlers keep working
+func (gw *gzipResponseWriter) Flush() {
+        if !gw.decided {
+                gw.decide(len(gw.buf) >= gw.minSize)
+        }
+        if gw.gz != nil {
+                gw.gz.Flush()
+        }
+        if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+                flusher.Flush()
+        }
+}
+
+// Close finishes the response: small bodies go out uncompressed, the gzip stream is terminated
+func (gw *gzipResponseWriter) Close() {
+        if !gw.decided {
+                if !gw.wroteHeader && len(gw.buf) == 0 {
+                        // Handler wrote nothing; net/http sends the default 200
+                        return
+                }
+                gw.decide(false)
+        }
+        if gw.gz != nil {
+                gw.gz.Close()
+                gzipWriterPool.Put(gw.gz)
+                gw.gz = nil
+        }
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+        return gw.ResponseWriter
+}
@@ -2,7 +2,12 @@ package main
 
 import (
         "fmt"
+        "maps"
+        "math"
+        "net"
+        "net/http"
         "os"
+        "slices"
         "strconv"
         "strings"
         "time"
@@ -17,28 +22,99 @@ type Config struct {
         Env     string `json:"env"`
         LogLevel string `json:"log_level"`
 
+        // Log output (stdout unless LogFile is set)
+        LogFile        string `json:"log_file"`
+        LogMaxSizeMB   int    `json:"log_max_size_mb"`
+        LogMaxAgeDays  int    `json:"log_max_age_days"`
+        LogMaxBackups  int    `json:"log_max_backups"`
+        LogCompress    bool   `json:"log_compress"`
+
+        // SQL logging
+        LogSQLText         bool          `json:"log_sql_text"`
+        SlowQueryThreshold time.Duration `json:"slow_query_threshold"`
+
         // Database configuration
-        DatabaseURL string `json:"database_url"`
+        DatabaseURL   string `json:"database_url"`
+        RunMigrations bool   `json:"run_migrations"` // Apply embedded migrations on startup
 
         // Authentication configuration
         BcryptCost           int           `json:"bcrypt_cost"`
         JWTSecret            string        `json:"jwt_secret"`
+        JWTSecretMinLength   int           `json:"jwt_secret_min_length"`
         JWTAccessTokenTTL    time.Duration `json:"jwt_access_token_ttl"`
         JWTRefreshTokenTTL   time.Duration `json:"jwt_refresh_token_ttl"`
+        JWTSessionBinding    bool          `json:"jwt_session_binding"` // Reject access tokens after logout-all/password change
         CookieName           string        `json:"cookie_name"`         // For refresh tokens
         CookieSecure         bool          `json:"cookie_secure"`
         CookieHTTPOnly       bool          `json:"cookie_http_only"`
         CookieSameSite       string        `json:"cookie_same_site"`
+        AdminTokenTTL        time.Duration `json:"admin_token_ttl"`
 
         // Game/Business logic constants
         InitialBalance     float64 `json:"initial_balance"`
         TopupAmount        float64 `json:"topup_amount"`
         MaxTopupBalance    float64 `json:"max_topup_balance"`
+        TopupCooldown      time.Duration `json:"topup_cooldown"`
         MinPasswordLength  int     `json:"min_password_length"`
 
+        // Password strength policy
+        PasswordRequireDigit  bool `json:"password_require_digit"`
+        PasswordRequireUpper  bool `json:"password_require_upper"`
+        PasswordRequireLower  bool `json:"password_require_lower"`
+        PasswordRequireSymbol bool `json:"password_require_symbol"`
+        PasswordRejectCommon  bool `json:"password_reject_common"`
+
+        // Registration email domain blocklist (in addition to the embedded disposable list)
+        BlockedEmailDomains []string `json:"blocked_email_domains"`
+
+        // Minimum time between nickname changes
+        NicknameChangeCooldown time.Duration `json:"nickname_change_cooldown"`
+
+        // Guest play (POST /api/auth/guest)
+        GuestPlayEnabled     bool          `json:"guest_play_enabled"`
+        GuestInactivityTTL   time.Duration `json:"guest_inactivity_ttl"`   // Inactive guests are deleted after this; 0 = never
+        GuestCleanupInterval time.Duration `json:"guest_cleanup_interval"` // How often the scheduler looks for them
+
+        // Profile picture uploads (PUT /api/account/picture)
+        PictureStorage     string `json:"picture_storage"` // "local" or "s3"
+        PictureMaxBytes    int    `json:"picture_max_bytes"`
+        PicturePublicURL   string `json:"picture_public_url"` // Base URL of saved pictures; backend default when empty
+        PictureLocalDir    string `json:"picture_local_dir"`
+        PictureS3Endpoint  string `json:"picture_s3_endpoint"`
+        PictureS3Region    string `json:"picture_s3_region"`
+        PictureS3Bucket    string `json:"picture_s3_bucket"`
+        PictureS3AccessKey string `json:"-"`
+        PictureS3SecretKey string `json:"-"`
+
         // Betting limits
-        MinBetAmount      float64 `json:"min_bet_amount"`
-        MaxBetAmount      float64 `json:"max_bet_amount"`
+        MinBetAmount          float64       `json:"min_bet_amount"`
+        MaxBetAmount          float64       `json:"max_bet_amount"`
+        BetCutoffBuffer       time.Duration `json:"bet_cutoff_buffer"`
+        MaxOddsAge            time.Duration `json:"max_odds_age"`
+        MinOdds               float64       `json:"min_odds"`          // Synced prices outside MinOdds..MaxOdds are dropped
+        MaxOdds               float64       `json:"max_odds"`
+        MaxPotentialWin       float64       `json:"max_potential_win"` // Largest payout a single bet may have; 0 = unlimited
+        BetReviewThreshold    float64       `json:"bet_review_threshold"` // Stakes above this wait for admin approval; 0 = disabled
+        MaxPendingBetsPerUser int           `json:"max_pending_bets_per_user"`
+        MaxBatchBets          int           `json:"max_batch_bets"`    // Bets per POST /api/bets/batch
+        BetBatchPartial       bool          `json:"bet_batch_partial"` // Place the valid bets of a batch instead of rejecting it
+
+        // Leaderboard seasons
+        SeasonMonths int       `json:"season_months"` // Calendar months per season, a divisor of 12
+        SeasonPrizes []float64 `json:"season_prizes"` // Credited to ranks 1..N when a season is closed
+
+        // Matches response cache (ETag computed once per cache entry)
+        MatchesCacheTTL time.Duration `json:"matches_cache_ttl"`
+
+        // Platform stats cache (GET /api/stats runs aggregate queries over all bets)
+        StatsCacheTTL time.Duration `json:"stats_cache_ttl"`
+
+        // User lookup cache (the auth middleware loads the user on every request)
+        UserCacheTTL time.Duration `json:"user_cache_ttl"`
+
+        // Response compression
+        CompressionEnabled bool `json:"compression_enabled"`
+        CompressionMinSize int  `json:"compression_min_size"` // Bytes; smaller bodies are sent uncompressed
 
         // CORS configuration
         CORSAllowedOrigins []string `json:"cors_allowed_origins"`
@@ -53,30 +129,146 @@ type Config struct {
         WriteTimeout      int `json:"write_timeout"`
         IdleTimeout       int `json:"idle_timeout"`
 
+        // Serve the detailed (admin) health response on the public /api/health
+        HealthDetailedPublic bool `json:"health_detailed_public"`
+
+        // Maintenance mode: 503 for users, admin and health routes still served
+        MaintenanceMode       bool          `json:"maintenance_mode"` // Initial state; toggled at runtime via /api/admin/maintenance
+        MaintenanceRetryAfter time.Duration `json:"maintenance_retry_after"`
+
+        // Language of user-facing messages when Accept-Language names no supported locale
+        DefaultLocale string `json:"default_locale"`
+
+        // Largest accepted request body; picture uploads use PictureMaxBytes instead
+        MaxRequestBodyBytes int64 `json:"max_request_body_bytes"`
+
         // Rate limiting
         RateLimitRequests int `json:"rate_limit_requests"`
         RateLimitWindow   int `json:"rate_limit_window"`
 
+        // Reverse proxies (CIDRs or IPs) whose forwarded client IP headers are trusted
+        TrustedProxies []string `json:"trusted_proxies"`
+
         // Database connection pool
         DBMaxConns        int `json:"db_max_conns"`
         DBMinConns        int `json:"db_min_conns"`
         DBMaxLifetime     int `json:"db_max_lifetime"`
         DBMaxIdleTime     int `json:"db_max_idle_time"`
 
+        // Read query retries on transient errors
+        DBRetryAttempts int           `json:"db_retry_attempts"`
+        DBRetryBackoff  time.Duration `json:"db_retry_backoff"`
+
         // HSTS configuration
         HSTSMaxAge        int `json:"hsts_max_age"`
 
         // Odds API configuration
-        OddsAPIKey        string `json:"odds_api_key"`
+        OddsAPIKey              string        `json:"odds_api_key"`
+        OddsAPISport            string        `json:"odds_api_sport"` // Sport key synced, e.g. soccer_epl; two-way sports have no draw
+        OddsAPIQuotaWarning     int           `json:"odds_api_quota_warning"`
+        OddsAPIRateLimitBackoff time.Duration `json:"odds_api_rate_limit_backoff"`
+        OddsAPITimeout          time.Duration `json:"odds_api_timeout"`
+        OddsAPIMarkets          []string      `json:"odds_api_markets"` // h2h plus optional btts, correct_score, totals
+
+        // House margin shaved off bookmaker odds at sync time; stored odds are what users see and are paid at
+        HouseMarginPercent float64 `json:"house_margin_percent"`
+
+        // Team name aliases ("Alias=Canonical Name"), on top of the built-in list
+        TeamAliases []string `json:"team_aliases"`
+
+        // Background scheduler (replaces external cron POSTs)
+        EnableOddsSyncCron   bool          `json:"enable_odds_sync_cron"`
+        OddsSyncInterval     time.Duration `json:"odds_sync_interval"`
+        EnableScoresSyncCron bool          `json:"enable_scores_sync_cron"`
+        ScoresSyncInterval   time.Duration `json:"scores_sync_interval"`
+        EnableCalcCron       bool          `json:"enable_calc_cron"`
+        CalcInterval         time.Duration `json:"calc_interval"`
+
+        // Bet settlement
+        CalcBatchSize       int           `json:"calc_batch_size"`
+        CalcVoidGracePeriod time.Duration `json:"calc_void_grace_period"`
+        ShootoutDecidesH2H  bool          `json:"shootout_decides_h2h"` // Penalty shootout winner wins 1X2 instead of a draw
 
         // Google OAuth configuration
-        GoogleClientID     string `json:"google_client_id"`
-        GoogleClientSecret string `json:"google_client_secret"`
-        GoogleRedirectURL  string `json:"google_redirect_url"`
+        GoogleClientID     string        `json:"google_client_id"`
+        GoogleClientSecret string        `json:"google_client_secret"`
+        GoogleRedirectURL  string        `json:"google_redirect_url"`
+        GoogleOAuthTimeout time.Duration `json:"google_oauth_timeout"`
+        OAuthAllowedRedirectHosts []string `json:"oauth_allowed_redirect_hosts"` // Hosts (and their subdomains) a login may redirect back to
 
         // Telegram configuration
-        TelegramBotToken  string `json:"telegram_bot_token"`
-        TelegramChannelID string `json:"telegram_channel_id"`
+        TelegramBotToken  string        `json:"telegram_bot_token"`
+        TelegramChannelID string        `json:"telegram_channel_id"`
+        TelegramTimeout   time.Duration `json:"telegram_timeout"`
+
+        // Discord configuration
+        DiscordWebhookURL string        `json:"discord_webhook_url"` // Channel webhook for settlement summaries
+        DiscordTimeout    time.Duration `json:"discord_timeout"`
+
+        // Hooks run on every new account (signup_hooks.go)
+        UserCreatedHooks          []string      `json:"user_created_hooks"` // log, webhook
+        UserCreatedWebhookURL     string        `json:"-"`                  // May embed a token
+        UserCreatedWebhookTimeout time.Duration `json:"user_created_webhook_timeout"`
+
+        // Clock used for time-based logic (replaced by a FakeClock in tests)
+        Clock Clock `json:"-"`
+}
+
+// sportHasDraw reports whether the synced sport settles 1X2 with a draw
+func (c *Config) sportHasDraw() bool {
+        return sportHasDraw(c.OddsAPISport)
+}
+
+// marketEnabled reports whether ODDS_API_MARKETS syncs, and so takes bets on, a market
+func (c *Config) marketEnabled(market string) bool {
+        return slices.Contains(c.OddsAPIMarkets, market)
+}
+
+// now returns the current time from the configured clock
+func (c *Config) now() time.Time {
+        if c.Clock == nil {
+                return time.Now()
+        }
+        return c.Clock.Now()
+}
+
+// trustedProxyCIDRs parses TrustedProxies; invalid entries are skipped (Validate reports them)
+func (c *Config) trustedProxyCIDRs() []CIDR {
+        var cidrs []CIDR
+        for _, proxy := range c.TrustedProxies {
+                if cidr, err := ParseCIDR(proxy); err == nil {
+                        cidrs = append(cidrs, cidr)
+                }
+        }
+        return cidrs
+}
+
+// cookieSameSite maps the CookieSameSite setting to its http.SameSite value
+func (c *Config) cookieSameSite() http.SameSite {
+        switch strings.ToLower(c.CookieSameSite) {
+        case "strict":
+                return http.SameSiteStrictMode
+        case "none":
+                return http.SameSiteNoneMode
+        default:
+                return http.SameSiteLaxMode
+        }
+}
+
+// defaultJWTSecret is the development placeholder; it is rejected in production
+const defaultJWTSecret = "your-super-secret-jwt-key-change-in-production"
+
+// validateJWTSecret checks that JWT_SECRET is set, not the placeholder and long enough
+func validateJWTSecret(config *Config) error {
+        switch {
+        case config.JWTSecret == "":
+                return fmt.Errorf("JWT_SECRET environment variable is required")
+        case config.JWTSecret == defaultJWTSecret:
+                return fmt.Errorf("JWT_SECRET must be changed from the default value")
+        case len(config.JWTSecret) < config.JWTSecretMinLength:
+                return fmt.Errorf("JWT_SECRET must be at least %d characters (got %d)", config.JWTSecretMinLength, len(config.JWTSecret))
+        }
+        return nil
 }
 
 // loadConfig loads configuration from environment variables with defaults
@@ -90,28 +282,99 @@ func loadConfig() (*Config, error) {
                 Env:       getEnvString("NODE_ENV", "development"),
                 LogLevel:  getEnvString("LOG_LEVEL", "INFO"),
 
+                // Log file with rotation (empty = stdout)
+                LogFile:       getEnvString("LOG_FILE", ""),
+                LogMaxSizeMB:  getEnvInt("LOG_MAX_SIZE_MB", 100), // Rotate when the file reaches this size
+                LogMaxAgeDays: getEnvInt("LOG_MAX_AGE_DAYS", 28), // 0 = keep rotated files regardless of age
+                LogMaxBackups: getEnvInt("LOG_MAX_BACKUPS", 7),   // 0 = keep all rotated files
+                LogCompress:   getEnvBool("LOG_COMPRESS", true),  // Gzip rotated files
+
+                // SQL logging
+                LogSQLText:         getEnvBool("LOG_SQL_TEXT", false),                               // Full query text at DEBUG level
+                SlowQueryThreshold: getEnvDuration("SLOW_QUERY_THRESHOLD", 500*time.Millisecond), // Warn at any level; 0 disables
+
                 // Database (required) - prefer EXTERNAL_DATABASE_URL if set
-                DatabaseURL: getEnvStringWithFallback("EXTERNAL_DATABASE_URL", "DATABASE_URL", ""),
+                DatabaseURL:   getEnvStringWithFallback("EXTERNAL_DATABASE_URL", "DATABASE_URL", ""),
+                RunMigrations: getEnvBool("RUN_MIGRATIONS", false), // Otherwise run "freebet-api migrate" manually
 
                 // Authentication defaults (from environment)
                 BcryptCost:           getEnvInt("BCRYPT_COST", 12), // bcrypt.DefaultCost is 10, we use 12 for better security
-                JWTSecret:            getEnvString("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"), // Must be set in production
+                JWTSecret:            getEnvString("JWT_SECRET", defaultJWTSecret), // Must be set in production
+                JWTSecretMinLength:   getEnvInt("JWT_SECRET_MIN_LENGTH", 32), // Enforced in production
                 JWTAccessTokenTTL:    getEnvDuration("JWT_ACCESS_TOKEN_TTL", 15*time.Minute), // 15 minutes
                 JWTRefreshTokenTTL:   getEnvDuration("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour), // 7 days
+                JWTSessionBinding:    getEnvBool("JWT_SESSION_BINDING", false), // Checks token version on every request
                 CookieName:           getEnvString("COOKIE_NAME", "refresh_token"), // Changed from session_token
                 CookieSecure:         getEnvBool("COOKIE_SECURE", false), // true in production
                 CookieHTTPOnly:       getEnvBool("COOKIE_HTTP_ONLY", true), // Always true for security
                 CookieSameSite:       getEnvString("COOKIE_SAME_SITE", "strict"), // CSRF protection: "strict", "lax", "none"
+                AdminTokenTTL:        getEnvDuration("ADMIN_TOKEN_TTL", 1*time.Hour), // Admin session token lifetime
 
                 // Game/Business logic constants (from environment)
                 InitialBalance:     getEnvFloat64("INITIAL_BALANCE", 10000.0), // $10,000 starting balance
                 TopupAmount:        getEnvFloat64("TOPUP_AMOUNT", 10000.0), // $10,000 topup amount
                 MaxTopupBalance:   getEnvFloat64("MAX_TOPUP_BALANCE", 500.0), // Can only topup if balance < $500
+                TopupCooldown:      getEnvDuration("TOPUP_COOLDOWN", 24*time.Hour), // Time between top-ups
                 MinPasswordLength:  getEnvInt("MIN_PASSWORD_LENGTH", 6), // Minimum password length
 
+                // Password strength policy (from environment)
+                PasswordRequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+                PasswordRequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+                PasswordRequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+                PasswordRequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+                PasswordRejectCommon:  getEnvBool("PASSWORD_REJECT_COMMON", true), // Reject passwords from the embedded common list
+
+                // Registration email domain blocklist (from environment, comma-separated)
+                BlockedEmailDomains: getEnvStringList("BLOCKED_EMAIL_DOMAINS", nil),
+
+                // Nickname changes (from environment)
+                NicknameChangeCooldown: getEnvDuration("NICKNAME_CHANGE_COOLDOWN", 30*24*time.Hour), // Once per 30 days; 0 = no cooldown
+
+                // Guest play (from environment)
+                GuestPlayEnabled:     getEnvBool("GUEST_PLAY_ENABLED", true),
+                GuestInactivityTTL:   getEnvDuration("GUEST_INACTIVITY_TTL", 30*24*time.Hour),
+                GuestCleanupInterval: getEnvDuration("GUEST_CLEANUP_INTERVAL", time.Hour),
+
+                // Profile picture uploads (from environment)
+                PictureStorage:     strings.ToLower(getEnvString("PICTURE_STORAGE", "local")),
+                PictureMaxBytes:    getEnvInt("PICTURE_MAX_BYTES", 2*1024*1024), // 2 MB
+                PicturePublicURL:   getEnvString("PICTURE_PUBLIC_URL", ""),
+                PictureLocalDir:    getEnvString("PICTURE_LOCAL_DIR", "./uploads/pictures"),
+                PictureS3Endpoint:  getEnvString("PICTURE_S3_ENDPOINT", ""),
+                PictureS3Region:    getEnvString("PICTURE_S3_REGION", "us-east-1"),
+                PictureS3Bucket:    getEnvString("PICTURE_S3_BUCKET", ""),
+                PictureS3AccessKey: getEnvString("PICTURE_S3_ACCESS_KEY", ""),
+                PictureS3SecretKey: getEnvString("PICTURE_S3_SECRET_KEY", ""),
+
                 // Betting limits (from environment)
                 MinBetAmount:       getEnvFloat64("MIN_BET_AMOUNT", 1.0), // Minimum bet amount
                 MaxBetAmount:       getEnvFloat64("MAX_BET_AMOUNT", 100000.0), // Maximum bet amount
+                BetCutoffBuffer:    getEnvDuration("BET_CUTOFF_BUFFER", 60*time.Second), // Betting closes this long before kickoff
+                MaxOddsAge:         getEnvDuration("MAX_ODDS_AGE", 0), // Refuse bets on odds not synced within this long; 0 = disabled
+                MinOdds:            getEnvFloat64("MIN_ODDS", 1.01),
+                MaxOdds:            getEnvFloat64("MAX_ODDS", 1000),
+                MaxPotentialWin:    getEnvFloat64("MAX_POTENTIAL_WIN", 1000000),
+                BetReviewThreshold: getEnvFloat64("BET_REVIEW_THRESHOLD", 0),
+                MaxPendingBetsPerUser: getEnvInt("MAX_PENDING_BETS_PER_USER", 50), // Unsettled bets allowed at once; 0 = unlimited
+                MaxBatchBets:          getEnvInt("MAX_BATCH_BETS", 20),
+                BetBatchPartial:       getEnvBool("BET_BATCH_PARTIAL", false), // Default: all-or-nothing
+
+                // Leaderboard seasons (from environment)
+                SeasonMonths: getEnvInt("SEASON_MONTHS", 1),
+                SeasonPrizes: getEnvFloat64List("SEASON_PRIZES", nil), // e.g. 1000,500,250; empty = no prizes
+
+                // Matches response cache (from environment)
+                MatchesCacheTTL:    getEnvDuration("MATCHES_CACHE_TTL", 30*time.Second), // 0 disables caching (ETag still sent)
+
+                // Platform stats cache (from environment)
+                StatsCacheTTL:      getEnvDuration("STATS_CACHE_TTL", 5*time.Minute), // 0 recomputes on every request
+
+                // User lookup cache (from environment)
+                UserCacheTTL:       getEnvDuration("USER_CACHE_TTL", 10*time.Second), // 0 disables caching
+
+                // Response compression (from environment)
+                CompressionEnabled: getEnvBool("COMPRESSION_ENABLED", true),
+                CompressionMinSize: getEnvInt("COMPRESSION_MIN_SIZE", 1024), // Skip gzip for bodies under 1KB
 
                 // CORS configuration from environment
                 CORSAllowedOrigins: getEnvCORSOrigins("CORS_ALLOWED_ORIGINS",
@@ -136,35 +399,80 @@ func loadConfig() (*Config, error) {
                 WriteTimeout:       getEnvInt("WRITE_TIMEOUT", 15),
                 IdleTimeout:        getEnvInt("IDLE_TIMEOUT", 60),
 
+                HealthDetailedPublic: getEnvBool("HEALTH_DETAILED_PUBLIC", false), // Legacy /api/health with counts
+
+                MaintenanceMode:       getEnvBool("MAINTENANCE_MODE", false),
+                MaintenanceRetryAfter: getEnvDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute), // Retry-After on maintenance 503s
+
+                DefaultLocale: strings.ToLower(getEnvString("DEFAULT_LOCALE", localeEnglish)),
+
+                MaxRequestBodyBytes: int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 1024*1024)), // 1 MB
+
                 // Rate limiting (from environment)
                 RateLimitRequests:  getEnvInt("RATE_LIMIT_REQUESTS", 100), // Requests per window
                 RateLimitWindow:    getEnvInt("RATE_LIMIT_WINDOW", 60),    // Window in seconds
 
+                // Forwarded headers are ignored unless the peer is a trusted proxy
+                TrustedProxies: getEnvStringList("TRUSTED_PROXIES", nil),
+
                 // Database connection pool (from environment)
                 DBMaxConns:         getEnvInt("DB_MAX_CONNS", 10),
                 DBMinConns:         getEnvInt("DB_MIN_CONNS", 1),
                 DBMaxLifetime:      getEnvInt("DB_MAX_LIFETIME", 3600),     // 1 hour in seconds
                 DBMaxIdleTime:      getEnvInt("DB_MAX_IDLE_TIME", 1800),    // 30 minutes in seconds
 
+                // Read query retries (writes and transactions are never retried)
+                DBRetryAttempts: getEnvInt("DB_RETRY_ATTEMPTS", 2),                          // Retries after the first attempt, 0 disables
+                DBRetryBackoff:  getEnvDuration("DB_RETRY_BACKOFF", 100*time.Millisecond), // Doubled after each retry
+
                 // HSTS configuration (from environment)
                 HSTSMaxAge:         getEnvInt("HSTS_MAX_AGE", 31536000), // 1 year in seconds
 
                 // Odds API configuration (from environment)
-                OddsAPIKey:         getEnvString("ODDS_API_KEY", ""),
+                OddsAPIKey:              getEnvString("ODDS_API_KEY", ""),
+                OddsAPISport:            getEnvString("ODDS_API_SPORT", "soccer_epl"),
+                OddsAPIQuotaWarning:     getEnvInt("ODDS_API_QUOTA_WARNING", 50),                       // Warn below this many remaining requests; 0 disables
+                OddsAPIRateLimitBackoff: getEnvDuration("ODDS_API_RATE_LIMIT_BACKOFF", 15*time.Minute), // Pause after a 429 without Retry-After
+                OddsAPITimeout:          getEnvDuration("ODDS_API_TIMEOUT", 10*time.Second),
+                OddsAPIMarkets:          getEnvStringList("ODDS_API_MARKETS", []string{MarketH2H, MarketBTTS, MarketCorrectScore}),
+                HouseMarginPercent:      getEnvFloat64("HOUSE_MARGIN_PERCENT", 0), // 0 = raw bookmaker odds
+                TeamAliases:             getEnvStringList("TEAM_ALIASES", nil),
+
+                // Background scheduler (from environment, disabled by default)
+                EnableOddsSyncCron:   getEnvBool("ENABLE_ODDS_SYNC_CRON", false),
+                OddsSyncInterval:     getEnvDuration("ODDS_SYNC_INTERVAL", 6*time.Hour),
+                EnableScoresSyncCron: getEnvBool("ENABLE_SCORES_SYNC_CRON", false),
+                ScoresSyncInterval:   getEnvDuration("SCORES_SYNC_INTERVAL", 1*time.Hour),
+                EnableCalcCron:       getEnvBool("ENABLE_CALC_CRON", false),
+                CalcInterval:         getEnvDuration("CALC_INTERVAL", 1*time.Hour),
+
+                // Bet settlement
+                CalcBatchSize:       getEnvInt("CALC_BATCH_SIZE", 100),          // Matches loaded per query
+                CalcVoidGracePeriod: getEnvDuration("CALC_VOID_GRACE_PERIOD", 0), // Void scoreless completed matches after kickoff + period; 0 = never
+                ShootoutDecidesH2H:  getEnvBool("SHOOTOUT_DECIDES_H2H", false),
 
                 // Google OAuth configuration (from environment)
                 GoogleClientID:     getEnvString("GOOGLE_CLIENT_ID", ""),
                 GoogleClientSecret: getEnvString("GOOGLE_CLIENT_SECRET", ""),
                 GoogleRedirectURL:  getEnvString("GOOGLE_REDIRECT_URL", "http://localhost:3001/api/auth/google/callback"),
+                GoogleOAuthTimeout: getEnvDuration("GOOGLE_OAUTH_TIMEOUT", 10*time.Second),
+                OAuthAllowedRedirectHosts: getEnvStringList("OAUTH_ALLOWED_REDIRECT_HOSTS", []string{"localhost", "127.0.0.1", "freebet.guru"}),
 
                 // Telegram configuration (from environment)
                 TelegramBotToken:   getEnvString("TELEGRAM_BOT_TOKEN", ""),
                 TelegramChannelID:  getEnvString("TELEGRAM_CHANNEL_ID", ""),
-        }
+                TelegramTimeout:    getEnvDuration("TELEGRAM_TIMEOUT", 10*time.Second),
 
-        // Validate required configuration
-        if config.DatabaseURL == "" {
-                return nil, fmt.Errorf("DATABASE_URL environment variable is required")
+                // Discord configuration (from environment)
+                DiscordWebhookURL: getEnvString("DISCORD_WEBHOOK_URL", ""),
+                DiscordTimeout:    getEnvDuration("DISCORD_TIMEOUT", 10*time.Second),
+
+                // Signup hooks (from environment)
+                UserCreatedHooks:          getEnvStringList("USER_CREATED_HOOKS", []string{userCreatedHookLog}),
+                UserCreatedWebhookURL:     getEnvString("USER_CREATED_WEBHOOK_URL", ""),
+                UserCreatedWebhookTimeout: getEnvDuration("USER_CREATED_WEBHOOK_TIMEOUT", 10*time.Second),
+
+                Clock: RealClock{},
         }
 
         // Environment-specific overrides
@@ -172,9 +480,327 @@ func loadConfig() (*Config, error) {
                 config.CookieSecure = true // HTTPS only in production
         }
 
+        // Validate ranges and relationships
+        if err := config.Validate(); err != nil {
+                return nil, err
+        }
+
         return config, nil
 }
 
+// Validate checks required settings, sane ranges and relationships between settings
+// Returns a single error listing every problem found
+func (c *Config) Validate() error {
+        var problems []string
+        addProblem := func(format string, args ...interface{}) {
+                problems = append(problems, fmt.Sprintf(format, args...))
+        }
+
+        // Server
+        if c.Port < 1 || c.Port > 65535 {
+                addProblem("API_PORT must be between 1 and 65535 (got %d)", c.Port)
+        }
+        if c.DatabaseURL == "" {
+                addProblem("DATABASE_URL environment variable is required")
+        }
+        if c.SlowQueryThreshold < 0 {
+                addProblem("SLOW_QUERY_THRESHOLD must not be negative (got %v)", c.SlowQueryThreshold)
+        }
+        if c.LogFile != "" {
+                if c.LogMaxSizeMB <= 0 {
+                        addProblem("LOG_MAX_SIZE_MB must be positive (got %d)", c.LogMaxSizeMB)
+                }
+                if c.LogMaxAgeDays < 0 {
+                        addProblem("LOG_MAX_AGE_DAYS must not be negative (got %d)", c.LogMaxAgeDays)
+                }
+                if c.LogMaxBackups < 0 {
+                        addProblem("LOG_MAX_BACKUPS must not be negative (got %d)", c.LogMaxBackups)
+                }
+        }
+
+        // Authentication
+        if c.BcryptCost < 10 || c.BcryptCost > 14 {
+                addProblem("BCRYPT_COST must be between 10 and 14 (got %d)", c.BcryptCost)
+        }
+        if c.Env == "production" {
+                // Refuse to start with a forgeable JWT secret
+                if err := validateJWTSecret(c); err != nil {
+                        addProblem("%s", err.Error())
+                }
+        }
+        if c.JWTAccessTokenTTL <= 0 {
+                addProblem("JWT_ACCESS_TOKEN_TTL must be positive (got %v)", c.JWTAccessTokenTTL)
+        }
+        if c.JWTRefreshTokenTTL <= 0 {
+                addProblem("JWT_REFRESH_TOKEN_TTL must be positive (got %v)", c.JWTRefreshTokenTTL)
+        } else if c.JWTRefreshTokenTTL < c.JWTAccessTokenTTL {
+                addProblem("JWT_REFRESH_TOKEN_TTL (%v) must not be shorter than JWT_ACCESS_TOKEN_TTL (%v)", c.JWTRefreshTokenTTL, c.JWTAccessTokenTTL)
+        }
+        if c.AdminTokenTTL <= 0 {
+                addProblem("ADMIN_TOKEN_TTL must be positive (got %v)", c.AdminTokenTTL)
+        }
+        if c.CookieName == "" {
+                addProblem("COOKIE_NAME must not be empty")
+        }
+        switch strings.ToLower(c.CookieSameSite) {
+        case "strict", "lax":
+        case "none":
+                // Browsers drop SameSite=None cookies without the Secure attribute
+                if !c.CookieSecure {
+                        addProblem("COOKIE_SAME_SITE=none requires COOKIE_SECURE=true")
+                }
+        default:
+                addProblem("COOKIE_SAME_SITE must be one of strict, lax, none (got %q)", c.CookieSameSite)
+        }
+
+        // A credentialed wildcard would let any site make authenticated requests
+        if c.CORSCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+                addProblem("CORS_ALLOWED_ORIGINS=* cannot be combined with CORS_CREDENTIALS=true; list the allowed origins")
+        }
+
+        // Game/Business logic
+        if c.InitialBalance < 0 {
+                addProblem("INITIAL_BALANCE must not be negative (got %.2f)", c.InitialBalance)
+        }
+        if c.TopupAmount <= 0 {
+                addProblem("TOPUP_AMOUNT must be positive (got %.2f)", c.TopupAmount)
+        }
+        if c.TopupCooldown <= 0 {
+                addProblem("TOPUP_COOLDOWN must be positive (got %v)", c.TopupCooldown)
+        }
+        if c.MinPasswordLength < 1 {
+                addProblem("MIN_PASSWORD_LENGTH must be at least 1 (got %d)", c.MinPasswordLength)
+        }
+        if c.NicknameChangeCooldown < 0 {
+                addProblem("NICKNAME_CHANGE_COOLDOWN must not be negative (got %v)", c.NicknameChangeCooldown)
+        }
+        if c.GuestInactivityTTL < 0 {
+                addProblem("GUEST_INACTIVITY_TTL must not be negative (got %v)", c.GuestInactivityTTL)
+        }
+        if c.GuestInactivityTTL > 0 && c.GuestCleanupInterval <= 0 {
+                addProblem("GUEST_CLEANUP_INTERVAL must be positive (got %v)", c.GuestCleanupInterval)
+        }
+
+        // Profile picture uploads
+        if c.PictureMaxBytes <= 0 {
+                addProblem("PICTURE_MAX_BYTES must be positive (got %d)", c.PictureMaxBytes)
+        }
+        switch c.PictureStorage {
+        case "local":
+                if c.PictureLocalDir == "" {
+                        addProblem("PICTURE_LOCAL_DIR is required when PICTURE_STORAGE=local")
+                }
+        case "s3":
+                if c.PictureS3Endpoint == "" || c.PictureS3Bucket == "" || c.PictureS3AccessKey == "" || c.PictureS3SecretKey == "" {
+                        addProblem("PICTURE_S3_ENDPOINT, PICTURE_S3_BUCKET, PICTURE_S3_ACCESS_KEY and PICTURE_S3_SECRET_KEY are required when PICTURE_STORAGE=s3")
+                }
+        default:
+                addProblem("PICTURE_STORAGE must be \"local\" or \"s3\" (got %q)", c.PictureStorage)
+        }
+
+        // Betting limits
+        if c.MinBetAmount <= 0 {
+                addProblem("MIN_BET_AMOUNT must be positive (got %.2f)", c.MinBetAmount)
+        }
+        if c.MaxBetAmount < c.MinBetAmount {
+                addProblem("MAX_BET_AMOUNT (%.2f) must not be less than MIN_BET_AMOUNT (%.2f)", c.MaxBetAmount, c.MinBetAmount)
+        }
+        if c.MaintenanceRetryAfter < time.Second {
+                addProblem("MAINTENANCE_RETRY_AFTER must be at least 1s (got %v)", c.MaintenanceRetryAfter)
+        }
+        if c.BetCutoffBuffer < 0 {
+                addProblem("BET_CUTOFF_BUFFER must not be negative (got %v)", c.BetCutoffBuffer)
+        }
+        if c.MaxOddsAge < 0 {
+                addProblem("MAX_ODDS_AGE must not be negative (got %v)", c.MaxOddsAge)
+        }
+        if c.MinOdds <= 1 {
+                addProblem("MIN_ODDS must be greater than 1 (got %.2f)", c.MinOdds)
+        }
+        if c.MaxOdds <= c.MinOdds {
+                addProblem("MAX_ODDS (%.2f) must be greater than MIN_ODDS (%.2f)", c.MaxOdds, c.MinOdds)
+        }
+        if c.MaxPotentialWin < 0 {
+                addProblem("MAX_POTENTIAL_WIN must not be negative (got %.2f)", c.MaxPotentialWin)
+        }
+        if c.BetReviewThreshold < 0 {
+                addProblem("BET_REVIEW_THRESHOLD must not be negative (got %.2f)", c.BetReviewThreshold)
+        }
+        if c.MaxPendingBetsPerUser < 0 {
+                addProblem("MAX_PENDING_BETS_PER_USER must not be negative (got %d)", c.MaxPendingBetsPerUser)
+        }
+        if c.MaxBatchBets < 1 {
+                addProblem("MAX_BATCH_BETS must be at least 1 (got %d)", c.MaxBatchBets)
+        }
+        if c.SeasonMonths < 1 || 12%c.SeasonMonths != 0 {
+                addProblem("SEASON_MONTHS must be one of 1, 2, 3, 4, 6, 12 (got %d)", c.SeasonMonths)
+        }
+        for i, prize := range c.SeasonPrizes {
+                if math.IsNaN(prize) || prize < 0 {
+                        addProblem("SEASON_PRIZES entry %d must be a non-negative number", i+1)
+                }
+        }
+        if c.MatchesCacheTTL < 0 {
+                addProblem("MATCHES_CACHE_TTL must not be negative (got %v)", c.MatchesCacheTTL)
+        }
+        if c.StatsCacheTTL < 0 {
+                addProblem("STATS_CACHE_TTL must not be negative (got %v)", c.StatsCacheTTL)
+        }
+        if c.UserCacheTTL < 0 {
+                addProblem("USER_CACHE_TTL must not be negative (got %v)", c.UserCacheTTL)
+        }
+
+        // Response compression
+        if c.CompressionMinSize < 0 {
+                addProblem("COMPRESSION_MIN_SIZE must not be negative (got %d)", c.CompressionMinSize)
+        }
+
+        // Pagination
+        if c.DefaultPlayerLimit < 1 {
+                addProblem("PAGINATION_DEFAULT_LIMIT must be positive (got %d)", c.DefaultPlayerLimit)
+        }
+        if c.MaxPlayerLimit < c.DefaultPlayerLimit {
+                addProblem("PAGINATION_MAX_LIMIT (%d) must not be less than PAGINATION_DEFAULT_LIMIT (%d)", c.MaxPlayerLimit, c.DefaultPlayerLimit)
+        }
+
+        // Server timeouts
+        if c.ReadTimeout <= 0 {
+                addProblem("READ_TIMEOUT must be positive (got %d)", c.ReadTimeout)
+        }
+        if c.WriteTimeout <= 0 {
+                addProblem("WRITE_TIMEOUT must be positive (got %d)", c.WriteTimeout)
+        }
+        if c.IdleTimeout <= 0 {
+                addProblem("IDLE_TIMEOUT must be positive (got %d)", c.IdleTimeout)
+        }
+
+        if !isSupportedLocale(c.DefaultLocale) {
+                addProblem("DEFAULT_LOCALE must be one of %s (got %q)", strings.Join(supportedLocales, ", "), c.DefaultLocale)
+        }
+        if c.MaxRequestBodyBytes <= 0 {
+                addProblem("MAX_REQUEST_BODY_BYTES must be positive (got %d)", c.MaxRequestBodyBytes)
+        }
+
+        // Rate limiting
+        if c.RateLimitRequests <= 0 {
+                addProblem("RATE_LIMIT_REQUESTS must be positive (got %d)", c.RateLimitRequests)
+        }
+        if c.RateLimitWindow <= 0 {
+                addProblem("RATE_LIMIT_WINDOW must be positive (got %d)", c.RateLimitWindow)
+        }
+        for _, proxy := range c.TrustedProxies {
+                if _, err := ParseCIDR(proxy); err != nil {
+                        addProblem("TRUSTED_PROXIES entry %q is not a CIDR or IP address", proxy)
+                }
+        }
+
+        // Database connection pool
+        if c.DBMaxConns <= 0 {
+                addProblem("DB_MAX_CONNS must be positive (got %d)", c.DBMaxConns)
+        }
+        if c.DBMinConns < 0 {
+                addProblem("DB_MIN_CONNS must not be negative (got %d)", c.DBMinConns)
+        } else if c.DBMinConns > c.DBMaxConns {
+                addProblem("DB_MIN_CONNS (%d) must not exceed DB_MAX_CONNS (%d)", c.DBMinConns, c.DBMaxConns)
+        }
+        if c.DBMaxLifetime <= 0 {
+                addProblem("DB_MAX_LIFETIME must be positive (got %d)", c.DBMaxLifetime)
+        }
+        if c.DBMaxIdleTime <= 0 {
+                addProblem("DB_MAX_IDLE_TIME must be positive (got %d)", c.DBMaxIdleTime)
+        }
+        if c.DBRetryAttempts < 0 || c.DBRetryAttempts > 5 {
+                addProblem("DB_RETRY_ATTEMPTS must be between 0 and 5 (got %d)", c.DBRetryAttempts)
+        }
+        if c.DBRetryBackoff < 0 {
+                addProblem("DB_RETRY_BACKOFF must not be negative (got %v)", c.DBRetryBackoff)
+        }
+
+        // Odds API
+        if !validSportKey(c.OddsAPISport) {
+                addProblem("ODDS_API_SPORT must be an Odds API sport key like soccer_epl (got %q)", c.OddsAPISport)
+        }
+        if c.OddsAPIQuotaWarning < 0 {
+                addProblem("ODDS_API_QUOTA_WARNING must not be negative (got %d)", c.OddsAPIQuotaWarning)
+        }
+        if c.OddsAPIRateLimitBackoff <= 0 {
+                addProblem("ODDS_API_RATE_LIMIT_BACKOFF must be positive (got %v)", c.OddsAPIRateLimitBackoff)
+        }
+
+        hasH2H := false
+        for _, market := range c.OddsAPIMarkets {
+                if _, ok := betMarkets[market]; !ok {
+                        addProblem("ODDS_API_MARKETS: unsupported market %q (use %s)", market, strings.Join(slices.Sorted(maps.Keys(betMarkets)), ", "))
+                }
+                if market == MarketH2H {
+                        hasH2H = true
+                }
+        }
+        if !hasH2H {
+                addProblem("ODDS_API_MARKETS must include h2h")
+        }
+        if c.HouseMarginPercent < 0 || c.HouseMarginPercent >= maxHouseMarginPercent {
+                addProblem("HOUSE_MARGIN_PERCENT must be between 0 and %d (got %.2f)", maxHouseMarginPercent, c.HouseMarginPercent)
+        }
+
+        for _, entry := range c.TeamAliases {
+                if _, _, err := parseTeamAlias(entry); err != nil {
+                        addProblem("TEAM_ALIASES: %s", err.Error())
+                }
+        }
+
+        // Outbound HTTP timeouts
+        if c.OddsAPITimeout <= 0 {
+                addProblem("ODDS_API_TIMEOUT must be positive (got %v)", c.OddsAPITimeout)
+        }
+        if c.TelegramTimeout <= 0 {
+                addProblem("TELEGRAM_TIMEOUT must be positive (got %v)", c.TelegramTimeout)
+        }
+        if c.DiscordTimeout <= 0 {
+                addProblem("DISCORD_TIMEOUT must be positive (got %v)", c.DiscordTimeout)
+        }
+        if c.DiscordWebhookURL != "" && !strings.HasPrefix(c.DiscordWebhookURL, "https://") {
+                addProblem("DISCORD_WEBHOOK_URL must be an https:// URL")
+        }
+        for _, hook := range c.UserCreatedHooks {
+                if !slices.Contains(userCreatedHookNames, hook) {
+                        addProblem("USER_CREATED_HOOKS: unknown hook %q (use %s)", hook, strings.Join(userCreatedHookNames, ", "))
+                }
+        }
+        if slices.Contains(c.UserCreatedHooks, userCreatedHookWebhook) && !strings.HasPrefix(c.UserCreatedWebhookURL, "https://") {
+                addProblem("USER_CREATED_WEBHOOK_URL must be an https:// URL when USER_CREATED_HOOKS includes webhook")
+        }
+        if c.UserCreatedWebhookTimeout <= 0 {
+                addProblem("USER_CREATED_WEBHOOK_TIMEOUT must be positive (got %v)", c.UserCreatedWebhookTimeout)
+        }
+        if c.GoogleOAuthTimeout <= 0 {
+                addProblem("GOOGLE_OAUTH_TIMEOUT must be positive (got %v)", c.GoogleOAuthTimeout)
+        }
+        for _, host := range c.OAuthAllowedRedirectHosts {
+                if strings.ContainsAny(host, "/*") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+                        addProblem("OAUTH_ALLOWED_REDIRECT_HOSTS entry %q must be a bare host name (no scheme, port or wildcard)", host)
+                }
+        }
+
+        // Bet settlement
+        if c.CalcBatchSize <= 0 {
+                addProblem("CALC_BATCH_SIZE must be positive (got %d)", c.CalcBatchSize)
+        }
+        if c.CalcVoidGracePeriod < 0 {
+                addProblem("CALC_VOID_GRACE_PERIOD must not be negative (got %v)", c.CalcVoidGracePeriod)
+        }
+
+        // HSTS
+        if c.HSTSMaxAge < 0 {
+                addProblem("HSTS_MAX_AGE must not be negative (got %d)", c.HSTSMaxAge)
+        }
+
+        if len(problems) == 0 {
+                return nil
+        }
+        return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
 // Helper functions for environment variable parsing
 func getEnvString(key, defaultValue string) string {
         if value := os.Getenv(key); value != "" {
@@ -258,3 +884,40 @@ func getEnvCORSOrigins(key string, defaultOrigins []string) []string {
         }
         return defaultOrigins
 }
+
+// getEnvStringList parses a comma-separated list environment variable
+// Example: "mailinator.com,yopmail.com"
+func getEnvStringList(key string, defaultValue []string) []string {
+        if value := os.Getenv(key); value != "" {
+                var items []string
+                for _, item := range strings.Split(value, ",") {
+                        item = strings.TrimSpace(item)
+                        if item != "" {
+                                items = append(items, item)
+                        }
+                }
+                if len(items) > 0 {
+                        return items
+                }
+        }
+        return defaultValue
+}
+
+// getEnvFloat64List parses a comma-separated list of numbers
+// Unparsable entries become NaN so Validate can report them
+// Example: "1000,500,250"
+func getEnvFloat64List(key string, defaultValue []float64) []float64 {
+        items := getEnvStringList(key, nil)
+        if items == nil {
+                return defaultValue
+        }
+        values := make([]float64, 0, len(items))
+        for _, item := range items {
+                value, err := strconv.ParseFloat(item, 64)
+                if err != nil {
+                        value = math.NaN()
+                }
+                values = append(values, value)
+        }
+        return values
+}
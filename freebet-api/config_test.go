@@ -0,0 +1,161 @@
+package main
+
+import (
+        "net/http"
+        "strings"
+        "testing"
+)
+
+// validTestConfig loads the default configuration with the required settings present
+func validTestConfig(t *testing.T) *Config {
+        t.Helper()
+
+        t.Setenv("DATABASE_URL", "postgres://test")
+        config, err := loadConfig()
+        if err != nil {
+                t.Fatalf("loadConfig: %v", err)
+        }
+        return config
+}
+
+func TestJWTSecretProductionGuard(t *testing.T) {
+        strong := strings.Repeat("s", 32)
+
+        tests := []struct {
+                name    string
+                env     string
+                secret  string
+                wantErr string // "" = valid
+        }{
+                {"production with a strong secret", "production", strong, ""},
+                {"production with an empty secret", "production", "", "JWT_SECRET environment variable is required"},
+                {"production with the default secret", "production", defaultJWTSecret, "must be changed from the default"},
+                {"production with a short secret", "production", strong[:31], "at least 32 characters (got 31)"},
+                {"development with the default secret", "development", defaultJWTSecret, ""},
+                {"development with a short secret", "development", "short", ""},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        config := validTestConfig(t)
+                        config.Env = tt.env
+                        config.CookieSecure = true
+                        config.JWTSecret = tt.secret
+
+                        err := config.Validate()
+                        if tt.wantErr == "" {
+                                if err != nil {
+                                        t.Fatalf("Validate() = %v, want nil", err)
+                                }
+                                return
+                        }
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
+
+func TestLoadConfigRefusesDefaultJWTSecretInProduction(t *testing.T) {
+        t.Setenv("DATABASE_URL", "postgres://test")
+        t.Setenv("NODE_ENV", "production")
+        t.Setenv("JWT_SECRET", "")
+        if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+                t.Fatalf("loadConfig() = %v, want a JWT_SECRET error", err)
+        }
+
+        t.Setenv("JWT_SECRET", strings.Repeat("s", 32))
+        if _, err := loadConfig(); err != nil {
+                t.Fatalf("loadConfig() with a strong secret = %v", err)
+        }
+}
+
+func TestConfigValidate(t *testing.T) {
+        tests := []struct {
+                name    string
+                mutate  func(c *Config)
+                wantErr string
+        }{
+                {"bcrypt cost too low", func(c *Config) { c.BcryptCost = 3 }, "BCRYPT_COST must be between 10 and 14 (got 3)"},
+                {"bcrypt cost too high", func(c *Config) { c.BcryptCost = 15 }, "BCRYPT_COST must be between 10 and 14 (got 15)"},
+                {"max bet below min bet", func(c *Config) { c.MinBetAmount, c.MaxBetAmount = 10, 5 }, "MAX_BET_AMOUNT (5.00) must not be less than MIN_BET_AMOUNT (10.00)"},
+                {"non-positive min bet", func(c *Config) { c.MinBetAmount = 0 }, "MIN_BET_AMOUNT must be positive"},
+                {"negative pool size", func(c *Config) { c.DBMaxConns = -1 }, "DB_MAX_CONNS must be positive (got -1)"},
+                {"min conns above max conns", func(c *Config) { c.DBMinConns, c.DBMaxConns = 5, 2 }, "DB_MIN_CONNS (5) must not exceed DB_MAX_CONNS (2)"},
+                {"zero read timeout", func(c *Config) { c.ReadTimeout = 0 }, "READ_TIMEOUT must be positive (got 0)"},
+                {"unknown same-site", func(c *Config) { c.CookieSameSite = "loose" }, `COOKIE_SAME_SITE must be one of strict, lax, none (got "loose")`},
+                {"missing database url", func(c *Config) { c.DatabaseURL = "" }, "DATABASE_URL environment variable is required"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        config := validTestConfig(t)
+                        tt.mutate(config)
+                        err := config.Validate()
+                        if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                                t.Fatalf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+                        }
+                })
+        }
+}
+
+func TestConfigValidateListsEveryProblem(t *testing.T) {
+        config := validTestConfig(t)
+        if err := config.Validate(); err != nil {
+                t.Fatalf("defaults do not validate: %v", err)
+        }
+
+        config.BcryptCost = 3
+        config.MinBetAmount, config.MaxBetAmount = 10, 5
+        config.ReadTimeout = 0
+        err := config.Validate()
+        if err == nil {
+                t.Fatal("Validate() = nil, want an error")
+        }
+        for _, want := range []string{"BCRYPT_COST", "MAX_BET_AMOUNT", "READ_TIMEOUT"} {
+                if !strings.Contains(err.Error(), want) {
+                        t.Errorf("error does not mention %s:\n%v", want, err)
+                }
+        }
+        if got := strings.Count(err.Error(), "\n  - "); got != 3 {
+                t.Errorf("error lists %d problems, want 3:\n%v", got, err)
+        }
+}
+
+func TestLoadConfigRejectsInvalidEnvironment(t *testing.T) {
+        t.Setenv("DATABASE_URL", "postgres://test")
+        t.Setenv("BCRYPT_COST", "3")
+        if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "BCRYPT_COST") {
+                t.Fatalf("loadConfig() = %v, want a BCRYPT_COST error", err)
+        }
+}
+
+func TestCookieSameSite(t *testing.T) {
+        tests := []struct {
+                setting string
+                want    http.SameSite
+        }{
+                {"strict", http.SameSiteStrictMode},
+                {"Strict", http.SameSiteStrictMode},
+                {"lax", http.SameSiteLaxMode},
+                {"none", http.SameSiteNoneMode},
+        }
+        for _, tt := range tests {
+                config := &Config{CookieSameSite: tt.setting}
+                if got := config.cookieSameSite(); got != tt.want {
+                        t.Errorf("cookieSameSite(%q) = %v, want %v", tt.setting, got, tt.want)
+                }
+        }
+}
+
+func TestConfigValidateSameSiteNoneRequiresSecure(t *testing.T) {
+        config := validTestConfig(t)
+        config.CookieSameSite = "none"
+        config.CookieSecure = false
+        if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "COOKIE_SAME_SITE=none requires COOKIE_SECURE=true") {
+                t.Fatalf("Validate() = %v, want the SameSite=None error", err)
+        }
+
+        config.CookieSecure = true
+        if err := config.Validate(); err != nil {
+                t.Fatalf("Validate() with Secure = %v, want nil", err)
+        }
+}
@@ -2,18 +2,84 @@ package main
 
 import (
         "context"
+        "errors"
         "fmt"
+        "io"
+        "net"
         "net/url"
+        "strconv"
         "strings"
+        "syscall"
         "time"
 
+        "github.com/jackc/pgx/v5"
+        "github.com/jackc/pgx/v5/pgconn"
         "github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrInsufficientFunds is returned when a conditional debit finds the balance too low
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrNicknameTaken is returned by UpdateUserNickname when another user has the nickname
+var ErrNicknameTaken = errors.New("nickname already taken")
+
+// ErrNotGuest is returned by the guest upgrade methods when the account is already a full account
+var ErrNotGuest = errors.New("account is not a guest")
+
+// ErrAccountExists is returned by the guest upgrade methods when the email, nickname or Google ID belongs to another account
+var ErrAccountExists = errors.New("account already exists")
+
+// ErrAdminExists is returned by CreateAdmin when the username is already taken
+var ErrAdminExists = errors.New("admin already exists")
+
+// Not-found errors returned by lookups instead of pgx.ErrNoRows
+// Any other error from a lookup is a genuine database failure
+var (
+        ErrUserNotFound         = errors.New("user not found")
+        ErrMatchNotFound        = errors.New("match not found")
+        ErrRefreshTokenNotFound = errors.New("refresh token not found")
+        ErrAdminNotFound        = errors.New("admin not found")
+        ErrAdminSessionNotFound = errors.New("admin session not found")
+        ErrSeasonNotFound       = errors.New("season not found")
+        ErrNotificationNotFound = errors.New("notification not found")
+        ErrBetNotFound          = errors.New("bet not found")
+)
+
+// ErrBetNotInReview is returned by ApproveBet and RejectBet when the bet is not held for review
+var ErrBetNotInReview = errors.New("bet is not in review")
+
+// ErrInvalidBetCursor is returned by GetUserBets for a cursor that cannot come from a listing
+var ErrInvalidBetCursor = errors.New("invalid bet cursor")
+
+// ErrSeasonClosed is returned by CloseSeason when the season was already closed
+var ErrSeasonClosed = errors.New("season already closed")
+
+// ErrMatchStarted is returned by ApproveBet once betting on the match has closed
+var ErrMatchStarted = errors.New("match already started")
+
+// ErrMatchAlreadySettled is returned by the settlement methods when the match is already calculated
+var ErrMatchAlreadySettled = errors.New("match already settled")
+
+// ErrCalcInProgress is returned by TryLockCalc while another calculation run holds the lock
+var ErrCalcInProgress = errors.New("calculation already in progress")
+
+// calcLockID is the advisory lock key that keeps calculation runs from overlapping across instances
+const calcLockID = 720452
+
+// notFound translates pgx.ErrNoRows into the given not-found error
+func notFound(err error, notFoundErr error) error {
+        if errors.Is(err, pgx.ErrNoRows) {
+                return notFoundErr
+        }
+        return err
+}
+
 // PostgresDB implements the Database interface using PostgreSQL
 type PostgresDB struct {
-        pool   *pgxpool.Pool
-        logger *Logger
+        pool          *pgxpool.Pool
+        logger        *Logger
+        retryAttempts int
+        retryBackoff  time.Duration
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
@@ -71,18 +137,83 @@ func NewPostgresDB(databaseURL string, dbConfig *Config, logger *Logger) (*Postg
         logger.LogDB("PostgreSQL connection established")
 
         return &PostgresDB{
-                pool:   pool,
-                logger: logger,
+                pool:          pool,
+                logger:        logger,
+                retryAttempts: dbConfig.DBRetryAttempts,
+                retryBackoff:  dbConfig.DBRetryBackoff,
         }, nil
 }
 
+// transientPgCodes are server errors that a retry can succeed on
+var transientPgCodes = map[string]bool{
+        "08000": true, // connection_exception
+        "08003": true, // connection_does_not_exist
+        "08006": true, // connection_failure
+        "40001": true, // serialization_failure
+        "40P01": true, // deadlock_detected
+        "53300": true, // too_many_connections
+        "57P01": true, // admin_shutdown (restart during deploy)
+        "57P03": true, // cannot_connect_now (starting up)
+}
+
+// isTransientDBError reports whether a failed read is worth retrying
+func isTransientDBError(err error) bool {
+        if err == nil || errors.Is(err, pgx.ErrNoRows) {
+                return false
+        }
+
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) {
+                return transientPgCodes[pgErr.Code]
+        }
+
+        // Connection reset, pool acquire timeout, failed dial
+        var connectErr *pgconn.ConnectError
+        var netErr net.Error
+        return pgconn.SafeToRetry(err) || pgconn.Timeout(err) ||
+                errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+                errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// withRetry runs a read-only query, retrying transient errors with exponential backoff
+// fn must be safe to repeat: no writes, no transactions, and it must reset its results
+// Retries stop as soon as ctx is cancelled
+func (db *PostgresDB) withRetry(ctx context.Context, operation string, fn func() error) error {
+        err := fn()
+        backoff := db.retryBackoff
+        for attempt := 1; attempt <= db.retryAttempts && isTransientDBError(err) && ctx.Err() == nil; attempt++ {
+                db.logger.LogWarning("[DB] %s failed (%s), retry %d/%d in %v", operation, err.Error(), attempt, db.retryAttempts, backoff)
+                select {
+                case <-time.After(backoff):
+                case <-ctx.Done():
+                        return err
+                }
+                backoff *= 2
+                err = fn()
+        }
+        return err
+}
+
 // Ping tests the database connection
-func (db *PostgresDB) Ping() error {
-        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (db *PostgresDB) Ping(ctx context.Context) error {
+        ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
         defer cancel()
         return db.pool.Ping(ctx)
 }
 
+// PoolStats returns a snapshot of the connection pool
+func (db *PostgresDB) PoolStats() *PoolStats {
+        stat := db.pool.Stat()
+        return &PoolStats{
+                TotalConns:        stat.TotalConns(),
+                IdleConns:         stat.IdleConns(),
+                AcquiredConns:     stat.AcquiredConns(),
+                MaxConns:          stat.MaxConns(),
+                AcquireCount:      stat.AcquireCount(),
+                EmptyAcquireCount: stat.EmptyAcquireCount(),
+        }
+}
+
 // Close closes the database connection pool
 func (db *PostgresDB) Close() error {
         db.logger.LogDB("Closing PostgreSQL connection pool")
@@ -91,110 +222,160 @@ func (db *PostgresDB) Close() error {
 }
 
 // User methods
-func (db *PostgresDB) GetUserByEmail(email string) (*User, error) {
+func (db *PostgresDB) GetUserByEmail(ctx context.Context, email string) (*User, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT users by email", []interface{}{email}, time.Since(start))
-        }()
 
         query := `
-                SELECT id, email, nickname, password_hash, google_id, picture_url, auth_provider,
-                       money, topup, last_topup_at, created_at, updated_at
+                SELECT id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                       money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
                 FROM users WHERE email = $1`
 
+        defer func() {
+                db.logger.LogSQL("SELECT users by email", query, []interface{}{email}, time.Since(start))
+        }()
+
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
         err := db.pool.QueryRow(ctx, query, email).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrUserNotFound)
         }
 
         return &user, nil
 }
 
-func (db *PostgresDB) GetUserByNickname(nickname string) (*User, error) {
+func (db *PostgresDB) GetUserByNickname(ctx context.Context, nickname string) (*User, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT users by nickname", []interface{}{nickname}, time.Since(start))
-        }()
 
         query := `
-                SELECT id, email, nickname, password_hash, google_id, picture_url, auth_provider,
-                       money, topup, last_topup_at, created_at, updated_at
+                SELECT id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                       money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
                 FROM users WHERE nickname = $1`
 
+        defer func() {
+                db.logger.LogSQL("SELECT users by nickname", query, []interface{}{nickname}, time.Since(start))
+        }()
+
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
         err := db.pool.QueryRow(ctx, query, nickname).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrUserNotFound)
         }
 
         return &user, nil
 }
 
-func (db *PostgresDB) GetUserByID(id string) (*User, error) {
+// GetUserByEmailOrNickname looks up a login identifier in one round trip
+// An email match wins over a nickname match so the result is deterministic
+func (db *PostgresDB) GetUserByEmailOrNickname(ctx context.Context, identifier string) (*User, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT users by ID", []interface{}{id}, time.Since(start))
-        }()
 
         query := `
-                SELECT id, email, nickname, password_hash, google_id, picture_url, auth_provider,
-                       money, topup, last_topup_at, created_at, updated_at
-                FROM users WHERE id = $1`
+                SELECT id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                       money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
+                FROM users WHERE email = $1 OR nickname = $1
+                ORDER BY COALESCE(email = $1, FALSE) DESC
+                LIMIT 1`
+
+        defer func() {
+                db.logger.LogSQL("SELECT users by email or nickname", query, []interface{}{identifier}, time.Since(start))
+        }()
 
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, id).Scan(
+        err := db.pool.QueryRow(ctx, query, identifier).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrUserNotFound)
         }
 
         return &user, nil
 }
 
-func (db *PostgresDB) CreateUser(email, passwordHash, nickname string, initialBalance float64) (*User, error) {
+func (db *PostgresDB) GetUserByID(ctx context.Context, id string) (*User, error) {
         start := time.Now()
+
+        query := `
+                SELECT id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                       money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
+                FROM users WHERE id = $1`
+
         defer func() {
-                db.logger.LogSQL("INSERT user", []interface{}{email, nickname}, time.Since(start))
+                db.logger.LogSQL("SELECT users by ID", query, []interface{}{id}, time.Since(start))
         }()
 
+        var user User
+        err := db.withRetry(ctx, "SELECT users by ID", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                return db.pool.QueryRow(ctx, query, id).Scan(
+                        &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
+                        &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
+                        &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
+                )
+        })
+
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return nil, ErrUserNotFound // Not a UUID, so no such user
+        }
+        if err != nil {
+                return nil, notFound(err, ErrUserNotFound)
+        }
+
+        return &user, nil
+}
+
+func (db *PostgresDB) CreateUser(ctx context.Context, email, passwordHash, nickname string, initialBalance float64) (*User, error) {
+        start := time.Now()
+
+        // The initial grant goes into the ledger in the same statement
         query := `
-                INSERT INTO users (email, nickname, password_hash, auth_provider, money, topup, last_topup_at)
-                VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
-                RETURNING id, email, nickname, password_hash, google_id, picture_url,
-                         auth_provider, money, topup, last_topup_at, created_at, updated_at`
+                WITH u AS (
+                        INSERT INTO users (email, nickname, password_hash, auth_provider, money, topup, last_topup_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+                        RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url,
+                                 auth_provider, money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
+                ), grant_entry AS (
+                        INSERT INTO ledger (user_id, type, amount, balance_after)
+                        SELECT id, '` + LedgerInitialGrant + `', money, money FROM u WHERE money <> 0
+                )
+                SELECT * FROM u`
+
+        defer func() {
+                db.logger.LogSQL("INSERT user", query, []interface{}{email, nickname}, time.Since(start))
+        }()
 
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
         err := db.pool.QueryRow(ctx, query, email, nickname, passwordHash, "email", initialBalance, 1).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
         if err != nil {
@@ -204,124 +385,230 @@ func (db *PostgresDB) CreateUser(email, passwordHash, nickname string, initialBa
         return &user, nil
 }
 
-func (db *PostgresDB) UpdateUserMoney(userID string, newMoney float64) error {
+// TopupUser credits a top-up, bumps the top-up counter and records it in the ledger
+// Returns the new balance
+func (db *PostgresDB) TopupUser(ctx context.Context, userID string, amount float64) (float64, error) {
+        query := `
+                UPDATE users
+                SET money = money + $1, topup = COALESCE(topup, 0) + 1, last_topup_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2
+                RETURNING money`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("UPDATE user money", []interface{}{userID, newMoney}, time.Since(start))
+                db.logger.LogSQL("UPDATE user topup", query, []interface{}{userID, amount}, time.Since(start))
         }()
 
-        query := `UPDATE users SET money = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        _, err := db.pool.Exec(ctx, query, newMoney, userID)
-        return err
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return 0, err
+        }
+        defer tx.Rollback(ctx)
+
+        var newBalance float64
+        if err := tx.QueryRow(ctx, query, amount, userID).Scan(&newBalance); err != nil {
+                return 0, notFound(err, ErrUserNotFound)
+        }
+        if err := recordLedger(ctx, tx, userID, LedgerTopup, amount, newBalance, ""); err != nil {
+                return 0, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return 0, err
+        }
+        return newBalance, nil
 }
 
-func (db *PostgresDB) IncrementUserTopup(userID string) error {
+// GetTopupTimes returns when the user's most recent top-ups happened, newest first
+func (db *PostgresDB) GetTopupTimes(ctx context.Context, userID string, limit int) ([]time.Time, error) {
+        query := `
+                SELECT created_at FROM ledger
+                WHERE user_id = $1 AND type = $2
+                ORDER BY id DESC
+                LIMIT $3`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("UPDATE user topup", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("SELECT ledger topups", query, []interface{}{userID, limit}, time.Since(start))
         }()
 
-        query := `UPDATE users SET topup = COALESCE(topup, 0) + 1, last_topup_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+        var times []time.Time
+        err := db.withRetry(ctx, "SELECT ledger topups", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
-
-        _, err := db.pool.Exec(ctx, query, userID)
-        return err
+                rows, err := db.pool.Query(ctx, query, userID, LedgerTopup, limit)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                times = nil // Reset on retry
+                for rows.Next() {
+                        var createdAt time.Time
+                        if err := rows.Scan(&createdAt); err != nil {
+                                return err
+                        }
+                        times = append(times, createdAt)
+                }
+                return rows.Err()
+        })
+        if err != nil {
+                return nil, err
+        }
+        return times, nil
 }
 
-func (db *PostgresDB) GetUserLastTopupTime(userID string) (*time.Time, error) {
+func (db *PostgresDB) GetUserLastTopupTime(ctx context.Context, userID string) (*time.Time, error) {
+        query := `SELECT last_topup_at FROM users WHERE id = $1`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT user last_topup_at", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("SELECT user last_topup_at", query, []interface{}{userID}, time.Since(start))
         }()
 
-        query := `SELECT last_topup_at FROM users WHERE id = $1`
-
         var lastTopupAt *time.Time
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
         err := db.pool.QueryRow(ctx, query, userID).Scan(&lastTopupAt)
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrUserNotFound)
         }
 
         return lastTopupAt, nil
 }
 
-func (db *PostgresDB) UpdateUserPassword(userID string, newPasswordHash string) error {
+// GetUserNicknameChangedAt returns when the user last changed their nickname (nil if never)
+func (db *PostgresDB) GetUserNicknameChangedAt(ctx context.Context, userID string) (*time.Time, error) {
+        query := `SELECT nickname_changed_at FROM users WHERE id = $1`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("UPDATE user password", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("SELECT user nickname_changed_at", query, []interface{}{userID}, time.Since(start))
         }()
 
-        query := `UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        var changedAt *time.Time
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        _, err := db.pool.Exec(ctx, query, newPasswordHash, userID)
-        return err
+        err := db.pool.QueryRow(ctx, query, userID).Scan(&changedAt)
+        if err != nil {
+                return nil, notFound(err, ErrUserNotFound)
+        }
+
+        return changedAt, nil
 }
 
+// UpdateUserNickname renames a user and records the change time
+// The unique index decides races between two users claiming the same nickname
+func (db *PostgresDB) UpdateUserNickname(ctx context.Context, userID, nickname string) (*User, error) {
+        query := `
+                UPDATE users SET nickname = $2, nickname_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1
+                RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                          money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest`
 
-// Google OAuth User methods
-func (db *PostgresDB) GetUserByGoogleID(googleID string) (*User, error) {
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT user by google_id", []interface{}{googleID[:10] + "..."}, time.Since(start))
+                db.logger.LogSQL("UPDATE user nickname", query, []interface{}{userID, nickname}, time.Since(start))
         }()
 
-        query := `
-                SELECT u.id, u.email, u.nickname, u.password_hash, u.google_id, u.picture_url,
-                       u.auth_provider, u.money, u.topup, u.last_topup_at, u.created_at, u.updated_at
-                FROM users u
-                WHERE u.google_id = $1`
-
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, googleID).Scan(
+        err := db.pool.QueryRow(ctx, query, userID, nickname).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+                return nil, ErrNicknameTaken
+        }
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrUserNotFound)
         }
 
         return &user, nil
 }
 
-func (db *PostgresDB) CreateUserWithGoogle(googleID, email, nickname, pictureURL string, initialBalance float64) (*User, error) {
+// UpdateUserPicture sets the user's profile picture URL
+func (db *PostgresDB) UpdateUserPicture(ctx context.Context, userID, pictureURL string) error {
+        query := `UPDATE users SET picture_url = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE user picture", query, []interface{}{userID, pictureURL}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        tag, err := db.pool.Exec(ctx, query, userID, pictureURL)
+        if err != nil {
+                return err
+        }
+        if tag.RowsAffected() == 0 {
+                return ErrUserNotFound
+        }
+        return nil
+}
+
+func (db *PostgresDB) UpdateUserPassword(ctx context.Context, userID string, newPasswordHash string) error {
+        query := `UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("INSERT user with google", []interface{}{email, nickname}, time.Since(start))
+                db.logger.LogSQL("UPDATE user password", query, []interface{}{userID}, time.Since(start))
         }()
 
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        _, err := db.pool.Exec(ctx, query, newPasswordHash, userID)
+        return err
+}
+
+// CreateGuestUser creates a guest account without email or password
+// The initial grant goes into the ledger in the same statement
+func (db *PostgresDB) CreateGuestUser(ctx context.Context, nickname string, initialBalance float64) (*User, error) {
         query := `
-                INSERT INTO users (email, nickname, google_id, picture_url, auth_provider, money, topup, last_topup_at)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
-                RETURNING id, email, nickname, password_hash, google_id, picture_url,
-                         auth_provider, money, topup, last_topup_at, created_at, updated_at`
+                WITH u AS (
+                        INSERT INTO users (nickname, auth_provider, money, topup, last_topup_at, is_guest, last_active_at)
+                        VALUES ($1, 'guest', $2, 1, CURRENT_TIMESTAMP, TRUE, CURRENT_TIMESTAMP)
+                        RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                                 money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
+                ), grant_entry AS (
+                        INSERT INTO ledger (user_id, type, amount, balance_after)
+                        SELECT id, '` + LedgerInitialGrant + `', money, money FROM u WHERE money <> 0
+                )
+                SELECT * FROM u`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("INSERT guest user", query, []interface{}{nickname}, time.Since(start))
+        }()
 
         var user User
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, email, nickname, googleID, pictureURL, "google", initialBalance, 1).Scan(
+        err := db.pool.QueryRow(ctx, query, nickname, initialBalance).Scan(
                 &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
                 &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
-                &user.LastTopupAt, &user.CreatedAt, &user.UpdatedAt,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+                return nil, ErrNicknameTaken
+        }
         if err != nil {
                 return nil, err
         }
@@ -329,600 +616,752 @@ func (db *PostgresDB) CreateUserWithGoogle(googleID, email, nickname, pictureURL
         return &user, nil
 }
 
-// JWT Refresh Token methods
-func (db *PostgresDB) CreateRefreshToken(userID string, token string, expiresAt time.Time) (*RefreshToken, error) {
+// UpgradeGuestUser turns a guest into an email account; bets, balance and ledger stay with the user ID
+func (db *PostgresDB) UpgradeGuestUser(ctx context.Context, userID, email, passwordHash, nickname string) (*User, error) {
+        query := `
+                UPDATE users
+                SET email = $2, password_hash = $3, nickname = $4, auth_provider = 'email',
+                    is_guest = FALSE, last_active_at = NULL, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1 AND is_guest
+                RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                          money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("INSERT refresh_token", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("UPDATE guest upgrade", query, []interface{}{userID, email, nickname}, time.Since(start))
         }()
 
-        query := `
-                INSERT INTO refresh_tokens (user_id, token, expires_at)
-                VALUES ($1, $2, $3)
-                RETURNING id, user_id, token, expires_at, created_at`
-
-        var refreshToken RefreshToken
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        var user User
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, userID, token, expiresAt).Scan(
-                &refreshToken.ID, &refreshToken.UserID, &refreshToken.Token,
-                &refreshToken.ExpiresAt, &refreshToken.CreatedAt,
+        err := db.pool.QueryRow(ctx, query, userID, email, passwordHash, nickname).Scan(
+                &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
+                &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+                return nil, ErrAccountExists
+        }
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrNotGuest)
         }
 
-        return &refreshToken, nil
+        return &user, nil
 }
 
-func (db *PostgresDB) GetRefreshTokenByToken(token string) (*RefreshToken, error) {
+// LinkGuestGoogle turns a guest into a Google account
+// A picture the guest uploaded is kept over the Google one
+func (db *PostgresDB) LinkGuestGoogle(ctx context.Context, userID, googleID, email, pictureURL string) (*User, error) {
+        query := `
+                UPDATE users
+                SET google_id = $2, email = $3, picture_url = COALESCE(picture_url, NULLIF($4, '')), auth_provider = 'google',
+                    is_guest = FALSE, last_active_at = NULL, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $1 AND is_guest
+                RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url, auth_provider,
+                          money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT refresh_token by token", []interface{}{token[:10] + "..."}, time.Since(start))
+                db.logger.LogSQL("UPDATE guest link Google", query, []interface{}{userID, googleID, email}, time.Since(start))
         }()
 
-        query := `
-                SELECT rt.id, rt.user_id, rt.token, rt.expires_at, rt.created_at
-                FROM refresh_tokens rt
-                WHERE rt.token = $1 AND rt.expires_at > CURRENT_TIMESTAMP`
-
-        var refreshToken RefreshToken
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        var user User
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, token).Scan(
-                &refreshToken.ID, &refreshToken.UserID, &refreshToken.Token,
-                &refreshToken.ExpiresAt, &refreshToken.CreatedAt,
+        err := db.pool.QueryRow(ctx, query, userID, googleID, email, pictureURL).Scan(
+                &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
+                &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
         )
 
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+                return nil, ErrAccountExists
+        }
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrNotGuest)
         }
 
-        return &refreshToken, nil
+        return &user, nil
 }
 
-func (db *PostgresDB) DeleteRefreshToken(token string) error {
+// TouchGuest records guest activity so the account isn't expired
+func (db *PostgresDB) TouchGuest(ctx context.Context, userID string) error {
+        query := `UPDATE users SET last_active_at = CURRENT_TIMESTAMP WHERE id = $1 AND is_guest`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("DELETE refresh_token", []interface{}{token[:10] + "..."}, time.Since(start))
+                db.logger.LogSQL("UPDATE guest activity", query, []interface{}{userID}, time.Since(start))
         }()
 
-        query := `DELETE FROM refresh_tokens WHERE token = $1`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        _, err := db.pool.Exec(ctx, query, token)
+        _, err := db.pool.Exec(ctx, query, userID)
         return err
 }
 
-func (db *PostgresDB) DeleteAllUserRefreshTokens(userID string) error {
+// DeleteInactiveGuests deletes guests whose last activity (token refresh, bet or balance change) is older than inactiveSince
+// Their bets, ledger and tokens go with them (ON DELETE CASCADE)
+func (db *PostgresDB) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int, error) {
+        query := `
+                DELETE FROM users u
+                WHERE u.is_guest
+                  AND GREATEST(COALESCE(u.last_active_at, u.created_at), u.updated_at) < $1
+                  AND NOT EXISTS (SELECT 1 FROM bets b WHERE b.user_id = u.id AND b.status IN ('pending', 'review'))`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("DELETE all user refresh_tokens", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("DELETE inactive guests", query, []interface{}{inactiveSince}, time.Since(start))
         }()
 
-        query := `DELETE FROM refresh_tokens WHERE user_id = $1`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
         defer cancel()
 
-        _, err := db.pool.Exec(ctx, query, userID)
-        return err
+        tag, err := db.pool.Exec(ctx, query, inactiveSince)
+        if err != nil {
+                return 0, err
+        }
+        return int(tag.RowsAffected()), nil
 }
 
-// Bet methods
-func (db *PostgresDB) GetUserBets(userID string, playerNickname string) ([]Bet, error) {
+// Google OAuth User methods
+func (db *PostgresDB) GetUserByGoogleID(ctx context.Context, googleID string) (*User, error) {
         start := time.Now()
 
-        var query string
-        var args []interface{}
-
-        if playerNickname != "" {
-                // Get bets for another player
-                query = `
-                        SELECT b.bet_id, b.user_id, b.match_id, b.bet_type, b.bet_amount,
-                                   b.odds, b.potential_win, b.status, b.home_team, b.away_team, b.created_at,
-                                   m.commence_time
-                        FROM bets b
-                        JOIN users u ON b.user_id = u.id
-                        LEFT JOIN epl_matches m ON b.match_id = m.api_id
-                        WHERE u.nickname = $1
-                        ORDER BY b.created_at DESC`
-                args = []interface{}{playerNickname}
-        } else {
-                // Get bets for current user
-                query = `
-                        SELECT b.bet_id, b.user_id, b.match_id, b.bet_type, b.bet_amount,
-                                   b.odds, b.potential_win, b.status, b.home_team, b.away_team, b.created_at,
-                                   m.commence_time
-                        FROM bets b
-                        LEFT JOIN epl_matches m ON b.match_id = m.api_id
-                        WHERE b.user_id = $1
-                        ORDER BY b.created_at DESC`
-                args = []interface{}{userID}
-        }
+        query := `
+                SELECT u.id, COALESCE(u.email, ''), u.nickname, u.password_hash, u.google_id, u.picture_url,
+                       u.auth_provider, u.money, u.topup, u.last_topup_at, u.token_version, u.deleted_at, u.created_at, u.updated_at, u.is_guest
+                FROM users u
+                WHERE u.google_id = $1`
 
         defer func() {
-                db.logger.LogSQL("SELECT bets", args, time.Since(start))
+                db.logger.LogSQL("SELECT user by google_id", query, []interface{}{maskToken(googleID)}, time.Since(start))
         }()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+        var user User
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        rows, err := db.pool.Query(ctx, query, args...)
-        if err != nil {
-                return nil, err
-        }
-        defer rows.Close()
+        err := db.pool.QueryRow(ctx, query, googleID).Scan(
+                &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
+                &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
+        )
 
-        var bets []Bet
-        for rows.Next() {
-                var bet Bet
-                err := rows.Scan(
-                        &bet.BetID, &bet.UserID, &bet.MatchID, &bet.BetType,
-                        &bet.BetAmount, &bet.Odds, &bet.PotentialWin, &bet.Status,
-                        &bet.HomeTeam, &bet.AwayTeam, &bet.CreatedAt, &bet.CommenceTime,
-                )
-                if err != nil {
-                        return nil, err
-                }
-                bets = append(bets, bet)
+        if err != nil {
+                return nil, notFound(err, ErrUserNotFound)
         }
 
-        return bets, rows.Err()
+        return &user, nil
 }
 
-func (db *PostgresDB) PlaceBet(bet *Bet) (*Bet, error) {
+func (db *PostgresDB) CreateUserWithGoogle(ctx context.Context, googleID, email, nickname, pictureURL string, initialBalance float64) (*User, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("INSERT bet", []interface{}{bet.UserID, bet.MatchID}, time.Since(start))
-        }()
 
+        // The initial grant goes into the ledger in the same statement
         query := `
-                INSERT INTO bets (user_id, match_id, bet_type, bet_amount, odds, potential_win, status, home_team, away_team, created_at)
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
-                RETURNING bet_id`
+                WITH u AS (
+                        INSERT INTO users (email, nickname, google_id, picture_url, auth_provider, money, topup, last_topup_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+                        RETURNING id, COALESCE(email, ''), nickname, password_hash, google_id, picture_url,
+                                 auth_provider, money, topup, last_topup_at, token_version, deleted_at, created_at, updated_at, is_guest
+                ), grant_entry AS (
+                        INSERT INTO ledger (user_id, type, amount, balance_after)
+                        SELECT id, '` + LedgerInitialGrant + `', money, money FROM u WHERE money <> 0
+                )
+                SELECT * FROM u`
 
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
+        defer func() {
+                db.logger.LogSQL("INSERT user with google", query, []interface{}{email, nickname}, time.Since(start))
+        }()
+
+        var user User
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
 
-        err := db.pool.QueryRow(ctx, query,
-                bet.UserID, bet.MatchID, bet.BetType, bet.BetAmount,
-                bet.Odds, bet.PotentialWin, bet.Status, bet.HomeTeam, bet.AwayTeam,
-        ).Scan(&bet.BetID)
+        err := db.pool.QueryRow(ctx, query, email, nickname, googleID, pictureURL, "google", initialBalance, 1).Scan(
+                &user.ID, &user.Email, &user.Nickname, &user.PasswordHash, &user.GoogleID,
+                &user.PictureURL, &user.AuthProvider, &user.Money, &user.Topup,
+                &user.LastTopupAt, &user.TokenVersion, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt, &user.IsGuest,
+        )
 
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_nickname_key" {
+                return nil, ErrNicknameTaken
+        }
         if err != nil {
                 return nil, err
         }
 
-        return bet, nil
-}
-
-func (db *PostgresDB) GetMatchByID(matchID string) (*Match, error) {
-        return db.GetMatchByAPIID(matchID)
+        return &user, nil
 }
 
-// Match methods
-func (db *PostgresDB) GetMatches() ([]Match, error) {
+// JWT Refresh Token methods
+func (db *PostgresDB) CreateRefreshToken(ctx context.Context, userID string, token string, expiresAt time.Time) (*RefreshToken, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT matches", nil, time.Since(start))
-        }()
 
         query := `
-                SELECT id, api_id, home_team, away_team, commence_time,
-                           home_odds, draw_odds, away_odds, completed, home_score, away_score, calculated, result
-                FROM epl_matches
-                WHERE home_odds IS NOT NULL AND draw_odds IS NOT NULL AND away_odds IS NOT NULL
-                        AND home_odds != 0 AND draw_odds != 0 AND away_odds != 0
-                        AND commence_time > CURRENT_TIMESTAMP
-                ORDER BY commence_time ASC`
+                INSERT INTO refresh_tokens (user_id, token, expires_at)
+                VALUES ($1, $2, $3)
+                RETURNING id, user_id, token, expires_at, created_at`
+
+        defer func() {
+                db.logger.LogSQL("INSERT refresh_token", query, []interface{}{userID}, time.Since(start))
+        }()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+        var refreshToken RefreshToken
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        rows, err := db.pool.Query(ctx, query)
+        err := db.pool.QueryRow(ctx, query, userID, token, expiresAt).Scan(
+                &refreshToken.ID, &refreshToken.UserID, &refreshToken.Token,
+                &refreshToken.ExpiresAt, &refreshToken.CreatedAt,
+        )
+
         if err != nil {
                 return nil, err
         }
-        defer rows.Close()
-
-        var matches []Match
-        for rows.Next() {
-                var match Match
-                err := rows.Scan(
-                        &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
-                        &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
-                        &match.AwayOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
-                        &match.Calculated, &match.Result,
-                )
-                if err != nil {
-                        return nil, err
-                }
-                matches = append(matches, match)
-        }
 
-        return matches, rows.Err()
+        return &refreshToken, nil
 }
 
-// Players methods
-func (db *PostgresDB) GetPlayers(limit, offset int) ([]PlayerDisplay, error) {
+func (db *PostgresDB) GetRefreshTokenByToken(ctx context.Context, token string) (*RefreshToken, error) {
         start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT players", []interface{}{limit, offset}, time.Since(start))
-        }()
 
         query := `
-                SELECT
-                        u.id, u.nickname, u.money, u.topup, u.created_at, u.updated_at,
-                        COUNT(b.bet_id) as bets,
-                        COALESCE(SUM(CASE WHEN b.status = 'won' THEN 1 ELSE 0 END), 0) as won_bets,
-                        COALESCE(SUM(CASE WHEN b.status IN ('won','lost') THEN 1 ELSE 0 END), 0) as settled_bets,
-                        AVG(b.odds) as avg_odds
-                FROM users u
-                LEFT JOIN bets b ON u.id = b.user_id
-                GROUP BY u.id, u.nickname, u.money, u.topup, u.created_at, u.updated_at
-                ORDER BY bets DESC, u.money DESC
-                LIMIT $1 OFFSET $2`
+                SELECT rt.id, rt.user_id, rt.token, rt.expires_at, rt.created_at
+                FROM refresh_tokens rt
+                WHERE rt.token = $1 AND rt.expires_at > CURRENT_TIMESTAMP`
+
+        defer func() {
+                db.logger.LogSQL("SELECT refresh_token by token", query, []interface{}{maskToken(token)}, time.Since(start))
+        }()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+        var refreshToken RefreshToken
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        rows, err := db.pool.Query(ctx, query, limit, offset)
+        err := db.pool.QueryRow(ctx, query, token).Scan(
+                &refreshToken.ID, &refreshToken.UserID, &refreshToken.Token,
+                &refreshToken.ExpiresAt, &refreshToken.CreatedAt,
+        )
+
         if err != nil {
-                return nil, err
+                return nil, notFound(err, ErrRefreshTokenNotFound)
         }
-        defer rows.Close()
-
-        var players []PlayerDisplay
-        for rows.Next() {
-                var player PlayerDisplay
-                var avgOdds *float64
-                var createdAt, updatedAt time.Time
 
-                err := rows.Scan(
-                        &player.ID, &player.Nickname, &player.Money, &player.Topup,
-                        &createdAt, &updatedAt, &player.Bets, &player.WonBets,
-                        &player.SettledBets, &avgOdds,
-                )
-                if err != nil {
-                        return nil, err
-                }
+        return &refreshToken, nil
+}
 
-                // Convert timestamps to ISO strings
-                player.Created = createdAt.Format(time.RFC3339)
-                player.Updated = updatedAt.Format(time.RFC3339)
+func (db *PostgresDB) DeleteRefreshToken(ctx context.Context, token string) error {
+        query := `DELETE FROM refresh_tokens WHERE token = $1`
 
-                // Handle nullable avg_odds
-                if avgOdds != nil {
-                        player.AvgOdds = *avgOdds
-                }
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("DELETE refresh_token", query, []interface{}{maskToken(token)}, time.Since(start))
+        }()
 
-                players = append(players, player)
-        }
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
 
-        return players, rows.Err()
+        _, err := db.pool.Exec(ctx, query, token)
+        return err
 }
 
-func (db *PostgresDB) GetTotalPlayers() (int, error) {
+func (db *PostgresDB) DeleteAllUserRefreshTokens(ctx context.Context, userID string) error {
+        query := `DELETE FROM refresh_tokens WHERE user_id = $1`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT COUNT players", nil, time.Since(start))
+                db.logger.LogSQL("DELETE all user refresh_tokens", query, []interface{}{userID}, time.Since(start))
         }()
 
-        query := `SELECT COUNT(*) as total FROM users`
-
-        var total int
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err := db.pool.QueryRow(ctx, query).Scan(&total)
-        return total, err
+        _, err := db.pool.Exec(ctx, query, userID)
+        return err
 }
 
-// GetUserStats returns betting statistics for a user
-func (db *PostgresDB) GetUserStats(userID string) (bets int, wonBets int, settledBets int, avgOdds float64, err error) {
+func (db *PostgresDB) IncrementUserTokenVersion(ctx context.Context, userID string) error {
+        query := `UPDATE users SET token_version = token_version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT user stats", []interface{}{userID}, time.Since(start))
+                db.logger.LogSQL("UPDATE user token_version", query, []interface{}{userID}, time.Since(start))
         }()
 
-        query := `
-                SELECT 
-                        COUNT(*) as bets,
-                        COALESCE(SUM(CASE WHEN status = 'won' THEN 1 ELSE 0 END), 0) as won_bets,
-                        COALESCE(SUM(CASE WHEN status IN ('won','lost') THEN 1 ELSE 0 END), 0) as settled_bets,
-                        COALESCE(AVG(odds), 0) as avg_odds
-                FROM bets WHERE user_id = $1`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        err = db.pool.QueryRow(ctx, query, userID).Scan(&bets, &wonBets, &settledBets, &avgOdds)
-        return
+        _, err := db.pool.Exec(ctx, query, userID)
+        return err
 }
 
-// GetDatabaseStats returns database statistics
-func (db *PostgresDB) GetDatabaseStats() (map[string]int, error) {
+// SetUserDisabled soft-deletes (disabled) or restores a user
+// Disabling also bumps token_version so outstanding access tokens stop working
+func (db *PostgresDB) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+        query := `UPDATE users SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+        if disabled {
+                query = `UPDATE users
+                         SET deleted_at = COALESCE(deleted_at, CURRENT_TIMESTAMP), token_version = token_version + 1,
+                             updated_at = CURRENT_TIMESTAMP
+                         WHERE id = $1`
+        }
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT database stats", nil, time.Since(start))
+                db.logger.LogSQL("UPDATE user deleted_at", query, []interface{}{userID}, time.Since(start))
         }()
 
-        stats := make(map[string]int)
-
-        ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        var count int
-
-        // Get users count
-        err := db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+        result, err := db.pool.Exec(ctx, query, userID)
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return ErrUserNotFound // Not a UUID, so no such user
+        }
         if err != nil {
-                return nil, fmt.Errorf("failed to get users count: %w", err)
+                return err
         }
-        stats["users"] = count
+        if result.RowsAffected() == 0 {
+                return ErrUserNotFound
+        }
+        return nil
+}
 
-        // Get refresh tokens count (replaces sessions)
-        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM refresh_tokens").Scan(&count)
+// recordLedger writes one balance change inside tx; amount is signed and
+// balanceAfter is the balance once it was applied
+func recordLedger(ctx context.Context, tx pgx.Tx, userID, entryType string, amount, balanceAfter float64, reference string) error {
+        _, err := tx.Exec(ctx, `
+                INSERT INTO ledger (user_id, type, amount, balance_after, reference)
+                VALUES ($1, $2, $3, $4, NULLIF($5, ''))`,
+                userID, entryType, amount, balanceAfter, reference)
         if err != nil {
-                return nil, fmt.Errorf("failed to get refresh tokens count: %w", err)
+                return fmt.Errorf("failed to record ledger entry: %w", err)
         }
-        stats["sessions"] = count // Keep "sessions" key for backward compatibility
+        return nil
+}
 
-        // Get bets count
-        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM bets").Scan(&count)
+// recordNotification adds an entry to a user's inbox inside tx, so it commits with the event
+func recordNotification(ctx context.Context, tx pgx.Tx, userID, notificationType string, payload interface{}) error {
+        _, err := tx.Exec(ctx, `INSERT INTO notifications (user_id, type, payload) VALUES ($1, $2, $3)`,
+                userID, notificationType, payload)
         if err != nil {
-                return nil, fmt.Errorf("failed to get bets count: %w", err)
+                return fmt.Errorf("failed to record notification: %w", err)
         }
-        stats["bets"] = count
+        return nil
+}
 
-        // Get matches count (assuming epl_matches table)
-        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM epl_matches").Scan(&count)
+// creditUser adds a signed amount to a user's balance inside tx and records it in the ledger
+func creditUser(ctx context.Context, tx pgx.Tx, userID, entryType string, amount float64, reference string) (float64, error) {
+        var balance float64
+        err := tx.QueryRow(ctx, `UPDATE users SET money = money + $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING money`,
+                amount, userID).Scan(&balance)
         if err != nil {
-                // If epl_matches doesn't exist, try matches table or set to 0
-                stats["matches"] = 0
-                db.logger.LogWarning("epl_matches table not found, matches count set to 0")
-        } else {
-                stats["matches"] = count
+                return 0, notFound(err, ErrUserNotFound)
         }
-
-        return stats, nil
+        return balance, recordLedger(ctx, tx, userID, entryType, amount, balance, reference)
 }
 
-// Admin methods
-func (db *PostgresDB) GetAdminByUsername(username string) (*Admin, error) {
+// GetLedger pages a user's balance history, newest first, and returns the total number of entries
+func (db *PostgresDB) GetLedger(ctx context.Context, userID string, limit, offset int) ([]LedgerEntry, int, error) {
+        query := `
+                SELECT id, type, amount, balance_after, COALESCE(reference, ''), created_at
+                FROM ledger WHERE user_id = $1
+                ORDER BY id DESC
+                LIMIT $2 OFFSET $3`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT admin by username", []interface{}{username}, time.Since(start))
+                db.logger.LogSQL("SELECT ledger", query, []interface{}{userID, limit, offset}, time.Since(start))
         }()
 
-        query := `SELECT id, username, email, password_hash, is_active, last_login, created_at
-                FROM admins WHERE username = $1 AND is_active = true`
-
-        var admin Admin
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
+        var entries []LedgerEntry
+        var total int
+        err := db.withRetry(ctx, "SELECT ledger", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
 
-        err := db.pool.QueryRow(ctx, query, username).Scan(
-                &admin.ID, &admin.Username, &admin.Email, &admin.PasswordHash,
-                &admin.IsActive, &admin.LastLogin, &admin.CreatedAt,
-        )
+                rows, err := db.pool.Query(ctx, query, userID, limit, offset)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                entries = nil // Reset on retry
+                for rows.Next() {
+                        var entry LedgerEntry
+                        if err := rows.Scan(&entry.ID, &entry.Type, &entry.Amount, &entry.BalanceAfter, &entry.Reference, &entry.CreatedAt); err != nil {
+                                return err
+                        }
+                        entries = append(entries, entry)
+                }
+                if err := rows.Err(); err != nil {
+                        return err
+                }
 
+                return db.pool.QueryRow(ctx, `SELECT COUNT(*) FROM ledger WHERE user_id = $1`, userID).Scan(&total)
+        })
         if err != nil {
-                return nil, err
+                return nil, 0, err
         }
-
-        return &admin, nil
+        return entries, total, nil
 }
 
-func (db *PostgresDB) UpdateAdminLastLogin(adminID string) error {
+// AdjustUserBalance applies a signed admin correction and records it in balance_adjustments
+// Returns the new balance; the update and the record commit together
+func (db *PostgresDB) AdjustUserBalance(ctx context.Context, userID, adminID string, amount float64, reason string) (float64, error) {
+        query := `
+                UPDATE users SET money = money + $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2 AND money + $1 >= 0
+                RETURNING money`
+
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("UPDATE admin last_login", []interface{}{adminID}, time.Since(start))
+                db.logger.LogSQL("UPDATE user balance (admin)", query, []interface{}{amount, userID}, time.Since(start))
         }()
 
-        query := `UPDATE admins SET last_login = CURRENT_TIMESTAMP WHERE id = $1`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        _, err := db.pool.Exec(ctx, query, adminID)
-        return err
-}
-
-// Match sync methods
-func (db *PostgresDB) UpsertMatch(match *Match) (*Match, error) {
-        start := time.Now()
-        defer func() {
-                db.logger.LogSQL("UPSERT match", []interface{}{match.APIID}, time.Since(start))
-        }()
-
-        // Check if match exists
-        existingMatch, err := db.GetMatchByAPIID(match.APIID)
-        if err == nil && existingMatch != nil {
-                // Update existing match
-                return db.UpdateMatchByAPIID(match.APIID, match)
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return 0, err
         }
+        defer tx.Rollback(ctx)
 
-        // Create new match
-        query := `
-                INSERT INTO epl_matches (
-                        api_id, home_team, away_team, commence_time,
-                        home_score, away_score, home_odds, draw_odds, away_odds,
-                        completed, calculated, result
-                )
-                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-                RETURNING id, api_id, home_team, away_team, commence_time,
-                          home_odds, draw_odds, away_odds, completed, home_score, away_score, calculated, result`
-
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
-
-        var resultMatch Match
-        homeScore := -1
-        awayScore := -1
-        if match.HomeScore != nil {
-                homeScore = *match.HomeScore
+        // Zero rows means either no such user or a debit larger than the balance
+        var newBalance float64
+        err = tx.QueryRow(ctx, query, amount, userID).Scan(&newBalance)
+        if errors.Is(err, pgx.ErrNoRows) {
+                var exists bool
+                if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+                        return 0, err
+                }
+                if !exists {
+                        return 0, ErrUserNotFound
+                }
+                return 0, ErrInsufficientFunds
         }
-        if match.AwayScore != nil {
-                awayScore = *match.AwayScore
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return 0, ErrUserNotFound // Not a UUID, so no such user
+        }
+        if err != nil {
+                return 0, err
         }
 
-        err = db.pool.QueryRow(ctx, query,
-                match.APIID, match.HomeTeam, match.AwayTeam, match.CommenceTime,
-                homeScore, awayScore, match.HomeOdds, match.DrawOdds, match.AwayOdds,
-                match.Completed, match.Calculated, match.Result,
-        ).Scan(
-                &resultMatch.ID, &resultMatch.APIID, &resultMatch.HomeTeam, &resultMatch.AwayTeam,
-                &resultMatch.CommenceTime, &resultMatch.HomeOdds, &resultMatch.DrawOdds,
-                &resultMatch.AwayOdds, &resultMatch.Completed, &resultMatch.HomeScore,
-                &resultMatch.AwayScore, &resultMatch.Calculated, &resultMatch.Result,
-        )
-
+        var adjustmentID string
+        err = tx.QueryRow(ctx, `
+                INSERT INTO balance_adjustments (user_id, admin_id, amount, reason, balance_after)
+                VALUES ($1, $2, $3, $4, $5)
+                RETURNING id`,
+                userID, adminID, amount, reason, newBalance).Scan(&adjustmentID)
         if err != nil {
-                return nil, err
+                return 0, fmt.Errorf("failed to record balance adjustment: %w", err)
+        }
+        if err := recordLedger(ctx, tx, userID, LedgerAdminAdjustment, amount, newBalance, adjustmentID); err != nil {
+                return 0, err
         }
 
-        return &resultMatch, nil
+        if err := tx.Commit(ctx); err != nil {
+                return 0, err
+        }
+        return newBalance, nil
 }
 
-func (db *PostgresDB) GetMatchByAPIID(apiID string) (*Match, error) {
-        start := time.Now()
-        defer func() {
-                db.logger.LogSQL("SELECT match by API ID", []interface{}{apiID}, time.Since(start))
-        }()
+// Bet methods
 
-        query := `SELECT id, api_id, home_team, away_team, commence_time,
-                         home_odds, draw_odds, away_odds, completed, home_score, away_score, calculated, result
-                  FROM epl_matches WHERE api_id = $1`
+// betMatchScoreColumns selects the joined match's final score and result for a bet listing
+// Scores of matches still in play (or the -1 "missing" marker) are left out
+const betMatchScoreColumns = `CASE WHEN m.completed AND m.home_score >= 0 AND m.away_score >= 0 THEN m.home_score END,
+                                   CASE WHEN m.completed AND m.home_score >= 0 AND m.away_score >= 0 THEN m.away_score END,
+                                   m.result`
 
-        var match Match
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
+func (db *PostgresDB) GetUserBets(ctx context.Context, userID string, playerNickname string, page BetPage) ([]Bet, error) {
+        start := time.Now()
 
-        err := db.pool.QueryRow(ctx, query, apiID).Scan(
-                &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
-                &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
-                &match.AwayOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
-                &match.Calculated, &match.Result,
-        )
+        var query string
+        var args []interface{}
 
-        if err != nil {
-                return nil, err
+        if playerNickname != "" {
+                // Get bets for another player
+                query = `
+                        SELECT b.bet_id, b.user_id, b.match_id, b.bet_type, b.bet_amount,
+                                   b.odds, b.potential_win, b.status, b.home_team, b.away_team, b.created_at,
+                                   b.settled_at, m.commence_time, ` + betMatchScoreColumns + `
+                        FROM bets b
+                        JOIN users u ON b.user_id = u.id
+                        LEFT JOIN epl_matches m ON b.match_id = m.api_id
+                        WHERE u.nickname = $1`
+                args = []interface{}{playerNickname}
+        } else {
+                // Get bets for current user
+                query = `
+                        SELECT b.bet_id, b.user_id, b.match_id, b.bet_type, b.bet_amount,
+                                   b.odds, b.potential_win, b.status, b.home_team, b.away_team, b.created_at,
+                                   b.settled_at, m.commence_time, ` + betMatchScoreColumns + `
+                        FROM bets b
+                        LEFT JOIN epl_matches m ON b.match_id = m.api_id
+                        WHERE b.user_id = $1`
+                args = []interface{}{userID}
         }
 
-        return &match, nil
-}
+        // Keyset paging: the row comparison walks idx_bets_user_id_created_at_bet_id from the cursor,
+        // so deep pages cost the same as the first and bets placed meanwhile do not shift them
+        if page.After != nil {
+                args = append(args, page.After.CreatedAt, page.After.BetID)
+                query += fmt.Sprintf(" AND (b.created_at, b.bet_id) < ($%d, $%d::uuid)", len(args)-1, len(args))
+        }
+        query += " ORDER BY b.created_at DESC, b.bet_id DESC"
+        if page.Limit > 0 {
+                args = append(args, page.Limit)
+                query += fmt.Sprintf(" LIMIT $%d", len(args))
+                if page.After == nil && page.Offset > 0 {
+                        args = append(args, page.Offset)
+                        query += fmt.Sprintf(" OFFSET $%d", len(args))
+                }
+        }
 
-func (db *PostgresDB) UpdateMatchByAPIID(apiID string, match *Match) (*Match, error) {
-        start := time.Now()
         defer func() {
-                db.logger.LogSQL("UPDATE match by API ID", []interface{}{apiID}, time.Since(start))
+                db.logger.LogSQL("SELECT bets", query, args, time.Since(start))
         }()
 
-        // Build dynamic update query
-        updates := []string{}
-        values := []interface{}{}
-        paramCount := 1
+        var bets []Bet
+        err := db.withRetry(ctx, "SELECT bets", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
 
-        if match.HomeTeam != "" {
-                updates = append(updates, fmt.Sprintf("home_team = $%d", paramCount))
-                values = append(values, match.HomeTeam)
-                paramCount++
+                rows, err := db.pool.Query(ctx, query, args...)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                bets = nil // Reset on retry
+                for rows.Next() {
+                        var bet Bet
+                        err := rows.Scan(
+                                &bet.BetID, &bet.UserID, &bet.MatchID, &bet.BetType,
+                                &bet.BetAmount, &bet.Odds, &bet.PotentialWin, &bet.Status,
+                                &bet.HomeTeam, &bet.AwayTeam, &bet.CreatedAt, &bet.SettledAt, &bet.CommenceTime,
+                                &bet.HomeScore, &bet.AwayScore, &bet.Result,
+                        )
+                        if err != nil {
+                                return err
+                        }
+                        bets = append(bets, bet)
+                }
+                return rows.Err()
+        })
+
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return nil, ErrInvalidBetCursor // Cursor bet ID is not a UUID
         }
-        if match.AwayTeam != "" {
-                updates = append(updates, fmt.Sprintf("away_team = $%d", paramCount))
-                values = append(values, match.AwayTeam)
-                paramCount++
+        return bets, err
+}
+
+// PlaceBet debits the stake and inserts the bet in one transaction
+// The debit is conditional on sufficient funds, so concurrent bets cannot overdraw the account
+func (db *PostgresDB) PlaceBet(ctx context.Context, bet *Bet) (*Bet, float64, error) {
+        placed, balance, err := db.PlaceBets(ctx, []*Bet{bet})
+        if err != nil {
+                return nil, 0, err
         }
-        if !match.CommenceTime.IsZero() {
-                updates = append(updates, fmt.Sprintf("commence_time = $%d", paramCount))
-                values = append(values, match.CommenceTime)
-                paramCount++
+        return placed[0], balance, nil
+}
+
+// PlaceBets debits the total stake and inserts every bet in one transaction
+// All bets must belong to the same user; ErrInsufficientFunds if the balance is too low
+func (db *PostgresDB) PlaceBets(ctx context.Context, bets []*Bet) ([]*Bet, float64, error) {
+        query := `
+                INSERT INTO bets (user_id, match_id, bet_type, bet_amount, odds, potential_win, status, home_team, away_team, created_at)
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+                RETURNING bet_id`
+
+        userID := bets[0].UserID
+        var stake Cents
+        for _, bet := range bets {
+                stake += toCents(bet.BetAmount)
         }
-        if match.HomeOdds != nil {
-                updates = append(updates, fmt.Sprintf("home_odds = $%d", paramCount))
-                values = append(values, *match.HomeOdds)
-                paramCount++
+        total := stake.Float64()
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("INSERT bets", query, []interface{}{userID, len(bets)}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return nil, 0, err
         }
-        if match.DrawOdds != nil {
-                updates = append(updates, fmt.Sprintf("draw_odds = $%d", paramCount))
-                values = append(values, *match.DrawOdds)
-                paramCount++
+        defer tx.Rollback(ctx)
+
+        // Atomic debit of the total stake - zero rows means the balance was too low
+        var newBalance float64
+        debitQuery := `
+                UPDATE users SET money = money - $1, updated_at = CURRENT_TIMESTAMP
+                WHERE id = $2 AND money >= $1
+                RETURNING money`
+        err = tx.QueryRow(ctx, debitQuery, total, userID).Scan(&newBalance)
+        if errors.Is(err, pgx.ErrNoRows) {
+                return nil, 0, ErrInsufficientFunds
         }
-        if match.AwayOdds != nil {
-                updates = append(updates, fmt.Sprintf("away_odds = $%d", paramCount))
-                values = append(values, *match.AwayOdds)
-                paramCount++
+        if err != nil {
+                return nil, 0, err
         }
-        if match.HomeScore != nil {
-                updates = append(updates, fmt.Sprintf("home_score = $%d", paramCount))
-                values = append(values, *match.HomeScore)
-                paramCount++
+
+        // One ledger entry per bet, with the running balance
+        balance := addMoney(newBalance, total)
+        for _, bet := range bets {
+                err = tx.QueryRow(ctx, query,
+                        bet.UserID, bet.MatchID, bet.BetType, bet.BetAmount,
+                        bet.Odds, bet.PotentialWin, bet.Status, bet.HomeTeam, bet.AwayTeam,
+                ).Scan(&bet.BetID)
+                if err != nil {
+                        return nil, 0, err
+                }
+
+                balance = addMoney(balance, -bet.BetAmount)
+                if err := recordLedger(ctx, tx, bet.UserID, LedgerBetStake, -bet.BetAmount, balance, bet.BetID); err != nil {
+                        return nil, 0, err
+                }
         }
-        if match.AwayScore != nil {
-                updates = append(updates, fmt.Sprintf("away_score = $%d", paramCount))
-                values = append(values, *match.AwayScore)
-                paramCount++
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, 0, err
         }
-        updates = append(updates, fmt.Sprintf("completed = $%d", paramCount))
-        values = append(values, match.Completed)
-        paramCount++
 
-        updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+        return bets, newBalance, nil
+}
 
-        query := fmt.Sprintf(`
-                UPDATE epl_matches
-                SET %s
-                WHERE api_id = $%d
-                RETURNING id, api_id, home_team, away_team, commence_time,
-                          home_odds, draw_odds, away_odds, completed, home_score, away_score, calculated, result`,
-                strings.Join(updates, ", "), paramCount)
+func (db *PostgresDB) GetMatchByID(ctx context.Context, matchID string) (*Match, error) {
+        return db.GetMatchByAPIID(ctx, matchID)
+}
 
-        values = append(values, apiID)
+// Match methods
+func (db *PostgresDB) GetMatches(ctx context.Context) ([]Match, error) {
+        start := time.Now()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
+        query := `
+                SELECT id, api_id, home_team, away_team, commence_time,
+                           home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source
+                FROM epl_matches
+                WHERE home_odds IS NOT NULL AND away_odds IS NOT NULL
+                        AND home_odds != 0 AND away_odds != 0 AND (draw_odds IS NULL OR draw_odds != 0)
+                        AND commence_time > CURRENT_TIMESTAMP
+                ORDER BY commence_time ASC`
 
-        var resultMatch Match
-        err := db.pool.QueryRow(ctx, query, values...).Scan(
-                &resultMatch.ID, &resultMatch.APIID, &resultMatch.HomeTeam, &resultMatch.AwayTeam,
-                &resultMatch.CommenceTime, &resultMatch.HomeOdds, &resultMatch.DrawOdds,
-                &resultMatch.AwayOdds, &resultMatch.Completed, &resultMatch.HomeScore,
-                &resultMatch.AwayScore, &resultMatch.Calculated, &resultMatch.Result,
-        )
+        defer func() {
+                db.logger.LogSQL("SELECT matches", query, nil, time.Since(start))
+        }()
 
-        if err != nil {
-                return nil, err
-        }
+        var matches []Match
+        err := db.withRetry(ctx, "SELECT matches", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
 
-        return &resultMatch, nil
+                rows, err := db.pool.Query(ctx, query)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                matches = nil // Reset on retry
+                for rows.Next() {
+                        var match Match
+                        err := rows.Scan(
+                                &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
+                                &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
+                                &match.AwayOdds, &match.BTTSYesOdds, &match.BTTSNoOdds, &match.CorrectScoreOdds, &match.TotalsOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
+                                &match.Calculated, &match.Result, &match.CreatedAt, &match.UpdatedAt, &match.OddsUpdatedAt, &match.ShootoutWinner, &match.OddsSource,
+                        )
+                        if err != nil {
+                                return err
+                        }
+                        matches = append(matches, match)
+                }
+                return rows.Err()
+        })
+
+        return matches, err
+}
+
+// matchStatusConditions maps a match list status to its WHERE clause
+var matchStatusConditions = map[string]string{
+        MatchStatusUpcoming: `home_odds IS NOT NULL AND away_odds IS NOT NULL
+                AND home_odds != 0 AND away_odds != 0 AND (draw_odds IS NULL OR draw_odds != 0)
+                AND commence_time > CURRENT_TIMESTAMP`,
+        MatchStatusLive:     `commence_time <= CURRENT_TIMESTAMP AND completed = false`,
+        MatchStatusFinished: `completed = true AND home_score IS NOT NULL AND away_score IS NOT NULL`,
 }
 
-func (db *PostgresDB) GetCompletedUncalculatedMatches() ([]Match, error) {
+// ListMatches returns a page of matches for the given filter and the total count
+func (db *PostgresDB) ListMatches(ctx context.Context, filter MatchFilter) ([]Match, int, error) {
+        var query string
+        var args []interface{}
         start := time.Now()
         defer func() {
-                db.logger.LogSQL("SELECT completed uncalculated matches", nil, time.Since(start))
+                db.logger.LogSQL("SELECT matches (filtered)", query, args, time.Since(start))
         }()
 
-        query := `SELECT id, api_id, home_team, away_team, commence_time,
-                         home_odds, draw_odds, away_odds, completed, home_score, away_score, calculated, result
-                  FROM epl_matches
-                  WHERE completed = TRUE AND calculated = FALSE
-                        AND home_score IS NOT NULL AND away_score IS NOT NULL
-                        AND home_score != -1 AND away_score != -1`
+        condition, ok := matchStatusConditions[filter.Status]
+        if !ok {
+                return nil, 0, fmt.Errorf("unknown match status: %s", filter.Status)
+        }
 
-        ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+        conditions := []string{"(" + condition + ")"}
+        if filter.From != nil {
+                args = append(args, *filter.From)
+                conditions = append(conditions, fmt.Sprintf("commence_time >= $%d", len(args)))
+        }
+        if filter.To != nil {
+                args = append(args, *filter.To)
+                conditions = append(conditions, fmt.Sprintf("commence_time < $%d", len(args)))
+        }
+        if filter.Team != "" {
+                args = append(args, "%"+escapeLikePattern(filter.Team)+"%")
+                conditions = append(conditions, fmt.Sprintf("(home_team ILIKE $%d OR away_team ILIKE $%d)", len(args), len(args)))
+        }
+        where := strings.Join(conditions, " AND ")
+
+        order := "ASC"
+        if filter.SortDesc {
+                order = "DESC"
+        }
+
+        ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
         defer cancel()
 
-        rows, err := db.pool.Query(ctx, query)
+        var total int
+        err := db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM epl_matches WHERE "+where, args...).Scan(&total)
         if err != nil {
-                return nil, err
+                return nil, 0, err
+        }
+
+        args = append(args, filter.Limit, filter.Offset)
+        query = `
+                SELECT id, api_id, home_team, away_team, commence_time,
+                           home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source
+                FROM epl_matches
+                WHERE ` + where + `
+                ORDER BY commence_time ` + order + `, id ` + order + fmt.Sprintf(`
+                LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+        rows, err := db.pool.Query(ctx, query, args...)
+        if err != nil {
+                return nil, 0, err
         }
         defer rows.Close()
 
@@ -932,93 +1371,1666 @@ func (db *PostgresDB) GetCompletedUncalculatedMatches() ([]Match, error) {
                 err := rows.Scan(
                         &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
                         &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
-                        &match.AwayOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
-                        &match.Calculated, &match.Result,
+                        &match.AwayOdds, &match.BTTSYesOdds, &match.BTTSNoOdds, &match.CorrectScoreOdds, &match.TotalsOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
+                        &match.Calculated, &match.Result, &match.CreatedAt, &match.UpdatedAt, &match.OddsUpdatedAt, &match.ShootoutWinner, &match.OddsSource,
                 )
                 if err != nil {
-                        return nil, err
+                        return nil, 0, err
                 }
                 matches = append(matches, match)
         }
 
-        return matches, rows.Err()
+        return matches, total, rows.Err()
+}
+
+// escapeLikePattern escapes LIKE wildcards so user input matches literally
+func escapeLikePattern(value string) string {
+        return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// Players methods
+
+// playerSortOrders are the ORDER BY clauses of GetPlayers per PlayerSort* order;
+// id breaks ties so that players level on every key keep one order and no page repeats or skips them
+var playerSortOrders = map[string]string{
+        PlayerSortBets:   "bets DESC, money DESC, id ASC",
+        PlayerSortProfit: "profit DESC, bets DESC, id ASC",
+        PlayerSortROI:    "roi DESC, profit DESC, id ASC",
 }
 
-func (db *PostgresDB) UpdateMatchCalculated(apiID string, result string) error {
+// GetPlayers pages through non-guest players with their betting aggregates
+func (db *PostgresDB) GetPlayers(ctx context.Context, limit, offset int, sort string) ([]PlayerDisplay, error) {
         start := time.Now()
+
+        order, ok := playerSortOrders[sort]
+        if !ok {
+                order = playerSortOrders[PlayerSortBets]
+        }
+        query := `
+                SELECT id, nickname, money, topup, created_at, updated_at, bets, won_bets, settled_bets, push_bets, avg_odds, profit, roi
+                FROM (
+                        SELECT
+                                u.id, u.nickname, u.money, u.topup, u.created_at, u.updated_at,
+                                COUNT(b.bet_id) as bets,
+                                COALESCE(SUM(CASE WHEN b.status = 'won' THEN 1 ELSE 0 END), 0) as won_bets,
+                                COALESCE(SUM(CASE WHEN b.status IN ('won','lost') THEN 1 ELSE 0 END), 0) as settled_bets,
+                                COALESCE(SUM(CASE WHEN b.status = 'push' THEN 1 ELSE 0 END), 0) as push_bets,
+                                AVG(b.odds) as avg_odds,
+                                COALESCE(SUM(CASE WHEN b.status = 'won' THEN b.potential_win - b.bet_amount
+                                                  WHEN b.status = 'lost' THEN -b.bet_amount ELSE 0 END), 0) as profit,
+                                COALESCE(SUM(CASE WHEN b.status = 'won' THEN b.potential_win - b.bet_amount
+                                                  WHEN b.status = 'lost' THEN -b.bet_amount ELSE 0 END)
+                                         / NULLIF(SUM(CASE WHEN b.status IN ('won','lost') THEN b.bet_amount ELSE 0 END), 0) * 100, 0) as roi
+                        FROM users u
+                        LEFT JOIN bets b ON u.id = b.user_id
+                        WHERE NOT u.is_guest
+                        GROUP BY u.id, u.nickname, u.money, u.topup, u.created_at, u.updated_at
+                ) players
+                ORDER BY ` + order + `
+                LIMIT $1 OFFSET $2`
+
         defer func() {
-                db.logger.LogSQL("UPDATE match calculated", []interface{}{apiID, result}, time.Since(start))
+                db.logger.LogSQL("SELECT players", query, []interface{}{limit, offset}, time.Since(start))
         }()
 
-        query := `UPDATE epl_matches SET calculated = TRUE, result = $1, updated_at = NOW() WHERE api_id = $2`
+        var players []PlayerDisplay
+        err := db.withRetry(ctx, "SELECT players", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-        defer cancel()
+                rows, err := db.pool.Query(ctx, query, limit, offset)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                players = nil // Reset on retry
+                for rows.Next() {
+                        var player PlayerDisplay
+                        var avgOdds *float64
+                        var createdAt, updatedAt time.Time
+
+                        err := rows.Scan(
+                                &player.ID, &player.Nickname, &player.Money, &player.Topup,
+                                &createdAt, &updatedAt, &player.Bets, &player.WonBets,
+                                &player.SettledBets, &player.PushBets, &avgOdds, &player.Profit, &player.ROI,
+                        )
+                        if err != nil {
+                                return err
+                        }
+
+                        // Convert timestamps to ISO strings
+                        player.Created = createdAt.Format(time.RFC3339)
+                        player.Updated = updatedAt.Format(time.RFC3339)
+
+                        // Handle nullable avg_odds
+                        if avgOdds != nil {
+                                player.AvgOdds = *avgOdds
+                        }
+
+                        players = append(players, player)
+                }
+                return rows.Err()
+        })
 
-        _, err := db.pool.Exec(ctx, query, result, apiID)
-        return err
+        return players, err
+}
+
+func (db *PostgresDB) GetTotalPlayers(ctx context.Context) (int, error) {
+        query := `SELECT COUNT(*) as total FROM users WHERE NOT is_guest`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT COUNT players", query, nil, time.Since(start))
+        }()
+
+        var total int
+        err := db.withRetry(ctx, "SELECT COUNT players", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                return db.pool.QueryRow(ctx, query).Scan(&total)
+        })
+        return total, err
 }
 
-func (db *PostgresDB) UpdateBetsStatusAndUserMoney(matchAPIID string, result string) error {
+// GetUserStats returns betting statistics for a user
+func (db *PostgresDB) GetUserStats(ctx context.Context, userID string) (bets int, wonBets int, settledBets int, pushBets int, avgOdds float64, err error) {
         start := time.Now()
+
+        query := `
+                SELECT 
+                        COUNT(*) as bets,
+                        COALESCE(SUM(CASE WHEN status = 'won' THEN 1 ELSE 0 END), 0) as won_bets,
+                        COALESCE(SUM(CASE WHEN status IN ('won','lost') THEN 1 ELSE 0 END), 0) as settled_bets,
+                        COALESCE(SUM(CASE WHEN status = 'push' THEN 1 ELSE 0 END), 0) as push_bets,
+                        COALESCE(AVG(odds), 0) as avg_odds
+                FROM bets WHERE user_id = $1`
+
         defer func() {
-                db.logger.LogSQL("UPDATE bets status and user money", []interface{}{matchAPIID, result}, time.Since(start))
+                db.logger.LogSQL("SELECT user stats", query, []interface{}{userID}, time.Since(start))
         }()
 
-        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
         defer cancel()
 
-        // Start transaction
-        tx, err := db.pool.Begin(ctx)
-        if err != nil {
-                return err
-        }
-        defer tx.Rollback(ctx)
+        err = db.pool.QueryRow(ctx, query, userID).Scan(&bets, &wonBets, &settledBets, &pushBets, &avgOdds)
+        return
+}
 
-        // Update bets status
-        updateBetsQuery := `
-                UPDATE bets
-                SET status = CASE WHEN bet_type = $1 THEN 'won' ELSE 'lost' END
-                WHERE match_id = $2 AND status = 'pending'
-                RETURNING user_id, potential_win, status`
+// GetBettingRecords aggregates the bets of several users in one query, keyed by user ID
+func (db *PostgresDB) GetBettingRecords(ctx context.Context, userIDs []string) (map[string]BettingRecord, error) {
+        query := `
+                SELECT
+                        user_id,
+                        COUNT(*) as bets,
+                        COALESCE(SUM(CASE WHEN status = 'won' THEN 1 ELSE 0 END), 0) as won_bets,
+                        COALESCE(SUM(CASE WHEN status IN ('won','lost') THEN 1 ELSE 0 END), 0) as settled_bets,
+                        COALESCE(AVG(odds), 0) as avg_odds,
+                        COALESCE(SUM(CASE WHEN status IN ('won','lost') THEN bet_amount ELSE 0 END), 0) as staked,
+                        COALESCE(SUM(CASE WHEN status = 'won' THEN potential_win ELSE 0 END), 0) as returned
+                FROM bets WHERE user_id = ANY($1)
+                GROUP BY user_id`
 
-        rows, err := tx.Query(ctx, updateBetsQuery, result, matchAPIID)
-        if err != nil {
-                return err
-        }
-        defer rows.Close()
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT betting records", query, []interface{}{userIDs}, time.Since(start))
+        }()
 
-        // Collect winning bets
-        type winningBet struct {
-                userID      string
-                potentialWin float64
-        }
-        var winningBets []winningBet
+        records := make(map[string]BettingRecord)
+        err := db.withRetry(ctx, "SELECT betting records", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
 
-        for rows.Next() {
-                var userID string
-                var potentialWin float64
-                var status string
-                if err := rows.Scan(&userID, &potentialWin, &status); err != nil {
+                rows, err := db.pool.Query(ctx, query, userIDs)
+                if err != nil {
                         return err
                 }
-                if status == "won" {
-                        winningBets = append(winningBets, winningBet{userID: userID, potentialWin: potentialWin})
+                defer rows.Close()
+
+                clear(records) // Reset on retry
+                for rows.Next() {
+                        var userID string
+                        var record BettingRecord
+                        if err := rows.Scan(&userID, &record.Bets, &record.WonBets, &record.SettledBets,
+                                &record.AvgOdds, &record.Staked, &record.Returned); err != nil {
+                                return err
+                        }
+                        records[userID] = record
                 }
+                return rows.Err()
+        })
+        if err != nil {
+                return nil, err
         }
+        return records, nil
+}
 
-        // Update user money for winners
-        for _, bet := range winningBets {
-                updateMoneyQuery := `UPDATE users SET money = money + $1 WHERE id = $2`
-                if _, err := tx.Exec(ctx, updateMoneyQuery, bet.potentialWin, bet.userID); err != nil {
-                        return err
-                }
-        }
+// CountPendingBets returns how many unsettled bets a user has, including bets held for review
+func (db *PostgresDB) CountPendingBets(ctx context.Context, userID string) (int, error) {
+        query := `SELECT COUNT(*) FROM bets WHERE user_id = $1 AND status IN ('pending', 'review')`
 
-        // Commit transaction
-        if err := tx.Commit(ctx); err != nil {
-                return err
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT COUNT pending bets", query, []interface{}{userID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        var count int
+        err := db.pool.QueryRow(ctx, query, userID).Scan(&count)
+        return count, err
+}
+
+// GetUserLimits returns a user's self-limits, empty when none were set
+func (db *PostgresDB) GetUserLimits(ctx context.Context, userID string) (*UserLimits, error) {
+        query := `
+                SELECT daily_wager_limit, weekly_wager_limit, daily_loss_limit, weekly_loss_limit, self_excluded_until
+                FROM user_limits WHERE user_id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT user limits", query, []interface{}{userID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        limits := &UserLimits{UserID: userID}
+        err := db.pool.QueryRow(ctx, query, userID).Scan(
+                &limits.DailyWagerLimit, &limits.WeeklyWagerLimit,
+                &limits.DailyLossLimit, &limits.WeeklyLossLimit, &limits.SelfExcludedUntil,
+        )
+        if errors.Is(err, pgx.ErrNoRows) {
+                return &UserLimits{UserID: userID}, nil
+        }
+        if err != nil {
+                return nil, err
         }
+        return limits, nil
+}
 
-        return nil
-}
\ No newline at end of file
+// SetUserLimits creates or replaces a user's self-limits
+func (db *PostgresDB) SetUserLimits(ctx context.Context, limits *UserLimits) error {
+        query := `
+                INSERT INTO user_limits (user_id, daily_wager_limit, weekly_wager_limit, daily_loss_limit, weekly_loss_limit, self_excluded_until)
+                VALUES ($1, $2, $3, $4, $5, $6)
+                ON CONFLICT (user_id) DO UPDATE SET
+                        daily_wager_limit = EXCLUDED.daily_wager_limit,
+                        weekly_wager_limit = EXCLUDED.weekly_wager_limit,
+                        daily_loss_limit = EXCLUDED.daily_loss_limit,
+                        weekly_loss_limit = EXCLUDED.weekly_loss_limit,
+                        self_excluded_until = EXCLUDED.self_excluded_until,
+                        updated_at = CURRENT_TIMESTAMP`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPSERT user limits", query, []interface{}{limits.UserID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        _, err := db.pool.Exec(ctx, query, limits.UserID, limits.DailyWagerLimit, limits.WeeklyWagerLimit,
+                limits.DailyLossLimit, limits.WeeklyLossLimit, limits.SelfExcludedUntil)
+        return err
+}
+
+// GetBetUsageSince sums a user's stakes and settled results for bets placed since the given time
+func (db *PostgresDB) GetBetUsageSince(ctx context.Context, userID string, since time.Time) (*BetUsage, error) {
+        query := `
+                SELECT
+                        COALESCE(SUM(bet_amount) FILTER (WHERE status <> 'void'), 0) AS wagered,
+                        COALESCE(SUM(CASE WHEN status = 'lost' THEN bet_amount
+                                          WHEN status = 'won' THEN bet_amount - potential_win
+                                          ELSE 0 END), 0) AS net_loss,
+                        COALESCE(SUM(bet_amount) FILTER (WHERE status IN ('pending', 'review')), 0) AS pending
+                FROM bets WHERE user_id = $1 AND created_at >= $2`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT bet usage", query, []interface{}{userID, since}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        var usage BetUsage
+        err := db.pool.QueryRow(ctx, query, userID, since).Scan(&usage.Wagered, &usage.NetLoss, &usage.Pending)
+        if err != nil {
+                return nil, err
+        }
+        return &usage, nil
+}
+
+// GetDatabaseStats returns database statistics
+func (db *PostgresDB) GetDatabaseStats(ctx context.Context) (map[string]int, error) {
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT database stats", "", nil, time.Since(start))
+        }()
+
+        stats := make(map[string]int)
+
+        ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+        defer cancel()
+
+        var count int
+
+        // Get users count
+        err := db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get users count: %w", err)
+        }
+        stats["users"] = count
+
+        // Get refresh tokens count (replaces sessions)
+        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM refresh_tokens").Scan(&count)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get refresh tokens count: %w", err)
+        }
+        stats["sessions"] = count // Keep "sessions" key for backward compatibility
+
+        // Get bets count
+        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM bets").Scan(&count)
+        if err != nil {
+                return nil, fmt.Errorf("failed to get bets count: %w", err)
+        }
+        stats["bets"] = count
+
+        // Get matches count (assuming epl_matches table)
+        err = db.pool.QueryRow(ctx, "SELECT COUNT(*) FROM epl_matches").Scan(&count)
+        if err != nil {
+                // If epl_matches doesn't exist, try matches table or set to 0
+                stats["matches"] = 0
+                db.logger.LogWarning("epl_matches table not found, matches count set to 0")
+        } else {
+                stats["matches"] = count
+        }
+
+        return stats, nil
+}
+
+// Admin methods
+func (db *PostgresDB) GetAdminByUsername(ctx context.Context, username string) (*Admin, error) {
+        query := `SELECT id, username, email, password_hash, is_active, last_login, created_at
+                FROM admins WHERE username = $1 AND is_active = true`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT admin by username", query, []interface{}{username}, time.Since(start))
+        }()
+
+        var admin Admin
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, username).Scan(
+                &admin.ID, &admin.Username, &admin.Email, &admin.PasswordHash,
+                &admin.IsActive, &admin.LastLogin, &admin.CreatedAt,
+        )
+
+        if err != nil {
+                return nil, notFound(err, ErrAdminNotFound)
+        }
+
+        return &admin, nil
+}
+
+func (db *PostgresDB) CreateAdmin(ctx context.Context, username, email, passwordHash string) (*Admin, error) {
+        start := time.Now()
+
+        query := `
+                INSERT INTO admins (username, email, password_hash)
+                VALUES ($1, $2, $3)
+                ON CONFLICT (username) DO NOTHING
+                RETURNING id, username, email, password_hash, is_active, last_login, created_at`
+
+        defer func() {
+                db.logger.LogSQL("INSERT admin", query, []interface{}{username, email}, time.Since(start))
+        }()
+
+        var admin Admin
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, username, email, passwordHash).Scan(
+                &admin.ID, &admin.Username, &admin.Email, &admin.PasswordHash,
+                &admin.IsActive, &admin.LastLogin, &admin.CreatedAt,
+        )
+
+        if errors.Is(err, pgx.ErrNoRows) {
+                return nil, ErrAdminExists
+        }
+        if err != nil {
+                return nil, err
+        }
+
+        return &admin, nil
+}
+
+func (db *PostgresDB) GetAdminByID(ctx context.Context, id string) (*Admin, error) {
+        query := `SELECT id, username, email, password_hash, is_active, last_login, created_at
+                FROM admins WHERE id = $1 AND is_active = true`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT admin by ID", query, []interface{}{id}, time.Since(start))
+        }()
+
+        var admin Admin
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, id).Scan(
+                &admin.ID, &admin.Username, &admin.Email, &admin.PasswordHash,
+                &admin.IsActive, &admin.LastLogin, &admin.CreatedAt,
+        )
+
+        if err != nil {
+                return nil, notFound(err, ErrAdminNotFound)
+        }
+
+        return &admin, nil
+}
+
+func (db *PostgresDB) UpdateAdminLastLogin(ctx context.Context, adminID string) error {
+        query := `UPDATE admins SET last_login = CURRENT_TIMESTAMP WHERE id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE admin last_login", query, []interface{}{adminID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        _, err := db.pool.Exec(ctx, query, adminID)
+        return err
+}
+
+// Admin session methods
+func (db *PostgresDB) CreateAdminSession(ctx context.Context, adminID string, token string, expiresAt time.Time) (*AdminSession, error) {
+        start := time.Now()
+
+        query := `
+                INSERT INTO admin_sessions (admin_id, token, expires_at)
+                VALUES ($1, $2, $3)
+                RETURNING id, admin_id, token, expires_at, created_at`
+
+        defer func() {
+                db.logger.LogSQL("INSERT admin_session", query, []interface{}{adminID}, time.Since(start))
+        }()
+
+        var session AdminSession
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, adminID, token, expiresAt).Scan(
+                &session.ID, &session.AdminID, &session.Token,
+                &session.ExpiresAt, &session.CreatedAt,
+        )
+
+        if err != nil {
+                return nil, err
+        }
+
+        return &session, nil
+}
+
+func (db *PostgresDB) GetAdminSessionByToken(ctx context.Context, token string) (*AdminSession, error) {
+        start := time.Now()
+
+        query := `
+                SELECT s.id, s.admin_id, s.token, s.expires_at, s.created_at
+                FROM admin_sessions s
+                WHERE s.token = $1 AND s.expires_at > CURRENT_TIMESTAMP`
+
+        defer func() {
+                db.logger.LogSQL("SELECT admin_session by token", query, []interface{}{maskToken(token)}, time.Since(start))
+        }()
+
+        var session AdminSession
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, token).Scan(
+                &session.ID, &session.AdminID, &session.Token,
+                &session.ExpiresAt, &session.CreatedAt,
+        )
+
+        if err != nil {
+                return nil, notFound(err, ErrAdminSessionNotFound)
+        }
+
+        return &session, nil
+}
+
+func (db *PostgresDB) DeleteAdminSession(ctx context.Context, token string) error {
+        query := `DELETE FROM admin_sessions WHERE token = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("DELETE admin_session", query, []interface{}{maskToken(token)}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        _, err := db.pool.Exec(ctx, query, token)
+        return err
+}
+
+// Match sync methods
+func (db *PostgresDB) UpsertMatch(ctx context.Context, match *Match) (*Match, error) {
+        query := `
+                INSERT INTO epl_matches (
+                        api_id, home_team, away_team, commence_time,
+                        home_score, away_score, home_odds, draw_odds, away_odds,
+                        btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds,
+                        completed, calculated, result, odds_updated_at, shootout_winner, odds_source
+                )
+                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+                        CASE WHEN $17 THEN CURRENT_TIMESTAMP END, $18, $19)
+                RETURNING id, api_id, home_team, away_team, commence_time,
+                          home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPSERT match", query, []interface{}{match.APIID}, time.Since(start))
+        }()
+
+        // Check if match exists
+        existingMatch, err := db.GetMatchByAPIID(ctx, match.APIID)
+        if err == nil && existingMatch != nil {
+                // Update existing match
+                return db.UpdateMatchByAPIID(ctx, match.APIID, match)
+        }
+        if err != nil && !errors.Is(err, ErrMatchNotFound) {
+                return nil, err
+        }
+
+        // Create new match
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        var resultMatch Match
+        homeScore := -1
+        awayScore := -1
+        if match.HomeScore != nil {
+                homeScore = *match.HomeScore
+        }
+        if match.AwayScore != nil {
+                awayScore = *match.AwayScore
+        }
+
+        err = db.pool.QueryRow(ctx, query,
+                match.APIID, match.HomeTeam, match.AwayTeam, match.CommenceTime,
+                homeScore, awayScore, match.HomeOdds, match.DrawOdds, match.AwayOdds,
+                match.BTTSYesOdds, match.BTTSNoOdds, match.CorrectScoreOdds, match.TotalsOdds,
+                match.Completed, match.Calculated, match.Result, hasOdds(match), match.ShootoutWinner, match.OddsSource,
+        ).Scan(
+                &resultMatch.ID, &resultMatch.APIID, &resultMatch.HomeTeam, &resultMatch.AwayTeam,
+                &resultMatch.CommenceTime, &resultMatch.HomeOdds, &resultMatch.DrawOdds,
+                &resultMatch.AwayOdds, &resultMatch.BTTSYesOdds, &resultMatch.BTTSNoOdds, &resultMatch.CorrectScoreOdds, &resultMatch.TotalsOdds, &resultMatch.Completed, &resultMatch.HomeScore,
+                &resultMatch.AwayScore, &resultMatch.Calculated, &resultMatch.Result, &resultMatch.CreatedAt, &resultMatch.UpdatedAt, &resultMatch.OddsUpdatedAt, &resultMatch.ShootoutWinner, &resultMatch.OddsSource,
+        )
+
+        // Same fixture already stored under another api_id: merge into that row
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == fixtureIndexName {
+                existing, findErr := db.FindMatchByTeams(ctx, match.HomeTeam, match.AwayTeam, match.CommenceTime, 24*time.Hour)
+                if findErr != nil {
+                        return nil, err
+                }
+                return db.UpdateMatchByAPIID(ctx, existing.APIID, mergedFixture(existing, match))
+        }
+        if err != nil {
+                return nil, err
+        }
+
+        return &resultMatch, nil
+}
+
+func (db *PostgresDB) GetMatchByAPIID(ctx context.Context, apiID string) (*Match, error) {
+        start := time.Now()
+
+        query := `SELECT id, api_id, home_team, away_team, commence_time,
+                         home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source
+                  FROM epl_matches WHERE api_id = $1`
+
+        defer func() {
+                db.logger.LogSQL("SELECT match by API ID", query, []interface{}{apiID}, time.Since(start))
+        }()
+
+        var match Match
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        err := db.pool.QueryRow(ctx, query, apiID).Scan(
+                &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
+                &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
+                &match.AwayOdds, &match.BTTSYesOdds, &match.BTTSNoOdds, &match.CorrectScoreOdds, &match.TotalsOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
+                &match.Calculated, &match.Result, &match.CreatedAt, &match.UpdatedAt, &match.OddsUpdatedAt, &match.ShootoutWinner, &match.OddsSource,
+        )
+
+        if err != nil {
+                return nil, notFound(err, ErrMatchNotFound)
+        }
+
+        return &match, nil
+}
+
+// fixtureIndexName is the unique index allowing one row per fixture (teams + kickoff date)
+const fixtureIndexName = "idx_epl_matches_fixture"
+
+// mergedFixture prepares an incoming duplicate of existing for UpdateMatchByAPIID
+// Keeps the stored api_id and completion; nil odds and scores leave the stored values alone
+func mergedFixture(existing, incoming *Match) *Match {
+        merged := *incoming
+        merged.APIID = existing.APIID
+        merged.Completed = incoming.Completed || existing.Completed
+        return &merged
+}
+
+// FindMatchByTeams finds the fixture between two teams (case-insensitive) kicking off
+// within window of commenceTime, closest first
+func (db *PostgresDB) FindMatchByTeams(ctx context.Context, homeTeam, awayTeam string, commenceTime time.Time, window time.Duration) (*Match, error) {
+        query := `SELECT id, api_id, home_team, away_team, commence_time,
+                         home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source
+                  FROM epl_matches
+                  WHERE lower(home_team) = lower($1) AND lower(away_team) = lower($2)
+                    AND commence_time BETWEEN $3 AND $4
+                  ORDER BY abs(extract(epoch FROM commence_time - $5::timestamptz))
+                  LIMIT 1`
+        params := []interface{}{homeTeam, awayTeam, commenceTime.Add(-window), commenceTime.Add(window), commenceTime}
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT match by teams", query, params, time.Since(start))
+        }()
+
+        var match Match
+        err := db.withRetry(ctx, "SELECT match by teams", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                return db.pool.QueryRow(ctx, query, params...).Scan(
+                        &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
+                        &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
+                        &match.AwayOdds, &match.BTTSYesOdds, &match.BTTSNoOdds, &match.CorrectScoreOdds, &match.TotalsOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
+                        &match.Calculated, &match.Result, &match.CreatedAt, &match.UpdatedAt, &match.OddsUpdatedAt, &match.ShootoutWinner, &match.OddsSource,
+                )
+        })
+
+        if err != nil {
+                return nil, notFound(err, ErrMatchNotFound)
+        }
+
+        return &match, nil
+}
+
+func (db *PostgresDB) UpdateMatchByAPIID(ctx context.Context, apiID string, match *Match) (*Match, error) {
+        var query string
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE match by API ID", query, []interface{}{apiID}, time.Since(start))
+        }()
+
+        // Build dynamic update query
+        updates := []string{}
+        values := []interface{}{}
+        paramCount := 1
+
+        if match.HomeTeam != "" {
+                updates = append(updates, fmt.Sprintf("home_team = $%d", paramCount))
+                values = append(values, match.HomeTeam)
+                paramCount++
+        }
+        if match.AwayTeam != "" {
+                updates = append(updates, fmt.Sprintf("away_team = $%d", paramCount))
+                values = append(values, match.AwayTeam)
+                paramCount++
+        }
+        if !match.CommenceTime.IsZero() {
+                updates = append(updates, fmt.Sprintf("commence_time = $%d", paramCount))
+                values = append(values, match.CommenceTime)
+                paramCount++
+        }
+        if match.HomeOdds != nil {
+                updates = append(updates, fmt.Sprintf("home_odds = $%d", paramCount))
+                values = append(values, *match.HomeOdds)
+                paramCount++
+        }
+        if match.DrawOdds != nil {
+                updates = append(updates, fmt.Sprintf("draw_odds = $%d", paramCount))
+                values = append(values, *match.DrawOdds)
+                paramCount++
+        }
+        if match.AwayOdds != nil {
+                updates = append(updates, fmt.Sprintf("away_odds = $%d", paramCount))
+                values = append(values, *match.AwayOdds)
+                paramCount++
+        }
+        if match.BTTSYesOdds != nil {
+                updates = append(updates, fmt.Sprintf("btts_yes_odds = $%d", paramCount))
+                values = append(values, *match.BTTSYesOdds)
+                paramCount++
+        }
+        if match.BTTSNoOdds != nil {
+                updates = append(updates, fmt.Sprintf("btts_no_odds = $%d", paramCount))
+                values = append(values, *match.BTTSNoOdds)
+                paramCount++
+        }
+        if len(match.CorrectScoreOdds) > 0 {
+                updates = append(updates, fmt.Sprintf("correct_score_odds = $%d", paramCount))
+                values = append(values, match.CorrectScoreOdds)
+                paramCount++
+        }
+        if len(match.TotalsOdds) > 0 {
+                updates = append(updates, fmt.Sprintf("totals_odds = $%d", paramCount))
+                values = append(values, match.TotalsOdds)
+                paramCount++
+        }
+        if match.HomeScore != nil {
+                updates = append(updates, fmt.Sprintf("home_score = $%d", paramCount))
+                values = append(values, *match.HomeScore)
+                paramCount++
+        }
+        if match.AwayScore != nil {
+                updates = append(updates, fmt.Sprintf("away_score = $%d", paramCount))
+                values = append(values, *match.AwayScore)
+                paramCount++
+        }
+        if match.ShootoutWinner != nil {
+                updates = append(updates, fmt.Sprintf("shootout_winner = $%d", paramCount))
+                values = append(values, *match.ShootoutWinner)
+                paramCount++
+        }
+        if match.OddsSource != nil {
+                updates = append(updates, fmt.Sprintf("odds_source = $%d", paramCount))
+                values = append(values, *match.OddsSource)
+                paramCount++
+        }
+        updates = append(updates, fmt.Sprintf("completed = $%d", paramCount))
+        values = append(values, match.Completed)
+        paramCount++
+
+        updates = append(updates, "updated_at = CURRENT_TIMESTAMP")
+        if hasOdds(match) {
+                // Odds syncs confirm the prices even when unchanged; bets on stale odds are refused
+                updates = append(updates, "odds_updated_at = CURRENT_TIMESTAMP")
+        }
+
+        query = fmt.Sprintf(`
+                UPDATE epl_matches
+                SET %s
+                WHERE api_id = $%d
+                RETURNING id, api_id, home_team, away_team, commence_time,
+                          home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source`,
+                strings.Join(updates, ", "), paramCount)
+
+        values = append(values, apiID)
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        var resultMatch Match
+        err := db.pool.QueryRow(ctx, query, values...).Scan(
+                &resultMatch.ID, &resultMatch.APIID, &resultMatch.HomeTeam, &resultMatch.AwayTeam,
+                &resultMatch.CommenceTime, &resultMatch.HomeOdds, &resultMatch.DrawOdds,
+                &resultMatch.AwayOdds, &resultMatch.BTTSYesOdds, &resultMatch.BTTSNoOdds, &resultMatch.CorrectScoreOdds, &resultMatch.TotalsOdds, &resultMatch.Completed, &resultMatch.HomeScore,
+                &resultMatch.AwayScore, &resultMatch.Calculated, &resultMatch.Result, &resultMatch.CreatedAt, &resultMatch.UpdatedAt, &resultMatch.OddsUpdatedAt, &resultMatch.ShootoutWinner, &resultMatch.OddsSource,
+        )
+
+        if err != nil {
+                return nil, notFound(err, ErrMatchNotFound)
+        }
+
+        return &resultMatch, nil
+}
+
+// GetCompletedUncalculatedMatches returns up to limit unsettled completed matches after afterAPIID
+// Scoreless matches are included so the caller can report or void them
+func (db *PostgresDB) GetCompletedUncalculatedMatches(ctx context.Context, afterAPIID string, limit int) ([]Match, error) {
+        start := time.Now()
+
+        query := `SELECT id, api_id, home_team, away_team, commence_time,
+                         home_odds, draw_odds, away_odds, btts_yes_odds, btts_no_odds, correct_score_odds, totals_odds, completed, home_score, away_score, calculated, result, created_at, updated_at, odds_updated_at, shootout_winner, odds_source
+                  FROM epl_matches
+                  WHERE completed = TRUE AND calculated = FALSE AND api_id > $1
+                  ORDER BY api_id
+                  LIMIT $2`
+
+        defer func() {
+                db.logger.LogSQL("SELECT completed uncalculated matches", query, []interface{}{afterAPIID, limit}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+        defer cancel()
+
+        rows, err := db.pool.Query(ctx, query, afterAPIID, limit)
+        if err != nil {
+                return nil, err
+        }
+        defer rows.Close()
+
+        var matches []Match
+        for rows.Next() {
+                var match Match
+                err := rows.Scan(
+                        &match.ID, &match.APIID, &match.HomeTeam, &match.AwayTeam,
+                        &match.CommenceTime, &match.HomeOdds, &match.DrawOdds,
+                        &match.AwayOdds, &match.BTTSYesOdds, &match.BTTSNoOdds, &match.CorrectScoreOdds, &match.TotalsOdds, &match.Completed, &match.HomeScore, &match.AwayScore,
+                        &match.Calculated, &match.Result, &match.CreatedAt, &match.UpdatedAt, &match.OddsUpdatedAt, &match.ShootoutWinner, &match.OddsSource,
+                )
+                if err != nil {
+                        return nil, err
+                }
+                matches = append(matches, match)
+        }
+
+        return matches, rows.Err()
+}
+
+// lockUnsettledMatch locks the match row for a settlement transaction, so concurrent
+// settlements of one match run one after the other and see each other's result
+// ErrMatchAlreadySettled once the match is calculated
+func lockUnsettledMatch(ctx context.Context, tx pgx.Tx, matchAPIID string) error {
+        var calculated bool
+        err := tx.QueryRow(ctx, `SELECT calculated FROM epl_matches WHERE api_id = $1 FOR UPDATE`, matchAPIID).Scan(&calculated)
+        if err != nil {
+                return notFound(err, ErrMatchNotFound)
+        }
+        if calculated {
+                return ErrMatchAlreadySettled
+        }
+        return nil
+}
+
+// TryLockCalc takes the session advisory lock that serializes calculation runs across
+// instances; the returned func releases it. ErrCalcInProgress if another run holds it
+func (db *PostgresDB) TryLockCalc(ctx context.Context) (func(), error) {
+        conn, err := db.pool.Acquire(ctx)
+        if err != nil {
+                return nil, fmt.Errorf("failed to acquire connection: %w", err)
+        }
+
+        var locked bool
+        if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, calcLockID).Scan(&locked); err != nil {
+                conn.Release()
+                return nil, fmt.Errorf("failed to take calculation lock: %w", err)
+        }
+        if !locked {
+                conn.Release()
+                return nil, ErrCalcInProgress
+        }
+
+        // The lock belongs to this connection's session; hold the connection until unlock
+        return func() {
+                ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+                defer cancel()
+                if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, calcLockID); err != nil {
+                        // Closing the session drops the lock instead of returning it to the pool held
+                        db.logger.LogError("Failed to release calculation lock: %s", err.Error())
+                        conn.Conn().Close(ctx)
+                }
+                conn.Release()
+        }, nil
+}
+
+// markMatchCalculated records the match result in a settlement transaction
+func markMatchCalculated(ctx context.Context, tx pgx.Tx, matchAPIID, result string) error {
+        _, err := tx.Exec(ctx, `UPDATE epl_matches SET calculated = TRUE, result = $1, updated_at = NOW() WHERE api_id = $2`, result, matchAPIID)
+        return err
+}
+
+// SettleMatch settles a match's pending bets, credits winners and marks the match
+// calculated with result, all in one transaction: a match is paid out once or not at all.
+// Bets whose type is in winningBetTypes (see settledBetTypes) win, those in pushBetTypes
+// push and get their stake back, all others lose
+// ('push' and 'void' bets are excluded from settled counts and win rates)
+func (db *PostgresDB) SettleMatch(ctx context.Context, matchAPIID, result string, winningBetTypes, pushBetTypes []string) error {
+        // Update bets status
+        updateBetsQuery := `
+                UPDATE bets
+                SET status = CASE WHEN bet_type = ANY($1) THEN 'won' WHEN bet_type = ANY($3) THEN 'push' ELSE 'lost' END,
+                    settled_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE match_id = $2 AND status = 'pending'
+                RETURNING bet_id, user_id, match_id, bet_type, bet_amount, odds, potential_win, status,
+                          COALESCE(home_team, ''), COALESCE(away_team, '')`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE bets status and user money", updateBetsQuery, []interface{}{matchAPIID, result, winningBetTypes, pushBetTypes}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+        defer cancel()
+
+        // Start transaction
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return err
+        }
+        defer tx.Rollback(ctx)
+
+        if err := lockUnsettledMatch(ctx, tx, matchAPIID); err != nil {
+                return err
+        }
+
+        rows, err := tx.Query(ctx, updateBetsQuery, winningBetTypes, matchAPIID, pushBetTypes)
+        if err != nil {
+                return err
+        }
+        defer rows.Close()
+
+        // Collect settled bets
+        type settledBet struct {
+                userID       string
+                status       string
+                notification BetNotification
+        }
+        var settledBets []settledBet
+
+        for rows.Next() {
+                var bet settledBet
+                var potentialWin float64
+                n := &bet.notification
+                if err := rows.Scan(&n.BetID, &bet.userID, &n.MatchID, &n.BetType, &n.BetAmount, &n.Odds, &potentialWin, &bet.status,
+                        &n.HomeTeam, &n.AwayTeam); err != nil {
+                        return err
+                }
+                switch bet.status {
+                case "won":
+                        n.Payout = potentialWin
+                case "push":
+                        n.Payout = n.BetAmount
+                }
+                settledBets = append(settledBets, bet)
+        }
+        if err := rows.Err(); err != nil {
+                // Never commit a partial settlement
+                return err
+        }
+
+        // Pay out winners, refund pushes, one ledger entry per bet, and notify every bettor
+        for _, bet := range settledBets {
+                notificationType := NotificationLoss
+                switch bet.status {
+                case "won":
+                        notificationType = NotificationWin
+                        if _, err := creditUser(ctx, tx, bet.userID, LedgerBetPayout, bet.notification.Payout, bet.notification.BetID); err != nil {
+                                return err
+                        }
+                case "push":
+                        notificationType = NotificationPush
+                        if _, err := creditUser(ctx, tx, bet.userID, LedgerBetRefund, bet.notification.Payout, bet.notification.BetID); err != nil {
+                                return err
+                        }
+                }
+                if err := recordNotification(ctx, tx, bet.userID, notificationType, bet.notification); err != nil {
+                        return err
+                }
+        }
+
+        if err := markMatchCalculated(ctx, tx, matchAPIID, result); err != nil {
+                return err
+        }
+
+        // Commit transaction
+        if err := tx.Commit(ctx); err != nil {
+                return err
+        }
+
+        return nil
+}
+
+// GetNotifications pages a user's notifications, newest first
+// Returns the number matching the filter and the total unread count
+func (db *PostgresDB) GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]Notification, int, int, error) {
+        query := `
+                SELECT id, type, payload, read_at, created_at
+                FROM notifications
+                WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+                ORDER BY id DESC
+                LIMIT $3 OFFSET $4`
+        countQuery := `
+                SELECT COUNT(*), COUNT(*) FILTER (WHERE read_at IS NULL)
+                FROM notifications WHERE user_id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT notifications", query, []interface{}{userID, unreadOnly, limit, offset}, time.Since(start))
+        }()
+
+        var notifications []Notification
+        var total, unread int
+        err := db.withRetry(ctx, "SELECT notifications", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                rows, err := db.pool.Query(ctx, query, userID, unreadOnly, limit, offset)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                notifications = nil // Reset on retry
+                for rows.Next() {
+                        notification := Notification{UserID: userID}
+                        if err := rows.Scan(&notification.ID, &notification.Type, &notification.Payload, &notification.ReadAt, &notification.CreatedAt); err != nil {
+                                return err
+                        }
+                        notifications = append(notifications, notification)
+                }
+                if err := rows.Err(); err != nil {
+                        return err
+                }
+
+                return db.pool.QueryRow(ctx, countQuery, userID).Scan(&total, &unread)
+        })
+        if err != nil {
+                return nil, 0, 0, err
+        }
+
+        if unreadOnly {
+                total = unread
+        }
+        return notifications, total, unread, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications as read; already read ones keep their read_at
+func (db *PostgresDB) MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (*Notification, error) {
+        query := `
+                UPDATE notifications
+                SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+                WHERE id = $1 AND user_id = $2
+                RETURNING id, type, payload, read_at, created_at`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE notification read_at", query, []interface{}{notificationID, userID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        notification := Notification{UserID: userID}
+        err := db.pool.QueryRow(ctx, query, notificationID, userID).Scan(
+                &notification.ID, &notification.Type, &notification.Payload, &notification.ReadAt, &notification.CreatedAt,
+        )
+        if err != nil {
+                return nil, notFound(err, ErrNotificationNotFound)
+        }
+        return &notification, nil
+}
+
+// GetPlatformStats runs the aggregate queries behind GET /api/stats
+func (db *PostgresDB) GetPlatformStats(ctx context.Context) (*PlatformStats, error) {
+        totalsQuery := `
+                SELECT
+                        (SELECT COUNT(*) FROM users),
+                        COUNT(*),
+                        COALESCE(SUM(bet_amount), 0)
+                FROM bets
+                WHERE status <> 'void'`
+        biggestWinQuery := `
+                SELECT u.nickname, b.potential_win, b.odds, COALESCE(m.home_team, b.home_team, ''), COALESCE(m.away_team, b.away_team, '')
+                FROM bets b
+                JOIN users u ON u.id = b.user_id
+                LEFT JOIN epl_matches m ON m.api_id = b.match_id
+                WHERE b.status = 'won'
+                ORDER BY b.potential_win DESC, b.created_at
+                LIMIT 1`
+        popularTeamQuery := `
+                SELECT CASE WHEN b.bet_type = 'home' THEN m.home_team ELSE m.away_team END AS team, COUNT(*) AS bets
+                FROM bets b
+                JOIN epl_matches m ON m.api_id = b.match_id
+                WHERE b.bet_type IN ('home', 'away') AND b.status <> 'void'
+                GROUP BY team
+                ORDER BY bets DESC, team
+                LIMIT 1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT platform stats", totalsQuery, nil, time.Since(start))
+        }()
+
+        var stats PlatformStats
+        err := db.withRetry(ctx, "SELECT platform stats", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
+
+                stats = PlatformStats{} // Reset on retry
+                err := db.pool.QueryRow(ctx, totalsQuery).Scan(&stats.TotalUsers, &stats.TotalBets, &stats.TotalWagered)
+                if err != nil {
+                        return fmt.Errorf("failed to get totals: %w", err)
+                }
+
+                var win BiggestWin
+                err = db.pool.QueryRow(ctx, biggestWinQuery).Scan(&win.Nickname, &win.Amount, &win.Odds, &win.HomeTeam, &win.AwayTeam)
+                if err == nil {
+                        stats.BiggestWin = &win
+                } else if !errors.Is(err, pgx.ErrNoRows) {
+                        return fmt.Errorf("failed to get biggest win: %w", err)
+                }
+
+                var team PopularTeam
+                err = db.pool.QueryRow(ctx, popularTeamQuery).Scan(&team.Team, &team.Bets)
+                if err == nil {
+                        stats.MostPopularTeam = &team
+                } else if !errors.Is(err, pgx.ErrNoRows) {
+                        return fmt.Errorf("failed to get most popular team: %w", err)
+                }
+                return nil
+        })
+        if err != nil {
+                return nil, err
+        }
+        return &stats, nil
+}
+
+// GetExposure aggregates pending bets by match and bet type
+func (db *PostgresDB) GetExposure(ctx context.Context) ([]ExposureRow, error) {
+        query := `
+                SELECT b.match_id,
+                       COALESCE(m.home_team, MAX(b.home_team), ''),
+                       COALESCE(m.away_team, MAX(b.away_team), ''),
+                       m.commence_time,
+                       b.bet_type,
+                       COUNT(*),
+                       SUM(b.bet_amount),
+                       SUM(b.potential_win)
+                FROM bets b
+                LEFT JOIN epl_matches m ON m.api_id = b.match_id
+                WHERE b.status = 'pending'
+                GROUP BY b.match_id, m.home_team, m.away_team, m.commence_time, b.bet_type
+                ORDER BY b.match_id, b.bet_type`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT exposure", query, nil, time.Since(start))
+        }()
+
+        var exposure []ExposureRow
+        err := db.withRetry(ctx, "SELECT exposure", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
+
+                rows, err := db.pool.Query(ctx, query)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                exposure = nil // Reset on retry
+                for rows.Next() {
+                        var row ExposureRow
+                        if err := rows.Scan(&row.MatchID, &row.HomeTeam, &row.AwayTeam, &row.CommenceTime,
+                                &row.BetType, &row.Bets, &row.Stake, &row.PotentialPayout); err != nil {
+                                return err
+                        }
+                        exposure = append(exposure, row)
+                }
+                return rows.Err()
+        })
+        if err != nil {
+                return nil, err
+        }
+        return exposure, nil
+}
+
+// seasonStandingsQuery ranks players by their non-void bets placed in [$1, $2)
+const seasonStandingsQuery = `
+        SELECT ROW_NUMBER() OVER (ORDER BY s.profit DESC, s.won_bets DESC, s.bets DESC, u.nickname) AS rank,
+               u.id, u.nickname, s.bets, s.won_bets, s.settled_bets, s.wagered, s.profit
+        FROM (
+                SELECT user_id,
+                        COUNT(*) AS bets,
+                        COALESCE(SUM(CASE WHEN status = 'won' THEN 1 ELSE 0 END), 0) AS won_bets,
+                        COALESCE(SUM(CASE WHEN status IN ('won','lost') THEN 1 ELSE 0 END), 0) AS settled_bets,
+                        COALESCE(SUM(bet_amount), 0) AS wagered,
+                        COALESCE(SUM(CASE WHEN status = 'won' THEN potential_win - bet_amount
+                                          WHEN status = 'lost' THEN -bet_amount ELSE 0 END), 0) AS profit
+                FROM bets
+                WHERE created_at >= $1 AND created_at < $2 AND status <> 'void'
+                GROUP BY user_id
+        ) s
+        JOIN users u ON u.id = s.user_id
+        WHERE NOT u.is_guest`
+
+// GetOpenSeason returns the open season, starting one with the given bounds when none is open
+func (db *PostgresDB) GetOpenSeason(ctx context.Context, startsAt, endsAt time.Time) (*Season, error) {
+        selectQuery := `SELECT id, starts_at, ends_at, closed_at FROM seasons WHERE closed_at IS NULL`
+        // idx_seasons_open makes a concurrent second insert a no-op
+        insertQuery := `INSERT INTO seasons (starts_at, ends_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT open season", selectQuery, nil, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        var season Season
+        for attempt := 0; attempt < 2; attempt++ {
+                err := db.pool.QueryRow(ctx, selectQuery).Scan(&season.ID, &season.StartsAt, &season.EndsAt, &season.ClosedAt)
+                if err == nil {
+                        return &season, nil
+                }
+                if !errors.Is(err, pgx.ErrNoRows) {
+                        return nil, err
+                }
+                if _, err := db.pool.Exec(ctx, insertQuery, startsAt, endsAt); err != nil {
+                        return nil, err
+                }
+        }
+        return nil, ErrSeasonNotFound
+}
+
+// GetSeason returns a season by ID
+func (db *PostgresDB) GetSeason(ctx context.Context, id int) (*Season, error) {
+        query := `SELECT id, starts_at, ends_at, closed_at FROM seasons WHERE id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT season", query, []interface{}{id}, time.Since(start))
+        }()
+
+        var season Season
+        err := db.withRetry(ctx, "SELECT season", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                return db.pool.QueryRow(ctx, query, id).Scan(&season.ID, &season.StartsAt, &season.EndsAt, &season.ClosedAt)
+        })
+        if err != nil {
+                return nil, notFound(err, ErrSeasonNotFound)
+        }
+        return &season, nil
+}
+
+// GetLastClosedSeason returns the most recently closed season
+func (db *PostgresDB) GetLastClosedSeason(ctx context.Context) (*Season, error) {
+        query := `SELECT id, starts_at, ends_at, closed_at FROM seasons
+                  WHERE closed_at IS NOT NULL
+                  ORDER BY ends_at DESC, id DESC
+                  LIMIT 1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT last closed season", query, nil, time.Since(start))
+        }()
+
+        var season Season
+        err := db.withRetry(ctx, "SELECT last closed season", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+                defer cancel()
+
+                return db.pool.QueryRow(ctx, query).Scan(&season.ID, &season.StartsAt, &season.EndsAt, &season.ClosedAt)
+        })
+        if err != nil {
+                return nil, notFound(err, ErrSeasonNotFound)
+        }
+        return &season, nil
+}
+
+// GetSeasonStandings pages a season's standings and returns the number of ranked players
+// Open seasons are ranked live from bets; closed ones come from season_results
+func (db *PostgresDB) GetSeasonStandings(ctx context.Context, season *Season, limit, offset int) ([]SeasonStanding, int, error) {
+        var query, countQuery string
+        var params, countParams []interface{}
+        if season.ClosedAt == nil {
+                query = seasonStandingsQuery + ` ORDER BY rank LIMIT $3 OFFSET $4`
+                params = []interface{}{season.StartsAt, season.EndsAt, limit, offset}
+                countQuery = `SELECT COUNT(DISTINCT b.user_id) FROM bets b JOIN users u ON u.id = b.user_id
+                               WHERE b.created_at >= $1 AND b.created_at < $2 AND b.status <> 'void' AND NOT u.is_guest`
+                countParams = params[:2]
+        } else {
+                query = `SELECT rank, COALESCE(user_id::text, ''), nickname, bets, won_bets, settled_bets, wagered, profit, prize
+                         FROM season_results WHERE season_id = $1
+                         ORDER BY rank LIMIT $2 OFFSET $3`
+                params = []interface{}{season.ID, limit, offset}
+                countQuery = `SELECT COUNT(*) FROM season_results WHERE season_id = $1`
+                countParams = params[:1]
+        }
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT season standings", query, params, time.Since(start))
+        }()
+
+        var standings []SeasonStanding
+        var total int
+        err := db.withRetry(ctx, "SELECT season standings", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
+
+                rows, err := db.pool.Query(ctx, query, params...)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                standings = nil // Reset on retry
+                for rows.Next() {
+                        var standing SeasonStanding
+                        dest := []interface{}{&standing.Rank, &standing.UserID, &standing.Nickname, &standing.Bets,
+                                &standing.WonBets, &standing.SettledBets, &standing.Wagered, &standing.Profit}
+                        if season.ClosedAt != nil {
+                                dest = append(dest, &standing.Prize)
+                        }
+                        if err := rows.Scan(dest...); err != nil {
+                                return err
+                        }
+                        standings = append(standings, standing)
+                }
+                if err := rows.Err(); err != nil {
+                        return err
+                }
+
+                return db.pool.QueryRow(ctx, countQuery, countParams...).Scan(&total)
+        })
+        if err != nil {
+                return nil, 0, err
+        }
+        return standings, total, nil
+}
+
+// CloseSeason snapshots a season's final standings, credits prizes to ranks 1..len(prizes),
+// ends the season at endsAt and opens the next one from endsAt to nextEndsAt, in one transaction
+func (db *PostgresDB) CloseSeason(ctx context.Context, seasonID int, endsAt time.Time, prizes []float64, nextEndsAt time.Time) ([]SeasonStanding, *Season, error) {
+        snapshotQuery := `
+                INSERT INTO season_results (season_id, rank, user_id, nickname, bets, won_bets, settled_bets, wagered, profit)
+                SELECT $3::integer, standings.* FROM (` + seasonStandingsQuery + `) standings`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("CLOSE season", snapshotQuery, []interface{}{seasonID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+        defer cancel()
+
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return nil, nil, err
+        }
+        defer tx.Rollback(ctx)
+
+        // Lock the season so a concurrent close waits and then sees it closed
+        var startsAt time.Time
+        var closedAt *time.Time
+        err = tx.QueryRow(ctx, `SELECT starts_at, closed_at FROM seasons WHERE id = $1 FOR UPDATE`, seasonID).Scan(&startsAt, &closedAt)
+        if err != nil {
+                return nil, nil, notFound(err, ErrSeasonNotFound)
+        }
+        if closedAt != nil {
+                return nil, nil, ErrSeasonClosed
+        }
+
+        if _, err := tx.Exec(ctx, snapshotQuery, startsAt, endsAt, seasonID); err != nil {
+                return nil, nil, fmt.Errorf("failed to snapshot standings: %w", err)
+        }
+
+        for i, prize := range prizes {
+                if prize <= 0 {
+                        continue
+                }
+                var userID *string
+                err := tx.QueryRow(ctx,
+                        `UPDATE season_results SET prize = $3 WHERE season_id = $1 AND rank = $2 RETURNING user_id::text`,
+                        seasonID, i+1, prize).Scan(&userID)
+                if errors.Is(err, pgx.ErrNoRows) {
+                        break // Fewer players than prizes
+                }
+                if err != nil {
+                        return nil, nil, fmt.Errorf("failed to record prize: %w", err)
+                }
+                if userID == nil {
+                        continue
+                }
+                if _, err := creditUser(ctx, tx, *userID, LedgerSeasonPrize, prize, strconv.Itoa(seasonID)); err != nil {
+                        return nil, nil, fmt.Errorf("failed to credit prize: %w", err)
+                }
+        }
+
+        if _, err := tx.Exec(ctx, `UPDATE seasons SET ends_at = $2, closed_at = CURRENT_TIMESTAMP WHERE id = $1`, seasonID, endsAt); err != nil {
+                return nil, nil, err
+        }
+
+        next := Season{StartsAt: endsAt, EndsAt: nextEndsAt}
+        err = tx.QueryRow(ctx, `INSERT INTO seasons (starts_at, ends_at) VALUES ($1, $2) RETURNING id`, next.StartsAt, next.EndsAt).Scan(&next.ID)
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to open next season: %w", err)
+        }
+
+        rows, err := tx.Query(ctx, `SELECT rank, COALESCE(user_id::text, ''), nickname, bets, won_bets, settled_bets, wagered, profit, prize
+                                    FROM season_results WHERE season_id = $1 ORDER BY rank`, seasonID)
+        if err != nil {
+                return nil, nil, err
+        }
+        var standings []SeasonStanding
+        for rows.Next() {
+                var standing SeasonStanding
+                if err := rows.Scan(&standing.Rank, &standing.UserID, &standing.Nickname, &standing.Bets, &standing.WonBets,
+                        &standing.SettledBets, &standing.Wagered, &standing.Profit, &standing.Prize); err != nil {
+                        rows.Close()
+                        return nil, nil, err
+                }
+                standings = append(standings, standing)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return nil, nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, nil, err
+        }
+        return standings, &next, nil
+}
+
+// VoidMatch refunds the stake of every pending (or still in review) bet on a match, marks them
+// void and marks the match calculated with result "void", in one transaction
+func (db *PostgresDB) VoidMatch(ctx context.Context, matchAPIID string) (int, error) {
+        voidBetsQuery := `
+                UPDATE bets
+                SET status = 'void', settled_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+                WHERE match_id = $1 AND status IN ('pending', 'review')
+                RETURNING bet_id, user_id, match_id, bet_type, bet_amount, odds,
+                          COALESCE(home_team, ''), COALESCE(away_team, '')`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE bets void and refund", voidBetsQuery, []interface{}{matchAPIID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+        defer cancel()
+
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return 0, err
+        }
+        defer tx.Rollback(ctx)
+
+        if err := lockUnsettledMatch(ctx, tx, matchAPIID); err != nil {
+                return 0, err
+        }
+
+        rows, err := tx.Query(ctx, voidBetsQuery, matchAPIID)
+        if err != nil {
+                return 0, err
+        }
+
+        // Refund each bet, one ledger entry and notification per bet
+        type voidedBet struct {
+                userID       string
+                notification BetNotification
+        }
+        var voided []voidedBet
+        for rows.Next() {
+                var bet voidedBet
+                n := &bet.notification
+                if err := rows.Scan(&n.BetID, &bet.userID, &n.MatchID, &n.BetType, &n.BetAmount, &n.Odds, &n.HomeTeam, &n.AwayTeam); err != nil {
+                        rows.Close()
+                        return 0, err
+                }
+                n.Payout = n.BetAmount
+                voided = append(voided, bet)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return 0, err
+        }
+
+        for _, bet := range voided {
+                if _, err := creditUser(ctx, tx, bet.userID, LedgerBetRefund, bet.notification.BetAmount, bet.notification.BetID); err != nil {
+                        return 0, err
+                }
+                if err := recordNotification(ctx, tx, bet.userID, NotificationVoid, bet.notification); err != nil {
+                        return 0, err
+                }
+        }
+        count := len(voided)
+
+        if err := markMatchCalculated(ctx, tx, matchAPIID, "void"); err != nil {
+                return 0, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return 0, err
+        }
+
+        return count, nil
+}
+
+// reviewBetColumns are the columns ListBetsInReview and lockReviewBet read
+const reviewBetColumns = `bet_id, user_id, match_id, bet_type, bet_amount, odds, potential_win, status,
+                          COALESCE(home_team, ''), COALESCE(away_team, ''), created_at`
+
+// ListBetsInReview returns the bets held for admin review, oldest first
+func (db *PostgresDB) ListBetsInReview(ctx context.Context) ([]Bet, error) {
+        query := `SELECT ` + reviewBetColumns + ` FROM bets WHERE status = 'review' ORDER BY created_at, bet_id`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("SELECT bets in review", query, nil, time.Since(start))
+        }()
+
+        var bets []Bet
+        err := db.withRetry(ctx, "SELECT bets in review", func() error {
+                ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+                defer cancel()
+
+                rows, err := db.pool.Query(ctx, query)
+                if err != nil {
+                        return err
+                }
+                defer rows.Close()
+
+                bets = nil // Reset on retry
+                for rows.Next() {
+                        var bet Bet
+                        if err := rows.Scan(&bet.BetID, &bet.UserID, &bet.MatchID, &bet.BetType, &bet.BetAmount, &bet.Odds,
+                                &bet.PotentialWin, &bet.Status, &bet.HomeTeam, &bet.AwayTeam, &bet.CreatedAt); err != nil {
+                                return err
+                        }
+                        bets = append(bets, bet)
+                }
+                return rows.Err()
+        })
+        return bets, err
+}
+
+// lockReviewBet locks a bet for an admin decision; ErrBetNotFound if there is no such bet,
+// ErrBetNotInReview once it was approved or rejected
+func lockReviewBet(ctx context.Context, tx pgx.Tx, betID string) (*Bet, error) {
+        var bet Bet
+        err := tx.QueryRow(ctx, `SELECT `+reviewBetColumns+` FROM bets WHERE bet_id = $1 FOR UPDATE`, betID).Scan(
+                &bet.BetID, &bet.UserID, &bet.MatchID, &bet.BetType, &bet.BetAmount, &bet.Odds,
+                &bet.PotentialWin, &bet.Status, &bet.HomeTeam, &bet.AwayTeam, &bet.CreatedAt)
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return nil, ErrBetNotFound // Not a UUID, so no such bet
+        }
+        if err != nil {
+                return nil, notFound(err, ErrBetNotFound)
+        }
+        if bet.Status != "review" {
+                return nil, ErrBetNotInReview
+        }
+        return &bet, nil
+}
+
+// ApproveBet makes a bet held for review pending, so the next calculation settles it
+// ErrMatchAlreadySettled once its match was calculated: the bet can then only be rejected
+func (db *PostgresDB) ApproveBet(ctx context.Context, betID string, closesAt time.Time) (*Bet, error) {
+        query := `UPDATE bets SET status = 'pending', updated_at = CURRENT_TIMESTAMP WHERE bet_id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE bet approve", query, []interface{}{betID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return nil, err
+        }
+        defer tx.Rollback(ctx)
+
+        // Match before bet, the lock order of VoidMatch
+        var matchAPIID string
+        err = tx.QueryRow(ctx, `SELECT match_id FROM bets WHERE bet_id = $1`, betID).Scan(&matchAPIID)
+        var pgErr *pgconn.PgError
+        if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+                return nil, ErrBetNotFound
+        }
+        if err != nil {
+                return nil, notFound(err, ErrBetNotFound)
+        }
+        if err := lockUnsettledMatch(ctx, tx, matchAPIID); err != nil {
+                return nil, err
+        }
+
+        // A bet approved after betting closed could be settled on a known outcome
+        var commenceTime time.Time
+        if err := tx.QueryRow(ctx, `SELECT commence_time FROM epl_matches WHERE api_id = $1`, matchAPIID).Scan(&commenceTime); err != nil {
+                return nil, err
+        }
+        if !commenceTime.After(closesAt) {
+                return nil, ErrMatchStarted
+        }
+
+        bet, err := lockReviewBet(ctx, tx, betID)
+        if err != nil {
+                return nil, err
+        }
+        if _, err := tx.Exec(ctx, query, betID); err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, err
+        }
+        bet.Status = "pending"
+        return bet, nil
+}
+
+// RejectBet voids a bet held for review and refunds its reserved stake, with a ledger
+// entry and a void notification, in one transaction
+func (db *PostgresDB) RejectBet(ctx context.Context, betID string) (*Bet, error) {
+        query := `UPDATE bets SET status = 'void', settled_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE bet_id = $1`
+
+        start := time.Now()
+        defer func() {
+                db.logger.LogSQL("UPDATE bet reject and refund", query, []interface{}{betID}, time.Since(start))
+        }()
+
+        ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+        defer cancel()
+
+        tx, err := db.pool.Begin(ctx)
+        if err != nil {
+                return nil, err
+        }
+        defer tx.Rollback(ctx)
+
+        bet, err := lockReviewBet(ctx, tx, betID)
+        if err != nil {
+                return nil, err
+        }
+        if _, err := tx.Exec(ctx, query, betID); err != nil {
+                return nil, err
+        }
+        if _, err := creditUser(ctx, tx, bet.UserID, LedgerBetRefund, bet.BetAmount, bet.BetID); err != nil {
+                return nil, err
+        }
+        notification := BetNotification{
+                BetID:     bet.BetID,
+                MatchID:   bet.MatchID,
+                BetType:   bet.BetType,
+                BetAmount: bet.BetAmount,
+                Odds:      bet.Odds,
+                Payout:    bet.BetAmount,
+                HomeTeam:  bet.HomeTeam,
+                AwayTeam:  bet.AwayTeam,
+        }
+        if err := recordNotification(ctx, tx, bet.UserID, NotificationVoid, notification); err != nil {
+                return nil, err
+        }
+
+        if err := tx.Commit(ctx); err != nil {
+                return nil, err
+        }
+        bet.Status = "void"
+        return bet, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+        _ "embed"
+        "net/http"
+)
+
+// Hand-maintained OpenAPI 3 spec; update it together with routes.go and the DTOs in models.go
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage renders the spec with Swagger UI from a CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>FREEBET.GURU API docs</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// OpenAPI spec handler - serves the embedded spec (no auth required)
+func (h *Handler) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusOK)
+        w.Write(openAPISpec)
+}
+
+// API docs handler - Swagger UI for the spec (no auth required)
+func (h *Handler) docsHandler(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        w.WriteHeader(http.StatusOK)
+        w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+        _ "embed"
+        "strings"
+)
+
+// defaultBlockedEmailDomainsList is a newline-separated list of disposable email domains
+//
+//go:embed blocked_email_domains.txt
+var defaultBlockedEmailDomainsList string
+
+// defaultBlockedEmailDomains is the parsed form of defaultBlockedEmailDomainsList
+var defaultBlockedEmailDomains = strings.Fields(defaultBlockedEmailDomainsList)
+
+// isBlockedEmailDomain reports whether the email's domain (or a parent domain) is blocked
+// Checks the embedded disposable list plus config.BlockedEmailDomains, case-insensitively
+func isBlockedEmailDomain(email string, config *Config) bool {
+        at := strings.LastIndex(email, "@")
+        if at == -1 {
+                return false
+        }
+        domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+
+        blocked := func(list []string) bool {
+                for _, entry := range list {
+                        entry = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(entry)), "@")
+                        if entry == "" {
+                                continue
+                        }
+                        // Exact match or subdomain variant (e.g. "x.mailinator.com")
+                        if domain == entry || strings.HasSuffix(domain, "."+entry) {
+                                return true
+                        }
+                }
+                return false
+        }
+
+        return blocked(defaultBlockedEmailDomains) || blocked(config.BlockedEmailDomains)
+}
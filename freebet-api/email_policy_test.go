@@ -0,0 +1,45 @@
+package main
+
+import (
+        "net/http"
+        "testing"
+)
+
+func TestIsBlockedEmailDomain(t *testing.T) {
+        config := &Config{BlockedEmailDomains: []string{"Spam.Example", "@junk.test"}}
+
+        tests := []struct {
+                email   string
+                blocked bool
+        }{
+                {"user@mailinator.com", true},
+                {"user@MAILINATOR.COM", true},
+                {"user@x.mailinator.com", true},
+                {"user@mailinator.com.", true},
+                {"user@spam.example", true},
+                {"user@deep.sub.spam.example", true},
+                {"user@junk.test", true},
+                {"user@gmail.com", false},
+                {"user@notmailinator.com", false},
+                {"user@mailinator.com.evil.test", false},
+                {"not-an-email", false},
+        }
+        for _, tt := range tests {
+                if got := isBlockedEmailDomain(tt.email, config); got != tt.blocked {
+                        t.Errorf("isBlockedEmailDomain(%q) = %v, want %v", tt.email, got, tt.blocked)
+                }
+        }
+}
+
+func TestRegisterRejectsBlockedEmailDomain(t *testing.T) {
+        s := newTestServer(t)
+
+        var response map[string]interface{}
+        w := s.do("POST", "/api/auth/register", "", RegisterRequest{Email: "farmer@Mailinator.com", Password: "correct-horse-42", Nickname: "farmer", AgeConfirmed: true})
+        decodeResponse(t, w, http.StatusBadRequest, &response)
+        if fieldErrors, _ := response["errors"].(map[string]interface{}); fieldErrors["email"] == nil {
+                t.Fatalf("response = %v, want an email field error", response)
+        }
+
+        s.register("player@example.com", "player", "correct-horse-42")
+}
@@ -0,0 +1,58 @@
+package main
+
+// Machine-readable error codes, sent as "code" next to the human-readable "error"
+// Clients branch on the code; the message may be reworded or translated at any time
+const (
+        // Generic, by HTTP status
+        CodeInvalidRequest   = "INVALID_REQUEST"   // Missing or malformed parameters or fields
+        CodeInvalidJSON      = "INVALID_JSON"      // Body is not valid JSON for the endpoint
+        CodeValidationFailed = "VALIDATION_FAILED" // Field errors listed under "errors"
+        CodeRequestTooLarge  = "REQUEST_TOO_LARGE"
+        CodeUnauthorized     = "UNAUTHORIZED" // Missing, invalid or revoked access or admin token
+        CodeForbidden        = "FORBIDDEN"
+        CodeNotFound         = "NOT_FOUND"
+        CodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+        CodeRateLimited      = "RATE_LIMITED"
+        CodeInternal         = "INTERNAL_ERROR"
+        CodeUnavailable      = "SERVICE_UNAVAILABLE" // A dependency (Google, the Odds API) is unavailable
+        CodeMaintenance      = "MAINTENANCE"
+
+        // Accounts and authentication
+        CodeInvalidCredentials    = "INVALID_CREDENTIALS"
+        CodeInvalidRefreshToken   = "INVALID_REFRESH_TOKEN"
+        CodeInvalidLoginCode      = "INVALID_LOGIN_CODE" // OAuth one-time code unknown, used or expired
+        CodeAccountDisabled       = "ACCOUNT_DISABLED"
+        CodeAlreadyRegistered     = "ALREADY_REGISTERED"
+        CodeNicknameTaken         = "NICKNAME_TAKEN"
+        CodeInvalidNickname       = "INVALID_NICKNAME"
+        CodeNicknameChangeTooSoon = "NICKNAME_CHANGE_TOO_SOON"
+        CodeWeakPassword          = "WEAK_PASSWORD"
+        CodeAgeNotConfirmed       = "AGE_NOT_CONFIRMED"
+        CodeGuestPlayDisabled     = "GUEST_PLAY_DISABLED"
+        CodeUserNotFound          = "USER_NOT_FOUND"
+
+        // Balance and top-ups
+        CodeInsufficientBalance = "INSUFFICIENT_BALANCE"
+        CodeTopupCooldown       = "TOPUP_COOLDOWN"        // Already topped up within TOPUP_COOLDOWN
+        CodeTopupBalanceTooHigh = "TOPUP_BALANCE_TOO_HIGH" // Balance at or above MAX_TOPUP_BALANCE
+
+        // Bets
+        CodeMatchNotFound       = "MATCH_NOT_FOUND"
+        CodeMatchStarted        = "MATCH_STARTED" // Betting closed BET_CUTOFF_BUFFER before kickoff
+        CodeInvalidBetType      = "INVALID_BET_TYPE"
+        CodeInvalidBetAmount    = "INVALID_BET_AMOUNT"
+        CodeMarketUnavailable   = "MARKET_UNAVAILABLE" // Bet type not priced for the match (or sport)
+        CodeOddsChanged         = "ODDS_CHANGED"
+        CodeOddsStale           = "ODDS_STALE"
+        CodePotentialWinTooHigh = "POTENTIAL_WIN_TOO_HIGH"
+        CodeTooManyPendingBets  = "TOO_MANY_PENDING_BETS"
+        CodeBetLimitExceeded    = "BET_LIMIT_EXCEEDED"
+        CodeSelfExcluded        = "SELF_EXCLUDED"
+        CodeBatchRejected       = "BATCH_REJECTED" // Some bets in an all-or-nothing batch were invalid
+
+        // Admin
+        CodeCalcInProgress = "CALC_IN_PROGRESS"
+        CodeSeasonClosed   = "SEASON_CLOSED"
+        CodeBetNotInReview = "BET_NOT_IN_REVIEW" // Already approved or rejected, or never held
+        CodeMatchSettled   = "MATCH_SETTLED"
+)
@@ -0,0 +1,55 @@
+package main
+
+import "sort"
+
+// ExposureReport summarizes the outstanding liability on pending bets
+type ExposureReport struct {
+        PendingBets          int             `json:"pending_bets"`
+        TotalStake           float64         `json:"total_stake"`
+        TotalPotentialPayout float64         `json:"total_potential_payout"`
+        Matches              []MatchExposure `json:"matches"` // Largest potential payout first
+}
+
+// buildExposureReport groups per-outcome rows into matches, largest exposure first
+func buildExposureReport(rows []ExposureRow) ExposureReport {
+        report := ExposureReport{Matches: []MatchExposure{}}
+        index := make(map[string]int)
+        for _, row := range rows {
+                i, ok := index[row.MatchID]
+                if !ok {
+                        i = len(report.Matches)
+                        index[row.MatchID] = i
+                        report.Matches = append(report.Matches, MatchExposure{
+                                MatchID:      row.MatchID,
+                                HomeTeam:     row.HomeTeam,
+                                AwayTeam:     row.AwayTeam,
+                                CommenceTime: row.CommenceTime,
+                        })
+                }
+
+                match := &report.Matches[i]
+                match.Bets += row.Bets
+                match.Stake += row.Stake
+                match.PotentialPayout += row.PotentialPayout
+                match.MaxOutcomePayout = max(match.MaxOutcomePayout, row.PotentialPayout)
+                match.Outcomes = append(match.Outcomes, row.OutcomeExposure)
+
+                report.PendingBets += row.Bets
+                report.TotalStake += row.Stake
+                report.TotalPotentialPayout += row.PotentialPayout
+        }
+
+        for i := range report.Matches {
+                outcomes := report.Matches[i].Outcomes
+                sort.SliceStable(outcomes, func(a, b int) bool {
+                        return outcomes[a].PotentialPayout > outcomes[b].PotentialPayout
+                })
+        }
+        sort.SliceStable(report.Matches, func(a, b int) bool {
+                if report.Matches[a].PotentialPayout != report.Matches[b].PotentialPayout {
+                        return report.Matches[a].PotentialPayout > report.Matches[b].PotentialPayout
+                }
+                return report.Matches[a].MatchID < report.Matches[b].MatchID
+        })
+        return report
+}
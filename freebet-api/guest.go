@@ -0,0 +1,294 @@
+package main
+
+import (
+        "context"
+        "crypto/rand"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "math/big"
+        "net/http"
+
+        "golang.org/x/crypto/bcrypt"
+        "golang.org/x/oauth2"
+)
+
+// Guest nicknames are "guest" plus five digits, within the 10 character nickname limit
+const (
+        guestNicknamePrefix   = "guest"
+        guestNicknameAttempts = 5
+)
+
+// generateGuestNickname picks a random guest nickname; uniqueness is left to the database
+func generateGuestNickname() (string, error) {
+        n, err := rand.Int(rand.Reader, big.NewInt(100000))
+        if err != nil {
+                return "", err
+        }
+        return fmt.Sprintf("%s%05d", guestNicknamePrefix, n.Int64()), nil
+}
+
+// GuestHandler handles POST /api/auth/guest
+// Creates a guest account with the initial balance and logs it in without email or password
+func (h *Handler) guestHandler(w http.ResponseWriter, r *http.Request) {
+        if !h.config.GuestPlayEnabled {
+                h.writeError(w, r, http.StatusForbidden, CodeGuestPlayDisabled, "Guest play is disabled")
+                return
+        }
+
+        var req GuestRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+        if !req.AgeConfirmed {
+                h.writeError(w, r, http.StatusBadRequest, CodeAgeNotConfirmed, "You must confirm that you are 18 years or older")
+                return
+        }
+
+        // Retry the rare nickname collision
+        var user *User
+        for attempt := 0; attempt < guestNicknameAttempts && user == nil; attempt++ {
+                nickname, err := generateGuestNickname()
+                if err != nil {
+                        h.logger.LogError("Guest nickname generation failed: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Guest account creation failed")
+                        return
+                }
+                user, err = h.db.CreateGuestUser(r.Context(), nickname, h.config.InitialBalance)
+                if err != nil && !errors.Is(err, ErrNicknameTaken) {
+                        h.logger.LogError("Guest creation failed: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Guest account creation failed")
+                        return
+                }
+        }
+        if user == nil {
+                h.logger.LogError("Guest creation failed: no free nickname after %d attempts", guestNicknameAttempts)
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Guest account creation failed")
+                return
+        }
+
+        accessToken, refreshTokenString, err := h.startSession(r.Context(), w, user)
+        if err != nil {
+                h.logger.LogError("Guest session failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Guest account creation failed")
+                return
+        }
+
+        h.logger.LogSuccess("Guest account created: %s (%s)", user.Nickname, user.ID)
+
+        h.writeJSON(w, http.StatusOK, RegisterResponse{
+                Success:      true,
+                Message:      h.text(r, msgGuestCreated, "Guest account created. Add an email and password to keep it."),
+                AccessToken:  accessToken,
+                RefreshToken: refreshTokenString,
+                ExpiresIn:    accessTokenExpiresIn(h.config),
+                User: UserResponse{
+                        ID:           user.ID,
+                        Nickname:     user.Nickname,
+                        Money:        user.Money,
+                        Topup:        user.Topup,
+                        LastTopupAt:  user.LastTopupAt,
+                        AuthProvider: user.AuthProvider,
+                        IsGuest:      true,
+                },
+        })
+}
+
+// UpgradeGuestHandler handles POST /api/account/upgrade
+// Adds an email and password to the guest account; bets and balance stay with it
+func (h *Handler) upgradeGuestHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+        if !user.IsGuest {
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "Account is already registered")
+                return
+        }
+
+        var req UpgradeGuestRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+        nickname := req.Nickname
+        if nickname == "" {
+                nickname = user.Nickname
+        }
+
+        // Same rules as registration; age was confirmed when the guest was created
+        fieldErrors := validateRegistration(&RegisterRequest{
+                Email:        req.Email,
+                Password:     req.Password,
+                Nickname:     nickname,
+                AgeConfirmed: true,
+        }, h.config)
+        if _, bad := fieldErrors["email"]; !bad {
+                existingUser, err := h.db.GetUserByEmail(r.Context(), req.Email)
+                if err != nil && !errors.Is(err, ErrUserNotFound) {
+                        h.logger.LogError("Failed to look up email: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Account upgrade failed")
+                        return
+                }
+                if existingUser != nil {
+                        fieldErrors["email"] = "User with this email already exists"
+                }
+        }
+        if _, bad := fieldErrors["nickname"]; !bad && nickname != user.Nickname {
+                existingNickname, err := h.db.GetUserByNickname(r.Context(), nickname)
+                if err != nil && !errors.Is(err, ErrUserNotFound) {
+                        h.logger.LogError("Failed to look up nickname: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Account upgrade failed")
+                        return
+                }
+                if existingNickname != nil {
+                        fieldErrors["nickname"] = "Nickname is already taken"
+                }
+        }
+        if len(fieldErrors) > 0 {
+                h.logger.LogAuth("Guest upgrade rejected for %s: %v", user.ID, fieldErrors)
+                h.writeValidationErrors(w, r, fieldErrors, registrationFields)
+                return
+        }
+
+        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), h.config.BcryptCost)
+        if err != nil {
+                h.logger.LogError("Password hashing failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Account upgrade failed")
+                return
+        }
+
+        upgraded, err := h.db.UpgradeGuestUser(r.Context(), user.ID, req.Email, string(hashedPassword), nickname)
+        if errors.Is(err, ErrNotGuest) {
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "Account is already registered")
+                return
+        }
+        if errors.Is(err, ErrAccountExists) {
+                // Lost a race for the email or nickname
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "Email or nickname is already taken")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Guest upgrade failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Account upgrade failed")
+                return
+        }
+
+        // The old access token has no email; the refresh token stays valid
+        accessToken, err := generateAccessToken(upgraded, h.config)
+        if err != nil {
+                h.logger.LogError("Access token generation failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Account upgrade failed")
+                return
+        }
+
+        h.logger.LogSuccess("Guest %s upgraded to email account: %s", upgraded.ID, upgraded.Email)
+
+        h.writeJSON(w, http.StatusOK, GuestUpgradeResponse{
+                Success:     true,
+                Message:     h.text(r, msgAccountUpgraded, "Account upgraded. You can now log in with your email or nickname."),
+                AccessToken: accessToken,
+                ExpiresIn:   accessTokenExpiresIn(h.config),
+                User:        h.accountSummary(r.Context(), upgraded),
+        })
+}
+
+// UpgradeGuestGoogleHandler handles POST /api/account/upgrade/google
+// Returns the Google consent URL; the OAuth callback then links the Google account to this guest
+func (h *Handler) upgradeGuestGoogleHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+        if !user.IsGuest {
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "Account is already registered")
+                return
+        }
+        if h.config.GoogleClientID == "" || h.config.GoogleClientSecret == "" {
+                h.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Google authentication is not available")
+                return
+        }
+
+        redirectURL := sanitizeOAuthRedirectURL(r.URL.Query().Get("redirect_url"), h.config.OAuthAllowedRedirectHosts)
+        state, err := generateOAuthState(redirectURL, user.ID, h.config)
+        if err != nil {
+                h.logger.LogError("Failed to generate OAuth state: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to initiate authentication")
+                return
+        }
+
+        h.logger.LogAuth("Guest %s linking a Google account", user.ID)
+
+        h.writeJSON(w, http.StatusOK, GuestGoogleLinkResponse{
+                Success: true,
+                AuthURL: getGoogleOAuthConfig(h.config).AuthCodeURL(state, oauth2.AccessTypeOffline),
+        })
+}
+
+// linkGuestGoogle attaches a Google identity to a guest from the OAuth callback
+// ErrAccountExists when the Google account or its email is already registered
+func (h *Handler) linkGuestGoogle(ctx context.Context, guestID string, googleUser *GoogleUser) (*User, error) {
+        guest, err := h.db.GetUserByID(ctx, guestID)
+        if err != nil {
+                return nil, err
+        }
+        if guest.Disabled() {
+                return nil, ErrAccountDisabled
+        }
+
+        if _, err := h.db.GetUserByGoogleID(ctx, googleUser.ID); err == nil {
+                return nil, ErrAccountExists
+        } else if !errors.Is(err, ErrUserNotFound) {
+                return nil, err
+        }
+        if _, err := h.db.GetUserByEmail(ctx, googleUser.Email); err == nil {
+                return nil, ErrAccountExists
+        } else if !errors.Is(err, ErrUserNotFound) {
+                return nil, err
+        }
+
+        user, err := h.db.LinkGuestGoogle(ctx, guestID, googleUser.ID, googleUser.Email, googleUser.Picture)
+        if err != nil {
+                return nil, err
+        }
+        h.logger.LogSuccess("Guest %s upgraded to Google account: %s", user.ID, user.Email)
+        return user, nil
+}
+
+// writeGuestLinkError maps linkGuestGoogle errors to responses
+func (h *Handler) writeGuestLinkError(w http.ResponseWriter, r *http.Request, err error) {
+        switch {
+        case errors.Is(err, ErrAccountExists):
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "This Google account or email is already registered")
+        case errors.Is(err, ErrNotGuest):
+                h.writeError(w, r, http.StatusConflict, CodeAlreadyRegistered, "Account is already registered")
+        case errors.Is(err, ErrAccountDisabled):
+                h.writeError(w, r, http.StatusForbidden, CodeAccountDisabled, accountDisabledMessage)
+        case errors.Is(err, ErrUserNotFound):
+                h.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "Guest account not found")
+        default:
+                h.logger.LogError("Failed to link Google account: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
+        }
+}
+
+// startSession issues access and refresh tokens and sets the refresh token cookie
+func (h *Handler) startSession(ctx context.Context, w http.ResponseWriter, user *User) (string, string, error) {
+        accessToken, err := generateAccessToken(user, h.config)
+        if err != nil {
+                return "", "", err
+        }
+        refreshTokenString, err := generateRefreshToken(user.ID, h.config)
+        if err != nil {
+                return "", "", err
+        }
+        expiresAt := h.config.now().Add(h.config.JWTRefreshTokenTTL)
+        if _, err := h.db.CreateRefreshToken(ctx, user.ID, refreshTokenString, expiresAt); err != nil {
+                return "", "", err
+        }
+        h.setRefreshTokenCookie(w, refreshTokenString)
+        return accessToken, refreshTokenString, nil
+}
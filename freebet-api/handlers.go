@@ -2,33 +2,53 @@ package main
 
 import (
         "context"
+        "database/sql"
         "encoding/json"
+        "errors"
         "fmt"
-        "net"
+        "io"
+        "math"
         "net/http"
-        "net/url"
         "regexp"
         "strconv"
         "strings"
+        "sync"
         "time"
 
+        "github.com/gorilla/mux"
         "golang.org/x/crypto/bcrypt"
         "golang.org/x/oauth2"
 )
 
 // Handler struct contains dependencies
 type Handler struct {
-        db     Database
-        config *Config
-        logger *Logger
+        db      Database
+        config  *Config
+        logger  *Logger
+        sync     *SyncService
+        matches  *MatchesCache
+        stats    *StatsCache
+        pictures PictureStore
+        maintenance *Maintenance
+        userCreatedHook UserCreatedHook // Signup hooks; nil if none are configured
+
+        trustedProxies []CIDR // Parsed TRUSTED_PROXIES for ClientIP
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(db Database, config *Config, logger *Logger) *Handler {
+func NewHandler(db Database, config *Config, logger *Logger, syncService *SyncService) *Handler {
         return &Handler{
-                db:     db,
-                config: config,
-                logger: logger,
+                db:      db,
+                config:  config,
+                logger:  logger,
+                sync:     syncService,
+                matches:  syncService.matches,
+                stats:    NewStatsCache(config),
+                pictures: NewPictureStore(config),
+                maintenance: NewMaintenance(config),
+                userCreatedHook: NewUserCreatedHooks(config, logger),
+
+                trustedProxies: config.trustedProxyCIDRs(),
         }
 }
 
@@ -38,10 +58,82 @@ func validateEmail(email string) bool {
         return emailRegex.MatchString(email)
 }
 
-// Health check handler
+// registrationFields orders the register request fields for the top-level error
+var registrationFields = []string{"email", "password", "nickname", "age_confirmed"}
+
+// validateRegistration checks a registration request without touching the database
+// Returns one message per invalid field (JSON field names), empty when valid
+func validateRegistration(req *RegisterRequest, config *Config) map[string]string {
+        fieldErrors := make(map[string]string)
+
+        switch {
+        case req.Email == "":
+                fieldErrors["email"] = "Email is required"
+        case !validateEmail(req.Email):
+                fieldErrors["email"] = "Invalid email format"
+        case isBlockedEmailDomain(req.Email, config):
+                // Reject disposable/throwaway email domains
+                fieldErrors["email"] = "Email domain is not allowed"
+        }
+
+        if req.Password == "" {
+                fieldErrors["password"] = "Password is required"
+        } else if err := ValidatePassword(req.Password, config); err != nil {
+                fieldErrors["password"] = err.Error()
+        }
+
+        if req.Nickname == "" {
+                fieldErrors["nickname"] = "Nickname is required"
+        } else if err := validateNickname(req.Nickname); err != nil {
+                fieldErrors["nickname"] = err.Error()
+        }
+
+        if !req.AgeConfirmed {
+                fieldErrors["age_confirmed"] = "You must confirm that you are 18 years or older"
+        }
+
+        return fieldErrors
+}
+
+// validateNickname checks the nickname length rules shared by registration and renames
+func validateNickname(nickname string) error {
+        if len(nickname) < 3 || len(nickname) > 10 {
+                return errors.New("Nickname must be between 3 and 10 characters")
+        }
+        return nil
+}
+
+// HealthHandler handles GET /api/health
+// Public and cheap: only a database ping, no counts or deployment details
+// HEALTH_DETAILED_PUBLIC=true serves the detailed response here for older monitors
 func (h *Handler) healthHandler(w http.ResponseWriter, r *http.Request) {
+        if h.config.HealthDetailedPublic {
+                h.healthDetailedHandler(w, r)
+                return
+        }
+
+        ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+        defer cancel()
+        if err := h.db.Ping(ctx); err != nil {
+                h.logger.LogError("Health check database ping failed: %s", err.Error())
+                h.writeJSON(w, http.StatusServiceUnavailable, PublicHealthResponse{Ok: false, Status: "unavailable", Version: version})
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, PublicHealthResponse{Ok: true, Status: "ok", Version: version})
+}
+
+// HealthLiveHandler handles GET /api/health/live
+// Liveness only: answers while the process serves requests, without touching the database
+func (h *Handler) healthLiveHandler(w http.ResponseWriter, r *http.Request) {
+        h.writeJSON(w, http.StatusOK, PublicHealthResponse{Ok: true, Status: "ok", Version: version})
+}
+
+// HealthDetailedHandler handles GET /api/health/detailed (admin)
+// Includes row counts, the client IP, the port and connection pool stats
+func (h *Handler) healthDetailedHandler(w http.ResponseWriter, r *http.Request) {
         // Get database statistics
-        stats, err := h.db.GetDatabaseStats()
+        stats, err := h.db.GetDatabaseStats(r.Context())
         databaseStatus := "ok"
         if err != nil {
                 h.logger.LogError("Failed to get database stats: %s", err.Error())
@@ -59,7 +151,7 @@ func (h *Handler) healthHandler(w http.ResponseWriter, r *http.Request) {
                 stats["users"], stats["sessions"], stats["bets"], stats["matches"])
 
         // Get real client IP (not local server IP)
-        clientIP := h.getClientIP(r)
+        clientIP := ClientIP(r, h.trustedProxies)
 
         // Calculate uptime in seconds
         uptimeSeconds := int64(time.Since(h.logger.startTime).Seconds())
@@ -72,7 +164,7 @@ func (h *Handler) healthHandler(w http.ResponseWriter, r *http.Request) {
                 UptimeSeconds: uptimeSeconds,
                 ClientIP:      clientIP,
                 Time:          time.Now().Format(time.RFC3339),
-                Version:       "1.0.0",
+                Version:       version,
 
                 // Statistics
                 UsersCount:    stats["users"],
@@ -82,9 +174,30 @@ func (h *Handler) healthHandler(w http.ResponseWriter, r *http.Request) {
                 Port:          h.config.Port,
         }
 
+        // Connection pool stats (PostgreSQL only)
+        if pool, ok := h.db.(poolStatsProvider); ok {
+                response.Pool = pool.PoolStats()
+        }
+
         h.writeJSON(w, http.StatusOK, response)
 }
 
+// statsHandler handles GET /api/stats
+func (h *Handler) statsHandler(w http.ResponseWriter, r *http.Request) {
+        stats, generatedAt, err := h.stats.Get(r.Context(), h.db.GetPlatformStats)
+        if err != nil {
+                h.logger.LogError("Failed to get platform stats: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get stats")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, StatsResponse{
+                Success:     true,
+                Stats:       *stats,
+                GeneratedAt: generatedAt.UTC(),
+        })
+}
+
 // Root endpoint handler
 func (h *Handler) rootHandler(w http.ResponseWriter, r *http.Request) {
         response := RootResponse{
@@ -95,6 +208,8 @@ func (h *Handler) rootHandler(w http.ResponseWriter, r *http.Request) {
                         "bets":    "/api/bets",
                         "matches": "/api/matches",
                         "players": "/api/players",
+                        "stats":   "/api/stats",
+                        "docs":    "/api/docs",
                 },
         }
 
@@ -109,50 +224,37 @@ func (h *Handler) registerHandler(w http.ResponseWriter, r *http.Request) {
 
         var req RegisterRequest
         if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                h.writeError(w, http.StatusBadRequest, "Invalid JSON")
-                return
-        }
-
-        // Validate input
-        if req.Email == "" || req.Password == "" || req.Nickname == "" {
-                h.writeError(w, http.StatusBadRequest, "Email, password and nickname are required")
-                return
-        }
-
-        // Validate email format
-        if !validateEmail(req.Email) {
-                h.writeError(w, http.StatusBadRequest, "Invalid email format")
-                return
-        }
-
-        // Validate nickname length
-        if len(req.Nickname) < 3 || len(req.Nickname) > 10 {
-                h.writeError(w, http.StatusBadRequest, "Nickname must be between 3 and 10 characters")
-                return
-        }
-
-        // Validate age confirmation
-        if !req.AgeConfirmed {
-                h.writeError(w, http.StatusBadRequest, "You must confirm that you are 18 years or older")
-                return
-        }
-
-        if len(req.Password) < h.config.MinPasswordLength {
-                h.writeError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters long", h.config.MinPasswordLength))
+                h.writeDecodeError(w, r, err)
                 return
         }
 
-        // Check if user exists
-        existingUser, _ := h.db.GetUserByEmail(req.Email)
-        existingNickname, _ := h.db.GetUserByNickname(req.Nickname)
-        if existingUser != nil || existingNickname != nil {
-                var errorMsg string
+        // Validate input; every failing field is reported at once
+        fieldErrors := validateRegistration(&req, h.config)
+        if _, bad := fieldErrors["email"]; !bad {
+                existingUser, err := h.db.GetUserByEmail(r.Context(), req.Email)
+                if err != nil && !errors.Is(err, ErrUserNotFound) {
+                        h.logger.LogError("Failed to look up email: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
+                        return
+                }
                 if existingUser != nil {
-                        errorMsg = "User with this email already exists"
-                } else {
-                        errorMsg = "Nickname is already taken"
+                        fieldErrors["email"] = "User with this email already exists"
+                }
+        }
+        if _, bad := fieldErrors["nickname"]; !bad {
+                existingNickname, err := h.db.GetUserByNickname(r.Context(), req.Nickname)
+                if err != nil && !errors.Is(err, ErrUserNotFound) {
+                        h.logger.LogError("Failed to look up nickname: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
+                        return
                 }
-                h.writeError(w, http.StatusBadRequest, errorMsg)
+                if existingNickname != nil {
+                        fieldErrors["nickname"] = "Nickname is already taken"
+                }
+        }
+        if len(fieldErrors) > 0 {
+                h.logger.LogAuth("Registration rejected for %s: %v", req.Email, fieldErrors)
+                h.writeValidationErrors(w, r, fieldErrors, registrationFields)
                 return
         }
 
@@ -161,18 +263,19 @@ func (h *Handler) registerHandler(w http.ResponseWriter, r *http.Request) {
         hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), h.config.BcryptCost)
         if err != nil {
                 h.logger.LogError("Password hashing failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Registration failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
                 return
         }
 
         // Create user
         h.logger.LogAuth("Creating user record: %s", req.Email)
-        user, err := h.db.CreateUser(req.Email, string(hashedPassword), req.Nickname, h.config.InitialBalance)
+        user, err := h.db.CreateUser(r.Context(), req.Email, string(hashedPassword), req.Nickname, h.config.InitialBalance)
         if err != nil {
                 h.logger.LogError("User creation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Registration failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
                 return
         }
+        h.userCreated(user, signupPassword)
 
         // Generate JWT tokens
         h.logger.LogAuth("Generating JWT tokens for user: %s", user.ID)
@@ -180,23 +283,23 @@ func (h *Handler) registerHandler(w http.ResponseWriter, r *http.Request) {
         accessToken, err := generateAccessToken(user, h.config)
         if err != nil {
                 h.logger.LogError("Access token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Registration failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
                 return
         }
 
         refreshTokenString, err := generateRefreshToken(user.ID, h.config)
         if err != nil {
                 h.logger.LogError("Refresh token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Registration failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
                 return
         }
 
         // Store refresh token in database
-        expiresAt := time.Now().Add(h.config.JWTRefreshTokenTTL)
-        _, err = h.db.CreateRefreshToken(user.ID, refreshTokenString, expiresAt)
+        expiresAt := h.config.now().Add(h.config.JWTRefreshTokenTTL)
+        _, err = h.db.CreateRefreshToken(r.Context(), user.ID, refreshTokenString, expiresAt)
         if err != nil {
                 h.logger.LogError("Refresh token storage failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Registration failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Registration failed")
                 return
         }
 
@@ -207,9 +310,10 @@ func (h *Handler) registerHandler(w http.ResponseWriter, r *http.Request) {
 
         response := RegisterResponse{
                 Success:   true,
-                Message:   "Registration successful! You are now logged in.",
+                Message:   h.text(r, msgRegistered, "Registration successful! You are now logged in."),
                 AccessToken:  accessToken,
                 RefreshToken: refreshTokenString,
+                ExpiresIn:    accessTokenExpiresIn(h.config),
                 User: UserResponse{
                         ID:           user.ID,
                         Email:        user.Email,
@@ -230,58 +334,72 @@ func (h *Handler) loginHandler(w http.ResponseWriter, r *http.Request) {
 
         var req LoginRequest
         if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+                h.writeDecodeError(w, r, err)
                 return
         }
 
         if req.Identifier == "" || req.Password == "" {
-                h.writeError(w, http.StatusBadRequest, "Identifier and password are required")
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Identifier and password are required")
                 return
         }
 
         // Find user by email or nickname
         h.logger.LogAuth("Looking up user: %s", req.Identifier)
-        user, err := h.db.GetUserByEmail(req.Identifier)
+        user, err := h.db.GetUserByEmailOrNickname(r.Context(), req.Identifier)
         if err != nil {
-                user, err = h.db.GetUserByNickname(req.Identifier)
+                if !errors.Is(err, ErrUserNotFound) {
+                        // A database outage must not look like bad credentials
+                        h.logger.LogError("Failed to look up user: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Login failed")
+                        return
+                }
+                user = nil
         }
-        if err != nil {
-                h.logger.LogAuth("User not found: %s", req.Identifier)
-                h.writeError(w, http.StatusUnauthorized, "Invalid email/nickname or password")
+
+        // Verify password - bcrypt runs even for unknown users so timing doesn't reveal which accounts exist
+        if !h.verifyLoginPassword(user, req.Password) {
+                if user == nil {
+                        h.logger.LogAuth("User not found: %s", req.Identifier)
+                } else {
+                        h.logger.LogAuth("Invalid password for user: %s", user.ID)
+                }
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid email/nickname or password")
                 return
         }
 
-        // Verify password
-        h.logger.LogAuth("Verifying password for user: %s", user.ID)
-        if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(req.Password)); err != nil {
-                h.logger.LogAuth("Invalid password for user: %s", user.ID)
-                h.writeError(w, http.StatusUnauthorized, "Invalid email/nickname or password")
+        // Checked after the password so disabled accounts aren't revealed to guessers
+        if user.Disabled() {
+                h.logger.LogAuth("Login rejected for disabled user: %s", user.ID)
+                h.writeError(w, r, http.StatusForbidden, CodeAccountDisabled, accountDisabledMessage)
                 return
         }
 
+        // Hashes from before a BCRYPT_COST increase are upgraded while the plaintext is at hand
+        h.upgradePasswordHash(r.Context(), user, req.Password)
+
         // Generate JWT tokens
         h.logger.LogAuth("Generating JWT tokens for user: %s", user.ID)
 
         accessToken, err := generateAccessToken(user, h.config)
         if err != nil {
                 h.logger.LogError("Access token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Login failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Login failed")
                 return
         }
 
         refreshTokenString, err := generateRefreshToken(user.ID, h.config)
         if err != nil {
                 h.logger.LogError("Refresh token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Login failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Login failed")
                 return
         }
 
         // Store refresh token in database
-        expiresAt := time.Now().Add(h.config.JWTRefreshTokenTTL)
-        _, err = h.db.CreateRefreshToken(user.ID, refreshTokenString, expiresAt)
+        expiresAt := h.config.now().Add(h.config.JWTRefreshTokenTTL)
+        _, err = h.db.CreateRefreshToken(r.Context(), user.ID, refreshTokenString, expiresAt)
         if err != nil {
                 h.logger.LogError("Refresh token storage failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Login failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Login failed")
                 return
         }
 
@@ -294,6 +412,7 @@ func (h *Handler) loginHandler(w http.ResponseWriter, r *http.Request) {
                 Success:      true,
                 AccessToken:  accessToken,
                 RefreshToken: refreshTokenString,
+                ExpiresIn:    accessTokenExpiresIn(h.config),
                 User: UserResponse{
                         ID:           user.ID,
                         Email:        user.Email,
@@ -312,57 +431,64 @@ func (h *Handler) loginHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) userHandler(w http.ResponseWriter, r *http.Request) {
         h.logger.LogAuth("Validating JWT token...")
 
-        // Get access token from Authorization header
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-                h.logger.LogAuth("No JWT token found in Authorization header")
-                h.writeError(w, http.StatusUnauthorized, "No access token")
-                return
-        }
-
-        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-        // Validate JWT token
-        claims, err := validateAccessToken(tokenString, h.config)
-        if err != nil {
-                h.logger.LogAuth("Invalid JWT token: %s", err.Error())
-                h.writeError(w, http.StatusUnauthorized, "Invalid access token")
-                return
-        }
-
-        // Get user data
-        user, err := h.db.GetUserByID(claims.UserID)
-        if err != nil {
-                h.logger.LogError("Failed to get user data: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "User not found")
+        // Authenticated user (set by jwtAuthMiddleware)
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        // Get user betting stats
-        bets, wonBets, settledBets, avgOdds, _ := h.db.GetUserStats(user.ID)
-
         h.logger.LogSuccess("Session valid for user: %s", user.Nickname)
 
         response := LoginResponse{
                 Success: true,
-                User: UserResponse{
-                        ID:           user.ID,
-                        Email:        user.Email,
-                        Nickname:     user.Nickname,
-                        Money:        user.Money,
-                        Topup:        user.Topup,
-                        LastTopupAt:  user.LastTopupAt,
-                        Bets:         bets,
-                        WonBets:      wonBets,
-                        SettledBets:  settledBets,
-                        AvgOdds:      avgOdds,
-                        AuthProvider: user.AuthProvider,
-                },
+                User:    h.accountSummary(r.Context(), user),
+        }
+        if expiresAt, ok := getTokenExpiryFromContext(r.Context()); ok {
+                expiresAt = expiresAt.UTC()
+                response.ExpiresAt = &expiresAt
         }
 
         h.writeJSON(w, http.StatusOK, response)
 }
 
+// accountSummary builds the full user response with betting stats and remaining self-limits
+func (h *Handler) accountSummary(ctx context.Context, user *User) UserResponse {
+        // Get user betting stats
+        bets, wonBets, settledBets, pushBets, avgOdds, _ := h.db.GetUserStats(ctx, user.ID)
+
+        // Remaining self-limits, omitted when none are set
+        var limitsSummary *LimitsSummary
+        if limits, err := h.db.GetUserLimits(ctx, user.ID); err != nil {
+                h.logger.LogError("Failed to get limits for user %s: %s", user.ID, err.Error())
+        } else if summary, err := h.summarizeLimits(ctx, limits); err != nil {
+                h.logger.LogError("Failed to compute limits for user %s: %s", user.ID, err.Error())
+        } else if summary.hasLimits() {
+                limitsSummary = summary
+        }
+
+        // Only the cooldown end; the full status is GET /api/account/topup-status
+        topup := evaluateTopup(user, user.LastTopupAt, h.config, h.config.now())
+
+        return UserResponse{
+                ID:           user.ID,
+                Email:        user.Email,
+                Nickname:     user.Nickname,
+                Money:        user.Money,
+                Topup:        user.Topup,
+                LastTopupAt:  user.LastTopupAt,
+                NextTopupAt:  topup.NextAvailableAt,
+                Bets:         bets,
+                WonBets:      wonBets,
+                SettledBets:  settledBets,
+                PushBets:     pushBets,
+                AvgOdds:      avgOdds,
+                AuthProvider: user.AuthProvider,
+                IsGuest:      user.IsGuest,
+                Limits:       limitsSummary,
+        }
+}
+
 // Logout handler
 func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
         h.logger.LogAuth("Processing logout request")
@@ -371,7 +497,7 @@ func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
         if err == nil && cookie.Value != "" {
                 // Delete refresh token from database
                 h.logger.LogAuth("Deleting refresh token from database")
-                h.db.DeleteRefreshToken(cookie.Value)
+                h.db.DeleteRefreshToken(r.Context(), cookie.Value)
         }
 
         // Clear refresh token cookie
@@ -381,82 +507,83 @@ func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
         h.writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
-// Topup handler
-func (h *Handler) topupHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogAuth("Starting balance top-up process...")
+// Logout all handler - revokes every refresh token and outstanding access token of the user
+func (h *Handler) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogAuth("Processing logout from all devices")
 
-        // Get JWT token from Authorization header
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-                h.logger.LogAuth("No JWT token found in Authorization header")
-                h.writeError(w, http.StatusUnauthorized, "No access token")
+        // Authenticated user (set by jwtAuthMiddleware)
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-        // Validate JWT token
-        claims, err := validateAccessToken(tokenString, h.config)
-        if err != nil {
-                h.logger.LogAuth("Invalid JWT token: %s", err.Error())
-                h.writeError(w, http.StatusUnauthorized, "Invalid access token")
+        if err := h.db.DeleteAllUserRefreshTokens(r.Context(), user.ID); err != nil {
+                h.logger.LogError("Refresh token deletion failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Logout failed")
                 return
         }
 
-        // Get user data
-        user, err := h.db.GetUserByID(claims.UserID)
-        if err != nil {
-                h.logger.LogError("User not found: %s", err.Error())
-                h.writeError(w, http.StatusNotFound, "User not found")
+        if err := h.db.IncrementUserTokenVersion(r.Context(), user.ID); err != nil {
+                h.logger.LogError("Token version update failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Logout failed")
                 return
         }
 
-        h.logger.LogAuth("Processing top-up for user: %s", user.ID)
+        // Clear refresh token cookie
+        h.clearRefreshTokenCookie(w)
 
-        // Check balance
-        if user.Money >= h.config.MaxTopupBalance {
-                h.logger.LogAuth("Top-up not allowed: balance $%.2f >= $%.2f", user.Money, h.config.MaxTopupBalance)
-                h.writeError(w, http.StatusBadRequest, "Top-up not available. Balance must be less than $500.")
+        h.logger.LogSuccess("Logout from all devices successful for user: %s", user.ID)
+        h.writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// Topup handler
+func (h *Handler) topupHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogAuth("Starting balance top-up process...")
+
+        // Authenticated user (set by jwtAuthMiddleware)
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        // Check if user has already topped up today
-        lastTopupTime, err := h.db.GetUserLastTopupTime(user.ID)
+        h.logger.LogAuth("Processing top-up for user: %s", user.ID)
+
+        // Same rules as GET /api/account/topup-status; without the last top-up time the cooldown can't be checked
+        lastTopupTime, err := h.db.GetUserLastTopupTime(r.Context(), user.ID)
         if err != nil {
                 h.logger.LogError("Failed to get last topup time: %s", err.Error())
-                // Don't fail the request, just log
-        } else if lastTopupTime != nil {
-                // Check if last topup was less than 24 hours ago
-                timeSinceLastTopup := time.Since(*lastTopupTime)
-                if timeSinceLastTopup < 24*time.Hour {
-                        hoursRemaining := 24 - int(timeSinceLastTopup.Hours())
-                        minutesRemaining := 60 - int(timeSinceLastTopup.Minutes()) % 60
-                        h.logger.LogAuth("Top-up not allowed: last topup was %v ago", timeSinceLastTopup)
-                        h.writeError(w, http.StatusBadRequest, fmt.Sprintf("You can only top up once per day. Please wait %d hours and %d minutes.", hoursRemaining, minutesRemaining))
-                        return
-                }
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Top-up failed")
+                return
         }
-
-        // Update balance and increment topup counter
-        newBalance := user.Money + h.config.TopupAmount
-        h.logger.LogAuth("Balance will be updated: $%.2f → $%.2f", user.Money, newBalance)
-
-        if err := h.db.UpdateUserMoney(user.ID, newBalance); err != nil {
-                h.logger.LogError("Balance update failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Top-up failed")
+        now := h.config.now()
+        status := evaluateTopup(user, lastTopupTime, h.config, now)
+        switch status.Reason {
+        case topupBlockedBalance:
+                h.logger.LogAuth("Top-up not allowed: balance $%.2f >= $%.2f", user.Money, h.config.MaxTopupBalance)
+                h.writeError(w, r, http.StatusBadRequest, CodeTopupBalanceTooHigh, fmt.Sprintf("Top-up not available. Balance must be less than $%.0f.", h.config.MaxTopupBalance))
+                return
+        case topupBlockedCooldown:
+                hoursRemaining, minutesRemaining := formatWait(status.NextAvailableAt.Sub(now))
+                h.logger.LogAuth("Top-up not allowed: last topup was %v ago", now.Sub(*lastTopupTime))
+                h.writeError(w, r, http.StatusBadRequest, CodeTopupCooldown, fmt.Sprintf("You can only top up once per day. Please wait %d hours and %d minutes.", hoursRemaining, minutesRemaining))
                 return
         }
 
-        if err := h.db.IncrementUserTopup(user.ID); err != nil {
-                h.logger.LogError("Topup counter update failed: %s", err.Error())
-                // Don't fail the request, just log
+        // Credit the balance and increment the topup counter atomically
+        newBalance, err := h.db.TopupUser(r.Context(), user.ID, h.config.TopupAmount)
+        if err != nil {
+                h.logger.LogError("Balance update failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Top-up failed")
+                return
         }
 
         h.logger.LogSuccess("Balance updated successfully: $%.2f → $%.2f", user.Money, newBalance)
 
         response := TopupResponse{
                 Success:    true,
-                Message:    "Balance topped up successfully! Added $10,000.",
+                Message:    h.text(r, msgToppedUp, "Balance topped up successfully! Added $10,000."),
                 NewBalance: newBalance,
         }
 
@@ -467,29 +594,10 @@ func (h *Handler) topupHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
         h.logger.LogAuth("Starting password change process...")
 
-        // Get JWT token from Authorization header
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-                h.logger.LogAuth("No JWT token found in Authorization header")
-                h.writeError(w, http.StatusUnauthorized, "No access token")
-                return
-        }
-
-        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-        // Validate JWT token
-        claims, err := validateAccessToken(tokenString, h.config)
-        if err != nil {
-                h.logger.LogAuth("Invalid JWT token: %s", err.Error())
-                h.writeError(w, http.StatusUnauthorized, "Invalid access token")
-                return
-        }
-
-        // Get user data
-        user, err := h.db.GetUserByID(claims.UserID)
-        if err != nil {
-                h.logger.LogError("User not found: %s", err.Error())
-                h.writeError(w, http.StatusNotFound, "User not found")
+        // Authenticated user (set by jwtAuthMiddleware)
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
@@ -497,17 +605,17 @@ func (h *Handler) changePasswordHandler(w http.ResponseWriter, r *http.Request)
 
         var req ChangePasswordRequest
         if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+                h.writeDecodeError(w, r, err)
                 return
         }
 
         if req.CurrentPassword == "" || req.NewPassword == "" {
-                h.writeError(w, http.StatusBadRequest, "Current password and new password are required")
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Current password and new password are required")
                 return
         }
 
-        if len(req.NewPassword) < h.config.MinPasswordLength {
-                h.writeError(w, http.StatusBadRequest, fmt.Sprintf("New password must be at least %d characters long", h.config.MinPasswordLength))
+        if err := ValidatePassword(req.NewPassword, h.config); err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeWeakPassword, err.Error())
                 return
         }
 
@@ -515,7 +623,7 @@ func (h *Handler) changePasswordHandler(w http.ResponseWriter, r *http.Request)
         h.logger.LogAuth("Verifying current password...")
         if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(req.CurrentPassword)); err != nil {
                 h.logger.LogAuth("Current password is incorrect")
-                h.writeError(w, http.StatusBadRequest, "Current password is incorrect")
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidCredentials, "Current password is incorrect")
                 return
         }
 
@@ -524,650 +632,2045 @@ func (h *Handler) changePasswordHandler(w http.ResponseWriter, r *http.Request)
         hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), h.config.BcryptCost)
         if err != nil {
                 h.logger.LogError("Password hashing failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Password change failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Password change failed")
                 return
         }
 
         // Update password
         h.logger.LogAuth("Updating password in database...")
-        if err := h.db.UpdateUserPassword(user.ID, string(hashedPassword)); err != nil {
+        if err := h.db.UpdateUserPassword(r.Context(), user.ID, string(hashedPassword)); err != nil {
                 h.logger.LogError("Password update failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Password change failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Password change failed")
                 return
         }
 
         h.logger.LogSuccess("Password updated successfully for user: %s", user.ID)
 
-        h.writeJSON(w, http.StatusOK, map[string]bool{"success": true})
-}
-
-// BETS HANDLERS
+        // Invalidate access tokens issued before the password change
+        if err := h.db.IncrementUserTokenVersion(r.Context(), user.ID); err != nil {
+                h.logger.LogError("Token version update failed: %s", err.Error())
+                h.writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+                return
+        }
+        user.TokenVersion++
 
-// Get bets handler
-func (h *Handler) getBetsHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogBets("Getting user bets from PostgreSQL...")
+        // Issue a fresh access token so the current session keeps working
+        accessToken, err := generateAccessToken(user, h.config)
+        if err != nil {
+                h.logger.LogError("Access token generation failed: %s", err.Error())
+                h.writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+                return
+        }
 
-        // Check if requesting bets for another player
-        playerParam := r.URL.Query().Get("player")
-        var targetUserID string
-        var targetUser *User
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "success":      true,
+                "access_token": accessToken,
+                "expires_in":   accessTokenExpiresIn(h.config),
+        })
+}
 
-        if playerParam != "" {
-                // Viewing another player's bets - no auth required
-                h.logger.LogBets("Requesting bets for player: %s", playerParam)
-                var err error
-                targetUser, err = h.db.GetUserByNickname(playerParam)
-                if err != nil {
-                        h.logger.LogBets("Player %s not found", playerParam)
-                        h.writeError(w, http.StatusNotFound, "Player not found")
-                        return
-                }
-                targetUserID = targetUser.ID
-                h.logger.LogBets("Viewing bets for player: %s (%s)", playerParam, targetUserID)
-        } else {
-                // Viewing own bets - JWT required
-                h.logger.LogBets("Validating JWT for own bets...")
+// ChangeNicknameHandler handles POST /api/account/nickname
+// Leaderboard and bet views join on users, so the new nickname shows up immediately
+func (h *Handler) changeNicknameHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
 
-                authHeader := r.Header.Get("Authorization")
-                if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-                        h.logger.LogBets("No JWT token found in Authorization header")
-                        h.writeError(w, http.StatusUnauthorized, "No access token")
-                        return
-                }
+        var req ChangeNicknameRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
 
-                tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+        if err := validateNickname(req.Nickname); err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidNickname, err.Error())
+                return
+        }
+        if req.Nickname == user.Nickname {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "New nickname must be different from the current one")
+                return
+        }
 
-                claims, err := validateAccessToken(tokenString, h.config)
-                if err != nil {
-                        h.logger.LogBets("Invalid JWT token: %s", err.Error())
-                        h.writeError(w, http.StatusUnauthorized, "Invalid access token")
+        // Enforce the change cooldown
+        changedAt, err := h.db.GetUserNicknameChangedAt(r.Context(), user.ID)
+        if err != nil {
+                h.logger.LogError("Failed to get nickname change time: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Nickname change failed")
+                return
+        }
+        if changedAt != nil && h.config.NicknameChangeCooldown > 0 {
+                nextChange := changedAt.Add(h.config.NicknameChangeCooldown)
+                if h.config.now().Before(nextChange) {
+                        h.logger.LogAuth("Nickname change not allowed for user %s until %s", user.ID, nextChange.Format(time.RFC3339))
+                        h.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+                                "success":        false,
+                                "code":           CodeNicknameChangeTooSoon,
+                                "error":          h.text(r, CodeNicknameChangeTooSoon, fmt.Sprintf("You can change your nickname again after %s", nextChange.UTC().Format(time.RFC3339))),
+                                "next_change_at": nextChange.UTC().Format(time.RFC3339),
+                        })
                         return
                 }
+        }
 
-                targetUserID = claims.UserID
+        existing, err := h.db.GetUserByNickname(r.Context(), req.Nickname)
+        if err != nil && !errors.Is(err, ErrUserNotFound) {
+                h.logger.LogError("Failed to look up nickname: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Nickname change failed")
+                return
+        }
+        if existing != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeNicknameTaken, "Nickname is already taken")
+                return
         }
 
-        // Get bets
-        bets, err := h.db.GetUserBets(targetUserID, playerParam)
+        updated, err := h.db.UpdateUserNickname(r.Context(), user.ID, req.Nickname)
+        if errors.Is(err, ErrNicknameTaken) {
+                h.writeError(w, r, http.StatusBadRequest, CodeNicknameTaken, "Nickname is already taken")
+                return
+        }
         if err != nil {
-                h.logger.LogError("Failed to get bets: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get bets")
+                h.logger.LogError("Nickname update failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Nickname change failed")
                 return
         }
 
-        h.logger.LogBets("Found %d bets for user", len(bets))
-
-        // If viewing another player's bets, return extended response with player info and stats
-        if playerParam != "" && targetUser != nil {
-                // Calculate stats
-                wonBets := 0
-                settledBets := 0
-                totalOdds := 0.0
-                for _, bet := range bets {
-                        if bet.Status == "won" {
-                                wonBets++
-                                settledBets++
-                        } else if bet.Status == "lost" {
-                                settledBets++
-                        }
-                        totalOdds += bet.Odds
-                }
-
-                avgOdds := 0.0
-                if len(bets) > 0 {
-                        avgOdds = totalOdds / float64(len(bets))
-                }
-
-                winRate := 0.0
-                if settledBets > 0 {
-                        winRate = float64(wonBets) / float64(settledBets) * 100
-                }
-
-                response := map[string]interface{}{
-                        "success": true,
-                        "player": map[string]interface{}{
-                                "id":       targetUser.ID,
-                                "nickname": targetUser.Nickname,
-                                "money":    targetUser.Money,
-                                "created":  targetUser.CreatedAt,
-                        },
-                        "bets": bets,
-                        "stats": map[string]interface{}{
-                                "total_bets":   len(bets),
-                                "won_bets":     wonBets,
-                                "settled_bets": settledBets,
-                                "win_rate":     winRate,
-                                "avg_odds":     avgOdds,
-                        },
-                }
+        h.logger.LogSuccess("Nickname changed for user %s: %s -> %s", user.ID, user.Nickname, updated.Nickname)
 
-                h.writeJSON(w, http.StatusOK, response)
-                return
+        response := ChangeNicknameResponse{
+                Success: true,
+                User:    h.accountSummary(r.Context(), updated),
         }
-
-        // Standard response for own bets
-        var betDisplays []BetDisplay
-        for _, bet := range bets {
-                betDisplays = append(betDisplays, BetDisplay{
-                        ID:           bet.BetID,
-                        MatchID:      bet.MatchID,
-                        BetType:      bet.BetType,
-                        BetAmount:    bet.BetAmount,
-                        Odds:         bet.Odds,
-                        PotentialWin: bet.PotentialWin,
-                        Status:       bet.Status,
-                        HomeTeam:     bet.HomeTeam,
-                        AwayTeam:     bet.AwayTeam,
-                        CreatedAt:    bet.CreatedAt,
-                        CommenceTime: bet.CommenceTime,
-                })
+        if h.config.NicknameChangeCooldown > 0 {
+                nextChange := h.config.now().UTC().Add(h.config.NicknameChangeCooldown).Truncate(time.Second)
+                response.NextChangeAt = &nextChange
         }
 
-        response := BetsResponse{
-                Success: true,
-                Bets:    betDisplays,
+        // The access token carries the nickname claim; reissue it so clients see the new one
+        if accessToken, err := generateAccessToken(updated, h.config); err != nil {
+                h.logger.LogError("Access token generation failed: %s", err.Error())
+        } else {
+                response.AccessToken = accessToken
         }
 
         h.writeJSON(w, http.StatusOK, response)
 }
 
-// Place bet handler
-func (h *Handler) placeBetHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogBets("Placing a new bet...")
-
-        // Get JWT token from Authorization header
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-                h.logger.LogBets("No JWT token found in Authorization header")
-                h.writeError(w, http.StatusUnauthorized, "No access token")
+// GetLedgerHandler handles GET /api/account/ledger
+// Lists every balance change with the running balance, newest first
+func (h *Handler) getLedgerHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-        h.logger.LogBets("Validating JWT token...")
-
-        // Validate JWT token
-        claims, err := validateAccessToken(tokenString, h.config)
+        limit, offset := h.pageParams(r)
+        entries, total, err := h.db.GetLedger(r.Context(), user.ID, limit, offset)
         if err != nil {
-                h.logger.LogBets("Invalid JWT token: %s", err.Error())
-                h.writeError(w, http.StatusUnauthorized, "Invalid access token")
+                h.logger.LogError("Failed to get ledger: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get ledger")
                 return
         }
+        if entries == nil {
+                entries = []LedgerEntry{}
+        }
 
-        // Get user data
-        user, err := h.db.GetUserByID(claims.UserID)
-        if err != nil {
-                h.logger.LogError("User not found: %s", err.Error())
-                h.writeError(w, http.StatusNotFound, "User not found")
+        h.writeJSON(w, http.StatusOK, LedgerResponse{
+                Success: true,
+                Entries: entries,
+                Pagination: PaginationInfo{
+                        Limit:   limit,
+                        Offset:  offset,
+                        Total:   total,
+                        HasMore: offset+limit < total,
+                },
+        })
+}
+
+// UploadPictureHandler handles PUT /api/account/picture
+// Accepts a multipart "picture" field; the type is sniffed from the content, not trusted from the client
+func (h *Handler) uploadPictureHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        var req PlaceBetRequest
-        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-                h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+        maxBytes := int64(h.config.PictureMaxBytes)
+        tooLarge := fmt.Sprintf("Picture must be at most %d KB", (maxBytes+1023)/1024)
+
+        // Leave room for the multipart envelope around the file
+        r.Body = http.MaxBytesReader(w, r.Body, pictureUploadLimit(h.config))
+        file, _, err := r.FormFile("picture")
+        if err != nil {
+                var maxErr *http.MaxBytesError
+                if errors.As(err, &maxErr) {
+                        h.writeError(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, tooLarge)
+                        return
+                }
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Expected a multipart form with a \"picture\" file")
                 return
         }
+        defer file.Close()
 
-        // Validate request
-        if req.MatchID == "" || req.BetType == "" || req.BetAmount <= 0 || req.Odds <= 0 {
-                h.writeError(w, http.StatusBadRequest, "Missing required fields")
+        data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+        if err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read picture")
                 return
         }
-
-        if req.BetAmount > user.Money {
-                h.writeError(w, http.StatusBadRequest, "Insufficient balance")
+        if int64(len(data)) > maxBytes {
+                h.writeError(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, tooLarge)
                 return
         }
 
-        // Validate bet type
-        if req.BetType != "home" && req.BetType != "draw" && req.BetType != "away" {
-                h.writeError(w, http.StatusBadRequest, "Invalid bet type")
+        contentType := http.DetectContentType(data)
+        ext, ok := pictureTypes[contentType]
+        if !ok {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Picture must be a JPEG, PNG, GIF or WebP image")
                 return
         }
 
-        // Check if match exists and hasn't started
-        match, err := h.db.GetMatchByID(req.MatchID)
+        // A new name per upload, so cached copies of the old picture never shadow it
+        pictureURL, err := h.pictures.Save(r.Context(), user.ID+"/"+generateTokenID()+ext, contentType, data)
         if err != nil {
-                h.writeError(w, http.StatusNotFound, "Match not found")
+                h.logger.LogError("Failed to store picture for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Picture upload failed")
                 return
         }
 
-        if match.CommenceTime.Before(time.Now()) {
-                h.logger.LogBets("Match %s has already started or finished", req.MatchID)
-                h.writeError(w, http.StatusBadRequest, "Cannot place bet on a match that has already started")
+        if err := h.db.UpdateUserPicture(r.Context(), user.ID, pictureURL); err != nil {
+                h.logger.LogError("Failed to update picture for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Picture upload failed")
                 return
         }
 
-        // Create bet
-        bet := &Bet{
-                UserID:       user.ID,
-                MatchID:      req.MatchID,
-                BetType:      req.BetType,
-                BetAmount:    req.BetAmount,
-                Odds:         req.Odds,
-                PotentialWin: req.BetAmount * req.Odds,
-                Status:       "pending",
-                HomeTeam:     req.HomeTeam,
-                AwayTeam:     req.AwayTeam,
-        }
+        h.logger.LogSuccess("Profile picture updated for user %s (%s, %d bytes)", user.ID, contentType, len(data))
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "success":     true,
+                "picture_url": pictureURL,
+        })
+}
 
-        h.logger.LogBets("Inserting bet into database...")
+// BETS HANDLERS
+
+// Get bets handler - own bets, JWT required (user set by jwtAuthMiddleware)
+func (h *Handler) getBetsHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogBets("Getting user bets from PostgreSQL...")
+
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        oddsFormat, ok := h.oddsFormatParam(w, r)
+        if !ok {
+                return
+        }
+        page, ok := h.betPageParams(w, r)
+        if !ok {
+                return
+        }
 
-        // Use transaction-like behavior (simplified)
-        placedBet, err := h.db.PlaceBet(bet)
+        // Get bets
+        bets, nextCursor, err := h.userBetPage(r, user.ID, "", page)
         if err != nil {
-                h.logger.LogError("Failed to place bet: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to place bet")
+                h.writeBetPageError(w, r, err)
                 return
         }
 
-        // Update user balance
-        h.logger.LogBets("Updating user balance...")
-        newBalance := user.Money - req.BetAmount
-        if err := h.db.UpdateUserMoney(user.ID, newBalance); err != nil {
-                h.logger.LogError("Failed to update balance: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to update balance")
+        h.logger.LogBets("Found %d bets for user", len(bets))
+
+        var betDisplays []BetDisplay
+        for _, bet := range bets {
+                betDisplays = append(betDisplays, BetDisplay{
+                        ID:           bet.BetID,
+                        MatchID:      bet.MatchID,
+                        BetType:      bet.BetType,
+                        BetAmount:    bet.BetAmount,
+                        Odds:         bet.Odds,
+                        PotentialWin: bet.PotentialWin,
+                        Status:       bet.Status,
+                        HomeTeam:     bet.HomeTeam,
+                        AwayTeam:     bet.AwayTeam,
+                        CreatedAt:    bet.CreatedAt,
+                        SettledAt:    bet.SettledAt,
+                        CommenceTime: bet.CommenceTime,
+                        HomeScore:    bet.HomeScore,
+                        AwayScore:    bet.AwayScore,
+                        Result:       bet.Result,
+                        OddsDisplay:  formatOddsPtr(&bet.Odds, oddsFormat),
+                })
+        }
+
+        response := BetsResponse{
+                Success:    true,
+                Bets:       betDisplays,
+                NextCursor: nextCursor,
+        }
+        if oddsFormat != OddsFormatDecimal {
+                response.OddsFormat = oddsFormat
+        }
+
+        h.writeJSON(w, http.StatusOK, response)
+}
+
+// Get player bets handler - another player's bets with player info and stats (no auth required)
+func (h *Handler) getPlayerBetsHandler(w http.ResponseWriter, r *http.Request) {
+        nickname := mux.Vars(r)["nickname"]
+        h.logger.LogBets("Requesting bets for player: %s", nickname)
+
+        oddsFormat, ok := h.oddsFormatParam(w, r)
+        if !ok {
+                return
+        }
+        page, ok := h.betPageParams(w, r)
+        if !ok {
                 return
         }
 
-        h.logger.LogSuccess("Bet placed successfully! User: %s, Amount: $%.2f, New balance: $%.2f",
-                user.Nickname, req.BetAmount, newBalance)
-        h.logger.LogSuccess("BetID: %s", placedBet.BetID)
+        targetUser, err := h.db.GetUserByNickname(r.Context(), nickname)
+        if errors.Is(err, ErrUserNotFound) {
+                h.logger.LogBets("Player %s not found", nickname)
+                h.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "Player not found")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to get player %s: %s", nickname, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get player")
+                return
+        }
 
-        response := BetResponse{
-                Success: true,
-                Bet: BetInfo{
-                        ID:           placedBet.BetID,
-                        Amount:       req.BetAmount,
-                        Odds:         req.Odds,
-                        PotentialWin: req.BetAmount * req.Odds,
-                        NewBalance:   newBalance,
+        h.logger.LogBets("Viewing bets for player: %s (%s)", nickname, targetUser.ID)
+
+        bets, nextCursor, err := h.userBetPage(r, targetUser.ID, nickname, page)
+        if err != nil {
+                h.writeBetPageError(w, r, err)
+                return
+        }
+
+        h.logger.LogBets("Found %d bets for user", len(bets))
+
+        // Stats cover the whole history, not just this page
+        totalBets, wonBets, settledBets, pushBets, avgOdds, err := h.db.GetUserStats(r.Context(), targetUser.ID)
+        if err != nil {
+                h.logger.LogError("Failed to get stats for player %s: %s", nickname, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get bets")
+                return
+        }
+
+        winRate := 0.0
+        if settledBets > 0 {
+                winRate = float64(wonBets) / float64(settledBets) * 100
+        }
+
+        response := map[string]interface{}{
+                "success": true,
+                "player": map[string]interface{}{
+                        "id":       targetUser.ID,
+                        "nickname": targetUser.Nickname,
+                        "money":    targetUser.Money,
+                        "created":  targetUser.CreatedAt,
+                },
+                "bets": formatBetOdds(bets, oddsFormat),
+                "stats": map[string]interface{}{
+                        "total_bets":   totalBets,
+                        "won_bets":     wonBets,
+                        "settled_bets": settledBets,
+                        "push_bets":    pushBets,
+                        "win_rate":     winRate,
+                        "avg_odds":     avgOdds,
                 },
         }
+        if nextCursor != "" {
+                response["next_cursor"] = nextCursor
+        }
+        if oddsFormat != OddsFormatDecimal {
+                response["odds_format"] = oddsFormat
+        }
 
         h.writeJSON(w, http.StatusOK, response)
 }
 
-// MATCHES HANDLERS
+// comparePlayersHandler handles GET /api/players/compare?a=nick1&b=nick2 (no auth required)
+func (h *Handler) comparePlayersHandler(w http.ResponseWriter, r *http.Request) {
+        nicknameA := strings.TrimSpace(r.URL.Query().Get("a"))
+        nicknameB := strings.TrimSpace(r.URL.Query().Get("b"))
+        if nicknameA == "" || nicknameB == "" {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Both a and b nicknames are required")
+                return
+        }
 
-// Get matches handler
-func (h *Handler) getMatchesHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogSystem("MATCHES", "Getting matches from database...")
-        
-        matches, err := h.db.GetMatches()
+        users := make([]*User, 0, 2)
+        for _, nickname := range []string{nicknameA, nicknameB} {
+                user, err := h.db.GetUserByNickname(r.Context(), nickname)
+                if errors.Is(err, ErrUserNotFound) {
+                        h.writeError(w, r, http.StatusNotFound, CodeUserNotFound, fmt.Sprintf("Player %s not found", nickname))
+                        return
+                }
+                if err != nil {
+                        h.logger.LogError("Failed to get player %s: %s", nickname, err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to compare players")
+                        return
+                }
+                users = append(users, user)
+        }
+        if users[0].ID == users[1].ID {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Choose two different players")
+                return
+        }
+
+        records, err := h.db.GetBettingRecords(r.Context(), []string{users[0].ID, users[1].ID})
         if err != nil {
-                h.logger.LogError("Failed to get matches: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get matches")
+                h.logger.LogError("Failed to get betting records: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to compare players")
                 return
         }
 
-        h.logger.LogSystem("MATCHES", "Found %d matches", len(matches))
+        h.writeJSON(w, http.StatusOK, PlayerComparisonResponse{
+                Success: true,
+                A:       playerComparison(users[0], records[users[0].ID]),
+                B:       playerComparison(users[1], records[users[1].ID]),
+        })
+}
 
-        // Convert to response format
-        var matchDisplays []MatchDisplay
-        for _, match := range matches {
-                matchDisplays = append(matchDisplays, MatchDisplay{
-                        ID:           match.APIID,
-                        HomeTeam:     match.HomeTeam,
-                        AwayTeam:     match.AwayTeam,
-                        CommenceTime: match.CommenceTime,
-                        HomeOdds:     match.HomeOdds,
-                        DrawOdds:     match.DrawOdds,
-                        AwayOdds:     match.AwayOdds,
-                })
+// playerComparison fills one side of a comparison; rates are 0 without settled bets
+func playerComparison(user *User, record BettingRecord) PlayerComparison {
+        comparison := PlayerComparison{
+                Nickname:    user.Nickname,
+                Money:       user.Money,
+                Bets:        record.Bets,
+                WonBets:     record.WonBets,
+                SettledBets: record.SettledBets,
+                AvgOdds:     record.AvgOdds,
+        }
+        if record.SettledBets > 0 {
+                comparison.WinRate = float64(record.WonBets) / float64(record.SettledBets) * 100
         }
+        if record.Staked > 0 {
+                comparison.ROI = (record.Returned - record.Staked) / record.Staked * 100
+        }
+        return comparison
+}
 
-        response := MatchesResponse{
-                Success: true,
-                Matches: matchDisplays,
+// playerBet is a Bet with its odds in the requested ?oddsFormat
+type playerBet struct {
+        Bet
+        OddsDisplay *string `json:"odds_display,omitempty"`
+}
+
+// formatBetOdds adds odds_display to each bet for non-decimal formats
+func formatBetOdds(bets []Bet, oddsFormat string) interface{} {
+        if oddsFormat == OddsFormatDecimal {
+                return bets
         }
+        formatted := make([]playerBet, len(bets))
+        for i := range bets {
+                formatted[i] = playerBet{Bet: bets[i], OddsDisplay: formatOddsPtr(&bets[i].Odds, oddsFormat)}
+        }
+        return formatted
+}
 
-        h.writeJSON(w, http.StatusOK, response)
+// oddsFormatParam reads ?oddsFormat (default decimal); writes 400 and returns false if invalid
+func (h *Handler) oddsFormatParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+        format, err := parseOddsFormat(r.URL.Query().Get("oddsFormat"))
+        if err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+                return "", false
+        }
+        return format, true
 }
 
-// PLAYERS HANDLERS
+// timeParam parses an optional RFC 3339 query parameter; writes 400 and returns false if invalid
+func (h *Handler) timeParam(w http.ResponseWriter, r *http.Request, name string) (*time.Time, bool) {
+        raw := r.URL.Query().Get(name)
+        if raw == "" {
+                return nil, true
+        }
+        parsed, err := time.Parse(time.RFC3339, raw)
+        if err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid %s. Use an RFC 3339 time such as 2025-08-16T00:00:00Z", name))
+                return nil, false
+        }
+        return &parsed, true
+}
 
-// Get players handler
-func (h *Handler) getPlayersHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogSystem("PLAYERS", "Getting players list...")
+// maxTeamFilterLength bounds the ?team= match filter
+const maxTeamFilterLength = 100
 
-        // Parse pagination parameters
-        limit := h.config.DefaultPlayerLimit
-        offset := 0
+// betRejection explains why a bet request was refused
+type betRejection struct {
+        status  int
+        code    string
+        message string
+        details map[string]interface{} // Extra response fields (current_odds, commence_time, ...)
+}
 
-        if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
-                if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 && parsedLimit <= h.config.MaxPlayerLimit {
-                        limit = parsedLimit
-                }
+// writeBetRejection responds with a rejected bet's status, error and details
+func (h *Handler) writeBetRejection(w http.ResponseWriter, r *http.Request, rejection *betRejection) {
+        if len(rejection.details) == 0 {
+                h.writeError(w, r, rejection.status, rejection.code, rejection.message)
+                return
+        }
+        response := map[string]interface{}{"success": false, "code": rejection.code, "error": h.text(r, rejection.code, rejection.message)}
+        for key, value := range rejection.details {
+                response[key] = value
         }
+        h.writeJSON(w, rejection.status, response)
+}
 
-        if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
-                if parsedOffset, err := strconv.Atoi(offsetParam); err == nil && parsedOffset >= 0 {
-                        offset = parsedOffset
+// prepareBet validates one bet request against its match and builds the pending bet
+// Account-wide checks (balance, pending cap, limits) are left to the caller
+func (h *Handler) prepareBet(ctx context.Context, user *User, req PlaceBetRequest) (*Bet, *betRejection, error) {
+        if req.MatchID == "" || req.BetType == "" || req.BetAmount <= 0 || req.Odds <= 0 {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeInvalidRequest, message: "Missing required fields"}, nil
+        }
+        if !isWholeCents(req.BetAmount) {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeInvalidBetAmount, message: "Bet amount must have at most 2 decimal places"}, nil
+        }
+
+        // Validate bet type against the market registry: home, draw, away, btts_yes, btts_no or cs_<home>-<away>
+        market, betType, ok := betMarketFor(req.BetType)
+        if !ok {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeInvalidBetType, message: "Invalid bet type"}, nil
+        }
+        if !h.config.marketEnabled(market) {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeMarketUnavailable, message: "This market is not available"}, nil
+        }
+        if !betTypeAllowed(betType, h.config.sportHasDraw()) {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeMarketUnavailable, message: "Draw bets are not available for this sport"}, nil
+        }
+        req.BetType = betType
+
+        // Check if match exists and hasn't started
+        match, err := h.db.GetMatchByID(ctx, req.MatchID)
+        if errors.Is(err, ErrMatchNotFound) {
+                return nil, &betRejection{status: http.StatusNotFound, code: CodeMatchNotFound, message: "Match not found"}, nil
+        }
+        if err != nil {
+                return nil, nil, fmt.Errorf("failed to get match %s: %w", req.MatchID, err)
+        }
+
+        // Bets are priced at the stored odds (house margin already applied by the sync);
+        // the client's odds must match what it was shown
+        odds, ok := matchOdds(match, req.BetType)
+        if !ok || !oddsInRange(odds, h.config) {
+                return nil, &betRejection{status: http.StatusBadRequest, code: CodeMarketUnavailable, message: "This market is not available for the match"}, nil
+        }
+
+        // Odds no sync has refreshed within MaxOddsAge may no longer reflect the market;
+        // accept_odds_change cannot help since there are no newer odds to accept
+        if h.config.MaxOddsAge > 0 && (match.OddsUpdatedAt == nil || h.config.now().Sub(*match.OddsUpdatedAt) > h.config.MaxOddsAge) {
+                h.logger.LogBets("Odds for match %s are stale (updated %v)", req.MatchID, match.OddsUpdatedAt)
+                return nil, &betRejection{
+                        status:  http.StatusConflict,
+                        code:    CodeOddsStale,
+                        message: "Odds for this match are out of date, please try again later",
+                        details: map[string]interface{}{"current_odds": odds, "odds_updated_at": match.OddsUpdatedAt},
+                }, nil
+        }
+
+        if math.Abs(req.Odds-odds) > oddsTolerance {
+                if !req.AcceptOddsChange {
+                        h.logger.LogBets("Odds for %s on match %s changed: requested %.2f, current %.2f", req.BetType, req.MatchID, req.Odds, odds)
+                        return nil, &betRejection{
+                                status:  http.StatusBadRequest,
+                                code:    CodeOddsChanged,
+                                message: "Odds have changed, please review the current odds",
+                                details: map[string]interface{}{"current_odds": odds, "odds_updated_at": match.OddsUpdatedAt},
+                        }, nil
                 }
+                h.logger.LogBets("Odds for %s on match %s changed: requested %.2f, accepted current %.2f", req.BetType, req.MatchID, req.Odds, odds)
+        }
+
+        // Betting closes BetCutoffBuffer before kickoff (server time, UTC)
+        serverTime := h.config.now().UTC()
+        commenceTime := match.CommenceTime.UTC()
+        if !serverTime.Before(commenceTime.Add(-h.config.BetCutoffBuffer)) {
+                h.logger.LogBets("Match %s has already started or betting is closed", req.MatchID)
+                return nil, &betRejection{
+                        status:  http.StatusBadRequest,
+                        code:    CodeMatchStarted,
+                        message: "Cannot place bet on a match that has already started",
+                        details: map[string]interface{}{
+                                "commence_time": commenceTime.Format(time.RFC3339),
+                                "server_time":   serverTime.Format(time.RFC3339),
+                        },
+                }, nil
+        }
+
+        // The payout is capped; the largest stake that fits is offered back to the client
+        payout := potentialWin(req.BetAmount, odds)
+        if h.config.MaxPotentialWin > 0 && payout > h.config.MaxPotentialWin {
+                h.logger.LogBets("Potential win %.2f on match %s exceeds the maximum %.2f", payout, req.MatchID, h.config.MaxPotentialWin)
+                return nil, &betRejection{
+                        status:  http.StatusBadRequest,
+                        code:    CodePotentialWinTooHigh,
+                        message: fmt.Sprintf("Potential win exceeds the maximum of $%.2f", h.config.MaxPotentialWin),
+                        details: map[string]interface{}{
+                                "max_potential_win": h.config.MaxPotentialWin,
+                                "max_stake":         maxStakeForPayout(h.config.MaxPotentialWin, odds),
+                        },
+                }, nil
         }
 
-        h.logger.LogSystem("PLAYERS", "Fetching players (limit: %d, offset: %d)", limit, offset)
+        // Very large stakes are reserved but only stand once an admin approves them
+        status := "pending"
+        if h.config.BetReviewThreshold > 0 && req.BetAmount > h.config.BetReviewThreshold {
+                h.logger.LogBets("Bet of $%.2f by user %s on match %s held for review", req.BetAmount, user.ID, req.MatchID)
+                status = "review"
+        }
 
-        // Get players
-        players, err := h.db.GetPlayers(limit, offset)
+        return &Bet{
+                UserID:       user.ID,
+                MatchID:      req.MatchID,
+                BetType:      req.BetType,
+                BetAmount:    req.BetAmount,
+                Odds:         odds,
+                PotentialWin: payout,
+                Status:       status,
+                HomeTeam:     req.HomeTeam,
+                AwayTeam:     req.AwayTeam,
+        }, nil, nil
+}
+
+// checkPendingBets enforces MaxPendingBetsPerUser for adding count more bets; writes the error response
+func (h *Handler) checkPendingBets(w http.ResponseWriter, r *http.Request, user *User, count int) bool {
+        // Cap unsettled bets per user (0 disables the limit)
+        if h.config.MaxPendingBetsPerUser <= 0 {
+                return true
+        }
+        pending, err := h.db.CountPendingBets(r.Context(), user.ID)
         if err != nil {
-                h.logger.LogError("Failed to get players: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get players")
+                h.logger.LogError("Failed to count pending bets for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bet")
+                return false
+        }
+        if pending+count > h.config.MaxPendingBetsPerUser {
+                h.logger.LogBets("User %s has %d pending bets, limit is %d", user.ID, pending, h.config.MaxPendingBetsPerUser)
+                h.writeError(w, r, http.StatusBadRequest, CodeTooManyPendingBets, fmt.Sprintf("You can have at most %d pending bets; wait for some to be settled", h.config.MaxPendingBetsPerUser))
+                return false
+        }
+        return true
+}
+
+// Place bet handler
+func (h *Handler) placeBetHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogBets("Placing a new bet...")
+
+        // Authenticated user (set by jwtAuthMiddleware)
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
                 return
         }
 
-        // Get total count for pagination
-        total, err := h.db.GetTotalPlayers()
+        var req PlaceBetRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+
+        if req.BetAmount > user.Money {
+                h.writeError(w, r, http.StatusBadRequest, CodeInsufficientBalance, "Insufficient balance")
+                return
+        }
+
+        // Validate request, match and odds
+        bet, rejection, err := h.prepareBet(r.Context(), user, req)
         if err != nil {
-                h.logger.LogError("Failed to get total count: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get players")
+                h.logger.LogError("Failed to place bet: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bet")
+                return
+        }
+        if rejection != nil {
+                h.writeBetRejection(w, r, rejection)
                 return
         }
 
-        h.logger.LogSystem("PLAYERS", "Found %d players (total: %d)", len(players), total)
+        if !h.checkPendingBets(w, r, user, 1) {
+                return
+        }
 
-        response := PlayersResponse{
+        // Responsible-gambling self-exclusion and wager/loss limits
+        if !h.checkBetLimits(w, r, user, req.BetAmount) {
+                return
+        }
+
+        h.logger.LogBets("Inserting bet into database...")
+
+        // Debit and insert happen atomically in one transaction
+        placedBet, newBalance, err := h.db.PlaceBet(r.Context(), bet)
+        if errors.Is(err, ErrInsufficientFunds) {
+                h.logger.LogBets("Insufficient balance for user %s", user.ID)
+                h.writeError(w, r, http.StatusBadRequest, CodeInsufficientBalance, "Insufficient balance")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to place bet: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bet")
+                return
+        }
+
+        h.logger.LogSuccess("Bet placed successfully! User: %s, Amount: $%.2f, New balance: $%.2f",
+                user.Nickname, req.BetAmount, newBalance)
+        h.logger.LogSuccess("BetID: %s", placedBet.BetID)
+
+        response := BetResponse{
                 Success: true,
-                Players: players,
-                Pagination: PaginationInfo{
-                        Limit:   limit,
-                        Offset:  offset,
-                        Total:   total,
-                        HasMore: offset+limit < total,
+                Bet: BetInfo{
+                        ID:           placedBet.BetID,
+                        Status:       placedBet.Status,
+                        Amount:       req.BetAmount,
+                        Odds:         placedBet.Odds,
+                        PotentialWin: placedBet.PotentialWin,
+                        NewBalance:   newBalance,
                 },
         }
 
-        h.writeJSON(w, http.StatusOK, response)
-}
+        h.writeJSON(w, http.StatusOK, response)
+}
+
+// PlaceBetsBatchHandler handles POST /api/bets/batch
+// Places several independent single bets in one transaction that debits the total stake.
+// Any invalid bet rejects the whole batch unless BET_BATCH_PARTIAL is set, in which case
+// the valid bets are placed and the rejected ones reported
+func (h *Handler) placeBetsBatchHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        var req PlaceBetsBatchRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+        if len(req.Bets) == 0 {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No bets in the batch")
+                return
+        }
+        if len(req.Bets) > h.config.MaxBatchBets {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("A batch can contain at most %d bets", h.config.MaxBatchBets))
+                return
+        }
+
+        h.logger.LogBets("Placing a batch of %d bets for user %s", len(req.Bets), user.ID)
+
+        // Validate every bet before touching the balance
+        results := make([]BatchBetResult, len(req.Bets))
+        var accepted []*Bet
+        var acceptedIndex []int
+        totalStake := 0.0
+        for i, betReq := range req.Bets {
+                results[i].Index = i
+                bet, rejection, err := h.prepareBet(r.Context(), user, betReq)
+                if err != nil {
+                        h.logger.LogError("Failed to place bet batch: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bets")
+                        return
+                }
+                if rejection != nil {
+                        results[i].Code = rejection.code
+                        results[i].Error = h.text(r, rejection.code, rejection.message)
+                        results[i].Details = rejection.details
+                        continue
+                }
+                accepted = append(accepted, bet)
+                acceptedIndex = append(acceptedIndex, i)
+                totalStake = addMoney(totalStake, bet.BetAmount)
+        }
+
+        rejected := len(req.Bets) - len(accepted)
+        if len(accepted) == 0 || (rejected > 0 && !h.config.BetBatchPartial) {
+                h.logger.LogBets("Bet batch rejected: %d of %d bets invalid", rejected, len(req.Bets))
+                for i := range results {
+                        if results[i].Error == "" {
+                                results[i].Code = CodeBatchRejected
+                                results[i].Error = h.text(r, CodeBatchRejected, "Not placed because other bets in the batch were rejected")
+                        }
+                }
+                h.writeJSON(w, http.StatusBadRequest, BatchBetResponse{
+                        Success:    false,
+                        Code:       CodeBatchRejected,
+                        Error:      h.text(r, CodeBatchRejected, fmt.Sprintf("%d of %d bets were rejected; no bets were placed", rejected, len(req.Bets))),
+                        Results:    results,
+                        Rejected:   rejected,
+                        NewBalance: user.Money, // Unchanged
+                })
+                return
+        }
+
+        if totalStake > user.Money {
+                h.writeError(w, r, http.StatusBadRequest, CodeInsufficientBalance, "Insufficient balance")
+                return
+        }
+        if !h.checkPendingBets(w, r, user, len(accepted)) {
+                return
+        }
+        // Limits apply to the batch's total stake
+        if !h.checkBetLimits(w, r, user, totalStake) {
+                return
+        }
+
+        // One debit of the total stake and all inserts in one transaction
+        placed, newBalance, err := h.db.PlaceBets(r.Context(), accepted)
+        if errors.Is(err, ErrInsufficientFunds) {
+                h.logger.LogBets("Insufficient balance for user %s", user.ID)
+                h.writeError(w, r, http.StatusBadRequest, CodeInsufficientBalance, "Insufficient balance")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to place bet batch: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bets")
+                return
+        }
+
+        // Running balance after each bet, in request order
+        balance := addMoney(newBalance, totalStake)
+        for k, bet := range placed {
+                balance = addMoney(balance, -bet.BetAmount)
+                results[acceptedIndex[k]] = BatchBetResult{
+                        Index:   acceptedIndex[k],
+                        Success: true,
+                        Bet: &BetInfo{
+                                ID:           bet.BetID,
+                                Status:       bet.Status,
+                                Amount:       bet.BetAmount,
+                                Odds:         bet.Odds,
+                                PotentialWin: bet.PotentialWin,
+                                NewBalance:   balance,
+                        },
+                }
+        }
+
+        h.logger.LogSuccess("Bet batch placed! User: %s, Bets: %d, Stake: $%.2f, New balance: $%.2f",
+                user.Nickname, len(placed), totalStake, newBalance)
+
+        h.writeJSON(w, http.StatusOK, BatchBetResponse{
+                Success:    true,
+                Results:    results,
+                Placed:     len(placed),
+                Rejected:   rejected,
+                TotalStake: totalStake,
+                NewBalance: newBalance,
+        })
+}
+
+// MATCHES HANDLERS
+
+// Get matches handler
+func (h *Handler) getMatchesHandler(w http.ResponseWriter, r *http.Request) {
+        query := r.URL.Query()
+        if query.Get("status") != "" || query.Get("limit") != "" || query.Get("offset") != "" || query.Get("sort") != "" ||
+                query.Get("from") != "" || query.Get("to") != "" || query.Get("team") != "" {
+                h.listMatchesHandler(w, r)
+                return
+        }
+
+        oddsFormat, ok := h.oddsFormatParam(w, r)
+        if !ok {
+                return
+        }
+
+        // Only the decimal payload is cached; other formats are rendered per request
+        if oddsFormat != OddsFormatDecimal {
+                body, err := h.loadMatchesPayload(r.Context(), oddsFormat)
+                if err != nil {
+                        h.logger.LogError("Failed to get matches: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get matches")
+                        return
+                }
+                h.writeMatchesPayload(w, r, body, weakETag(body))
+                return
+        }
+
+        // Default: all upcoming matches with odds (cached, unpaginated)
+        body, etag, generation, cached := h.matches.Get()
+        if !cached {
+                var err error
+                body, err = h.loadMatchesPayload(r.Context(), OddsFormatDecimal)
+                if err != nil {
+                        h.logger.LogError("Failed to get matches: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get matches")
+                        return
+                }
+                etag = h.matches.Set(body, generation)
+        }
+
+        h.writeMatchesPayload(w, r, body, etag)
+}
+
+// listMatchesHandler serves filtered, paginated matches
+// Query: status=upcoming|live|finished, from, to (RFC 3339 commence time bounds), team,
+// limit, offset, sort=commence_time|-commence_time, oddsFormat
+func (h *Handler) listMatchesHandler(w http.ResponseWriter, r *http.Request) {
+        query := r.URL.Query()
+
+        oddsFormat, ok := h.oddsFormatParam(w, r)
+        if !ok {
+                return
+        }
+
+        filter := MatchFilter{
+                Status: MatchStatusUpcoming,
+                Limit:  h.config.DefaultPlayerLimit,
+                Offset: 0,
+        }
+
+        if status := query.Get("status"); status != "" {
+                switch status {
+                case MatchStatusUpcoming, MatchStatusLive, MatchStatusFinished:
+                        filter.Status = status
+                default:
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid status. Use upcoming, live or finished")
+                        return
+                }
+        }
+
+        // Finished matches default to most recent first
+        filter.SortDesc = filter.Status == MatchStatusFinished
+        switch query.Get("sort") {
+        case "":
+        case "commence_time":
+                filter.SortDesc = false
+        case "-commence_time":
+                filter.SortDesc = true
+        default:
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid sort. Use commence_time or -commence_time")
+                return
+        }
+
+        if filter.From, ok = h.timeParam(w, r, "from"); !ok {
+                return
+        }
+        if filter.To, ok = h.timeParam(w, r, "to"); !ok {
+                return
+        }
+        if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "from must be before to")
+                return
+        }
+
+        filter.Team = strings.TrimSpace(query.Get("team"))
+        if len(filter.Team) > maxTeamFilterLength {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("team must be at most %d characters", maxTeamFilterLength))
+                return
+        }
+
+        if limitParam := query.Get("limit"); limitParam != "" {
+                if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 && parsedLimit <= h.config.MaxPlayerLimit {
+                        filter.Limit = parsedLimit
+                }
+        }
+
+        if offsetParam := query.Get("offset"); offsetParam != "" {
+                if parsedOffset, err := strconv.Atoi(offsetParam); err == nil && parsedOffset >= 0 {
+                        filter.Offset = parsedOffset
+                }
+        }
+
+        h.logger.LogSystem("MATCHES", "Listing %s matches (team: %q, limit: %d, offset: %d, desc: %v)", filter.Status, filter.Team, filter.Limit, filter.Offset, filter.SortDesc)
+
+        matches, total, err := h.db.ListMatches(r.Context(), filter)
+        if err != nil {
+                h.logger.LogError("Failed to list matches: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get matches")
+                return
+        }
+
+        response := MatchesResponse{
+                Success: true,
+                Matches: toMatchDisplays(matches, filter.Status != MatchStatusUpcoming, oddsFormat),
+                Pagination: &PaginationInfo{
+                        Limit:   filter.Limit,
+                        Offset:  filter.Offset,
+                        Total:   total,
+                        HasMore: filter.Offset+filter.Limit < total,
+                },
+        }
+        if oddsFormat != OddsFormatDecimal {
+                response.OddsFormat = oddsFormat
+        }
+
+        body, err := json.Marshal(response)
+        if err != nil {
+                h.logger.LogError("Failed to encode matches: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get matches")
+                return
+        }
+
+        h.writeMatchesPayload(w, r, body, weakETag(body))
+}
+
+// writeMatchesPayload writes a rendered matches body with its ETag, or 304 if the client has it
+func (h *Handler) writeMatchesPayload(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+        // Clients must revalidate, but can reuse their copy on 304
+        w.Header().Set("ETag", etag)
+        w.Header().Set("Cache-Control", "no-cache")
+
+        if etagMatches(r, etag) {
+                w.WriteHeader(http.StatusNotModified)
+                return
+        }
+
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusOK)
+        w.Write(body)
+}
+
+// loadMatchesPayload renders the matches response from the database
+func (h *Handler) loadMatchesPayload(ctx context.Context, oddsFormat string) ([]byte, error) {
+        h.logger.LogSystem("MATCHES", "Getting matches from database...")
+
+        matches, err := h.db.GetMatches(ctx)
+        if err != nil {
+                return nil, err
+        }
+
+        h.logger.LogSystem("MATCHES", "Found %d matches", len(matches))
+
+        response := MatchesResponse{
+                Success: true,
+                Matches: toMatchDisplays(matches, false, oddsFormat),
+        }
+        if oddsFormat != OddsFormatDecimal {
+                response.OddsFormat = oddsFormat
+        }
+
+        return json.Marshal(response)
+}
+
+// toMatchDisplays converts matches to response format
+// withResults adds completion, settlement, scores and result (live/finished listings)
+// Non-decimal oddsFormat adds the *_odds_display fields next to the decimal odds
+func toMatchDisplays(matches []Match, withResults bool, oddsFormat string) []MatchDisplay {
+        var matchDisplays []MatchDisplay
+        for _, match := range matches {
+                display := MatchDisplay{
+                        ID:           match.APIID,
+                        HomeTeam:     match.HomeTeam,
+                        AwayTeam:     match.AwayTeam,
+                        CommenceTime: match.CommenceTime,
+                        HomeOdds:     match.HomeOdds,
+                        DrawOdds:     match.DrawOdds,
+                        AwayOdds:     match.AwayOdds,
+                        HomeOddsDisplay: formatOddsPtr(match.HomeOdds, oddsFormat),
+                        DrawOddsDisplay: formatOddsPtr(match.DrawOdds, oddsFormat),
+                        AwayOddsDisplay: formatOddsPtr(match.AwayOdds, oddsFormat),
+                        BTTSYesOdds:  match.BTTSYesOdds,
+                        BTTSNoOdds:   match.BTTSNoOdds,
+                        CorrectScoreOdds: match.CorrectScoreOdds,
+                        TotalsOdds:   match.TotalsOdds,
+                        BTTSYesOddsDisplay: formatOddsPtr(match.BTTSYesOdds, oddsFormat),
+                        BTTSNoOddsDisplay:  formatOddsPtr(match.BTTSNoOdds, oddsFormat),
+                        CorrectScoreOddsDisplay: formatOddsMap(match.CorrectScoreOdds, oddsFormat),
+                        TotalsOddsDisplay:  formatOddsMap(match.TotalsOdds, oddsFormat),
+                        UpdatedAt:    match.UpdatedAt,
+                        OddsUpdatedAt: match.OddsUpdatedAt,
+                }
+                if withResults {
+                        completed, calculated := match.Completed, match.Calculated
+                        display.Completed = &completed
+                        display.Calculated = &calculated
+                        display.HomeScore = match.HomeScore
+                        display.AwayScore = match.AwayScore
+                        display.Result = match.Result
+                }
+                matchDisplays = append(matchDisplays, display)
+        }
+        return matchDisplays
+}
+
+// PLAYERS HANDLERS
+
+// Get players handler
+func (h *Handler) getPlayersHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogSystem("PLAYERS", "Getting players list...")
+
+        limit, offset := h.pageParams(r)
+
+        order := r.URL.Query().Get("sort")
+        switch order {
+        case "":
+                order = PlayerSortBets
+        case PlayerSortBets, PlayerSortProfit, PlayerSortROI:
+        default:
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid sort. Use bets, profit or roi")
+                return
+        }
+
+        h.logger.LogSystem("PLAYERS", "Fetching players (limit: %d, offset: %d, sort: %s)", limit, offset, order)
+
+        // Get players
+        players, err := h.db.GetPlayers(r.Context(), limit, offset, order)
+        if err != nil {
+                h.logger.LogError("Failed to get players: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get players")
+                return
+        }
+
+        // Get total count for pagination
+        total, err := h.db.GetTotalPlayers(r.Context())
+        if err != nil {
+                h.logger.LogError("Failed to get total count: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get players")
+                return
+        }
+
+        h.logger.LogSystem("PLAYERS", "Found %d players (total: %d)", len(players), total)
+
+        response := PlayersResponse{
+                Success: true,
+                Players: players,
+                Pagination: PaginationInfo{
+                        Limit:   limit,
+                        Offset:  offset,
+                        Total:   total,
+                        HasMore: offset+limit < total,
+                },
+        }
+
+        h.writeJSON(w, http.StatusOK, response)
+}
+
+// pageParams parses ?limit= and ?offset= for paged listings; invalid values fall back to the defaults
+func (h *Handler) pageParams(r *http.Request) (int, int) {
+        limit := h.config.DefaultPlayerLimit
+        offset := 0
+
+        if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+                if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 && parsedLimit <= h.config.MaxPlayerLimit {
+                        limit = parsedLimit
+                }
+        }
+
+        if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+                if parsedOffset, err := strconv.Atoi(offsetParam); err == nil && parsedOffset >= 0 {
+                        offset = parsedOffset
+                }
+        }
+        return limit, offset
+}
+
+// currentSeason returns the open leaderboard season, starting one for the current period if needed
+func (h *Handler) currentSeason(ctx context.Context) (*Season, error) {
+        startsAt, endsAt := seasonBounds(h.config.now(), h.config.SeasonMonths)
+        return h.db.GetOpenSeason(ctx, startsAt, endsAt)
+}
+
+// leaderboardHandler handles GET /api/leaderboard?season=current|previous|<id> (no auth required)
+func (h *Handler) leaderboardHandler(w http.ResponseWriter, r *http.Request) {
+        var season *Season
+        var err error
+        switch param := r.URL.Query().Get("season"); param {
+        case "", "current":
+                season, err = h.currentSeason(r.Context())
+        case "previous":
+                season, err = h.db.GetLastClosedSeason(r.Context())
+        default:
+                id, convErr := strconv.Atoi(param)
+                if convErr != nil || id < 1 {
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid season. Use current, previous or a season ID")
+                        return
+                }
+                season, err = h.db.GetSeason(r.Context(), id)
+        }
+        if errors.Is(err, ErrSeasonNotFound) {
+                h.writeError(w, r, http.StatusNotFound, CodeNotFound, "Season not found")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to get season: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get leaderboard")
+                return
+        }
+
+        limit, offset := h.pageParams(r)
+        standings, total, err := h.db.GetSeasonStandings(r.Context(), season, limit, offset)
+        if err != nil {
+                h.logger.LogError("Failed to get standings for season %d: %s", season.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get leaderboard")
+                return
+        }
+        if standings == nil {
+                standings = []SeasonStanding{}
+        }
+
+        h.writeJSON(w, http.StatusOK, LeaderboardResponse{
+                Success:   true,
+                Season:    *season,
+                Standings: standings,
+                Pagination: PaginationInfo{
+                        Limit:   limit,
+                        Offset:  offset,
+                        Total:   total,
+                        HasMore: offset+limit < total,
+                },
+        })
+}
+
+// HELPER FUNCTIONS
+
+var (
+        dummyPasswordHash     []byte
+        dummyPasswordHashOnce sync.Once
+)
+
+// comparePasswordHash is bcrypt.CompareHashAndPassword for login checks; tests count calls through it
+var comparePasswordHash = bcrypt.CompareHashAndPassword
+
+// compareDummyPassword spends one bcrypt comparison against a dummy hash of the configured cost,
+// so a login for an unknown account takes as long as a wrong password
+func compareDummyPassword(password string, config *Config) {
+        dummyPasswordHashOnce.Do(func() {
+                dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("freebet-dummy-password"), config.BcryptCost)
+        })
+        comparePasswordHash(dummyPasswordHash, []byte(password))
+}
+
+// verifyLoginPassword always performs one bcrypt comparison
+// Unknown users and accounts without a password are compared against a dummy hash of the same cost
+func (h *Handler) verifyLoginPassword(user *User, password string) bool {
+        if user == nil || !user.PasswordHash.Valid || user.PasswordHash.String == "" {
+                compareDummyPassword(password, h.config)
+                return false
+        }
+
+        return comparePasswordHash([]byte(user.PasswordHash.String), []byte(password)) == nil
+}
+
+// upgradePasswordHash rehashes a verified password whose bcrypt cost is below BCRYPT_COST
+// Failures are only logged; the login itself has already succeeded
+func (h *Handler) upgradePasswordHash(ctx context.Context, user *User, password string) {
+        cost, err := bcrypt.Cost([]byte(user.PasswordHash.String))
+        if err != nil || cost >= h.config.BcryptCost {
+                return
+        }
+
+        hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+        if err != nil {
+                h.logger.LogWarning("Password rehash failed for user %s: %s", user.ID, err.Error())
+                return
+        }
+        if err := h.db.UpdateUserPassword(ctx, user.ID, string(hashedPassword)); err != nil {
+                h.logger.LogWarning("Failed to store upgraded password hash for user %s: %s", user.ID, err.Error())
+                return
+        }
+        h.logger.LogAuth("Upgraded password hash for user %s from cost %d to %d", user.ID, cost, h.config.BcryptCost)
+}
+
+// Set refresh token cookie
+func (h *Handler) setRefreshTokenCookie(w http.ResponseWriter, token string) {
+        http.SetCookie(w, &http.Cookie{
+                Name:     h.config.CookieName,
+                Value:    token,
+                Path:     "/",
+                HttpOnly: h.config.CookieHTTPOnly,
+                Secure:   h.config.CookieSecure,
+                SameSite: h.config.cookieSameSite(),
+                MaxAge:   int(h.config.JWTRefreshTokenTTL.Seconds()),
+        })
+}
+
+// Clear refresh token cookie
+func (h *Handler) clearRefreshTokenCookie(w http.ResponseWriter) {
+        http.SetCookie(w, &http.Cookie{
+                Name:     h.config.CookieName,
+                Value:    "",
+                Path:     "/",
+                HttpOnly: h.config.CookieHTTPOnly,
+                Secure:   h.config.CookieSecure,
+                SameSite: h.config.cookieSameSite(),
+                MaxAge:   -1,
+        })
+}
+
+// Refresh token handler
+func (h *Handler) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogAuth("Processing token refresh request")
+
+        // Get refresh token from cookie
+        cookie, err := r.Cookie(h.config.CookieName)
+        if err != nil || cookie.Value == "" {
+                h.logger.LogAuth("No refresh token found")
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidRefreshToken, "No refresh token")
+                return
+        }
+
+        refreshTokenString := cookie.Value
+
+        // Generate new access token
+        accessToken, err := refreshAccessToken(r.Context(), refreshTokenString, h.db, h.config)
+        if errors.Is(err, ErrRefreshLookupFailed) {
+                // Keep the cookie - the token may still be valid once the database recovers
+                h.logger.LogError("Token refresh failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Token refresh failed")
+                return
+        }
+        if errors.Is(err, ErrAccountDisabled) {
+                h.logger.LogAuth("Token refresh rejected for a disabled account")
+                h.clearRefreshTokenCookie(w)
+                h.writeError(w, r, http.StatusForbidden, CodeAccountDisabled, accountDisabledMessage)
+                return
+        }
+        if err != nil {
+                h.logger.LogAuth("Token refresh failed: %s", err.Error())
+                // Clear invalid refresh token
+                h.clearRefreshTokenCookie(w)
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidRefreshToken, "Invalid refresh token")
+                return
+        }
+
+        h.logger.LogSuccess("Token refresh successful")
+
+        response := RefreshResponse{
+                Success:     true,
+                AccessToken: accessToken,
+                ExpiresIn:   accessTokenExpiresIn(h.config),
+        }
+
+        h.writeJSON(w, http.StatusOK, response)
+}
+
+// Not found handler - unmatched routes
+func (h *Handler) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+        h.writeError(w, r, http.StatusNotFound, CodeNotFound, "Not found")
+}
+
+// Method not allowed handler - known path, wrong method
+func (h *Handler) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+        h.writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// Write JSON response
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(status)
+        json.NewEncoder(w).Encode(data)
+}
+
+// Write error response; code is one of the Code* constants, message is the English text,
+// replaced by the code's translation when r asks for another locale
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+        response := APIResponse{
+                Success: false,
+                Code:    code,
+                Error:   h.text(r, code, message),
+        }
+        h.writeJSON(w, status, response)
+}
+
+// writeDecodeError reports a request body that failed to decode:
+// 413 when it exceeded MAX_REQUEST_BODY_BYTES, otherwise 400 Invalid JSON
+func (h *Handler) writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+        var maxErr *http.MaxBytesError
+        if errors.As(err, &maxErr) {
+                h.writeError(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, fmt.Sprintf("Request body too large (max %d bytes)", maxErr.Limit))
+                return
+        }
+        h.writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "Invalid JSON")
+}
+
+// writeValidationErrors responds 400 with every field error plus, for older clients,
+// the first one in field order as the top-level error
+func (h *Handler) writeValidationErrors(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string, fieldOrder []string) {
+        response := ValidationErrorResponse{Success: false, Code: CodeValidationFailed, Errors: fieldErrors}
+        for _, field := range fieldOrder {
+                if message, ok := fieldErrors[field]; ok {
+                        response.Error = h.text(r, CodeValidationFailed, message)
+                        break
+                }
+        }
+        h.writeJSON(w, http.StatusBadRequest, response)
+}
+
+// ADMIN AUTH HANDLERS
+
+// AdminLoginHandler handles POST /api/admin/login
+// Verifies admin credentials once and issues a short-lived admin token
+func (h *Handler) adminLoginHandler(w http.ResponseWriter, r *http.Request) {
+        h.logger.LogAuth("[ADMIN AUTH] Processing admin login request")
+
+        var req AdminLoginRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+
+        if req.Username == "" || req.Password == "" {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Username and password are required")
+                return
+        }
+
+        admin, err := h.db.GetAdminByUsername(r.Context(), req.Username)
+        if errors.Is(err, ErrAdminNotFound) {
+                // Same bcrypt cost as a wrong password, so timing doesn't reveal admin usernames
+                compareDummyPassword(req.Password, h.config)
+                h.logger.LogWarning("[ADMIN AUTH] Admin not found: %s", req.Username)
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("[ADMIN AUTH] Failed to look up admin %s: %s", req.Username, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Admin login failed")
+                return
+        }
+
+        if err := comparePasswordHash([]byte(admin.PasswordHash), []byte(req.Password)); err != nil {
+                h.logger.LogWarning("[ADMIN AUTH] Invalid password for admin: %s", req.Username)
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+                return
+        }
+
+        token, expiresAt, err := generateAdminToken(admin, h.config)
+        if err != nil {
+                h.logger.LogError("Admin token generation failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Admin login failed")
+                return
+        }
+
+        if _, err := h.db.CreateAdminSession(r.Context(), admin.ID, token, expiresAt); err != nil {
+                h.logger.LogError("Admin session storage failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Admin login failed")
+                return
+        }
+
+        if err := h.db.UpdateAdminLastLogin(r.Context(), admin.ID); err != nil {
+                h.logger.LogWarning("[ADMIN AUTH] Failed to update last login: %s", err.Error())
+                // Don't fail the request, just log
+        }
+
+        h.logger.LogSuccess("[ADMIN AUTH] Admin token issued for: %s", admin.Username)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":         true,
+                "admin":      admin.Username,
+                "token":      token,
+                "expires_at": expiresAt.Format(time.RFC3339),
+        })
+}
+
+// AdminRevokeHandler handles POST /api/admin/revoke
+// Revokes the Bearer admin token used to authenticate this request
+func (h *Handler) adminRevokeHandler(w http.ResponseWriter, r *http.Request) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        authHeader := r.Header.Get("Authorization")
+        if !strings.HasPrefix(authHeader, "Bearer ") {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "No admin token to revoke")
+                return
+        }
+
+        if err := h.db.DeleteAdminSession(r.Context(), strings.TrimPrefix(authHeader, "Bearer ")); err != nil {
+                h.logger.LogError("Failed to revoke admin token: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to revoke admin token")
+                return
+        }
+
+        h.logger.LogSuccess("[ADMIN AUTH] Admin token revoked for: %s", admin.Username)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":    true,
+                "admin": admin.Username,
+        })
+}
+
+// AdminOddsQuotaHandler handles GET /api/admin/odds-quota
+// Returns the Odds API quota observed by the latest odds or scores sync
+func (h *Handler) adminOddsQuotaHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        quota, observed := h.sync.quota.Latest()
+        if !observed {
+                h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                        "ok":       true,
+                        "observed": false,
+                        "message":  "No quota observed yet, run an odds or scores sync",
+                })
+                return
+        }
+
+        remaining, err := strconv.ParseFloat(quota.RequestsRemaining, 64)
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":                 true,
+                "observed":           true,
+                "requests_remaining": quota.RequestsRemaining,
+                "requests_used":      quota.RequestsUsed,
+                "source":             quota.Source,
+                "observed_at":        quota.ObservedAt.Format(time.RFC3339),
+                "warning_threshold":  h.config.OddsAPIQuotaWarning,
+                "low":                err == nil && h.sync.quota.isLow(remaining),
+        })
+}
+
+// AdminLogLevelHandler handles GET /api/admin/log-level
+func (h *Handler) adminLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":         true,
+                "level":      h.logger.Level(),
+                "configured": h.config.LogLevel,
+        })
+}
+
+// AdminSetLogLevelHandler handles POST /api/admin/log-level
+// Changes the log level until the next restart (e.g. DEBUG for SQL logs during an incident)
+func (h *Handler) adminSetLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        var req LogLevelRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+
+        previous := h.logger.Level()
+        if err := h.logger.SetLevel(req.Level); err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+                return
+        }
+
+        // WARN so the change is recorded even when the new level hides INFO
+        h.logger.LogWarning("[ADMIN] Log level changed from %s to %s by admin %s", previous, h.logger.Level(), admin.Username)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":       true,
+                "level":    h.logger.Level(),
+                "previous": previous,
+        })
+}
+
+// AdminMaintenanceHandler handles GET /api/admin/maintenance
+func (h *Handler) adminMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        enabled, since := h.maintenance.Status()
+        response := map[string]interface{}{
+                "ok":          true,
+                "maintenance": enabled,
+        }
+        if enabled {
+                response["since"] = since.Format(time.RFC3339)
+        }
+        h.writeJSON(w, http.StatusOK, response)
+}
+
+// AdminSetMaintenanceHandler handles POST /api/admin/maintenance
+// Switches maintenance mode on or off until the next restart (MAINTENANCE_MODE is the default)
+func (h *Handler) adminSetMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        var req MaintenanceRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+        if req.Enabled == nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "enabled is required")
+                return
+        }
+
+        previous := h.maintenance.Set(*req.Enabled)
+        if previous != *req.Enabled {
+                action := "disabled"
+                if *req.Enabled {
+                        action = "enabled"
+                }
+                h.logger.LogWarning("[ADMIN] Maintenance mode %s by admin %s", action, admin.Username)
+        }
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":          true,
+                "maintenance": *req.Enabled,
+                "previous":    previous,
+        })
+}
+
+// AdminExposureHandler handles GET /api/admin/exposure
+// Outstanding liability on pending bets, per match and outcome, largest exposure first
+func (h *Handler) adminExposureHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        rows, err := h.db.GetExposure(r.Context())
+        if err != nil {
+                h.logger.LogError("Failed to get exposure: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get exposure")
+                return
+        }
+        report := buildExposureReport(rows)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":                     true,
+                "pending_bets":           report.PendingBets,
+                "total_stake":            report.TotalStake,
+                "total_potential_payout": report.TotalPotentialPayout,
+                "matches":                report.Matches,
+        })
+}
+
+// AdminMatchHandler handles GET /api/admin/matches/{id}
+// The stored match as synced, including odds_source and odds_updated_at, for diagnosing odd prices
+func (h *Handler) adminMatchHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        matchID := mux.Vars(r)["id"]
+        match, err := h.db.GetMatchByAPIID(r.Context(), matchID)
+        if errors.Is(err, ErrMatchNotFound) {
+                h.writeError(w, r, http.StatusNotFound, CodeMatchNotFound, "Match not found")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to get match %s: %s", matchID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get match")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":    true,
+                "match": match,
+        })
+}
+
+// AdminReviewBetsHandler handles GET /api/admin/bets/review
+// Bets above BET_REVIEW_THRESHOLD waiting for approval, oldest first
+func (h *Handler) adminReviewBetsHandler(w http.ResponseWriter, r *http.Request) {
+        if _, ok := getAdminFromContext(r.Context()); !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        bets, err := h.db.ListBetsInReview(r.Context())
+        if err != nil {
+                h.logger.LogError("Failed to get bets in review: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get bets")
+                return
+        }
+        if bets == nil {
+                bets = []Bet{}
+        }
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":   true,
+                "bets": bets,
+        })
+}
+
+// AdminApproveBetHandler handles POST /api/admin/bets/{id}/approve
+// The bet becomes pending and is settled with its match; refused once betting on the match has closed
+func (h *Handler) adminApproveBetHandler(w http.ResponseWriter, r *http.Request) {
+        h.reviewBet(w, r, true)
+}
+
+// AdminRejectBetHandler handles POST /api/admin/bets/{id}/reject
+// The bet is voided and its reserved stake refunded
+func (h *Handler) adminRejectBetHandler(w http.ResponseWriter, r *http.Request) {
+        h.reviewBet(w, r, false)
+}
+
+// reviewBet applies an admin decision to the bet in the {id} path variable
+func (h *Handler) reviewBet(w http.ResponseWriter, r *http.Request, approve bool) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        betID := mux.Vars(r)["id"]
+        var bet *Bet
+        var err error
+        if approve {
+                // Approval follows the placement rule: betting closes BetCutoffBuffer before kickoff
+                bet, err = h.db.ApproveBet(r.Context(), betID, h.config.now().UTC().Add(h.config.BetCutoffBuffer))
+        } else {
+                bet, err = h.db.RejectBet(r.Context(), betID)
+        }
+        switch {
+        case errors.Is(err, ErrBetNotFound):
+                h.writeError(w, r, http.StatusNotFound, CodeNotFound, "Bet not found")
+                return
+        case errors.Is(err, ErrBetNotInReview):
+                h.writeError(w, r, http.StatusConflict, CodeBetNotInReview, "Bet is not awaiting review")
+                return
+        case errors.Is(err, ErrMatchAlreadySettled):
+                h.writeError(w, r, http.StatusConflict, CodeMatchSettled, "The match was already settled; reject the bet to refund it")
+                return
+        case errors.Is(err, ErrMatchStarted):
+                h.writeError(w, r, http.StatusConflict, CodeMatchStarted, "Betting on the match has closed; reject the bet to refund it")
+                return
+        case err != nil:
+                h.logger.LogError("Failed to review bet %s: %s", betID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to review bet")
+                return
+        }
+
+        action := "rejected and refunded"
+        if approve {
+                action = "approved"
+        }
+        h.logger.LogSuccess("[ADMIN] Bet %s ($%.2f by user %s) %s by admin %s", bet.BetID, bet.BetAmount, bet.UserID, action, admin.Username)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":  true,
+                "bet": bet,
+        })
+}
+
+// AdminDisableUserHandler handles POST /api/admin/users/{id}/disable
+// The account is soft-deleted: its bets stay for accounting, but login, refresh and the API are refused
+func (h *Handler) adminDisableUserHandler(w http.ResponseWriter, r *http.Request) {
+        h.setUserDisabled(w, r, true)
+}
+
+// AdminEnableUserHandler handles POST /api/admin/users/{id}/enable
+func (h *Handler) adminEnableUserHandler(w http.ResponseWriter, r *http.Request) {
+        h.setUserDisabled(w, r, false)
+}
+
+// setUserDisabled applies an admin disable/enable to the user in the {id} path variable
+func (h *Handler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        userID := mux.Vars(r)["id"]
+        err := h.db.SetUserDisabled(r.Context(), userID, disabled)
+        if errors.Is(err, ErrUserNotFound) {
+                h.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to update disabled flag for user %s: %s", userID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update user")
+                return
+        }
+
+        action := "enabled"
+        if disabled {
+                action = "disabled"
+        }
+        h.logger.LogSuccess("[ADMIN] User %s %s by admin %s", userID, action, admin.Username)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":       true,
+                "user_id":  userID,
+                "disabled": disabled,
+        })
+}
+
+// AdminAdjustBalanceHandler handles POST /api/admin/users/{id}/adjust-balance
+// Credits or debits a user (refunds, corrections); every change is recorded in balance_adjustments
+func (h *Handler) adminAdjustBalanceHandler(w http.ResponseWriter, r *http.Request) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        var req AdjustBalanceRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
 
-// HELPER FUNCTIONS
+        req.Reason = strings.TrimSpace(req.Reason)
+        if req.Amount == 0 || math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Amount must be a non-zero number")
+                return
+        }
+        if !isWholeCents(req.Amount) {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Amount must have at most 2 decimal places")
+                return
+        }
+        if req.Reason == "" || len(req.Reason) > 255 {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Reason is required (at most 255 characters)")
+                return
+        }
 
-// Set refresh token cookie
-func (h *Handler) setRefreshTokenCookie(w http.ResponseWriter, token string) {
-        http.SetCookie(w, &http.Cookie{
-                Name:     h.config.CookieName,
-                Value:    token,
-                Path:     "/",
-                HttpOnly: h.config.CookieHTTPOnly,
-                Secure:   h.config.CookieSecure,
-                SameSite: http.SameSiteLaxMode,
-                MaxAge:   int(h.config.JWTRefreshTokenTTL.Seconds()),
-        })
-}
+        userID := mux.Vars(r)["id"]
+        newBalance, err := h.db.AdjustUserBalance(r.Context(), userID, admin.ID, req.Amount, req.Reason)
+        if errors.Is(err, ErrUserNotFound) {
+                h.writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User not found")
+                return
+        }
+        if errors.Is(err, ErrInsufficientFunds) {
+                h.writeError(w, r, http.StatusBadRequest, CodeInsufficientBalance, "Adjustment would make the balance negative")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to adjust balance for user %s: %s", userID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to adjust balance")
+                return
+        }
 
-// Clear refresh token cookie
-func (h *Handler) clearRefreshTokenCookie(w http.ResponseWriter) {
-        http.SetCookie(w, &http.Cookie{
-                Name:     h.config.CookieName,
-                Value:    "",
-                Path:     "/",
-                HttpOnly: h.config.CookieHTTPOnly,
-                Secure:   h.config.CookieSecure,
-                SameSite: http.SameSiteLaxMode,
-                MaxAge:   -1,
+        h.logger.LogSuccess("[ADMIN] Balance of user %s adjusted by %+.2f by admin %s (%s), new balance: %.2f",
+                userID, req.Amount, admin.Username, req.Reason, newBalance)
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":          true,
+                "user_id":     userID,
+                "amount":      req.Amount,
+                "new_balance": newBalance,
         })
 }
 
-// Refresh token handler
-func (h *Handler) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
-        h.logger.LogAuth("Processing token refresh request")
-
-        // Get refresh token from cookie
-        cookie, err := r.Cookie(h.config.CookieName)
-        if err != nil || cookie.Value == "" {
-                h.logger.LogAuth("No refresh token found")
-                h.writeError(w, http.StatusUnauthorized, "No refresh token")
+// AdminCloseSeasonHandler handles POST /api/admin/seasons/close
+// Snapshots the open season's standings, credits SEASON_PRIZES and starts the next season
+func (h *Handler) adminCloseSeasonHandler(w http.ResponseWriter, r *http.Request) {
+        admin, ok := getAdminFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
                 return
         }
 
-        refreshTokenString := cookie.Value
+        // The body is optional
+        var req CloseSeasonRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+                h.writeDecodeError(w, r, err)
+                return
+        }
 
-        // Generate new access token
-        accessToken, err := refreshAccessToken(refreshTokenString, h.db, h.config)
+        season, err := h.currentSeason(r.Context())
         if err != nil {
-                h.logger.LogAuth("Token refresh failed: %s", err.Error())
-                // Clear invalid refresh token
-                h.clearRefreshTokenCookie(w)
-                h.writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+                h.logger.LogError("Failed to get current season: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to close season")
+                return
+        }
+        if req.SeasonID != 0 && req.SeasonID != season.ID {
+                h.writeError(w, r, http.StatusConflict, CodeSeasonClosed, fmt.Sprintf("Season %d is not open; the current season is %d", req.SeasonID, season.ID))
                 return
         }
 
-        h.logger.LogSuccess("Token refresh successful")
-
-        response := RefreshResponse{
-                Success:     true,
-                AccessToken: accessToken,
+        // Closing early ends the season now; the next one runs to the end of its period
+        now := h.config.now().UTC()
+        endsAt := season.EndsAt
+        if now.Before(endsAt) {
+                endsAt = now
         }
+        _, nextEndsAt := seasonBounds(endsAt, h.config.SeasonMonths)
 
-        h.writeJSON(w, http.StatusOK, response)
-}
+        prizes := h.config.SeasonPrizes
+        if req.AwardPrizes != nil && !*req.AwardPrizes {
+                prizes = nil
+        }
 
-// Write JSON response
-func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(status)
-        json.NewEncoder(w).Encode(data)
-}
+        standings, next, err := h.db.CloseSeason(r.Context(), season.ID, endsAt, prizes, nextEndsAt)
+        if errors.Is(err, ErrSeasonClosed) {
+                h.writeError(w, r, http.StatusConflict, CodeSeasonClosed, "Season already closed")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to close season %d: %s", season.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to close season")
+                return
+        }
 
-// Write error response
-func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
-        response := APIResponse{
-                Success: false,
-                Error:   message,
+        winners := []SeasonStanding{}
+        for _, standing := range standings {
+                if standing.Prize > 0 {
+                        winners = append(winners, standing)
+                }
         }
-        h.writeJSON(w, status, response)
+        h.logger.LogSystem("SEASONS", "Season %d closed by admin %s: %d players ranked, %d prizes awarded",
+                season.ID, admin.Username, len(standings), len(winners))
+
+        season.EndsAt = endsAt
+        season.ClosedAt = &now
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "ok":          true,
+                "season":      season,
+                "next_season": next,
+                "players":     len(standings),
+                "winners":     winners,
+        })
 }
 
 // ADMIN SYNC HANDLERS
 
 // OddsSyncHandler handles POST /api/odds/sync
+// ?dryRun=true reports what the sync would change without writing
 func (h *Handler) oddsSyncHandler(w http.ResponseWriter, r *http.Request) {
         start := time.Now()
 
         // Log incoming request details for debugging
-        clientIP := h.getClientIP(r)
+        clientIP := ClientIP(r, h.trustedProxies)
         h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST START ===")
         h.logger.LogSystem("ODDS_SYNC", "Client IP: %s, Time: %s", clientIP, start.Format(time.RFC3339))
 
         admin, ok := getAdminFromContext(r.Context())
         if !ok {
                 h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (UNAUTHORIZED) ===")
-                h.writeError(w, http.StatusUnauthorized, "Admin authentication required")
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
+                return
+        }
+
+        dryRun, ok := h.dryRunParam(w, r)
+        if !ok {
+                h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (BAD REQUEST) ===")
                 return
         }
 
-        h.logger.LogSystem("ODDS_SYNC", "Starting odds sync by admin: %s", admin.Username)
+        h.logger.LogSystem("ODDS_SYNC", "Starting odds sync by admin: %s (dry run: %v)", admin.Username, dryRun)
 
-        // Fetch odds from API
-        events, apiStats, err := fetchOddsFromAPI(h.config.OddsAPIKey)
+        result, err := h.sync.SyncOdds(r.Context(), dryRun)
         if err != nil {
-                h.logger.LogError("Failed to fetch odds from API: %s", err.Error())
+                h.logger.LogError("Odds sync failed: %s", err.Error())
                 h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (API ERROR) ===")
-                h.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch odds: %s", err.Error()))
+                status, code := h.syncErrorStatus(w, err)
+                h.writeError(w, r, status, code, err.Error())
                 return
         }
 
-        if len(events) == 0 {
+        duration := time.Since(start)
+        h.logger.LogSuccess("Odds sync completed: created=%d, updated=%d, merged=%d, skipped=%d, dry run=%v in %v", result.Created, result.Updated, result.Merged, result.Skipped, result.DryRun, duration)
+
+        response := map[string]interface{}{
+                "ok":       true,
+                "task":     "odds:sync",
+                "admin":    admin.Username,
+                "created":  result.Created,
+                "updated":  result.Updated,
+                "merged":   result.Merged,
+                "skipped":  result.Skipped,
+                "skipped_events": result.SkippedEvents, // [{api_id, reason, detail}]
+                "changes":  result.Changes,       // [{action, event_id, api_id, ..., fields}]
+                "dry_run":  result.DryRun,
+                "apiStats": result.APIStats,
+                "ms":       duration.Milliseconds(),
+        }
+        if result.Created == 0 && result.Updated == 0 && result.Merged == 0 && result.Skipped == 0 {
                 h.logger.LogSystem("ODDS_SYNC", "No upcoming matches found")
-                h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (NO MATCHES) ===")
-                h.writeJSON(w, http.StatusOK, map[string]interface{}{
-                        "ok":      true,
-                        "task":    "odds:sync",
-                        "admin":   admin.Username,
-                        "created": 0,
-                        "updated": 0,
-                        "skipped": 0,
-                        "message": "No upcoming matches found",
-                        "apiStats": apiStats,
-                        "ms":      time.Since(start).Milliseconds(),
-                })
-                return
+                response["message"] = "No upcoming matches found"
         }
 
-        // Process matches
-        results := map[string]int{
-                "created": 0,
-                "updated": 0,
-                "skipped": 0,
-        }
+        h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (SUCCESS) ===")
 
-        for _, event := range events {
-                match, err := processOddsEvent(event)
-                if err != nil {
-                        h.logger.LogError("Failed to process event: %s", err.Error())
-                        continue
-                }
+        h.writeJSON(w, http.StatusOK, response)
+}
 
-                // Check if match exists
-                existingMatch, err := h.db.GetMatchByAPIID(match.APIID)
-                if err == nil && existingMatch != nil {
-                        // Update existing match - preserve old odds if new ones are null
-                        if match.HomeOdds == nil {
-                                match.HomeOdds = existingMatch.HomeOdds
-                        }
-                        if match.DrawOdds == nil {
-                                match.DrawOdds = existingMatch.DrawOdds
-                        }
-                        if match.AwayOdds == nil {
-                                match.AwayOdds = existingMatch.AwayOdds
-                        }
-                        _, err = h.db.UpdateMatchByAPIID(match.APIID, match)
-                        if err != nil {
-                                h.logger.LogError("Failed to update match: %s", err.Error())
-                                continue
-                        }
-                        results["updated"]++
-                } else {
-                        // Create new match - only if has odds
-                        if match.HomeOdds == nil || match.DrawOdds == nil || match.AwayOdds == nil {
-                                results["skipped"]++
-                                continue
-                        }
-                        _, err = h.db.UpsertMatch(match)
-                        if err != nil {
-                                h.logger.LogError("Failed to create match: %s", err.Error())
-                                continue
-                        }
-                        results["created"]++
+// syncErrorStatus maps Odds API rate limiting to 503 with Retry-After; other failures are 500
+func (h *Handler) syncErrorStatus(w http.ResponseWriter, err error) (int, string) {
+        var backoffErr *OddsAPIBackoffError
+        if errors.As(err, &backoffErr) {
+                w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(backoffErr.Until.Sub(h.config.now()).Seconds()))))
+                return http.StatusServiceUnavailable, CodeUnavailable
+        }
+        var rateLimited *OddsAPIRateLimitError
+        if errors.As(err, &rateLimited) {
+                if rateLimited.RetryAfter > 0 {
+                        w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimited.RetryAfter.Seconds()))))
                 }
+                return http.StatusServiceUnavailable, CodeUnavailable
         }
+        return http.StatusInternalServerError, CodeInternal
+}
 
-        duration := time.Since(start)
-        h.logger.LogSuccess("Odds sync completed: created=%d, updated=%d, skipped=%d in %v", results["created"], results["updated"], results["skipped"], duration)
-
-        h.logger.LogSystem("ODDS_SYNC", "=== ODDS SYNC REQUEST END (SUCCESS) ===")
-
-        h.writeJSON(w, http.StatusOK, map[string]interface{}{
-                "ok":       true,
-                "task":     "odds:sync",
-                "admin":    admin.Username,
-                "created":  results["created"],
-                "updated":  results["updated"],
-                "skipped":  results["skipped"],
-                "apiStats": apiStats,
-                "ms":       duration.Milliseconds(),
-        })
+// dryRunParam parses ?dryRun=; writes 400 and returns false if invalid
+func (h *Handler) dryRunParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+        raw := r.URL.Query().Get("dryRun")
+        if raw == "" {
+                return false, true
+        }
+        dryRun, err := strconv.ParseBool(raw)
+        if err != nil {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "dryRun must be true or false")
+                return false, false
+        }
+        return dryRun, true
 }
 
 // ScoresSyncHandler handles POST /api/scores/sync
+// ?dryRun=true reports what the sync would change without writing
 func (h *Handler) scoresSyncHandler(w http.ResponseWriter, r *http.Request) {
         start := time.Now()
 
         // Log incoming request details for debugging
-        clientIP := h.getClientIP(r)
+        clientIP := ClientIP(r, h.trustedProxies)
         h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST START ===")
         h.logger.LogSystem("SCORES_SYNC", "Client IP: %s, Time: %s", clientIP, start.Format(time.RFC3339))
 
         admin, ok := getAdminFromContext(r.Context())
         if !ok {
                 h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (UNAUTHORIZED) ===")
-                h.writeError(w, http.StatusUnauthorized, "Admin authentication required")
-                return
-        }
-
-        h.logger.LogSystem("SCORES_SYNC", "Starting scores sync by admin: %s", admin.Username)
-
-        // Fetch scores from API
-        scores, apiStats, err := fetchScoresFromAPI(h.config.OddsAPIKey)
-        if err != nil {
-                h.logger.LogError("Failed to fetch scores from API: %s", err.Error())
-                h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (API ERROR) ===")
-                h.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to fetch scores: %s", err.Error()))
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
                 return
         }
 
-        if len(scores) == 0 {
-                h.logger.LogSystem("SCORES_SYNC", "No scores found")
-                h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (NO SCORES) ===")
-                h.writeJSON(w, http.StatusOK, map[string]interface{}{
-                        "ok":      true,
-                        "task":    "scores:sync",
-                        "admin":   admin.Username,
-                        "created": 0,
-                        "updated": 0,
-                        "message": "No scores found",
-                        "apiStats": apiStats,
-                        "ms":      time.Since(start).Milliseconds(),
-                })
+        dryRun, ok := h.dryRunParam(w, r)
+        if !ok {
+                h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (BAD REQUEST) ===")
                 return
         }
 
-        // Process scores
-        results := map[string]int{
-                "created": 0,
-                "updated": 0,
-        }
-
-        for _, score := range scores {
-                match, err := processScoreEvent(score)
-                if err != nil {
-                        h.logger.LogError("Failed to process score: %s", err.Error())
-                        continue
-                }
+        h.logger.LogSystem("SCORES_SYNC", "Starting scores sync by admin: %s (dry run: %v)", admin.Username, dryRun)
 
-                // Check if match exists
-                existingMatch, err := h.db.GetMatchByAPIID(match.APIID)
-                if err == nil && existingMatch != nil {
-                        // Update existing match - don't touch odds
-                        match.HomeOdds = existingMatch.HomeOdds
-                        match.DrawOdds = existingMatch.DrawOdds
-                        match.AwayOdds = existingMatch.AwayOdds
-                        _, err = h.db.UpdateMatchByAPIID(match.APIID, match)
-                        if err != nil {
-                                h.logger.LogError("Failed to update match: %s", err.Error())
-                                continue
-                        }
-                        results["updated"]++
-                } else {
-                        // Create new match with scores but no odds
-                        match.HomeOdds = nil
-                        match.DrawOdds = nil
-                        match.AwayOdds = nil
-                        _, err = h.db.UpsertMatch(match)
-                        if err != nil {
-                                h.logger.LogError("Failed to create match: %s", err.Error())
-                                continue
-                        }
-                        results["created"]++
-                }
+        result, err := h.sync.SyncScores(r.Context(), dryRun)
+        if err != nil {
+                h.logger.LogError("Scores sync failed: %s", err.Error())
+                h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (API ERROR) ===")
+                status, code := h.syncErrorStatus(w, err)
+                h.writeError(w, r, status, code, err.Error())
+                return
         }
 
         duration := time.Since(start)
-        h.logger.LogSuccess("Scores sync completed: created=%d, updated=%d in %v", results["created"], results["updated"], duration)
-
-        h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (SUCCESS) ===")
+        h.logger.LogSuccess("Scores sync completed: created=%d, updated=%d, merged=%d, skipped=%d, dry run=%v in %v", result.Created, result.Updated, result.Merged, result.Skipped, result.DryRun, duration)
 
-        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+        response := map[string]interface{}{
                 "ok":       true,
                 "task":     "scores:sync",
                 "admin":    admin.Username,
-                "created":  results["created"],
-                "updated":  results["updated"],
-                "apiStats": apiStats,
+                "created":  result.Created,
+                "updated":  result.Updated,
+                "merged":   result.Merged,
+                "skipped":  result.Skipped,
+                "skipped_events": result.SkippedEvents,
+                "changes":  result.Changes,
+                "dry_run":  result.DryRun,
+                "apiStats": result.APIStats,
                 "ms":       duration.Milliseconds(),
-        })
+        }
+        if result.Created == 0 && result.Updated == 0 && result.Merged == 0 && result.Skipped == 0 {
+                h.logger.LogSystem("SCORES_SYNC", "No scores found")
+                response["message"] = "No scores found"
+        }
+
+        h.logger.LogSystem("SCORES_SYNC", "=== SCORES SYNC REQUEST END (SUCCESS) ===")
+
+        h.writeJSON(w, http.StatusOK, response)
 }
 
 // CalcHandler handles POST /api/calc
@@ -1176,103 +2679,41 @@ func (h *Handler) calcHandler(w http.ResponseWriter, r *http.Request) {
 
         admin, ok := getAdminFromContext(r.Context())
         if !ok {
-                h.writeError(w, http.StatusUnauthorized, "Admin authentication required")
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Admin authentication required")
                 return
         }
 
         h.logger.LogSystem("CALC", "Starting calculation by admin: %s", admin.Username)
 
-        // Get completed uncalculated matches
-        matches, err := h.db.GetCompletedUncalculatedMatches()
-        if err != nil {
-                h.logger.LogError("Failed to get uncalculated matches: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get matches")
+        result, err := h.sync.CalculateMatches(r.Context())
+        if errors.Is(err, ErrCalcInProgress) {
+                h.logger.LogWarning("[CALC] Calculation by %s refused: another run is in progress", admin.Username)
+                h.writeError(w, r, http.StatusConflict, CodeCalcInProgress, "Calculation already in progress")
                 return
         }
-
-        updatedCount := 0
-        calculatedMatches := []map[string]interface{}{}
-
-        if len(matches) == 0 {
-                h.logger.LogSystem("CALC", "No matches to calculate")
-        } else {
-                for _, match := range matches {
-                // Determine result
-                var result string
-                if match.HomeScore == nil || match.AwayScore == nil {
-                        continue
-                }
-                if *match.HomeScore > *match.AwayScore {
-                        result = "home"
-                } else if *match.HomeScore < *match.AwayScore {
-                        result = "away"
-                } else {
-                        result = "draw"
-                }
-
-                // Update bets and user money
-                if err := h.db.UpdateBetsStatusAndUserMoney(match.APIID, result); err != nil {
-                        h.logger.LogError("Failed to update bets for match %s: %s", match.APIID, err.Error())
-                        continue
-                }
-
-                // Mark match as calculated
-                if err := h.db.UpdateMatchCalculated(match.APIID, result); err != nil {
-                        h.logger.LogError("Failed to mark match as calculated: %s", err.Error())
-                        continue
-                }
-
-                updatedCount++
-                calculatedMatches = append(calculatedMatches, map[string]interface{}{
-                        "home_team": match.HomeTeam,
-                        "away_team": match.AwayTeam,
-                        "score":     fmt.Sprintf("%d-%d", *match.HomeScore, *match.AwayScore),
-                        "result":    result,
-                })
-
-                h.logger.LogSuccess("Match calculated: %s %d-%d %s | Winner: %s",
-                        match.HomeTeam, *match.HomeScore, *match.AwayScore, match.AwayTeam, result)
-                }
-        }
-
-        // Send Telegram notification if configured (always send, even if no matches)
-        h.logger.LogSystem("CALC", "Checking Telegram notification: updatedCount=%d, botToken=%s, channelID=%s",
-                updatedCount, maskToken(h.config.TelegramBotToken), maskToken(h.config.TelegramChannelID))
-
-        if h.config.TelegramBotToken != "" && h.config.TelegramChannelID != "" {
-                h.logger.LogSystem("CALC", "Sending Telegram notification for %d matches", len(calculatedMatches))
-                if err := sendTelegramNotification(h.config.TelegramBotToken, h.config.TelegramChannelID, calculatedMatches); err != nil {
-                        h.logger.LogError("Failed to send Telegram notification: %s", err.Error())
-                } else {
-                        h.logger.LogSuccess("Telegram notification sent successfully")
-                }
-        } else {
-                if updatedCount == 0 {
-                        h.logger.LogSystem("CALC", "Skipping Telegram notification: no matches were updated")
-                }
-                if h.config.TelegramBotToken == "" {
-                        h.logger.LogSystem("CALC", "Skipping Telegram notification: bot token not configured")
-                }
-                if h.config.TelegramChannelID == "" {
-                        h.logger.LogSystem("CALC", "Skipping Telegram notification: channel ID not configured")
-                }
+        if err != nil {
+                h.logger.LogError("Calculation failed: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get matches")
+                return
         }
 
-        h.logger.LogSuccess("Calculation completed: %d matches processed", updatedCount)
+        h.logger.LogSuccess("Calculation completed: %d matches processed", result.Updated)
 
         message := "Calculation completed"
-        if updatedCount == 0 {
+        if result.Updated == 0 && result.Voided == 0 {
                 message = "No matches to calculate"
         }
 
         h.writeJSON(w, http.StatusOK, map[string]interface{}{
-                "ok":      true,
-                "task":    "calc",
-                "admin":   admin.Username,
-                "updated": updatedCount,
-                "message": message,
-                "matches": calculatedMatches,
-                "ms":      time.Since(start).Milliseconds(),
+                "ok":              true,
+                "task":            "calc",
+                "admin":           admin.Username,
+                "updated":         result.Updated,
+                "voided":          result.Voided,
+                "message":         message,
+                "matches":         result.Matches,
+                "awaiting_scores": result.AwaitingScores,
+                "ms":              time.Since(start).Milliseconds(),
         })
 }
 
@@ -1380,48 +2821,6 @@ func (h *Handler) analyticsHandler(w http.ResponseWriter, r *http.Request) {
 }
 */
 
-// getClientIP extracts the real client IP from request headers
-func (h *Handler) getClientIP(r *http.Request) string {
-        // Check X-Forwarded-For header (can contain multiple IPs)
-        xForwardedFor := r.Header.Get("X-Forwarded-For")
-        if xForwardedFor != "" {
-                // Take the first IP in the chain (original client)
-                ips := strings.Split(xForwardedFor, ",")
-                if len(ips) > 0 {
-                        ip := strings.TrimSpace(ips[0])
-                        if ip != "" && ip != "unknown" {
-                                return ip
-                        }
-                }
-        }
-
-        // Check X-Real-IP header
-        xRealIP := r.Header.Get("X-Real-IP")
-        if xRealIP != "" && xRealIP != "unknown" {
-                return xRealIP
-        }
-
-        // Check CF-Connecting-IP (Cloudflare)
-        cfConnectingIP := r.Header.Get("CF-Connecting-IP")
-        if cfConnectingIP != "" {
-                return cfConnectingIP
-        }
-
-        // Check X-Client-IP
-        xClientIP := r.Header.Get("X-Client-IP")
-        if xClientIP != "" {
-                return xClientIP
-        }
-
-        // Fallback to RemoteAddr (remove port if present)
-        remoteAddr := r.RemoteAddr
-        if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
-                return host
-        }
-
-        return remoteAddr
-}
-
 // GOOGLE OAUTH HANDLERS
 
 // Google OAuth login handler - initiates OAuth flow
@@ -1431,38 +2830,18 @@ func (h *Handler) googleLoginHandler(w http.ResponseWriter, r *http.Request) {
         // Check if Google OAuth is configured
         if h.config.GoogleClientID == "" || h.config.GoogleClientSecret == "" {
                 h.logger.LogError("Google OAuth not configured")
-                h.writeError(w, http.StatusServiceUnavailable, "Google authentication is not available")
+                h.writeError(w, r, http.StatusServiceUnavailable, CodeUnavailable, "Google authentication is not available")
                 return
         }
 
         // Get redirect URL from query parameter (optional)
-        redirectURL := r.URL.Query().Get("redirect_url")
-        if redirectURL != "" {
-                // Basic validation - allow localhost, relative URLs, and our domain
-                if parsedURL, err := url.Parse(redirectURL); err != nil {
-                        redirectURL = "" // Reset if invalid URL
-                } else if parsedURL.IsAbs() {
-                        // Allow localhost and freebet.guru domain
-                        allowedHosts := []string{"localhost", "127.0.0.1", "freebet.guru"}
-                        isAllowed := false
-                        for _, host := range allowedHosts {
-                                if strings.Contains(parsedURL.Host, host) {
-                                        isAllowed = true
-                                        break
-                                }
-                        }
-                        if !isAllowed {
-                                redirectURL = "" // Reset if not allowed host
-                        }
-                }
-                // Relative URLs are allowed
-        }
+        redirectURL := sanitizeOAuthRedirectURL(r.URL.Query().Get("redirect_url"), h.config.OAuthAllowedRedirectHosts)
 
         // Generate OAuth state
-        state, err := generateOAuthState(redirectURL)
+        state, err := generateOAuthState(redirectURL, "", h.config)
         if err != nil {
                 h.logger.LogError("Failed to generate OAuth state: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to initiate authentication")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to initiate authentication")
                 return
         }
 
@@ -1486,68 +2865,78 @@ func (h *Handler) googleCallbackHandler(w http.ResponseWriter, r *http.Request)
 
         if code == "" {
                 h.logger.LogAuth("No authorization code received")
-                h.writeError(w, http.StatusBadRequest, "Authorization code missing")
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Authorization code missing")
                 return
         }
 
         // Validate state parameter
-        oauthState, valid := validateOAuthState(state)
+        oauthState, valid := validateOAuthState(state, h.config)
         if !valid {
                 h.logger.LogAuth("Invalid or expired OAuth state")
-                h.writeError(w, http.StatusBadRequest, "Invalid authentication state")
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid authentication state")
                 return
         }
 
         // Exchange authorization code for access token
         oauthConfig := getGoogleOAuthConfig(h.config)
-        token, err := oauthConfig.Exchange(context.Background(), code)
+        oauthCtx := googleOAuthContext(r.Context(), h.config)
+        token, err := oauthConfig.Exchange(oauthCtx, code)
         if err != nil {
                 h.logger.LogError("Failed to exchange authorization code: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Authentication failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
                 return
         }
 
         // Get user info from Google
-        googleUser, err := getGoogleUserInfo(token, h.config)
+        googleUser, err := getGoogleUserInfo(oauthCtx, token, h.config)
         if err != nil {
                 h.logger.LogError("Failed to get Google user info: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Failed to get user information")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get user information")
                 return
         }
 
         h.logger.LogAuth("Google user authenticated: %s (%s)", googleUser.Email, googleUser.ID)
 
-        // Check if user exists
-        user, err := h.db.GetUserByGoogleID(googleUser.ID)
-        if err != nil {
+        // Guest upgrade (POST /api/account/upgrade/google) links instead of logging in
+        var user *User
+        if oauthState.LinkUserID != "" {
+                user, err = h.linkGuestGoogle(r.Context(), oauthState.LinkUserID, googleUser)
+                if err != nil {
+                        h.writeGuestLinkError(w, r, err)
+                        return
+                }
+        } else if user, err = h.db.GetUserByGoogleID(r.Context(), googleUser.ID); err != nil && !errors.Is(err, ErrUserNotFound) {
+                h.logger.LogError("Failed to look up Google user: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
+                return
+        } else if err != nil {
                 // User doesn't exist, create new user
                 h.logger.LogAuth("Creating new user for Google ID: %s", googleUser.ID)
 
-                nickname := generateNicknameFromGoogleEmail(googleUser.Email)
-                // Ensure nickname is unique
-                if existingUser, _ := h.db.GetUserByNickname(nickname); existingUser != nil {
-                        // Add random suffix if nickname exists
-                        nickname = fmt.Sprintf("%s%d", nickname, time.Now().Unix()%1000)
-                        if len(nickname) > 10 {
-                                nickname = nickname[:10]
-                        }
-                }
-
-                user, err = h.db.CreateUserWithGoogle(googleUser.ID, googleUser.Email, nickname, googleUser.Picture, h.config.InitialBalance)
+                user, err = h.createGoogleUser(r.Context(), googleUser, generateNicknameFromGoogleEmail(googleUser.Email))
                 if err != nil {
                         h.logger.LogError("Failed to create user: %s", err.Error())
-                        h.writeError(w, http.StatusInternalServerError, "User creation failed")
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "User creation failed")
                         return
                 }
 
                 h.logger.LogSuccess("Created new user via Google OAuth: %s", user.Email)
         } else {
+                if user.Disabled() {
+                        h.logger.LogAuth("Google login rejected for disabled user: %s", user.ID)
+                        h.writeError(w, r, http.StatusForbidden, CodeAccountDisabled, accountDisabledMessage)
+                        return
+                }
                 h.logger.LogAuth("Existing user logged in via Google: %s", user.Email)
 
-                // Update profile picture if changed
-                if user.PictureURL.String != googleUser.Picture {
-                        // Note: In production, you might want to add a method to update profile picture
-                        h.logger.LogAuth("Profile picture changed for user: %s", user.ID)
+                // Update profile picture if changed, unless the user uploaded their own
+                if googleUser.Picture != "" && user.PictureURL.String != googleUser.Picture && !h.pictures.Owns(user.PictureURL.String) {
+                        if err := h.db.UpdateUserPicture(r.Context(), user.ID, googleUser.Picture); err != nil {
+                                h.logger.LogError("Failed to update profile picture for user %s: %s", user.ID, err.Error())
+                        } else {
+                                user.PictureURL = sql.NullString{String: googleUser.Picture, Valid: true}
+                                h.logger.LogAuth("Profile picture updated from Google for user: %s", user.ID)
+                        }
                 }
         }
 
@@ -1555,23 +2944,23 @@ func (h *Handler) googleCallbackHandler(w http.ResponseWriter, r *http.Request)
         accessToken, err := generateAccessToken(user, h.config)
         if err != nil {
                 h.logger.LogError("Access token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Authentication failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
                 return
         }
 
         refreshTokenString, err := generateRefreshToken(user.ID, h.config)
         if err != nil {
                 h.logger.LogError("Refresh token generation failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Authentication failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
                 return
         }
 
         // Store refresh token in database
-        expiresAt := time.Now().Add(h.config.JWTRefreshTokenTTL)
-        _, err = h.db.CreateRefreshToken(user.ID, refreshTokenString, expiresAt)
+        expiresAt := h.config.now().Add(h.config.JWTRefreshTokenTTL)
+        _, err = h.db.CreateRefreshToken(r.Context(), user.ID, refreshTokenString, expiresAt)
         if err != nil {
                 h.logger.LogError("Refresh token storage failed: %s", err.Error())
-                h.writeError(w, http.StatusInternalServerError, "Authentication failed")
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
                 return
         }
 
@@ -1580,12 +2969,61 @@ func (h *Handler) googleCallbackHandler(w http.ResponseWriter, r *http.Request)
 
         h.logger.LogSuccess("Google OAuth authentication successful for user: %s", user.Email)
 
-        // Prepare response
-        response := map[string]interface{}{
+        // With a redirect URL the tokens stay server-side; the client swaps the one-time
+        // code at POST /api/auth/exchange so they never appear in URLs or history
+        if oauthState.RedirectURL != "" {
+                loginCode, err := issueOAuthLoginCode(accessToken, refreshTokenString, user, h.config)
+                if err != nil {
+                        h.logger.LogError("Login code generation failed: %s", err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
+                        return
+                }
+                redirectURL, err := oauthRedirectWithCode(oauthState.RedirectURL, loginCode)
+                if err != nil {
+                        h.logger.LogError("Invalid OAuth redirect URL %q: %s", oauthState.RedirectURL, err.Error())
+                        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Authentication failed")
+                        return
+                }
+                http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+                return
+        }
+
+        // Return JSON response
+        h.writeJSON(w, http.StatusOK, h.googleAuthResponse(r, user, accessToken, refreshTokenString))
+}
+
+// ExchangeCodeHandler handles POST /api/auth/exchange
+// Swaps the one-time code from the Google OAuth redirect for the issued tokens
+func (h *Handler) exchangeCodeHandler(w http.ResponseWriter, r *http.Request) {
+        var req ExchangeCodeRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+        if req.Code == "" {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Code is required")
+                return
+        }
+
+        entry, ok := redeemOAuthLoginCode(req.Code, h.config)
+        if !ok {
+                h.logger.LogAuth("Invalid or expired login code")
+                h.writeError(w, r, http.StatusUnauthorized, CodeInvalidLoginCode, "Invalid or expired code")
+                return
+        }
+
+        h.logger.LogAuth("Login code exchanged for user: %s", entry.User.ID)
+        h.writeJSON(w, http.StatusOK, h.googleAuthResponse(r, entry.User, entry.AccessToken, entry.RefreshToken))
+}
+
+// googleAuthResponse is the body returned after a Google login, directly or via the code exchange
+func (h *Handler) googleAuthResponse(r *http.Request, user *User, accessToken, refreshToken string) map[string]interface{} {
+        return map[string]interface{}{
                 "success":       true,
-                "message":       "Authentication successful",
+                "message":       h.text(r, msgAuthenticated, "Authentication successful"),
                 "access_token":  accessToken,
-                "refresh_token": refreshTokenString,
+                "refresh_token": refreshToken,
+                "expires_in":    accessTokenExpiresIn(h.config),
                 "user": map[string]interface{}{
                         "id":            user.ID,
                         "email":         user.Email,
@@ -1597,17 +3035,6 @@ func (h *Handler) googleCallbackHandler(w http.ResponseWriter, r *http.Request)
                         "last_topup_at": user.LastTopupAt,
                 },
         }
-
-        // If redirect URL was provided, redirect with tokens as query parameters
-        if oauthState.RedirectURL != "" {
-                redirectURL := fmt.Sprintf("%s?access_token=%s&refresh_token=%s",
-                        oauthState.RedirectURL, accessToken, refreshTokenString)
-                http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
-                return
-        }
-
-        // Return JSON response
-        h.writeJSON(w, http.StatusOK, response)
 }
 
 // formatUptime formats uptime seconds into a human readable string
@@ -0,0 +1,868 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "encoding/base64"
+        "encoding/json"
+        "fmt"
+        "io"
+        "net/http"
+        "net/http/httptest"
+        "sync"
+        "testing"
+        "time"
+
+        "golang.org/x/crypto/bcrypt"
+)
+
+// Test admin credentials, for Basic Auth on the admin routes
+const (
+        testAdminUsername = "admin"
+        testAdminPassword = "admin-password"
+)
+
+// testServer is the API wired to a MemoryDB and a fake clock, the way main.go wires it to Postgres
+type testServer struct {
+        t      *testing.T
+        db     *MemoryDB
+        config *Config
+        clock  *FakeClock
+        sync   *SyncService
+        router http.Handler
+}
+
+// newTestConfig loads the default configuration, cheapened for tests
+func newTestConfig(t *testing.T, clock Clock) *Config {
+        t.Helper()
+
+        config := validTestConfig(t)
+        config.BcryptCost = bcrypt.MinCost
+        config.RateLimitRequests = 10000
+        config.UserCreatedHooks = nil
+        config.Clock = clock
+        return config
+}
+
+// newTestServer builds a server with an empty MemoryDB and one admin
+func newTestServer(t *testing.T) *testServer {
+        t.Helper()
+
+        // JWTs are checked against the system time, so the fake clock starts there
+        clock := NewFakeClock(time.Now().UTC().Truncate(time.Second))
+        config := newTestConfig(t, clock)
+        db := NewMemoryDB(clock)
+        logger := NewLogger("ERROR", io.Discard)
+        syncService := NewSyncService(db, config, logger, nil)
+
+        hash, err := bcrypt.GenerateFromPassword([]byte(testAdminPassword), bcrypt.MinCost)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if _, err := db.CreateAdmin(context.Background(), testAdminUsername, "admin@example.com", string(hash)); err != nil {
+                t.Fatal(err)
+        }
+
+        return &testServer{
+                t:      t,
+                db:     db,
+                config: config,
+                clock:  clock,
+                sync:   syncService,
+                router: SetupRoutes(db, config, logger, syncService),
+        }
+}
+
+// do sends a request with an optional JSON body and Authorization header
+func (s *testServer) do(method, path, authorization string, body interface{}) *httptest.ResponseRecorder {
+        s.t.Helper()
+
+        var reader io.Reader
+        if body != nil {
+                payload, err := json.Marshal(body)
+                if err != nil {
+                        s.t.Fatal(err)
+                }
+                reader = bytes.NewReader(payload)
+        }
+        req := httptest.NewRequest(method, path, reader)
+        req.Header.Set("Content-Type", "application/json")
+        if authorization != "" {
+                req.Header.Set("Authorization", authorization)
+        }
+        w := httptest.NewRecorder()
+        s.router.ServeHTTP(w, req)
+        return w
+}
+
+// adminAuth is the Basic Auth header of the test admin
+func adminAuth() string {
+        return "Basic " + base64.StdEncoding.EncodeToString([]byte(testAdminUsername+":"+testAdminPassword))
+}
+
+// bearer is the Authorization header for an access token
+func bearer(token string) string {
+        return "Bearer " + token
+}
+
+// decodeResponse checks the status code and decodes the JSON body into v
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, status int, v interface{}) {
+        t.Helper()
+
+        if w.Code != status {
+                t.Fatalf("status = %d, want %d; body: %s", w.Code, status, w.Body.String())
+        }
+        if v != nil {
+                if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+                        t.Fatalf("decode %s: %v", w.Body.String(), err)
+                }
+        }
+}
+
+// register signs a user up through the API and returns the response
+func (s *testServer) register(email, nickname, password string) RegisterResponse {
+        s.t.Helper()
+
+        var response RegisterResponse
+        w := s.do("POST", "/api/auth/register", "", RegisterRequest{Email: email, Password: password, Nickname: nickname, AgeConfirmed: true})
+        decodeResponse(s.t, w, http.StatusOK, &response)
+        return response
+}
+
+// addMatch stores an upcoming match with 1X2 odds, kicking off in an hour
+// Team names come from apiID, since UpsertMatch merges fixtures with the same teams
+func (s *testServer) addMatch(apiID string, home, draw, away float64) *Match {
+        s.t.Helper()
+
+        match, err := s.db.UpsertMatch(context.Background(), &Match{
+                APIID:        apiID,
+                HomeTeam:     apiID + " United",
+                AwayTeam:     apiID + " City",
+                CommenceTime: s.clock.Now().Add(time.Hour),
+                HomeOdds:     &home,
+                DrawOdds:     &draw,
+                AwayOdds:     &away,
+        })
+        if err != nil {
+                s.t.Fatal(err)
+        }
+        return match
+}
+
+// finishMatch records a final score
+func (s *testServer) finishMatch(apiID string, homeScore, awayScore int) {
+        s.t.Helper()
+
+        if _, err := s.db.UpdateMatchByAPIID(context.Background(), apiID, &Match{HomeScore: &homeScore, AwayScore: &awayScore, Completed: true}); err != nil {
+                s.t.Fatal(err)
+        }
+}
+
+// placeBet bets through the API at the stored odds
+func (s *testServer) placeBet(token, matchID, betType string, amount, odds float64) *httptest.ResponseRecorder {
+        s.t.Helper()
+
+        return s.do("POST", "/api/bets", bearer(token), PlaceBetRequest{MatchID: matchID, BetType: betType, BetAmount: amount, Odds: odds})
+}
+
+func TestRegisterLoginBetSettle(t *testing.T) {
+        s := newTestServer(t)
+
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        if registered.User.Money != s.config.InitialBalance {
+                t.Fatalf("initial money = %v, want %v", registered.User.Money, s.config.InitialBalance)
+        }
+
+        var login LoginResponse
+        w := s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"})
+        decodeResponse(t, w, http.StatusOK, &login)
+        if login.AccessToken == "" {
+                t.Fatal("login returned no access token")
+        }
+
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+        var placed BetResponse
+        decodeResponse(t, s.placeBet(login.AccessToken, "match-1", "home", 100, 2.5), http.StatusOK, &placed)
+        if placed.Bet.Status != "pending" || placed.Bet.PotentialWin != 250 {
+                t.Fatalf("bet = %+v, want pending with potential_win 250", placed.Bet)
+        }
+        if want := s.config.InitialBalance - 100; placed.Bet.NewBalance != want {
+                t.Fatalf("new_balance = %v, want %v", placed.Bet.NewBalance, want)
+        }
+
+        s.finishMatch("match-1", 2, 1)
+        decodeResponse(t, s.do("POST", "/api/calc", adminAuth(), nil), http.StatusOK, nil)
+
+        var user LoginResponse
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(login.AccessToken), nil), http.StatusOK, &user)
+        if want := s.config.InitialBalance + 150; user.User.Money != want {
+                t.Fatalf("money after settlement = %v, want %v", user.User.Money, want)
+        }
+        if user.User.WonBets != 1 || user.User.SettledBets != 1 {
+                t.Fatalf("stats = %d won / %d settled, want 1 / 1", user.User.WonBets, user.User.SettledBets)
+        }
+
+        var bets BetsResponse
+        decodeResponse(t, s.do("GET", "/api/bets", bearer(login.AccessToken), nil), http.StatusOK, &bets)
+        if len(bets.Bets) != 1 || bets.Bets[0].Status != "won" {
+                t.Fatalf("bets = %+v, want one won bet", bets.Bets)
+        }
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+        s := newTestServer(t)
+        s.register("alice@example.com", "alice", "correct-horse-42")
+
+        tests := []struct {
+                name     string
+                email    string
+                nickname string
+        }{
+                {"email", "alice@example.com", "alice2"},
+                {"nickname", "other@example.com", "alice"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        w := s.do("POST", "/api/auth/register", "", RegisterRequest{Email: tt.email, Password: "correct-horse-42", Nickname: tt.nickname, AgeConfirmed: true})
+                        var response map[string]interface{}
+                        decodeResponse(t, w, http.StatusBadRequest, &response)
+                        if response["code"] != CodeValidationFailed {
+                                t.Fatalf("code = %v, want %s", response["code"], CodeValidationFailed)
+                        }
+                })
+        }
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+        s := newTestServer(t)
+        s.register("alice@example.com", "alice", "correct-horse-42")
+
+        w := s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice@example.com", Password: "wrong-password"})
+        if w.Code != http.StatusUnauthorized {
+                t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+        }
+}
+
+func TestPlaceBetChecksBalance(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+
+        w := s.placeBet(registered.AccessToken, "match-1", "home", s.config.InitialBalance+1, 2.5)
+        if w.Code != http.StatusBadRequest {
+                t.Fatalf("status = %d, want %d; body: %s", w.Code, http.StatusBadRequest, w.Body.String())
+        }
+
+        var user LoginResponse
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(registered.AccessToken), nil), http.StatusOK, &user)
+        if user.User.Money != s.config.InitialBalance || user.User.Bets != 0 {
+                t.Fatalf("user = %+v, want untouched balance and no bets", user.User)
+        }
+}
+
+func TestSettlementPaysOnlyWinners(t *testing.T) {
+        s := newTestServer(t)
+        alice := s.register("alice@example.com", "alice", "correct-horse-42")
+        bob := s.register("bob@example.com", "bob", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+
+        decodeResponse(t, s.placeBet(alice.AccessToken, "match-1", "draw", 50, 3.2), http.StatusOK, nil)
+        decodeResponse(t, s.placeBet(bob.AccessToken, "match-1", "away", 50, 2.8), http.StatusOK, nil)
+
+        s.finishMatch("match-1", 1, 1)
+        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                t.Fatal(err)
+        }
+
+        for _, tt := range []struct {
+                name  string
+                token string
+                money float64
+        }{
+                {"winner", alice.AccessToken, s.config.InitialBalance + 110},
+                {"loser", bob.AccessToken, s.config.InitialBalance - 50},
+        } {
+                var user LoginResponse
+                decodeResponse(t, s.do("GET", "/api/auth/user", bearer(tt.token), nil), http.StatusOK, &user)
+                if user.User.Money != tt.money {
+                        t.Errorf("%s money = %v, want %v", tt.name, user.User.Money, tt.money)
+                }
+        }
+
+        // A second run must not pay out again
+        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                t.Fatal(err)
+        }
+        var user LoginResponse
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(alice.AccessToken), nil), http.StatusOK, &user)
+        if want := s.config.InitialBalance + 110; user.User.Money != want {
+                t.Fatalf("money after second calc = %v, want %v", user.User.Money, want)
+        }
+}
+
+func TestLoginDoesNotRevealUnknownAccounts(t *testing.T) {
+        s := newTestServer(t)
+        s.register("alice@example.com", "alice", "correct-horse-42")
+        if _, err := s.db.CreateUserWithGoogle(context.Background(), "google-1", "bob@example.com", "bob", "", 100); err != nil {
+                t.Fatal(err)
+        }
+
+        var want string
+        for _, tt := range []struct {
+                name       string
+                identifier string
+        }{
+                {"wrong password", "alice"},
+                {"unknown user", "nobody@example.com"},
+                {"account without a password", "bob"},
+        } {
+                w := s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: tt.identifier, Password: "wrong-password"})
+                if w.Code != http.StatusUnauthorized {
+                        t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, http.StatusUnauthorized)
+                }
+                if want == "" {
+                        want = w.Body.String()
+                } else if w.Body.String() != want {
+                        t.Errorf("%s: body = %s, want %s", tt.name, w.Body.String(), want)
+                }
+        }
+
+        // Unknown users are compared against a hash of the configured cost, not skipped
+        if cost, err := bcrypt.Cost(dummyPasswordHash); err != nil || cost != s.config.BcryptCost {
+                t.Fatalf("dummy hash cost = %d (%v), want %d", cost, err, s.config.BcryptCost)
+        }
+}
+
+func TestAdminLoginDoesNotRevealUnknownAdmins(t *testing.T) {
+        s := newTestServer(t)
+
+        var compares int
+        compare := comparePasswordHash
+        comparePasswordHash = func(hash, password []byte) error {
+                compares++
+                return compare(hash, password)
+        }
+        t.Cleanup(func() { comparePasswordHash = compare })
+
+        basic := func(username, password string) string {
+                return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+        }
+
+        for _, endpoint := range []struct {
+                name    string
+                request func(username string) *httptest.ResponseRecorder
+        }{
+                {"login", func(username string) *httptest.ResponseRecorder {
+                        return s.do("POST", "/api/admin/login", "", AdminLoginRequest{Username: username, Password: "wrong-password"})
+                }},
+                {"basic auth", func(username string) *httptest.ResponseRecorder {
+                        return s.do("GET", "/api/admin/maintenance", basic(username, "wrong-password"), nil)
+                }},
+        } {
+                var want string
+                for _, username := range []string{testAdminUsername, "nobody"} {
+                        compares = 0
+                        w := endpoint.request(username)
+                        if w.Code != http.StatusUnauthorized {
+                                t.Fatalf("%s %s: status = %d, want %d", endpoint.name, username, w.Code, http.StatusUnauthorized)
+                        }
+                        // Both branches pay exactly one bcrypt comparison
+                        if compares != 1 {
+                                t.Errorf("%s %s: bcrypt comparisons = %d, want 1", endpoint.name, username, compares)
+                        }
+                        if want == "" {
+                                want = w.Body.String()
+                        } else if w.Body.String() != want {
+                                t.Errorf("%s %s: body = %s, want %s", endpoint.name, username, w.Body.String(), want)
+                        }
+                }
+        }
+}
+
+func TestPlaceBetCutoffBoundary(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        now := s.clock.Now()
+        odds := 2.0
+
+        tests := []struct {
+                name    string
+                kickoff time.Time
+                status  int
+        }{
+                {"one second before the cutoff", now.Add(s.config.BetCutoffBuffer + time.Second), http.StatusOK},
+                {"at the cutoff", now.Add(s.config.BetCutoffBuffer), http.StatusBadRequest},
+                {"inside the buffer", now.Add(s.config.BetCutoffBuffer / 2), http.StatusBadRequest},
+                {"after kickoff", now.Add(-time.Minute), http.StatusBadRequest},
+                {"kickoff in another zone", now.Add(s.config.BetCutoffBuffer).In(time.FixedZone("UTC+3", 3*60*60)), http.StatusBadRequest},
+        }
+        for i, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        // Distinct teams, or UpsertMatch would merge the fixtures into one row
+                        matchID := fmt.Sprintf("match-%d", i)
+                        if _, err := s.db.UpsertMatch(context.Background(), &Match{APIID: matchID, HomeTeam: fmt.Sprintf("Home %d", i), AwayTeam: fmt.Sprintf("Away %d", i), CommenceTime: tt.kickoff, HomeOdds: &odds, DrawOdds: &odds, AwayOdds: &odds}); err != nil {
+                                t.Fatal(err)
+                        }
+
+                        var response map[string]interface{}
+                        decodeResponse(t, s.placeBet(registered.AccessToken, matchID, "home", 10, odds), tt.status, &response)
+                        if tt.status == http.StatusOK {
+                                return
+                        }
+                        if response["code"] != CodeMatchStarted {
+                                t.Errorf("code = %v, want %s", response["code"], CodeMatchStarted)
+                        }
+                        if want := tt.kickoff.UTC().Format(time.RFC3339); response["commence_time"] != want {
+                                t.Errorf("commence_time = %v, want %s", response["commence_time"], want)
+                        }
+                        if want := now.UTC().Format(time.RFC3339); response["server_time"] != want {
+                                t.Errorf("server_time = %v, want %s", response["server_time"], want)
+                        }
+                })
+        }
+}
+
+func TestConcurrentBetsCannotOverdraw(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+
+        // Twice as many bets as the balance covers, all at once
+        stake := s.config.InitialBalance / 10
+        var wg sync.WaitGroup
+        statuses := make(chan int, 20)
+        for i := 0; i < 20; i++ {
+                wg.Add(1)
+                go func() {
+                        defer wg.Done()
+                        statuses <- s.placeBet(registered.AccessToken, "match-1", "home", stake, 2.0).Code
+                }()
+        }
+        wg.Wait()
+        close(statuses)
+
+        placed := 0
+        for status := range statuses {
+                switch status {
+                case http.StatusOK:
+                        placed++
+                case http.StatusBadRequest:
+                default:
+                        t.Errorf("unexpected status %d", status)
+                }
+        }
+        if placed != 10 {
+                t.Fatalf("placed %d bets, want 10", placed)
+        }
+
+        var user LoginResponse
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(registered.AccessToken), nil), http.StatusOK, &user)
+        if user.User.Money != 0 || user.User.Bets != 10 {
+                t.Fatalf("money = %v with %d bets, want 0 with 10", user.User.Money, user.User.Bets)
+        }
+}
+
+func TestRefreshTokenCookieSameSite(t *testing.T) {
+        for _, tt := range []struct {
+                setting string
+                want    http.SameSite
+        }{
+                {"strict", http.SameSiteStrictMode},
+                {"lax", http.SameSiteLaxMode},
+                {"none", http.SameSiteNoneMode},
+        } {
+                t.Run(tt.setting, func(t *testing.T) {
+                        s := newTestServer(t)
+                        s.config.CookieSameSite = tt.setting
+                        s.config.CookieSecure = true
+                        s.register("alice@example.com", "alice", "correct-horse-42")
+
+                        login := s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"})
+                        logout := s.do("POST", "/api/auth/logout", "", nil)
+                        for name, w := range map[string]*httptest.ResponseRecorder{"login": login, "logout": logout} {
+                                cookies := w.Result().Cookies()
+                                if len(cookies) != 1 || cookies[0].Name != s.config.CookieName {
+                                        t.Fatalf("%s set cookies %v, want the refresh token cookie", name, cookies)
+                                }
+                                if cookies[0].SameSite != tt.want || !cookies[0].Secure {
+                                        t.Errorf("%s cookie SameSite = %v, Secure = %v; want %v, true", name, cookies[0].SameSite, cookies[0].Secure, tt.want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestPendingBetLimitBoundary(t *testing.T) {
+        s := newTestServer(t)
+        s.config.MaxPendingBetsPerUser = 3
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+
+        for i := 0; i < s.config.MaxPendingBetsPerUser; i++ {
+                decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.0), http.StatusOK, nil)
+        }
+
+        var response map[string]interface{}
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.0), http.StatusBadRequest, &response)
+        if response["code"] != CodeTooManyPendingBets {
+                t.Fatalf("code = %v, want %s", response["code"], CodeTooManyPendingBets)
+        }
+
+        // Settled bets no longer count
+        s.finishMatch("match-1", 1, 0)
+        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                t.Fatal(err)
+        }
+        s.addMatch("match-2", 2.0, 3.0, 4.0)
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-2", "home", 10, 2.0), http.StatusOK, nil)
+}
+
+func TestDisabledUserIsLockedOut(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        login := s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"})
+        var loggedIn LoginResponse
+        decodeResponse(t, login, http.StatusOK, &loggedIn)
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+
+        decodeResponse(t, s.do("POST", "/api/admin/users/"+registered.User.ID+"/disable", adminAuth(), nil), http.StatusOK, nil)
+
+        wantDisabled := func(name string, w *httptest.ResponseRecorder) {
+                t.Helper()
+                var response map[string]interface{}
+                decodeResponse(t, w, http.StatusForbidden, &response)
+                if response["code"] != CodeAccountDisabled {
+                        t.Errorf("%s code = %v, want %s", name, response["code"], CodeAccountDisabled)
+                }
+        }
+        wantDisabled("login", s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"}))
+        wantDisabled("bet", s.placeBet(loggedIn.AccessToken, "match-1", "home", 10, 2.5))
+
+        refresh := httptest.NewRequest("POST", "/api/auth/refresh", nil)
+        for _, cookie := range login.Result().Cookies() {
+                refresh.AddCookie(cookie)
+        }
+        w := httptest.NewRecorder()
+        s.router.ServeHTTP(w, refresh)
+        wantDisabled("refresh", w)
+
+        // The row and its balance survive, so enabling restores the account as it was
+        decodeResponse(t, s.do("POST", "/api/admin/users/"+registered.User.ID+"/enable", adminAuth(), nil), http.StatusOK, nil)
+        decodeResponse(t, s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"}), http.StatusOK, &loggedIn)
+        decodeResponse(t, s.placeBet(loggedIn.AccessToken, "match-1", "home", 10, 2.5), http.StatusOK, nil)
+}
+
+func TestDisableUnknownUser(t *testing.T) {
+        s := newTestServer(t)
+
+        decodeResponse(t, s.do("POST", "/api/admin/users/no-such-user/disable", adminAuth(), nil), http.StatusNotFound, nil)
+}
+
+func TestRegisterReportsEveryInvalidField(t *testing.T) {
+        s := newTestServer(t)
+        s.register("alice@example.com", "alice", "correct-horse-42")
+
+        tests := []struct {
+                name    string
+                req     RegisterRequest
+                fields  []string
+                message string
+        }{
+                {"every field", RegisterRequest{Email: "not-an-email", Password: "x", Nickname: "ab"}, []string{"email", "password", "nickname", "age_confirmed"}, "Invalid email format"},
+                {"missing fields", RegisterRequest{AgeConfirmed: true}, []string{"email", "password", "nickname"}, "Email is required"},
+                {"taken nickname with a bad password", RegisterRequest{Email: "bob@example.com", Password: "x", Nickname: "alice", AgeConfirmed: true}, []string{"password", "nickname"}, ""},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        var response ValidationErrorResponse
+                        decodeResponse(t, s.do("POST", "/api/auth/register", "", tt.req), http.StatusBadRequest, &response)
+                        if response.Code != CodeValidationFailed || response.Error == "" {
+                                t.Fatalf("response = %+v, want VALIDATION_FAILED with a top-level error", response)
+                        }
+                        if len(response.Errors) != len(tt.fields) {
+                                t.Errorf("errors = %v, want exactly %v", response.Errors, tt.fields)
+                        }
+                        for _, field := range tt.fields {
+                                if response.Errors[field] == "" {
+                                        t.Errorf("errors = %v, missing %s", response.Errors, field)
+                                }
+                        }
+                        // Older clients only read error, which is the first invalid field's message
+                        if tt.message != "" && response.Error != tt.message {
+                                t.Errorf("error = %q, want %q", response.Error, tt.message)
+                        }
+                })
+        }
+}
+
+func TestLoginUpgradesPasswordHashCost(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        ctx := context.Background()
+
+        hashCost := func() int {
+                t.Helper()
+                user, err := s.db.GetUserByID(ctx, registered.User.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                cost, err := bcrypt.Cost([]byte(user.PasswordHash.String))
+                if err != nil {
+                        t.Fatal(err)
+                }
+                return cost
+        }
+
+        // A hash from before BCRYPT_COST was raised from 10 to 12
+        oldHash, err := bcrypt.GenerateFromPassword([]byte("correct-horse-42"), 10)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if err := s.db.UpdateUserPassword(ctx, registered.User.ID, string(oldHash)); err != nil {
+                t.Fatal(err)
+        }
+        s.config.BcryptCost = 12
+
+        // A failed login leaves the hash alone
+        decodeResponse(t, s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "wrong-password"}), http.StatusUnauthorized, nil)
+        if cost := hashCost(); cost != 10 {
+                t.Fatalf("cost after failed login = %d, want 10", cost)
+        }
+
+        var login LoginResponse
+        decodeResponse(t, s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"}), http.StatusOK, &login)
+        if cost := hashCost(); cost != 12 {
+                t.Fatalf("cost after login = %d, want 12", cost)
+        }
+
+        // The upgraded hash still verifies and the session issued alongside it works
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(login.AccessToken), nil), http.StatusOK, nil)
+        decodeResponse(t, s.do("POST", "/api/auth/login", "", LoginRequest{Identifier: "alice", Password: "correct-horse-42"}), http.StatusOK, nil)
+}
+
+func TestPlaceBetOnMovedOdds(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+
+        // The client saw 2.40 before the last sync moved home to 2.50
+        var response map[string]interface{}
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.4), http.StatusBadRequest, &response)
+        if response["current_odds"] != 2.5 {
+                t.Fatalf("current_odds = %v, want 2.5", response["current_odds"])
+        }
+
+        // Odds within the tolerance are not a change
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.5+oddsTolerance/2), http.StatusOK, nil)
+
+        var placed BetResponse
+        w := s.do("POST", "/api/bets", bearer(registered.AccessToken), PlaceBetRequest{MatchID: "match-1", BetType: "home", BetAmount: 10, Odds: 2.4, AcceptOddsChange: true})
+        decodeResponse(t, w, http.StatusOK, &placed)
+        if placed.Bet.Odds != 2.5 || placed.Bet.PotentialWin != 25 {
+                t.Fatalf("bet = %+v, want placed at the current 2.50", placed.Bet)
+        }
+}
+
+func TestPlaceBetOnStaleOdds(t *testing.T) {
+        s := newTestServer(t)
+        s.config.MaxOddsAge = 10 * time.Minute
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+
+        s.clock.Advance(s.config.MaxOddsAge)
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.5), http.StatusOK, nil)
+
+        s.clock.Advance(time.Second)
+        for _, accept := range []bool{false, true} {
+                var response map[string]interface{}
+                w := s.do("POST", "/api/bets", bearer(registered.AccessToken), PlaceBetRequest{MatchID: "match-1", BetType: "home", BetAmount: 10, Odds: 2.5, AcceptOddsChange: accept})
+                decodeResponse(t, w, http.StatusConflict, &response)
+                if response["current_odds"] != 2.5 || response["odds_updated_at"] == nil {
+                        t.Fatalf("response = %v, want the current odds and when they were synced", response)
+                }
+        }
+
+        // A sync refreshes the odds and reopens betting
+        odds := 2.6
+        if _, err := s.db.UpdateMatchByAPIID(context.Background(), "match-1", &Match{HomeOdds: &odds}); err != nil {
+                t.Fatal(err)
+        }
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.6), http.StatusOK, nil)
+}
+
+func TestListMatchesByTeamAndWindow(t *testing.T) {
+        s := newTestServer(t)
+        now := s.clock.Now()
+        odds := 2.0
+        for _, match := range []struct {
+                apiID, home, away string
+                kickoff           time.Duration
+                priced            bool
+        }{
+                {"ars-che", "Arsenal", "Chelsea", 2 * time.Hour, true},
+                {"liv-ars", "Liverpool", "Arsenal", 5 * time.Hour, true},
+                {"ars-tot", "Arsenal", "Tottenham", 30 * time.Hour, true},  // After the window
+                {"ars-ful", "Arsenal", "Fulham", 3 * time.Hour, false},     // No odds yet
+                {"che-eve", "Chelsea", "Everton", 4 * time.Hour, true},     // Other teams
+                {"wba-bur", "West Brom", "Burnley", 6 * time.Hour, true},
+        } {
+                m := &Match{APIID: match.apiID, HomeTeam: match.home, AwayTeam: match.away, CommenceTime: now.Add(match.kickoff)}
+                if match.priced {
+                        m.HomeOdds, m.DrawOdds, m.AwayOdds = &odds, &odds, &odds
+                }
+                if _, err := s.db.UpsertMatch(context.Background(), m); err != nil {
+                        t.Fatal(err)
+                }
+        }
+
+        window := fmt.Sprintf("from=%s&to=%s", now.Format(time.RFC3339), now.Add(24*time.Hour).Format(time.RFC3339))
+        tests := []struct {
+                query string
+                want  []string
+                total int
+        }{
+                {"team=arsenal&" + window, []string{"ars-che", "liv-ars"}, 2},
+                {"team=ARS", []string{"ars-che", "liv-ars", "ars-tot"}, 3},
+                {"team=arsenal&limit=1&" + window, []string{"ars-che"}, 2},
+                {"team=arsenal&limit=1&offset=1&" + window, []string{"liv-ars"}, 2},
+                {"team=brom&" + window, []string{"wba-bur"}, 1},
+                {"team=barcelona&" + window, []string{}, 0},
+                {fmt.Sprintf("from=%s", now.Add(3*time.Hour).Format(time.RFC3339)), []string{"che-eve", "liv-ars", "wba-bur", "ars-tot"}, 4},
+        }
+        for _, tt := range tests {
+                t.Run(tt.query, func(t *testing.T) {
+                        var response MatchesResponse
+                        decodeResponse(t, s.do("GET", "/api/matches?"+tt.query, "", nil), http.StatusOK, &response)
+                        got := []string{}
+                        for _, match := range response.Matches {
+                                got = append(got, match.ID)
+                        }
+                        if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                                t.Errorf("matches = %v, want %v", got, tt.want)
+                        }
+                        if response.Pagination == nil || response.Pagination.Total != tt.total {
+                                t.Errorf("pagination = %+v, want total %d", response.Pagination, tt.total)
+                        }
+                })
+        }
+
+        for _, query := range []string{"from=tomorrow", "to=2025-08-16", "from=" + now.Format(time.RFC3339) + "&to=" + now.Format(time.RFC3339)} {
+                decodeResponse(t, s.do("GET", "/api/matches?"+query, "", nil), http.StatusBadRequest, nil)
+        }
+}
+
+func TestTwoWaySportRejectsDrawBets(t *testing.T) {
+        s := newTestServer(t)
+        s.config.OddsAPISport = "basketball_nba"
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("game-1", 1.8, 3.0, 2.1)
+
+        var response map[string]interface{}
+        decodeResponse(t, s.placeBet(registered.AccessToken, "game-1", "draw", 10, 3.0), http.StatusBadRequest, &response)
+        if response["error"] != "Draw bets are not available for this sport" {
+                t.Fatalf("error = %v, want the two-way sport rejection", response["error"])
+        }
+        decodeResponse(t, s.placeBet(registered.AccessToken, "game-1", "home", 10, 1.8), http.StatusOK, nil)
+
+        // A level score can't settle a two-way market, so the bets are refunded
+        s.finishMatch("game-1", 100, 100)
+        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                t.Fatal(err)
+        }
+        var bets BetsResponse
+        decodeResponse(t, s.do("GET", "/api/bets", bearer(registered.AccessToken), nil), http.StatusOK, &bets)
+        if len(bets.Bets) != 1 || bets.Bets[0].Status != "void" {
+                t.Fatalf("bets = %+v, want the home bet voided", bets.Bets)
+        }
+        var user LoginResponse
+        decodeResponse(t, s.do("GET", "/api/auth/user", bearer(registered.AccessToken), nil), http.StatusOK, &user)
+        if user.User.Money != s.config.InitialBalance {
+                t.Fatalf("money = %v, want the stake refunded to %v", user.User.Money, s.config.InitialBalance)
+        }
+}
+
+func TestPotentialWinCapBoundary(t *testing.T) {
+        s := newTestServer(t)
+        s.config.MaxPotentialWin = 100
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.0, 4.0)
+
+        var response map[string]interface{}
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 40.01, 2.5), http.StatusBadRequest, &response)
+        if response["code"] != CodePotentialWinTooHigh || response["max_stake"] != 40.0 || response["max_potential_win"] != 100.0 {
+                t.Fatalf("response = %v, want POTENTIAL_WIN_TOO_HIGH offering a 40.00 stake", response)
+        }
+
+        // Exactly the maximum is allowed
+        var placed BetResponse
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 40, 2.5), http.StatusOK, &placed)
+        if placed.Bet.PotentialWin != 100 {
+                t.Fatalf("potential_win = %v, want 100", placed.Bet.PotentialWin)
+        }
+}
+
+func TestPlaceBetRejectsUnknownBetTypes(t *testing.T) {
+        s := newTestServer(t)
+        s.config.OddsAPIMarkets = []string{MarketH2H, MarketCorrectScore}
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+
+        for _, tt := range []struct {
+                betType string
+                code    string
+        }{
+                {"winner", CodeInvalidBetType},
+                {"Home", CodeInvalidBetType},
+                {"cs_99-0", CodeInvalidBetType},
+                {"over_2.25", CodeInvalidBetType},
+                {"btts_yes", CodeMarketUnavailable}, // Valid, but BTTS is not synced
+                {"over_2.5", CodeMarketUnavailable},
+        } {
+                var response map[string]interface{}
+                decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", tt.betType, 10, 2.0), http.StatusBadRequest, &response)
+                if response["code"] != tt.code {
+                        t.Errorf("%s: code = %v, want %s", tt.betType, response["code"], tt.code)
+                }
+        }
+}
+
+func TestPlayersPagingListsEachPlayerOnce(t *testing.T) {
+        s := newTestServer(t)
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+
+        // Mostly ties on every sort key, plus a few players who bet
+        const players = 23
+        for i := 0; i < players; i++ {
+                nickname := fmt.Sprintf("player%02d", i)
+                registered := s.register(nickname+"@example.com", nickname, "correct-horse-42")
+                if i%5 == 0 {
+                        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 10, 2.0), http.StatusOK, nil)
+                }
+        }
+
+        for _, order := range []string{PlayerSortBets, PlayerSortProfit, PlayerSortROI} {
+                t.Run(order, func(t *testing.T) {
+                        var listed []string
+                        seen := make(map[string]bool)
+                        for offset := 0; offset < players; offset += 4 {
+                                path := fmt.Sprintf("/api/players?sort=%s&limit=4&offset=%d", order, offset)
+                                var page, again PlayersResponse
+                                decodeResponse(t, s.do("GET", path, "", nil), http.StatusOK, &page)
+                                decodeResponse(t, s.do("GET", path, "", nil), http.StatusOK, &again)
+                                for i, player := range page.Players {
+                                        if again.Players[i].ID != player.ID {
+                                                t.Fatalf("offset %d: page order changed between requests", offset)
+                                        }
+                                        if seen[player.ID] {
+                                                t.Fatalf("%s listed twice (offset %d)", player.Nickname, offset)
+                                        }
+                                        seen[player.ID] = true
+                                        listed = append(listed, player.Nickname)
+                                }
+                        }
+                        if len(listed) != players {
+                                t.Fatalf("%d players listed across pages, want %d: %v", len(listed), players, listed)
+                        }
+                })
+        }
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+        "encoding/json"
+        "fmt"
+        "net/http"
+        "sort"
+        "strconv"
+        "strings"
+)
+
+// Supported locales; English is the source language, written inline at each call site
+const (
+        localeEnglish = "en"
+        localeSpanish = "es"
+)
+
+// supportedLocales are the locales DEFAULT_LOCALE and Accept-Language may select
+var supportedLocales = []string{localeEnglish, localeSpanish}
+
+// Keys of success messages; errors are keyed by their Code* constant
+const (
+        msgRegistered        = "REGISTERED"
+        msgGuestCreated      = "GUEST_CREATED"
+        msgAccountUpgraded   = "ACCOUNT_UPGRADED"
+        msgToppedUp          = "TOPPED_UP"
+        msgAuthenticated     = "AUTHENTICATED"
+        msgLimitsUpdated     = "LIMITS_UPDATED"
+        msgSelfExcluded      = "SELF_EXCLUSION_STARTED" // %s: until, RFC 3339
+        msgSelfExclusionKept = "SELF_EXCLUSION_KEPT"    // %s: existing until, RFC 3339
+)
+
+// messageCatalog maps locale -> message key -> text, which may be a fmt format
+// A missing locale or key falls back to the English text given by the caller
+type messageCatalog map[string]map[string]string
+
+// catalog holds the translations shipped with the server
+// Error entries are per code, so they are more general than the English message they replace
+var catalog = messageCatalog{
+        localeSpanish: {
+                CodeInvalidRequest:   "Solicitud no válida",
+                CodeInvalidJSON:      "JSON no válido",
+                CodeValidationFailed: "Revisa los campos del formulario",
+                CodeRequestTooLarge:  "La solicitud es demasiado grande",
+                CodeUnauthorized:     "Debes iniciar sesión",
+                CodeForbidden:        "Acceso denegado",
+                CodeNotFound:         "No encontrado",
+                CodeMethodNotAllowed: "Método no permitido",
+                CodeRateLimited:      "Demasiadas solicitudes. Inténtalo de nuevo más tarde",
+                CodeInternal:         "Error interno del servidor. Inténtalo de nuevo más tarde",
+                CodeUnavailable:      "Servicio no disponible temporalmente",
+                CodeMaintenance:      "Estamos en mantenimiento. Vuelve pronto",
+
+                CodeInvalidCredentials:    "Usuario o contraseña incorrectos",
+                CodeInvalidRefreshToken:   "La sesión ha caducado. Inicia sesión de nuevo",
+                CodeInvalidLoginCode:      "Código no válido o caducado",
+                CodeAccountDisabled:       "Esta cuenta ha sido desactivada",
+                CodeAlreadyRegistered:     "La cuenta ya está registrada",
+                CodeNicknameTaken:         "Ese apodo ya está en uso",
+                CodeInvalidNickname:       "Apodo no válido",
+                CodeNicknameChangeTooSoon: "Aún no puedes volver a cambiar tu apodo",
+                CodeWeakPassword:          "La contraseña es demasiado débil",
+                CodeAgeNotConfirmed:       "Debes confirmar que tienes 18 años o más",
+                CodeGuestPlayDisabled:     "El modo invitado está desactivado",
+                CodeUserNotFound:          "Usuario no encontrado",
+
+                CodeInsufficientBalance: "Saldo insuficiente",
+                CodeTopupCooldown:       "Solo puedes recargar una vez al día",
+                CodeTopupBalanceTooHigh: "Tu saldo es demasiado alto para recargar",
+
+                CodeMatchNotFound:       "Partido no encontrado",
+                CodeMatchStarted:        "No se puede apostar en un partido que ya ha comenzado",
+                CodeInvalidBetType:      "Tipo de apuesta no válido",
+                CodeInvalidBetAmount:    "Importe de apuesta no válido",
+                CodeMarketUnavailable:   "Este mercado no está disponible",
+                CodeOddsChanged:         "Las cuotas han cambiado. Revisa tu apuesta",
+                CodeOddsStale:           "Las cuotas de este partido están desactualizadas. Inténtalo más tarde",
+                CodePotentialWinTooHigh: "La ganancia potencial supera el máximo permitido",
+                CodeTooManyPendingBets:  "Tienes demasiadas apuestas pendientes",
+                CodeBetLimitExceeded:    "Esta apuesta supera tus límites de apuesta",
+                CodeSelfExcluded:        "Las apuestas están bloqueadas durante tu periodo de autoexclusión",
+                CodeBatchRejected:       "Se rechazaron algunas apuestas; no se realizó ninguna",
+
+                CodeCalcInProgress: "Ya hay un cálculo en curso",
+                CodeSeasonClosed:   "La temporada no está abierta",
+                CodeBetNotInReview: "La apuesta no está pendiente de revisión",
+                CodeMatchSettled:   "El partido ya fue liquidado",
+
+                msgRegistered:        "¡Registro completado! Ya has iniciado sesión.",
+                msgGuestCreated:      "Cuenta de invitado creada. Añade un correo y una contraseña para conservarla.",
+                msgAccountUpgraded:   "Cuenta actualizada. Ya puedes iniciar sesión con tu correo o apodo.",
+                msgToppedUp:          "¡Saldo recargado con éxito!",
+                msgAuthenticated:     "Autenticación correcta",
+                msgLimitsUpdated:     "Límites actualizados",
+                msgSelfExcluded:      "Las apuestas están bloqueadas hasta %s",
+                msgSelfExclusionKept: "Tu autoexclusión actual hasta %s es más larga y se mantiene",
+        },
+}
+
+// isSupportedLocale reports whether locale is one of supportedLocales
+func isSupportedLocale(locale string) bool {
+        for _, supported := range supportedLocales {
+                if locale == supported {
+                        return true
+                }
+        }
+        return false
+}
+
+// negotiateLocale picks the supported locale the Accept-Language header prefers most,
+// matching on the primary subtag ("es-MX" selects "es"); fallback if none matches
+func negotiateLocale(header, fallback string) string {
+        type preference struct {
+                locale  string
+                quality float64
+        }
+        var preferences []preference
+        for _, part := range strings.Split(header, ",") {
+                tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+                quality := 1.0
+                if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+                        parsed, err := strconv.ParseFloat(value, 64)
+                        if err != nil {
+                                continue
+                        }
+                        quality = parsed
+                }
+                primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+                if quality <= 0 || !isSupportedLocale(primary) {
+                        continue // "*" also lands here and leaves the fallback in charge
+                }
+                preferences = append(preferences, preference{locale: primary, quality: quality})
+        }
+        if len(preferences) == 0 {
+                return fallback
+        }
+        sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].quality > preferences[j].quality })
+        return preferences[0].locale
+}
+
+// requestLocale is the response language for r: Accept-Language, else DEFAULT_LOCALE
+func requestLocale(r *http.Request, config *Config) string {
+        return negotiateLocale(r.Header.Get("Accept-Language"), config.DefaultLocale)
+}
+
+// locale is the response language for r
+func (h *Handler) locale(r *http.Request) string {
+        return requestLocale(r, h.config)
+}
+
+// localize resolves a message for r's locale; english is the fmt format used when no translation exists
+func localize(r *http.Request, config *Config, key, english string, args ...interface{}) string {
+        format := english
+        if translated, ok := catalog[requestLocale(r, config)][key]; ok {
+                format = translated
+        }
+        if len(args) == 0 {
+                return format
+        }
+        return fmt.Sprintf(format, args...)
+}
+
+// text resolves a message for r's locale, see localize
+func (h *Handler) text(r *http.Request, key, english string, args ...interface{}) string {
+        return localize(r, h.config, key, english, args...)
+}
+
+// writeMiddlewareError is writeError for middleware that runs before a Handler exists
+// english must already be formatted: catalog entries for error codes take no arguments
+func writeMiddlewareError(w http.ResponseWriter, r *http.Request, config *Config, status int, code, english string) {
+        body, _ := json.Marshal(APIResponse{Success: false, Code: code, Error: localize(r, config, code, english)})
+        http.Error(w, string(body), status)
+}
+
+// localeMiddleware labels responses with the negotiated language and keeps caches from mixing languages
+// Registered before maintenance, rate limit and body limit, which answer through writeMiddlewareError
+func localeMiddleware(config *Config) func(http.Handler) http.Handler {
+        return func(next http.Handler) http.Handler {
+                return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        w.Header().Set("Content-Language", requestLocale(r, config))
+                        w.Header().Add("Vary", "Accept-Language")
+                        next.ServeHTTP(w, r)
+                })
+        }
+}
@@ -1,20 +1,36 @@
 package main
 
 import (
+        "context"
         "crypto/rand"
         "encoding/hex"
+        "errors"
+        "fmt"
         "time"
 
         "github.com/golang-jwt/jwt/v5"
 )
 
+// adminTokenAudience distinguishes admin tokens from user access tokens
+const adminTokenAudience = "freebet-admin"
+
+// ErrRefreshLookupFailed wraps database failures during refresh (as opposed to an invalid token)
+var ErrRefreshLookupFailed = errors.New("refresh token lookup failed")
+
+// ErrAccountDisabled is returned when a disabled (soft-deleted) user tries to get a new access token
+var ErrAccountDisabled = errors.New("account disabled")
+
+// accountDisabledMessage is the error shown to disabled users by login, refresh and the JWT middleware
+const accountDisabledMessage = "This account has been disabled"
+
 // generateAccessToken generates a new JWT access token
 func generateAccessToken(user *User, config *Config) (string, error) {
-        now := time.Now()
+        now := config.now()
         claims := AccessTokenClaims{
-                UserID:   user.ID,
-                Email:    user.Email,
-                Nickname: user.Nickname,
+                UserID:       user.ID,
+                Email:        user.Email,
+                Nickname:     user.Nickname,
+                TokenVersion: user.TokenVersion,
                 RegisteredClaims: jwt.RegisteredClaims{
                         IssuedAt:  jwt.NewNumericDate(now),
                         ExpiresAt: jwt.NewNumericDate(now.Add(config.JWTAccessTokenTTL)),
@@ -28,9 +44,14 @@ func generateAccessToken(user *User, config *Config) (string, error) {
         return token.SignedString([]byte(config.JWTSecret))
 }
 
+// accessTokenExpiresIn is the access token lifetime in seconds, reported to clients as expires_in
+func accessTokenExpiresIn(config *Config) int {
+        return int(config.JWTAccessTokenTTL / time.Second)
+}
+
 // generateRefreshToken generates a new JWT refresh token
 func generateRefreshToken(userID string, config *Config) (string, error) {
-        now := time.Now()
+        now := config.now()
         claims := RefreshTokenClaims{
                 UserID: userID,
                 RegisteredClaims: jwt.RegisteredClaims{
@@ -56,7 +77,7 @@ func validateAccessToken(tokenString string, config *Config) (*AccessTokenClaims
                         return nil, jwt.ErrSignatureInvalid
                 }
                 return []byte(config.JWTSecret), nil
-        })
+        }, jwt.WithTimeFunc(config.now))
 
         if err != nil {
                 return nil, err
@@ -78,7 +99,7 @@ func validateRefreshToken(tokenString string, config *Config) (*RefreshTokenClai
                         return nil, jwt.ErrSignatureInvalid
                 }
                 return []byte(config.JWTSecret), nil
-        })
+        }, jwt.WithTimeFunc(config.now))
 
         if err != nil {
                 return nil, err
@@ -91,6 +112,55 @@ func validateRefreshToken(tokenString string, config *Config) (*RefreshTokenClai
         return claims, nil
 }
 
+// generateAdminToken generates a new short-lived admin JWT
+func generateAdminToken(admin *Admin, config *Config) (string, time.Time, error) {
+        now := config.now()
+        expiresAt := now.Add(config.AdminTokenTTL)
+        claims := AdminTokenClaims{
+                AdminID:  admin.ID,
+                Username: admin.Username,
+                RegisteredClaims: jwt.RegisteredClaims{
+                        IssuedAt:  jwt.NewNumericDate(now),
+                        ExpiresAt: jwt.NewNumericDate(expiresAt),
+                        NotBefore: jwt.NewNumericDate(now),
+                        Issuer:    "freebet-api",
+                        Subject:   admin.ID,
+                        Audience:  jwt.ClaimStrings{adminTokenAudience},
+                        ID:        generateTokenID(),
+                },
+        }
+
+        token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+        signed, err := token.SignedString([]byte(config.JWTSecret))
+        if err != nil {
+                return "", time.Time{}, err
+        }
+        return signed, expiresAt, nil
+}
+
+// validateAdminToken validates and parses an admin token
+// User access tokens are rejected because they lack the admin audience
+func validateAdminToken(tokenString string, config *Config) (*AdminTokenClaims, error) {
+        claims := &AdminTokenClaims{}
+
+        token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+                if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+                        return nil, jwt.ErrSignatureInvalid
+                }
+                return []byte(config.JWTSecret), nil
+        }, jwt.WithAudience(adminTokenAudience), jwt.WithTimeFunc(config.now))
+
+        if err != nil {
+                return nil, err
+        }
+
+        if !token.Valid || claims.AdminID == "" {
+                return nil, jwt.ErrTokenMalformed
+        }
+
+        return claims, nil
+}
+
 // generateTokenID generates a random token ID for refresh tokens
 func generateTokenID() string {
         bytes := make([]byte, 16)
@@ -99,7 +169,7 @@ func generateTokenID() string {
 }
 
 // refreshAccessToken refreshes an access token using a valid refresh token
-func refreshAccessToken(refreshTokenString string, db Database, config *Config) (string, error) {
+func refreshAccessToken(ctx context.Context, refreshTokenString string, db Database, config *Config) (string, error) {
         // Validate refresh token
         refreshClaims, err := validateRefreshToken(refreshTokenString, config)
         if err != nil {
@@ -107,16 +177,30 @@ func refreshAccessToken(refreshTokenString string, db Database, config *Config)
         }
 
         // Check if refresh token exists in database (optional, but good practice)
-        storedToken, err := db.GetRefreshTokenByToken(refreshTokenString)
-        if err != nil || storedToken == nil {
+        storedToken, err := db.GetRefreshTokenByToken(ctx, refreshTokenString)
+        if errors.Is(err, ErrRefreshTokenNotFound) || (err == nil && storedToken == nil) {
                 return "", jwt.ErrTokenNotValidYet // Token not found or expired
         }
+        if err != nil {
+                return "", fmt.Errorf("%w: %v", ErrRefreshLookupFailed, err)
+        }
 
         // Get user data
-        user, err := db.GetUserByID(refreshClaims.UserID)
-        if err != nil {
+        user, err := db.GetUserByID(ctx, refreshClaims.UserID)
+        if errors.Is(err, ErrUserNotFound) {
                 return "", err
         }
+        if err != nil {
+                return "", fmt.Errorf("%w: %v", ErrRefreshLookupFailed, err)
+        }
+        if user.Disabled() {
+                return "", ErrAccountDisabled
+        }
+
+        // Refreshes keep guests from expiring; a failed touch only brings the expiry closer
+        if user.IsGuest {
+                db.TouchGuest(ctx, user.ID)
+        }
 
         // Generate new access token
         return generateAccessToken(user, config)
@@ -0,0 +1,259 @@
+package main
+
+import (
+        "context"
+        "encoding/json"
+        "fmt"
+        "math"
+        "net/http"
+        "time"
+)
+
+// Self-limits use rolling windows, so there is no timezone-dependent reset
+const (
+        dailyLimitWindow     = 24 * time.Hour
+        weeklyLimitWindow    = 7 * 24 * time.Hour
+        maxSelfExclusionDays = 1825 // 5 years
+)
+
+// limitUsage reports a limit against its usage; nil when the limit is not set
+func limitUsage(limit *float64, used float64) *LimitUsage {
+        if limit == nil {
+                return nil
+        }
+        return &LimitUsage{
+                Limit:     *limit,
+                Used:      roundMoney(used),
+                Remaining: math.Max(0, addMoney(*limit, -used)),
+        }
+}
+
+// lossUsed counts settled net losses plus stakes still at risk
+// Winnings offset losses, but a user ahead for the period never goes below zero
+func lossUsed(usage *BetUsage) float64 {
+        return addMoney(math.Max(0, usage.NetLoss), usage.Pending)
+}
+
+// summarizeLimits computes what is left of each limit over its rolling window
+func (h *Handler) summarizeLimits(ctx context.Context, limits *UserLimits) (*LimitsSummary, error) {
+        now := h.config.now()
+        summary := &LimitsSummary{}
+
+        if limits.SelfExcludedUntil != nil && now.Before(*limits.SelfExcludedUntil) {
+                summary.SelfExcludedUntil = limits.SelfExcludedUntil
+        }
+
+        if limits.DailyWagerLimit != nil || limits.DailyLossLimit != nil {
+                usage, err := h.db.GetBetUsageSince(ctx, limits.UserID, now.Add(-dailyLimitWindow))
+                if err != nil {
+                        return nil, err
+                }
+                summary.DailyWager = limitUsage(limits.DailyWagerLimit, usage.Wagered)
+                summary.DailyLoss = limitUsage(limits.DailyLossLimit, lossUsed(usage))
+        }
+
+        if limits.WeeklyWagerLimit != nil || limits.WeeklyLossLimit != nil {
+                usage, err := h.db.GetBetUsageSince(ctx, limits.UserID, now.Add(-weeklyLimitWindow))
+                if err != nil {
+                        return nil, err
+                }
+                summary.WeeklyWager = limitUsage(limits.WeeklyWagerLimit, usage.Wagered)
+                summary.WeeklyLoss = limitUsage(limits.WeeklyLossLimit, lossUsed(usage))
+        }
+
+        // The largest stake allowed is the smallest remaining amount across all limits
+        for _, usage := range []*LimitUsage{summary.DailyWager, summary.WeeklyWager, summary.DailyLoss, summary.WeeklyLoss} {
+                if usage == nil {
+                        continue
+                }
+                if summary.MaxStake == nil || usage.Remaining < *summary.MaxStake {
+                        remaining := usage.Remaining
+                        summary.MaxStake = &remaining
+                }
+        }
+
+        return summary, nil
+}
+
+// hasLimits reports whether any limit or an active self-exclusion applies
+func (s *LimitsSummary) hasLimits() bool {
+        return s.SelfExcludedUntil != nil || s.DailyWager != nil || s.WeeklyWager != nil ||
+                s.DailyLoss != nil || s.WeeklyLoss != nil
+}
+
+// checkBetLimits enforces self-exclusion and wager/loss limits before a bet is placed
+// Writes the error response and returns false when the bet is not allowed
+func (h *Handler) checkBetLimits(w http.ResponseWriter, r *http.Request, user *User, amount float64) bool {
+        limits, err := h.db.GetUserLimits(r.Context(), user.ID)
+        if err == nil {
+                var summary *LimitsSummary
+                summary, err = h.summarizeLimits(r.Context(), limits)
+                if err == nil {
+                        return h.allowBet(w, r, user, amount, summary)
+                }
+        }
+
+        h.logger.LogError("Failed to check limits for user %s: %s", user.ID, err.Error())
+        h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to place bet")
+        return false
+}
+
+// allowBet applies a computed limits summary to a bet amount
+func (h *Handler) allowBet(w http.ResponseWriter, r *http.Request, user *User, amount float64, summary *LimitsSummary) bool {
+        if summary.SelfExcludedUntil != nil {
+                h.logger.LogBets("Bet rejected: user %s is self-excluded until %s", user.ID, summary.SelfExcludedUntil.Format(time.RFC3339))
+                h.writeJSON(w, http.StatusForbidden, map[string]interface{}{
+                        "success":             false,
+                        "code":                CodeSelfExcluded,
+                        "error":               h.text(r, CodeSelfExcluded, "Betting is blocked during your self-exclusion period"),
+                        "self_excluded_until": summary.SelfExcludedUntil.UTC().Format(time.RFC3339),
+                })
+                return false
+        }
+
+        if summary.MaxStake != nil && amount > *summary.MaxStake {
+                h.logger.LogBets("Bet rejected: user %s stake $%.2f exceeds remaining limit $%.2f", user.ID, amount, *summary.MaxStake)
+                h.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+                        "success":   false,
+                        "code":      CodeBetLimitExceeded,
+                        "error":     h.text(r, CodeBetLimitExceeded, fmt.Sprintf("This bet exceeds your betting limits; the most you can stake now is $%.2f", *summary.MaxStake)),
+                        "max_stake": *summary.MaxStake,
+                })
+                return false
+        }
+
+        return true
+}
+
+// writeLimits responds with the user's limits and current usage
+func (h *Handler) writeLimits(w http.ResponseWriter, r *http.Request, limits *UserLimits, message string) {
+        summary, err := h.summarizeLimits(r.Context(), limits)
+        if err != nil {
+                h.logger.LogError("Failed to compute limits for user %s: %s", limits.UserID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to load limits")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, LimitsResponse{
+                Success: true,
+                Message: message,
+                Limits:  *limits,
+                Summary: *summary,
+        })
+}
+
+// GetLimitsHandler handles GET /api/auth/limits
+func (h *Handler) getLimitsHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        limits, err := h.db.GetUserLimits(r.Context(), user.ID)
+        if err != nil {
+                h.logger.LogError("Failed to get limits for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to load limits")
+                return
+        }
+
+        h.writeLimits(w, r, limits, "")
+}
+
+// SetLimitsHandler handles PUT /api/auth/limits
+// Replaces all four wager/loss limits; null or 0 removes a limit
+func (h *Handler) setLimitsHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        var req SetLimitsRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+
+        for _, limit := range []*float64{req.DailyWagerLimit, req.WeeklyWagerLimit, req.DailyLossLimit, req.WeeklyLossLimit} {
+                if limit != nil && (*limit < 0 || math.IsNaN(*limit) || math.IsInf(*limit, 0)) {
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Limits must be positive amounts")
+                        return
+                }
+        }
+
+        limits, err := h.db.GetUserLimits(r.Context(), user.ID)
+        if err != nil {
+                h.logger.LogError("Failed to get limits for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to save limits")
+                return
+        }
+
+        limits.DailyWagerLimit = normalizeLimit(req.DailyWagerLimit)
+        limits.WeeklyWagerLimit = normalizeLimit(req.WeeklyWagerLimit)
+        limits.DailyLossLimit = normalizeLimit(req.DailyLossLimit)
+        limits.WeeklyLossLimit = normalizeLimit(req.WeeklyLossLimit)
+
+        if err := h.db.SetUserLimits(r.Context(), limits); err != nil {
+                h.logger.LogError("Failed to save limits for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to save limits")
+                return
+        }
+
+        h.logger.LogBets("Betting limits updated for user %s", user.ID)
+        h.writeLimits(w, r, limits, h.text(r, msgLimitsUpdated, "Limits updated"))
+}
+
+// normalizeLimit treats 0 as "no limit" and rounds to cents
+func normalizeLimit(limit *float64) *float64 {
+        if limit == nil || *limit == 0 {
+                return nil
+        }
+        rounded := roundMoney(*limit)
+        return &rounded
+}
+
+// SelfExclusionHandler handles POST /api/auth/self-exclusion
+// Starts or extends a self-exclusion; an active period can never be shortened
+func (h *Handler) selfExclusionHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        var req SelfExclusionRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                h.writeDecodeError(w, r, err)
+                return
+        }
+
+        if req.Days < 1 || req.Days > maxSelfExclusionDays {
+                h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Self-exclusion must be between 1 and %d days", maxSelfExclusionDays))
+                return
+        }
+
+        limits, err := h.db.GetUserLimits(r.Context(), user.ID)
+        if err != nil {
+                h.logger.LogError("Failed to get limits for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to start self-exclusion")
+                return
+        }
+
+        until := h.config.now().UTC().Add(time.Duration(req.Days) * 24 * time.Hour).Truncate(time.Second)
+        message := h.text(r, msgSelfExcluded, "Betting is blocked until %s", until.Format(time.RFC3339))
+        if limits.SelfExcludedUntil != nil && limits.SelfExcludedUntil.After(until) {
+                until = *limits.SelfExcludedUntil
+                message = h.text(r, msgSelfExclusionKept, "Your existing self-exclusion until %s is longer and remains in place", until.UTC().Format(time.RFC3339))
+        }
+        limits.SelfExcludedUntil = &until
+
+        if err := h.db.SetUserLimits(r.Context(), limits); err != nil {
+                h.logger.LogError("Failed to save self-exclusion for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to start self-exclusion")
+                return
+        }
+
+        h.logger.LogBets("User %s self-excluded until %s", user.ID, until.Format(time.RFC3339))
+        h.writeLimits(w, r, limits, message)
+}
@@ -2,42 +2,104 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Logger represents a structured logger
 type Logger struct {
-	level    string
-	startTime time.Time
+	level     atomic.Value // string; read on every entry, changed at runtime by SetLevel
+	startTime time.Time  // Set once by NewLogger, read-only afterwards
+	mu        sync.Mutex // Serializes writes to out so concurrent entries never interleave
+	out       io.Writer
+
+	// Options below are set during startup, before the logger is shared, and only read afterwards
+
+	// SQL logging options (see SetSQLOptions)
+	sqlText       bool
+	slowQueryTime time.Duration
+
+	// Proxies whose forwarded headers name the client in request logs (see SetTrustedProxies)
+	trustedProxies []CIDR
 }
 
-// NewLogger creates a new logger instance
-func NewLogger(level string) *Logger {
-	return &Logger{
-		level:     strings.ToUpper(level),
+// NewLogger creates a new logger instance writing to out (stdout if nil)
+func NewLogger(level string, out io.Writer) *Logger {
+	if out == nil {
+		out = os.Stdout
+	}
+	l := &Logger{
 		startTime: time.Now(),
+		out:       out,
 	}
+	l.level.Store(strings.ToUpper(level))
+	return l
 }
 
-// shouldLog checks if the current log level allows logging this message
-func (l *Logger) shouldLog(level string) bool {
-	levels := map[string]int{
-		"DEBUG": 0,
-		"INFO":  1,
-		"WARN":  2,
-		"ERROR": 3,
+// Level returns the current log level
+func (l *Logger) Level() string {
+	return l.level.Load().(string)
+}
+
+// SetLevel changes the log level while running; concurrent entries see the old or new level
+func (l *Logger) SetLevel(level string) error {
+	level = strings.ToUpper(strings.TrimSpace(level))
+	if _, ok := logLevels[level]; !ok {
+		return fmt.Errorf("unknown log level %q (want DEBUG, INFO, WARN or ERROR)", level)
+	}
+	l.level.Store(level)
+	return nil
+}
+
+// NewLogOutput returns the configured log destination
+// With LogFile set, logs go to a size/age rotated file; otherwise to stdout
+func NewLogOutput(config *Config) io.Writer {
+	if config.LogFile == "" {
+		return os.Stdout
+	}
+	return &lumberjack.Logger{
+		Filename:   config.LogFile,
+		MaxSize:    config.LogMaxSizeMB,
+		MaxAge:     config.LogMaxAgeDays,
+		MaxBackups: config.LogMaxBackups,
+		Compress:   config.LogCompress,
+		LocalTime:  true,
 	}
+}
 
-	currentLevel, exists := levels[l.level]
+// println writes one formatted entry with a single Write under the mutex,
+// so concurrent entries (including wrapped multi-line ones) never interleave
+func (l *Logger) println(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.out, entry+"\n")
+}
+
+// logLevels orders the levels; unknown levels behave as INFO
+var logLevels = map[string]int{
+	"DEBUG": 0,
+	"INFO":  1,
+	"WARN":  2,
+	"ERROR": 3,
+}
+
+// shouldLog checks if the current log level allows logging this message
+func (l *Logger) shouldLog(level string) bool {
+	currentLevel, exists := logLevels[l.Level()]
 	if !exists {
-		currentLevel = levels["INFO"]
+		currentLevel = logLevels["INFO"]
 	}
 
-	msgLevel, exists := levels[strings.ToUpper(level)]
+	msgLevel, exists := logLevels[strings.ToUpper(level)]
 	if !exists {
-		msgLevel = levels["INFO"]
+		msgLevel = logLevels["INFO"]
 	}
 
 	return msgLevel >= currentLevel
@@ -98,78 +160,115 @@ func (l *Logger) formatMessage(level, category, message string, args ...interfac
 // LogInfo logs an info message
 func (l *Logger) LogInfo(message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "", message, args...))
+		l.println(l.formatMessage("INFO", "", message, args...))
 	}
 }
 
 // LogError logs an error message
 func (l *Logger) LogError(message string, args ...interface{}) {
 	if l.shouldLog("ERROR") {
-		fmt.Println(l.formatMessage("ERROR", "", message, args...))
+		l.println(l.formatMessage("ERROR", "", message, args...))
 	}
 }
 
 // LogWarning logs a warning message
 func (l *Logger) LogWarning(message string, args ...interface{}) {
 	if l.shouldLog("WARN") {
-		fmt.Println(l.formatMessage("WARN", "", message, args...))
+		l.println(l.formatMessage("WARN", "", message, args...))
 	}
 }
 
 // LogSuccess logs a success message
 func (l *Logger) LogSuccess(message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "", message, args...))
+		l.println(l.formatMessage("INFO", "", message, args...))
 	}
 }
 
 // LogSystem logs a system message with category
 func (l *Logger) LogSystem(category, message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", category, message, args...))
+		l.println(l.formatMessage("INFO", category, message, args...))
 	}
 }
 
 // LogDB logs a database-related message
 func (l *Logger) LogDB(message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "DB", message, args...))
+		l.println(l.formatMessage("INFO", "DB", message, args...))
 	}
 }
 
 // LogAuth logs an authentication-related message
 func (l *Logger) LogAuth(message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "AUTH", message, args...))
+		l.println(l.formatMessage("INFO", "AUTH", message, args...))
 	}
 }
 
 // LogBets logs a bets-related message
 func (l *Logger) LogBets(message string, args ...interface{}) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "BETS", message, args...))
+		l.println(l.formatMessage("INFO", "BETS", message, args...))
 	}
 }
 
+// SetSQLOptions enables full query text at DEBUG and sets the slow query threshold (0 disables)
+func (l *Logger) SetSQLOptions(logText bool, slowQueryTime time.Duration) {
+	l.sqlText = logText
+	l.slowQueryTime = slowQueryTime
+}
+
+// SetTrustedProxies sets the proxies whose forwarded headers are trusted for the logged client IP
+func (l *Logger) SetTrustedProxies(trustedProxies []CIDR) {
+	l.trustedProxies = trustedProxies
+}
+
 // LogSQL logs SQL query information
-func (l *Logger) LogSQL(operation string, params []interface{}, duration time.Duration) {
-	if l.shouldLog("DEBUG") {
-		paramStr := "none"
-		if len(params) > 0 {
-			// Truncate long parameter lists
-			paramStr = fmt.Sprintf("%v", params)
-			if len(paramStr) > 50 {
-				paramStr = paramStr[:47] + "..."
-			}
+// Callers must redact sensitive params (tokens, password hashes) with maskToken
+// Queries exceeding the slow query threshold are logged as warnings at any level,
+// so production (INFO) still sees degradations
+func (l *Logger) LogSQL(operation, query string, params []interface{}, duration time.Duration) {
+	slow := l.slowQueryTime > 0 && duration > l.slowQueryTime
+	if !slow && !l.shouldLog("DEBUG") {
+		return
+	}
+
+	paramStr := "none"
+	if len(params) > 0 {
+		// Truncate long parameter lists
+		paramStr = fmt.Sprintf("%v", params)
+		if len(paramStr) > 50 {
+			paramStr = paramStr[:47] + "..."
 		}
-		fmt.Println(l.formatMessage("DEBUG", "SQL", "%s | params: %s | %v", operation, paramStr, duration.Round(time.Millisecond)))
 	}
+
+	if slow {
+		// Always include the query text so the slow query can be found
+		l.println(l.formatMessage("WARN", "SQL", "Slow query: %s | %v | params: %s | %s",
+			operation, duration.Round(time.Millisecond), paramStr, compactSQL(query)))
+		return
+	}
+
+	if l.sqlText && query != "" {
+		l.println(l.formatMessage("DEBUG", "SQL", "%s | params: %s | %v | %s", operation, paramStr, duration.Round(time.Millisecond), compactSQL(query)))
+		return
+	}
+	l.println(l.formatMessage("DEBUG", "SQL", "%s | params: %s | %v", operation, paramStr, duration.Round(time.Millisecond)))
+}
+
+// compactSQL collapses whitespace so a query fits on one log entry
+func compactSQL(query string) string {
+	if query == "" {
+		return "(query text unavailable)"
+	}
+	return strings.Join(strings.Fields(query), " ")
 }
 
 // LogStartup logs application startup information
 func (l *Logger) LogStartup(name, port string) {
 	if l.shouldLog("INFO") {
-		fmt.Println(l.formatMessage("INFO", "STARTUP", "Starting %s on port %s", name, port))
+		l.println(l.formatMessage("INFO", "STARTUP", "Starting %s on port %s", name, port))
 	}
 }
 
@@ -177,7 +276,7 @@ func (l *Logger) LogStartup(name, port string) {
 func (l *Logger) LogShutdown() {
 	if l.shouldLog("INFO") {
 		uptime := time.Since(l.startTime)
-		fmt.Println(l.formatMessage("INFO", "SHUTDOWN", "Application uptime: %v", uptime.Round(time.Second)))
+		l.println(l.formatMessage("INFO", "SHUTDOWN", "Application uptime: %v", uptime.Round(time.Second)))
 	}
 }
 
@@ -185,7 +284,7 @@ func (l *Logger) LogShutdown() {
 func (l *Logger) LogMetrics() {
 	if l.shouldLog("INFO") {
 		uptime := time.Since(l.startTime)
-		fmt.Println(l.formatMessage("INFO", "METRICS", "Metrics - Uptime: %v", uptime.Round(time.Second)))
+		l.println(l.formatMessage("INFO", "METRICS", "Metrics - Uptime: %v", uptime.Round(time.Second)))
 	}
 }
 
@@ -194,7 +293,7 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Create a response writer wrapper to capture status code
+		// Create a response writer wrapper to capture status code and bytes written
 		wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 		// Call the next handler
@@ -203,16 +302,10 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 		// Log the request
 		duration := time.Since(start)
 		status := wrapper.statusCode
+		bytes := wrapper.bytesWritten
 		method := r.Method
 		path := r.URL.Path
-		ip := r.RemoteAddr
-
-		// Extract real IP if behind proxy
-		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-			ip = forwarded
-		} else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-			ip = realIP
-		}
+		ip := ClientIP(r, l.trustedProxies)
 
 		// Color code status (simple text indicators)
 		var statusIndicator string
@@ -229,20 +322,40 @@ func (l *Logger) Middleware(next http.Handler) http.Handler {
 		}
 
 		if l.shouldLog("INFO") {
-			fmt.Println(l.formatMessage("INFO", "HTTP",
-				"%s %s | %d %s | %v | %s",
-				method, path, status, statusIndicator, duration.Round(time.Millisecond), ip))
+			l.println(l.formatMessage("INFO", "HTTP",
+				"%s %s | %d %s | %v | %d bytes | %s",
+				method, path, status, statusIndicator, duration.Round(time.Millisecond), bytes, ip))
 		}
 	})
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and response size
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write counts the bytes actually written to the client
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Flush passes through to the underlying writer so streaming handlers keep working
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 }
\ No newline at end of file
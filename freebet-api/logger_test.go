@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// overlapWriter records output without locking of its own and counts writes that overlapped
+type overlapWriter struct {
+	writing  atomic.Int32
+	overlaps atomic.Int32
+	data     []byte
+}
+
+func (w *overlapWriter) Write(p []byte) (int, error) {
+	if w.writing.Add(1) > 1 {
+		w.overlaps.Add(1)
+	}
+	defer w.writing.Add(-1)
+	// Yield halfway through, so unserialized writers tear lines even on one CPU
+	half := len(p) / 2
+	w.data = append(w.data, p[:half]...)
+	runtime.Gosched()
+	w.data = append(w.data, p[half:]...)
+	return len(p), nil
+}
+
+func TestLoggerConcurrentEntriesAreWholeLines(t *testing.T) {
+	const goroutines, entries = 32, 200
+
+	out := &overlapWriter{}
+	logger := NewLogger("INFO", out)
+	padding := strings.Repeat("x", 60)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < entries; i++ {
+				switch i % 3 {
+				case 0:
+					logger.LogInfo("entry %d-%d %s end", g, i, padding)
+				case 1:
+					logger.LogAuth("entry %d-%d %s end", g, i, padding)
+				default:
+					logger.LogError("entry %d-%d %s end", g, i, padding)
+				}
+			}
+		}(g)
+	}
+	// Level changes race the entries; both levels log everything above
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < entries; i++ {
+			logger.SetLevel([]string{"DEBUG", "INFO"}[i%2])
+		}
+	}()
+	wg.Wait()
+
+	if n := out.overlaps.Load(); n > 0 {
+		t.Errorf("%d writes overlapped", n)
+	}
+
+	line := regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} (INFO |ERROR) (\[AUTH\] )?entry (\d+-\d+) x{60} end$`)
+	seen := make(map[string]bool)
+	for _, text := range strings.Split(strings.TrimSuffix(string(out.data), "\n"), "\n") {
+		match := line.FindStringSubmatch(text)
+		if match == nil {
+			t.Fatalf("torn line %q", text)
+		}
+		if seen[match[3]] {
+			t.Fatalf("entry %s logged twice", match[3])
+		}
+		seen[match[3]] = true
+	}
+	if len(seen) != goroutines*entries {
+		t.Fatalf("%d entries logged, want %d", len(seen), goroutines*entries)
+	}
+}
+
+func TestLoggerKeepsWrappedEntriesTogether(t *testing.T) {
+	const goroutines = 16
+
+	out := &overlapWriter{}
+	logger := NewLogger("INFO", out)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// Over 120 characters, so the entry is wrapped onto continuation lines
+			logger.LogDB("%s", strings.Repeat(fmt.Sprintf("g%02d ", g), 60))
+		}(g)
+	}
+	wg.Wait()
+
+	// Every continuation line follows a line of the same entry
+	lines := strings.Split(strings.TrimSuffix(string(out.data), "\n"), "\n")
+	owner := regexp.MustCompile(`\[DB\] (g\d{2}) `)
+	for i, text := range lines {
+		match := owner.FindStringSubmatch(text)
+		if match == nil {
+			t.Fatalf("line %d has no entry: %q", i, text)
+		}
+		if !strings.Contains(text, " INFO ") && !strings.Contains(lines[i-1], "[DB] "+match[1]+" ") {
+			t.Fatalf("continuation line %d of %s follows another entry: %q", i, match[1], lines[i-1])
+		}
+	}
+}
@@ -6,11 +6,17 @@ import (
         "net/http"
         "os"
         "os/signal"
+        "sync"
         "syscall"
         "time"
 )
 
 func main() {
+        // Subcommands (no arguments starts the API server)
+        if len(os.Args) > 1 {
+                os.Exit(runCommand(os.Args[1], os.Args[2:]))
+        }
+
         // Load configuration
         config, err := loadConfig()
         if err != nil {
@@ -19,11 +25,19 @@ func main() {
         }
 
         // Initialize logger
-        logger := NewLogger(config.LogLevel)
+        logger := NewLogger(config.LogLevel, NewLogOutput(config))
+        logger.SetSQLOptions(config.LogSQLText, config.SlowQueryThreshold)
+        logger.SetTrustedProxies(config.trustedProxyCIDRs())
 
         // Log startup information
         logger.LogStartup("FREEBET.GURU Go API", fmt.Sprintf("%d", config.Port))
         logger.LogInfo("Environment: %s", config.Env)
+        logger.LogInfo("Build: version %s, commit %s, built %s, %s", buildInfo.Version, buildInfo.Commit, buildInfo.BuildTime, buildInfo.GoVersion)
+
+        // Production refuses an insecure JWT secret in loadConfig; elsewhere just warn
+        if err := validateJWTSecret(config); err != nil {
+                logger.LogWarning("Insecure JWT secret: %s (this would be refused in production)", err.Error())
+        }
 
         // Initialize database
         db, err := NewPostgresDB(config.DatabaseURL, config, logger)
@@ -31,17 +45,26 @@ func main() {
                 logger.LogError("Failed to connect to database: %s", err.Error())
                 os.Exit(1)
         }
-        defer db.Close()
 
         // Test database connection
-        if err := db.Ping(); err != nil {
+        if err := db.Ping(context.Background()); err != nil {
                 logger.LogError("Database ping failed: %s", err.Error())
                 os.Exit(1)
         }
         logger.LogSuccess("Database connection established")
 
+        // Apply pending schema migrations
+        if config.RunMigrations {
+                applied, err := db.RunMigrations()
+                if err != nil {
+                        logger.LogError("Database migration failed: %s", err.Error())
+                        os.Exit(1)
+                }
+                logger.LogSuccess("Database migrations complete: %d applied", applied)
+        }
+
         // Log database statistics on startup
-        stats, err := db.GetDatabaseStats()
+        stats, err := db.GetDatabaseStats(context.Background())
         if err == nil {
                 logger.LogSystem("DATABASE", "Initial stats - Users: %d, Sessions: %d, Bets: %d, Matches: %d",
                         stats["users"], stats["sessions"], stats["bets"], stats["matches"])
@@ -49,8 +72,24 @@ func main() {
                 logger.LogWarning("Failed to get initial database stats: %s", err.Error())
         }
 
+        // Everything past startup reads users through the cache
+        store := NewUserCacheDB(db, config)
+
+        // Shared sync/calc logic for admin handlers and the scheduler
+        syncService := NewSyncService(store, config, logger, NewNotifiers(config, logger))
+
+        // Root context for background workers, cancelled on the shutdown signal
+        // Every worker registers on the WaitGroup so shutdown can drain it
+        rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+        defer stop()
+        var workers sync.WaitGroup
+
+        // Start background scheduler (jobs enabled via config flags)
+        scheduler := NewScheduler(syncService, store, config, logger)
+        scheduler.Start(rootCtx, &workers)
+
         // Setup routes with logging middleware
-        router := SetupRoutes(db, config, logger)
+        router := SetupRoutes(store, config, logger, syncService)
         
         // Wrap with logging middleware
         handler := logger.Middleware(router)
@@ -74,26 +113,53 @@ func main() {
                 }
         }()
 
-        // Setup graceful shutdown
-        quit := make(chan os.Signal, 1)
-        signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-        // Wait for interrupt signal
-        <-quit
+        // Wait for interrupt signal; a second signal kills the process immediately
+        <-rootCtx.Done()
+        stop()
         logger.LogWarning("Shutdown signal received, shutting down gracefully...")
 
-        // Give outstanding requests 30 seconds to complete
+        // Give outstanding requests and background workers 30 seconds to complete
         ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
         defer cancel()
 
+        exitCode := 0
+
         // Attempt graceful shutdown
         if err := server.Shutdown(ctx); err != nil {
                 logger.LogError("Server forced to shutdown: %s", err.Error())
-                os.Exit(1)
+                exitCode = 1
+        }
+
+        // Wait for background workers (already cancelled via rootCtx)
+        if !waitWithTimeout(ctx, &workers) {
+                logger.LogError("Background workers did not stop before the shutdown timeout")
+                exitCode = 1
         }
 
+        // Close the database only after nothing can use it anymore
+        db.Close()
+
         // Log final metrics and shutdown info
         logger.LogMetrics()
         logger.LogShutdown()
+        if exitCode != 0 {
+                os.Exit(exitCode)
+        }
         logger.LogSuccess("Server shutdown complete")
 }
+
+// waitWithTimeout waits for wg until ctx expires; returns false on timeout
+func waitWithTimeout(ctx context.Context, wg *sync.WaitGroup) bool {
+        done := make(chan struct{})
+        go func() {
+                wg.Wait()
+                close(done)
+        }()
+
+        select {
+        case <-done:
+                return true
+        case <-ctx.Done():
+                return false
+        }
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+        "fmt"
+        "net/http"
+        "strings"
+        "sync"
+        "time"
+)
+
+// Maintenance is the in-memory maintenance switch shared by the middleware and the admin endpoint
+// It starts from MAINTENANCE_MODE and resets to it on restart
+type Maintenance struct {
+        mu      sync.RWMutex
+        config  *Config
+        enabled bool
+        since   time.Time // When maintenance was last switched on
+}
+
+// NewMaintenance creates the switch in its configured initial state
+func NewMaintenance(config *Config) *Maintenance {
+        m := &Maintenance{config: config}
+        m.Set(config.MaintenanceMode)
+        return m
+}
+
+// Status reports whether maintenance is on and since when
+func (m *Maintenance) Status() (bool, time.Time) {
+        m.mu.RLock()
+        defer m.mu.RUnlock()
+        return m.enabled, m.since
+}
+
+// Set switches maintenance on or off; returns the previous state
+func (m *Maintenance) Set(enabled bool) bool {
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        previous := m.enabled
+        if enabled && !previous {
+                m.since = m.config.now()
+        }
+        if !enabled {
+                m.since = time.Time{}
+        }
+        m.enabled = enabled
+        return previous
+}
+
+// maintenanceExemptPrefixes stay reachable during maintenance: health checks, the build version and every
+// admin-authenticated route (keep in sync with the adminSync routes in SetupRoutes)
+// A trailing slash also matches the bare path and everything below it; others match exactly
+var maintenanceExemptPrefixes = []string{
+        "/api/health/", // /api/health, /api/health/live, /api/health/detailed
+        "/api/version",
+        "/api/admin/",
+        "/api/odds/sync",
+        "/api/scores/sync",
+        "/api/calc",
+}
+
+// maintenanceExempt reports whether the request bypasses maintenance mode
+func maintenanceExempt(r *http.Request) bool {
+        // CORS preflights carry no credentials; blocking them would hide the 503 from browsers
+        if r.Method == http.MethodOptions {
+                return true
+        }
+        for _, prefix := range maintenanceExemptPrefixes {
+                if r.URL.Path == strings.TrimSuffix(prefix, "/") || (strings.HasSuffix(prefix, "/") && strings.HasPrefix(r.URL.Path, prefix)) {
+                        return true
+                }
+        }
+        return false
+}
+
+// Maintenance middleware - answers 503 with Retry-After while maintenance is on
+func maintenanceMiddleware(maintenance *Maintenance, config *Config) func(http.Handler) http.Handler {
+        return func(next http.Handler) http.Handler {
+                return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        if enabled, _ := maintenance.Status(); !enabled || maintenanceExempt(r) {
+                                next.ServeHTTP(w, r)
+                                return
+                        }
+
+                        w.Header().Set("Retry-After", fmt.Sprintf("%d", int(config.MaintenanceRetryAfter.Seconds())))
+                        writeMiddlewareError(w, r, config, http.StatusServiceUnavailable, CodeMaintenance, "maintenance")
+                })
+        }
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+        "net/http"
+        "net/http/httptest"
+        "testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        enabled, disabled := true, false
+
+        decodeResponse(t, s.do("POST", "/api/admin/maintenance", adminAuth(), MaintenanceRequest{Enabled: &enabled}), http.StatusOK, nil)
+
+        blocked := []struct {
+                method        string
+                path          string
+                authorization string
+        }{
+                {"GET", "/api/matches", ""},
+                {"POST", "/api/auth/login", ""},
+                {"GET", "/api/bets", bearer(registered.AccessToken)},
+        }
+        for _, tt := range blocked {
+                t.Run("blocks "+tt.method+" "+tt.path, func(t *testing.T) {
+                        w := s.do(tt.method, tt.path, tt.authorization, nil)
+                        var response APIResponse
+                        decodeResponse(t, w, http.StatusServiceUnavailable, &response)
+                        if response.Success || response.Code != CodeMaintenance || response.Error != "maintenance" {
+                                t.Errorf("body = %+v, want the maintenance error", response)
+                        }
+                        if got := w.Header().Get("Retry-After"); got != "300" {
+                                t.Errorf("Retry-After = %q, want 300", got)
+                        }
+                })
+        }
+
+        bypassed := []struct {
+                method        string
+                path          string
+                authorization string
+        }{
+                {"GET", "/api/health", ""},
+                {"GET", "/api/health/live", ""},
+                {"GET", "/api/version", ""},
+                {"GET", "/api/admin/maintenance", adminAuth()},
+                {"POST", "/api/calc", adminAuth()},
+                {"OPTIONS", "/api/matches", ""},
+        }
+        for _, tt := range bypassed {
+                t.Run("bypasses "+tt.method+" "+tt.path, func(t *testing.T) {
+                        if w := s.do(tt.method, tt.path, tt.authorization, nil); w.Code == http.StatusServiceUnavailable {
+                                t.Errorf("status = 503, want the route served; body: %s", w.Body.String())
+                        }
+                })
+        }
+
+        // Admin routes still need admin credentials
+        decodeResponse(t, s.do("GET", "/api/admin/maintenance", "", nil), http.StatusUnauthorized, nil)
+
+        decodeResponse(t, s.do("POST", "/api/admin/maintenance", adminAuth(), MaintenanceRequest{Enabled: &disabled}), http.StatusOK, nil)
+        decodeResponse(t, s.do("GET", "/api/matches", "", nil), http.StatusOK, nil)
+}
+
+func TestMaintenanceExempt(t *testing.T) {
+        tests := []struct {
+                method string
+                path   string
+                want   bool
+        }{
+                {"GET", "/api/health", true},
+                {"GET", "/api/health/live", true},
+                {"GET", "/api/admin", true},
+                {"POST", "/api/admin/users/1/disable", true},
+                {"POST", "/api/calc", true},
+                {"OPTIONS", "/api/bets", true},
+                {"GET", "/api/healthz", false},
+                {"GET", "/api/administrator", false},
+                {"POST", "/api/calc/extra", false},
+                {"GET", "/api/version2", false},
+                {"GET", "/api/matches", false},
+        }
+        for _, tt := range tests {
+                r := httptest.NewRequest(tt.method, tt.path, nil)
+                if got := maintenanceExempt(r); got != tt.want {
+                        t.Errorf("maintenanceExempt(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+                }
+        }
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+        "fmt"
+        "maps"
+        "math"
+        "slices"
+        "strconv"
+        "strings"
+)
+
+// Odds API market keys
+const (
+        MarketH2H          = "h2h"           // Home / draw / away
+        MarketBTTS         = "btts"          // Both teams to score: Yes / No
+        MarketCorrectScore = "correct_score" // Exact final score, outcomes named "2-1" (home-away)
+        MarketTotals       = "totals"        // Total goals over / under a line, outcomes "Over" and "Under" with a point
+)
+
+// Bet types besides the 1X2 "home", "draw" and "away"
+const (
+        BetTypeBTTSYes     = "btts_yes"
+        BetTypeBTTSNo      = "btts_no"
+        correctScorePrefix = "cs_" // cs_<home>-<away>, e.g. cs_2-1
+        totalsOverPrefix   = "over_"  // over_<line>, e.g. over_2.5
+        totalsUnderPrefix  = "under_" // under_<line>, e.g. under_2.0
+)
+
+// maxTotalsLine bounds the line of a totals bet; lines come in half-goal steps
+const maxTotalsLine = 20
+
+// twoWaySportGroups are Odds API sport groups whose h2h market has no draw: ties go to
+// overtime, extra innings or a tiebreak, so only home and away are priced
+var twoWaySportGroups = []string{"americanfootball", "baseball", "basketball", "boxing", "icehockey", "mma", "tennis"}
+
+// sportHasDraw reports whether a sport key ("soccer_epl", "basketball_nba") offers a draw outcome
+func sportHasDraw(sportKey string) bool {
+        group, _, _ := strings.Cut(sportKey, "_")
+        return !slices.Contains(twoWaySportGroups, group)
+}
+
+// validSportKey reports whether key looks like an Odds API sport key; it is used as a URL path segment
+func validSportKey(key string) bool {
+        if key == "" {
+                return false
+        }
+        for _, r := range key {
+                if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+                        return false
+                }
+        }
+        return true
+}
+
+// maxCorrectScoreGoals bounds the goals per side accepted in a correct-score key
+const maxCorrectScoreGoals = 20
+
+// House margin bounds: HOUSE_MARGIN_PERCENT must stay below maxHouseMarginPercent and
+// no adjusted price drops under minMarginOdds (a win still pays more than the stake)
+const (
+        maxHouseMarginPercent = 50
+        minMarginOdds         = 1.01
+)
+
+// oddsTolerance absorbs float noise when comparing client odds to the stored cents
+const oddsTolerance = 0.005
+
+// scoreKey formats a score as the correct-score market key ("2-1")
+func scoreKey(homeScore, awayScore int) string {
+        return fmt.Sprintf("%d-%d", homeScore, awayScore)
+}
+
+// parseScoreKey parses "2-1" (or "2:1") into home and away goals
+func parseScoreKey(key string) (int, int, bool) {
+        home, away, ok := strings.Cut(strings.TrimSpace(key), "-")
+        if !ok {
+                home, away, ok = strings.Cut(strings.TrimSpace(key), ":")
+        }
+        if !ok {
+                return 0, 0, false
+        }
+        homeScore, err := strconv.Atoi(strings.TrimSpace(home))
+        if err != nil || homeScore < 0 || homeScore > maxCorrectScoreGoals {
+                return 0, 0, false
+        }
+        awayScore, err := strconv.Atoi(strings.TrimSpace(away))
+        if err != nil || awayScore < 0 || awayScore > maxCorrectScoreGoals {
+                return 0, 0, false
+        }
+        return homeScore, awayScore, true
+}
+
+// totalsBetType formats a totals bet type with its line to one decimal ("over_2.5", "under_2.0")
+func totalsBetType(prefix string, line float64) string {
+        return prefix + strconv.FormatFloat(line, 'f', 1, 64)
+}
+
+// validTotalsLine reports whether line is 0 to maxTotalsLine in half-goal steps
+// Whole lines can push; quarter lines (split stakes) are not offered
+func validTotalsLine(line float64) bool {
+        return line >= 0 && line <= maxTotalsLine && line*2 == math.Trunc(line*2)
+}
+
+// parseTotalsLine parses the line of a totals bet type ("2.5")
+func parseTotalsLine(raw string) (float64, bool) {
+        line, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+        if err != nil || !validTotalsLine(line) {
+                return 0, false
+        }
+        return line, true
+}
+
+// betMarket is one entry of the bet market registry: the bet types the market offers, where
+// their prices live on a match and how a final score settles them. Adding a market means
+// one betMarkets entry with these three funcs (plus syncing its prices)
+type betMarket struct {
+        normalize func(betType string) (string, bool)                                  // Canonical form of a bet type of this market
+        odds      func(match *Match, betType string) (*float64, bool)                  // Stored price of a canonical bet type; false if never offered
+        settle    func(outcome string, homeScore, awayScore int) (won, pushed []string) // Bet types that win and that push (stake refunded); all others lose
+}
+
+// betMarkets is the registry of bettable markets, keyed by Odds API market key
+// Betting on a market also needs it in ODDS_API_MARKETS
+var betMarkets = map[string]betMarket{
+        MarketH2H: {
+                normalize: func(betType string) (string, bool) {
+                        return betType, betType == "home" || betType == "draw" || betType == "away"
+                },
+                odds: func(match *Match, betType string) (*float64, bool) {
+                        switch betType {
+                        case "home":
+                                return match.HomeOdds, true
+                        case "draw":
+                                return match.DrawOdds, true
+                        }
+                        return match.AwayOdds, true
+                },
+                settle: func(outcome string, _, _ int) ([]string, []string) {
+                        return []string{outcome}, nil
+                },
+        },
+        MarketBTTS: {
+                normalize: func(betType string) (string, bool) {
+                        return betType, betType == BetTypeBTTSYes || betType == BetTypeBTTSNo
+                },
+                odds: func(match *Match, betType string) (*float64, bool) {
+                        if betType == BetTypeBTTSYes {
+                                return match.BTTSYesOdds, true
+                        }
+                        return match.BTTSNoOdds, true
+                },
+                settle: func(_ string, homeScore, awayScore int) ([]string, []string) {
+                        if homeScore > 0 && awayScore > 0 {
+                                return []string{BetTypeBTTSYes}, nil
+                        }
+                        return []string{BetTypeBTTSNo}, nil
+                },
+        },
+        MarketCorrectScore: {
+                normalize: func(betType string) (string, bool) {
+                        key, ok := strings.CutPrefix(betType, correctScorePrefix)
+                        if !ok {
+                                return "", false
+                        }
+                        homeScore, awayScore, ok := parseScoreKey(key)
+                        if !ok {
+                                return "", false
+                        }
+                        return correctScorePrefix + scoreKey(homeScore, awayScore), true
+                },
+                odds: func(match *Match, betType string) (*float64, bool) {
+                        price, offered := match.CorrectScoreOdds[strings.TrimPrefix(betType, correctScorePrefix)]
+                        return &price, offered
+                },
+                settle: func(_ string, homeScore, awayScore int) ([]string, []string) {
+                        return []string{correctScorePrefix + scoreKey(homeScore, awayScore)}, nil
+                },
+        },
+        MarketTotals: {
+                normalize: func(betType string) (string, bool) {
+                        for _, prefix := range []string{totalsOverPrefix, totalsUnderPrefix} {
+                                if raw, ok := strings.CutPrefix(betType, prefix); ok {
+                                        line, ok := parseTotalsLine(raw)
+                                        return totalsBetType(prefix, line), ok
+                                }
+                        }
+                        return "", false
+                },
+                odds: func(match *Match, betType string) (*float64, bool) {
+                        price, offered := match.TotalsOdds[betType]
+                        return &price, offered
+                },
+                // Every possible line, so a line no longer priced still settles
+                settle: func(_ string, homeScore, awayScore int) ([]string, []string) {
+                        total := float64(homeScore + awayScore)
+                        var won, pushed []string
+                        for line := 0.0; line <= maxTotalsLine; line += 0.5 {
+                                over, under := totalsBetType(totalsOverPrefix, line), totalsBetType(totalsUnderPrefix, line)
+                                switch {
+                                case total > line:
+                                        won = append(won, over)
+                                case total < line:
+                                        won = append(won, under)
+                                default:
+                                        pushed = append(pushed, over, under)
+                                }
+                        }
+                        return won, pushed
+                },
+        },
+}
+
+// betMarketFor finds the market a bet type belongs to and the bet type's canonical form
+// ("cs_02-1" -> correct_score, "cs_2-1")
+func betMarketFor(betType string) (string, string, bool) {
+        for key, market := range betMarkets {
+                if canonical, ok := market.normalize(betType); ok {
+                        return key, canonical, true
+                }
+        }
+        return "", "", false
+}
+
+// betTypeAllowed reports whether a canonical bet type can be placed for the sport;
+// two-way sports have no draw to bet on
+func betTypeAllowed(betType string, hasDraw bool) bool {
+        return hasDraw || betType != "draw"
+}
+
+// settledBetTypes lists the bet types of every market that win and that push for a final
+// score; all other pending bets lose
+func settledBetTypes(outcome string, homeScore, awayScore int) ([]string, []string) {
+        var won, pushed []string
+        for _, key := range slices.Sorted(maps.Keys(betMarkets)) {
+                marketWon, marketPushed := betMarkets[key].settle(outcome, homeScore, awayScore)
+                won = append(won, marketWon...)
+                pushed = append(pushed, marketPushed...)
+        }
+        return won, pushed
+}
+
+// matchOdds returns the stored odds for a (canonical) bet type; false when not priced
+func matchOdds(match *Match, betType string) (float64, bool) {
+        key, canonical, ok := betMarketFor(betType)
+        if !ok {
+                return 0, false
+        }
+        odds, offered := betMarkets[key].odds(match, canonical)
+        if !offered || odds == nil {
+                return 0, false
+        }
+        return *odds, true
+}
+
+// hasOdds reports whether a synced match carries any prices (odds sync rather than scores)
+func hasOdds(match *Match) bool {
+        return match.HomeOdds != nil || match.DrawOdds != nil || match.AwayOdds != nil ||
+                match.BTTSYesOdds != nil || match.BTTSNoOdds != nil || len(match.CorrectScoreOdds) > 0 || len(match.TotalsOdds) > 0
+}
+
+// oddsInRange reports whether a decimal price lies within MIN_ODDS..MAX_ODDS
+func oddsInRange(price float64, config *Config) bool {
+        return price >= config.MinOdds && price <= config.MaxOdds
+}
+
+// dropOutOfRangeOdds removes every price outside MIN_ODDS..MAX_ODDS from a synced match and
+// lists what was dropped ("home 1.00"); a feed glitch then leaves a market unpriced instead
+// of paying out on it
+func dropOutOfRangeOdds(match *Match, config *Config) []string {
+        var dropped []string
+        for _, price := range []struct {
+                name string
+                odds **float64
+        }{
+                {"home", &match.HomeOdds}, {"draw", &match.DrawOdds}, {"away", &match.AwayOdds},
+                {BetTypeBTTSYes, &match.BTTSYesOdds}, {BetTypeBTTSNo, &match.BTTSNoOdds},
+        } {
+                if *price.odds != nil && !oddsInRange(**price.odds, config) {
+                        dropped = append(dropped, fmt.Sprintf("%s %.2f", price.name, **price.odds))
+                        *price.odds = nil
+                }
+        }
+        for _, key := range slices.Sorted(maps.Keys(match.CorrectScoreOdds)) {
+                if price := match.CorrectScoreOdds[key]; !oddsInRange(price, config) {
+                        dropped = append(dropped, fmt.Sprintf("%s%s %.2f", correctScorePrefix, key, price))
+                        delete(match.CorrectScoreOdds, key)
+                }
+        }
+        for _, key := range slices.Sorted(maps.Keys(match.TotalsOdds)) {
+                if price := match.TotalsOdds[key]; !oddsInRange(price, config) {
+                        dropped = append(dropped, fmt.Sprintf("%s %.2f", key, price))
+                        delete(match.TotalsOdds, key)
+                }
+        }
+        return dropped
+}
+
+// marginOdds shaves percent off a decimal price, rounded to cents and kept at or
+// above minMarginOdds: 2.00 at 5% -> 1.90
+func marginOdds(price, percent float64) float64 {
+        if percent <= 0 || price <= minMarginOdds {
+                return price
+        }
+        return math.Max(math.Round(price*(1-percent/100)*100)/100, minMarginOdds)
+}
+
+// applyHouseMargin adjusts every price of a freshly synced match by the house margin
+// Done once at sync time, so listings, bet validation and payouts all use the same odds
+func applyHouseMargin(match *Match, percent float64) {
+        if percent <= 0 {
+                return
+        }
+        for _, odds := range []*float64{match.HomeOdds, match.DrawOdds, match.AwayOdds, match.BTTSYesOdds, match.BTTSNoOdds} {
+                if odds != nil {
+                        *odds = marginOdds(*odds, percent)
+                }
+        }
+        for key, price := range match.CorrectScoreOdds {
+                match.CorrectScoreOdds[key] = marginOdds(price, percent)
+        }
+        for key, price := range match.TotalsOdds {
+                match.TotalsOdds[key] = marginOdds(price, percent)
+        }
+}
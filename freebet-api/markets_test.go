@@ -0,0 +1,112 @@
+package main
+
+import (
+        "slices"
+        "testing"
+)
+
+func TestSportHasDraw(t *testing.T) {
+        for sport, want := range map[string]bool{
+                "soccer_epl":                true,
+                "soccer_uefa_champs_league": true,
+                "rugbyleague_nrl":           true,
+                "basketball_nba":            false,
+                "icehockey_nhl":             false,
+                "americanfootball_nfl":      false,
+                "tennis_atp_wimbledon":      false,
+                "mma_mixed_martial_arts":    false,
+        } {
+                if got := sportHasDraw(sport); got != want {
+                        t.Errorf("sportHasDraw(%q) = %v, want %v", sport, got, want)
+                }
+        }
+}
+
+func TestDropOutOfRangeOdds(t *testing.T) {
+        config := &Config{MinOdds: 1.01, MaxOdds: 1000}
+        price := func(odds float64) *float64 { return &odds }
+
+        match := &Match{
+                HomeOdds:         price(1.01),
+                DrawOdds:         price(1.00),
+                AwayOdds:         price(1000),
+                BTTSYesOdds:      price(1000.01),
+                BTTSNoOdds:       price(1.5),
+                CorrectScoreOdds: map[string]float64{"1-0": 7, "9-9": 5000},
+                TotalsOdds:       map[string]float64{"over_2.5": 0.5, "under_2.5": 1.8},
+        }
+        dropped := dropOutOfRangeOdds(match, config)
+
+        want := []string{"draw 1.00", "btts_yes 1000.01", "cs_9-9 5000.00", "over_2.5 0.50"}
+        if !slices.Equal(dropped, want) {
+                t.Errorf("dropped = %v, want %v", dropped, want)
+        }
+        if priceOf(match.HomeOdds) != 1.01 || priceOf(match.AwayOdds) != 1000 || priceOf(match.BTTSNoOdds) != 1.5 {
+                t.Errorf("boundary prices dropped: home %v, away %v, btts_no %v", priceOf(match.HomeOdds), priceOf(match.AwayOdds), priceOf(match.BTTSNoOdds))
+        }
+        if match.DrawOdds != nil || match.BTTSYesOdds != nil {
+                t.Error("out-of-range prices kept")
+        }
+        if len(match.CorrectScoreOdds) != 1 || len(match.TotalsOdds) != 1 {
+                t.Errorf("correct score %v, totals %v; want one price each", match.CorrectScoreOdds, match.TotalsOdds)
+        }
+}
+
+func TestBetMarketFor(t *testing.T) {
+        tests := []struct {
+                betType   string
+                market    string
+                canonical string
+        }{
+                {"home", MarketH2H, "home"},
+                {"draw", MarketH2H, "draw"},
+                {"away", MarketH2H, "away"},
+                {"btts_yes", MarketBTTS, "btts_yes"},
+                {"btts_no", MarketBTTS, "btts_no"},
+                {"cs_2-1", MarketCorrectScore, "cs_2-1"},
+                {"cs_02-1", MarketCorrectScore, "cs_2-1"},
+                {"cs_2:1", MarketCorrectScore, "cs_2-1"},
+                {"over_2.5", MarketTotals, "over_2.5"},
+                {"under_2", MarketTotals, "under_2.0"},
+
+                // Unknown bet types
+                {"", "", ""},
+                {"HOME", "", ""},
+                {"win", "", ""},
+                {"btts", "", ""},
+                {"cs_", "", ""},
+                {"cs_2", "", ""},
+                {"cs_21-0", "", ""},
+                {"cs_-1-0", "", ""},
+                {"over_", "", ""},
+                {"over_2.25", "", ""},
+                {"under_-0.5", "", ""},
+                {"over_20.5", "", ""},
+        }
+        for _, tt := range tests {
+                market, canonical, ok := betMarketFor(tt.betType)
+                if market != tt.market || canonical != tt.canonical || ok != (tt.market != "") {
+                        t.Errorf("betMarketFor(%q) = %q, %q, %v; want %q, %q", tt.betType, market, canonical, ok, tt.market, tt.canonical)
+                }
+        }
+}
+
+func TestBetMarketsSettleCanonicalBetTypes(t *testing.T) {
+        // Settlement must name bets the way placement stores them, or winners would lose
+        for key, market := range betMarkets {
+                for _, score := range [][2]int{{0, 0}, {2, 1}, {1, 3}, {4, 4}} {
+                        won, pushed := market.settle(matchOutcomeFor(score[0], score[1]), score[0], score[1])
+                        for _, betType := range append(won, pushed...) {
+                                if got, canonical, ok := betMarketFor(betType); !ok || got != key || canonical != betType {
+                                        t.Errorf("%s settles %q, which places as %q, %q, %v", key, betType, got, canonical, ok)
+                                }
+                        }
+                }
+        }
+}
+
+// matchOutcomeFor is the 1X2 outcome of a final score
+func matchOutcomeFor(homeScore, awayScore int) string {
+        outcome, _ := matchOutcome(Match{HomeScore: &homeScore, AwayScore: &awayScore}, false)
+        return outcome
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+        "crypto/sha256"
+        "encoding/hex"
+        "net/http"
+        "strings"
+        "sync"
+        "time"
+)
+
+// MatchesCache holds the rendered /api/matches payload and its ETag
+// Entries expire after MatchesCacheTTL and are dropped whenever a sync touches matches
+type MatchesCache struct {
+        mu      sync.Mutex
+        config  *Config
+        body    []byte
+        etag    string
+        expires time.Time
+
+        generation uint64 // Bumped on every invalidation so a load that raced one is not stored
+}
+
+// NewMatchesCache creates an empty matches cache
+func NewMatchesCache(config *Config) *MatchesCache {
+        return &MatchesCache{config: config}
+}
+
+// Get returns the cached payload and ETag if the entry is still fresh
+// On a miss the returned generation is passed to Set along with the reloaded payload
+func (c *MatchesCache) Get() ([]byte, string, uint64, bool) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        if c.body == nil || !c.config.now().Before(c.expires) {
+                return nil, "", c.generation, false
+        }
+        return c.body, c.etag, c.generation, true
+}
+
+// Set stores a freshly rendered payload and returns its ETag
+// Nothing is stored with MatchesCacheTTL <= 0 or if the cache was invalidated since Get returned generation,
+// but the ETag is still computed
+func (c *MatchesCache) Set(body []byte, generation uint64) string {
+        etag := weakETag(body)
+        if c.config.MatchesCacheTTL <= 0 {
+                return etag
+        }
+
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        if c.generation != generation {
+                return etag
+        }
+        c.body = body
+        c.etag = etag
+        c.expires = c.config.now().Add(c.config.MatchesCacheTTL)
+        return etag
+}
+
+// Invalidate drops the cached payload so the next request reloads from the database
+func (c *MatchesCache) Invalidate() {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        c.body = nil
+        c.etag = ""
+        c.generation++
+}
+
+// weakETag builds a weak validator from a hash of the payload
+func weakETag(body []byte) string {
+        sum := sha256.Sum256(body)
+        return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag (weak comparison)
+func etagMatches(r *http.Request, etag string) bool {
+        header := r.Header.Get("If-None-Match")
+        if header == "" {
+                return false
+        }
+
+        for _, candidate := range strings.Split(header, ",") {
+                candidate = strings.TrimSpace(candidate)
+                if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+                        return true
+                }
+        }
+        return false
+}
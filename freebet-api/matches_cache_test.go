@@ -0,0 +1,31 @@
+package main
+
+import (
+        "testing"
+        "time"
+)
+
+func TestMatchesCacheDropsLoadsThatRacedAnInvalidation(t *testing.T) {
+        config := newTestConfig(t, NewFakeClock(time.Now().UTC()))
+        config.MatchesCacheTTL = 30 * time.Second
+        cache := NewMatchesCache(config)
+
+        // A sync invalidates after the load read the old matches but before it is stored
+        _, _, generation, cached := cache.Get()
+        if cached {
+                t.Fatal("empty cache reported a hit")
+        }
+        cache.Invalidate()
+        cache.Set([]byte(`{"matches":["stale"]}`), generation)
+        if _, _, _, cached := cache.Get(); cached {
+                t.Fatal("the raced load was cached")
+        }
+
+        // The next load started after the invalidation is stored
+        _, _, generation, _ = cache.Get()
+        etag := cache.Set([]byte(`{"matches":["fresh"]}`), generation)
+        body, cachedETag, _, cached := cache.Get()
+        if !cached || string(body) != `{"matches":["fresh"]}` || cachedETag != etag {
+                t.Fatalf("Get() = %s, %q, %v; want the fresh payload and %q", body, cachedETag, cached, etag)
+        }
+}
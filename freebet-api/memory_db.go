@@ -0,0 +1,1661 @@
+package main
+
+import (
+        "context"
+        "database/sql"
+        "encoding/json"
+        "fmt"
+        "slices"
+        "sort"
+        "strconv"
+        "strings"
+        "sync"
+        "time"
+)
+
+// MemoryDB implements the Database interface with in-memory maps
+// Intended for handler tests with httptest; mirrors PostgresDB behavior
+// (unique email/nickname, conditional debits, settlement) without a server
+type MemoryDB struct {
+        mu            sync.Mutex
+        clock         Clock
+        users         map[string]*User
+        refreshTokens map[string]*RefreshToken
+        bets          []*Bet
+        matches       map[string]*Match // keyed by api_id
+        admins        map[string]*Admin
+        adminSessions map[string]*AdminSession
+        userLimits    map[string]*UserLimits
+        nicknameAt    map[string]time.Time // user ID -> last nickname change
+        guestActiveAt map[string]time.Time // guest user ID -> last activity
+        adjustments   []BalanceAdjustment
+        ledger        []LedgerEntry        // ID = index + 1
+        notifications []*Notification      // ID = index + 1
+        seasons       []*Season            // ID = index + 1
+        seasonResults map[int][]SeasonStanding
+        calcLock      sync.Mutex // TryLockCalc, held across a whole calculation run
+}
+
+var _ Database = (*MemoryDB)(nil)
+
+// NewMemoryDB creates an empty in-memory database
+// A nil clock falls back to the system time
+func NewMemoryDB(clock Clock) *MemoryDB {
+        if clock == nil {
+                clock = RealClock{}
+        }
+        return &MemoryDB{
+                clock:         clock,
+                users:         make(map[string]*User),
+                refreshTokens: make(map[string]*RefreshToken),
+                matches:       make(map[string]*Match),
+                admins:        make(map[string]*Admin),
+                adminSessions: make(map[string]*AdminSession),
+                userLimits:    make(map[string]*UserLimits),
+                nicknameAt:    make(map[string]time.Time),
+                guestActiveAt: make(map[string]time.Time),
+                seasonResults: make(map[int][]SeasonStanding),
+        }
+}
+
+// Ping always succeeds
+func (db *MemoryDB) Ping(ctx context.Context) error {
+        return nil
+}
+
+// Close is a no-op
+func (db *MemoryDB) Close() error {
+        return nil
+}
+
+// CreateAdmin adds an active admin
+func (db *MemoryDB) CreateAdmin(ctx context.Context, username, email, passwordHash string) (*Admin, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        for _, existing := range db.admins {
+                if existing.Username == username {
+                        return nil, ErrAdminExists
+                }
+        }
+
+        admin := &Admin{
+                ID:           generateTokenID(),
+                Username:     username,
+                Email:        email,
+                PasswordHash: passwordHash,
+                IsActive:     true,
+                CreatedAt:    db.clock.Now(),
+        }
+        db.admins[admin.ID] = admin
+        copied := *admin
+        return &copied, nil
+}
+
+// User methods
+func (db *MemoryDB) findUser(match func(u *User) bool) (*User, error) {
+        for _, u := range db.users {
+                if match(u) {
+                        copied := *u
+                        return &copied, nil
+                }
+        }
+        return nil, ErrUserNotFound
+}
+
+func (db *MemoryDB) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        // Guests have no email (NULL in PostgresDB)
+        return db.findUser(func(u *User) bool { return u.Email != "" && u.Email == email })
+}
+
+func (db *MemoryDB) GetUserByNickname(ctx context.Context, nickname string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        return db.findUser(func(u *User) bool { return u.Nickname == nickname })
+}
+
+func (db *MemoryDB) GetUserByEmailOrNickname(ctx context.Context, identifier string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        // Email match wins over nickname match, as in PostgresDB
+        if user, err := db.findUser(func(u *User) bool { return u.Email != "" && u.Email == identifier }); err == nil {
+                return user, nil
+        }
+        return db.findUser(func(u *User) bool { return u.Nickname == identifier })
+}
+
+func (db *MemoryDB) GetUserByGoogleID(ctx context.Context, googleID string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        return db.findUser(func(u *User) bool { return u.GoogleID.Valid && u.GoogleID.String == googleID })
+}
+
+func (db *MemoryDB) GetUserByID(ctx context.Context, id string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[id]
+        if !ok {
+                return nil, ErrUserNotFound
+        }
+        copied := *user
+        return &copied, nil
+}
+
+func (db *MemoryDB) insertUser(user *User) (*User, error) {
+        for _, u := range db.users {
+                if user.Email != "" && u.Email == user.Email {
+                        return nil, fmt.Errorf("duplicate key value violates unique constraint \"users_email_key\"")
+                }
+                if u.Nickname == user.Nickname {
+                        return nil, ErrNicknameTaken
+                }
+                if user.GoogleID.Valid && u.GoogleID.Valid && u.GoogleID.String == user.GoogleID.String {
+                        return nil, fmt.Errorf("duplicate key value violates unique constraint \"users_google_id_key\"")
+                }
+        }
+
+        now := db.clock.Now()
+        user.ID = generateTokenID()
+        user.Topup = 1
+        user.LastTopupAt = &now
+        user.CreatedAt = now
+        user.UpdatedAt = now
+        db.users[user.ID] = user
+        if user.Money != 0 {
+                db.recordLedger(user.ID, LedgerInitialGrant, user.Money, "")
+        }
+
+        copied := *user
+        return &copied, nil
+}
+
+func (db *MemoryDB) CreateUser(ctx context.Context, email, passwordHash, nickname string, initialBalance float64) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        return db.insertUser(&User{
+                Email:        email,
+                Nickname:     nickname,
+                PasswordHash: sql.NullString{String: passwordHash, Valid: true},
+                AuthProvider: "email",
+                Money:        initialBalance,
+        })
+}
+
+func (db *MemoryDB) CreateUserWithGoogle(ctx context.Context, googleID, email, nickname, pictureURL string, initialBalance float64) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        return db.insertUser(&User{
+                Email:        email,
+                Nickname:     nickname,
+                GoogleID:     sql.NullString{String: googleID, Valid: true},
+                PictureURL:   sql.NullString{String: pictureURL, Valid: pictureURL != ""},
+                AuthProvider: "google",
+                Money:        initialBalance,
+        })
+}
+
+func (db *MemoryDB) TopupUser(ctx context.Context, userID string, amount float64) (float64, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return 0, ErrUserNotFound
+        }
+        now := db.clock.Now()
+        user.Money = addMoney(user.Money, amount)
+        user.Topup++
+        user.LastTopupAt = &now
+        user.UpdatedAt = now
+        db.recordLedger(userID, LedgerTopup, amount, "")
+        return user.Money, nil
+}
+
+// recordLedger appends a balance change for a user whose money was already updated
+// Callers hold db.mu
+func (db *MemoryDB) recordLedger(userID, entryType string, amount float64, reference string) {
+        entry := LedgerEntry{
+                ID:        int64(len(db.ledger) + 1),
+                UserID:    userID,
+                Type:      entryType,
+                Amount:    amount,
+                Reference: reference,
+                CreatedAt: db.clock.Now(),
+        }
+        if user, ok := db.users[userID]; ok {
+                entry.BalanceAfter = user.Money
+        }
+        db.ledger = append(db.ledger, entry)
+}
+
+func (db *MemoryDB) GetLedger(ctx context.Context, userID string, limit, offset int) ([]LedgerEntry, int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        // ORDER BY id DESC
+        var entries []LedgerEntry
+        for i := len(db.ledger) - 1; i >= 0; i-- {
+                if db.ledger[i].UserID == userID {
+                        entries = append(entries, db.ledger[i])
+                }
+        }
+        total := len(entries)
+        if offset >= total {
+                return nil, total, nil
+        }
+        return entries[offset:min(offset+limit, total)], total, nil
+}
+
+// recordNotification adds an entry to a user's inbox
+// Callers hold db.mu
+func (db *MemoryDB) recordNotification(userID, notificationType string, payload interface{}) {
+        data, _ := json.Marshal(payload)
+        db.notifications = append(db.notifications, &Notification{
+                ID:        int64(len(db.notifications) + 1),
+                UserID:    userID,
+                Type:      notificationType,
+                Payload:   data,
+                CreatedAt: db.clock.Now(),
+        })
+}
+
+func (db *MemoryDB) GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]Notification, int, int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        // ORDER BY id DESC
+        var notifications []Notification
+        unread := 0
+        for i := len(db.notifications) - 1; i >= 0; i-- {
+                notification := db.notifications[i]
+                if notification.UserID != userID {
+                        continue
+                }
+                if notification.ReadAt == nil {
+                        unread++
+                } else if unreadOnly {
+                        continue
+                }
+                notifications = append(notifications, *notification)
+        }
+        total := len(notifications)
+        if offset >= total {
+                return nil, total, unread, nil
+        }
+        return notifications[offset:min(offset+limit, total)], total, unread, nil
+}
+
+func (db *MemoryDB) MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (*Notification, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        if notificationID < 1 || notificationID > int64(len(db.notifications)) {
+                return nil, ErrNotificationNotFound
+        }
+        notification := db.notifications[notificationID-1]
+        if notification.UserID != userID {
+                return nil, ErrNotificationNotFound
+        }
+        if notification.ReadAt == nil {
+                readAt := db.clock.Now()
+                notification.ReadAt = &readAt
+        }
+        copied := *notification
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetTopupTimes(ctx context.Context, userID string, limit int) ([]time.Time, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        var times []time.Time
+        for i := len(db.ledger) - 1; i >= 0 && len(times) < limit; i-- {
+                if db.ledger[i].UserID == userID && db.ledger[i].Type == LedgerTopup {
+                        times = append(times, db.ledger[i].CreatedAt)
+                }
+        }
+        return times, nil
+}
+
+func (db *MemoryDB) GetUserLastTopupTime(ctx context.Context, userID string) (*time.Time, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return nil, ErrUserNotFound
+        }
+        return user.LastTopupAt, nil
+}
+
+func (db *MemoryDB) GetUserNicknameChangedAt(ctx context.Context, userID string) (*time.Time, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if _, ok := db.users[userID]; !ok {
+                return nil, ErrUserNotFound
+        }
+        changedAt, ok := db.nicknameAt[userID]
+        if !ok {
+                return nil, nil
+        }
+        return &changedAt, nil
+}
+
+func (db *MemoryDB) UpdateUserNickname(ctx context.Context, userID, nickname string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return nil, ErrUserNotFound
+        }
+        for id, u := range db.users {
+                if id != userID && u.Nickname == nickname {
+                        return nil, ErrNicknameTaken
+                }
+        }
+        now := db.clock.Now()
+        user.Nickname = nickname
+        user.UpdatedAt = now
+        db.nicknameAt[userID] = now
+        copied := *user
+        return &copied, nil
+}
+
+func (db *MemoryDB) UpdateUserPicture(ctx context.Context, userID, pictureURL string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return ErrUserNotFound
+        }
+        user.PictureURL = sql.NullString{String: pictureURL, Valid: true}
+        user.UpdatedAt = db.clock.Now()
+        return nil
+}
+
+func (db *MemoryDB) UpdateUserPassword(ctx context.Context, userID string, newPasswordHash string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if user, ok := db.users[userID]; ok {
+                user.PasswordHash = sql.NullString{String: newPasswordHash, Valid: true}
+                user.UpdatedAt = db.clock.Now()
+        }
+        return nil
+}
+
+func (db *MemoryDB) CreateGuestUser(ctx context.Context, nickname string, initialBalance float64) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        for _, u := range db.users {
+                if u.Nickname == nickname {
+                        return nil, ErrNicknameTaken
+                }
+        }
+        user, err := db.insertUser(&User{
+                Nickname:     nickname,
+                AuthProvider: "guest",
+                Money:        initialBalance,
+                IsGuest:      true,
+        })
+        if err != nil {
+                return nil, err
+        }
+        db.guestActiveAt[user.ID] = user.CreatedAt
+        return user, nil
+}
+
+func (db *MemoryDB) UpgradeGuestUser(ctx context.Context, userID, email, passwordHash, nickname string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok || !user.IsGuest {
+                return nil, ErrNotGuest
+        }
+        for id, u := range db.users {
+                if id != userID && (u.Email == email || u.Nickname == nickname) {
+                        return nil, ErrAccountExists
+                }
+        }
+        user.Email = email
+        user.PasswordHash = sql.NullString{String: passwordHash, Valid: true}
+        user.Nickname = nickname
+        user.AuthProvider = "email"
+        user.IsGuest = false
+        user.UpdatedAt = db.clock.Now()
+        delete(db.guestActiveAt, userID)
+        copied := *user
+        return &copied, nil
+}
+
+func (db *MemoryDB) LinkGuestGoogle(ctx context.Context, userID, googleID, email, pictureURL string) (*User, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok || !user.IsGuest {
+                return nil, ErrNotGuest
+        }
+        for id, u := range db.users {
+                if id != userID && (u.Email == email || (u.GoogleID.Valid && u.GoogleID.String == googleID)) {
+                        return nil, ErrAccountExists
+                }
+        }
+        user.GoogleID = sql.NullString{String: googleID, Valid: true}
+        user.Email = email
+        if !user.PictureURL.Valid && pictureURL != "" {
+                user.PictureURL = sql.NullString{String: pictureURL, Valid: true}
+        }
+        user.AuthProvider = "google"
+        user.IsGuest = false
+        user.UpdatedAt = db.clock.Now()
+        delete(db.guestActiveAt, userID)
+        copied := *user
+        return &copied, nil
+}
+
+func (db *MemoryDB) TouchGuest(ctx context.Context, userID string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if user, ok := db.users[userID]; ok && user.IsGuest {
+                db.guestActiveAt[userID] = db.clock.Now()
+        }
+        return nil
+}
+
+func (db *MemoryDB) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        count := 0
+        for id, user := range db.users {
+                if !user.IsGuest {
+                        continue
+                }
+                lastActive := user.UpdatedAt
+                if activeAt := db.guestActiveAt[id]; activeAt.After(lastActive) {
+                        lastActive = activeAt
+                }
+                if !lastActive.Before(inactiveSince) || slices.ContainsFunc(db.bets, func(b *Bet) bool {
+                        return b.UserID == id && (b.Status == "pending" || b.Status == "review")
+                }) {
+                        continue
+                }
+                db.deleteUser(id)
+                count++
+        }
+        return count, nil
+}
+
+// deleteUser removes a user and everything ON DELETE CASCADE would remove; caller must hold db.mu
+func (db *MemoryDB) deleteUser(userID string) {
+        delete(db.users, userID)
+        delete(db.userLimits, userID)
+        delete(db.nicknameAt, userID)
+        delete(db.guestActiveAt, userID)
+        for token, rt := range db.refreshTokens {
+                if rt.UserID == userID {
+                        delete(db.refreshTokens, token)
+                }
+        }
+        db.bets = slices.DeleteFunc(db.bets, func(b *Bet) bool { return b.UserID == userID })
+        db.adjustments = slices.DeleteFunc(db.adjustments, func(a BalanceAdjustment) bool { return a.UserID == userID })
+        db.ledger = slices.DeleteFunc(db.ledger, func(e LedgerEntry) bool { return e.UserID == userID })
+        for _, notification := range db.notifications {
+                if notification.UserID == userID {
+                        notification.UserID = "" // Keep IDs = index + 1
+                }
+        }
+}
+
+// JWT Refresh Token methods
+func (db *MemoryDB) CreateRefreshToken(ctx context.Context, userID string, token string, expiresAt time.Time) (*RefreshToken, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if _, exists := db.refreshTokens[token]; exists {
+                return nil, fmt.Errorf("duplicate key value violates unique constraint \"refresh_tokens_token_key\"")
+        }
+        refreshToken := &RefreshToken{
+                ID:        generateTokenID(),
+                UserID:    userID,
+                Token:     token,
+                ExpiresAt: expiresAt,
+                CreatedAt: db.clock.Now(),
+        }
+        db.refreshTokens[token] = refreshToken
+        copied := *refreshToken
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetRefreshTokenByToken(ctx context.Context, token string) (*RefreshToken, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        refreshToken, ok := db.refreshTokens[token]
+        if !ok || !refreshToken.ExpiresAt.After(db.clock.Now()) {
+                return nil, ErrRefreshTokenNotFound
+        }
+        copied := *refreshToken
+        return &copied, nil
+}
+
+func (db *MemoryDB) DeleteRefreshToken(ctx context.Context, token string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        delete(db.refreshTokens, token)
+        return nil
+}
+
+func (db *MemoryDB) DeleteAllUserRefreshTokens(ctx context.Context, userID string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        for token, refreshToken := range db.refreshTokens {
+                if refreshToken.UserID == userID {
+                        delete(db.refreshTokens, token)
+                }
+        }
+        return nil
+}
+
+func (db *MemoryDB) IncrementUserTokenVersion(ctx context.Context, userID string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if user, ok := db.users[userID]; ok {
+                user.TokenVersion++
+                user.UpdatedAt = db.clock.Now()
+        }
+        return nil
+}
+
+func (db *MemoryDB) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return ErrUserNotFound
+        }
+        now := db.clock.Now()
+        if disabled {
+                if user.DeletedAt == nil {
+                        user.DeletedAt = &now
+                }
+                user.TokenVersion++
+        } else {
+                user.DeletedAt = nil
+        }
+        user.UpdatedAt = now
+        return nil
+}
+
+func (db *MemoryDB) AdjustUserBalance(ctx context.Context, userID, adminID string, amount float64, reason string) (float64, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        user, ok := db.users[userID]
+        if !ok {
+                return 0, ErrUserNotFound
+        }
+        if addMoney(user.Money, amount) < 0 {
+                return 0, ErrInsufficientFunds
+        }
+        user.Money = addMoney(user.Money, amount)
+        user.UpdatedAt = db.clock.Now()
+        db.adjustments = append(db.adjustments, BalanceAdjustment{
+                UserID:       userID,
+                AdminID:      adminID,
+                Amount:       amount,
+                Reason:       reason,
+                BalanceAfter: user.Money,
+                CreatedAt:    user.UpdatedAt,
+        })
+        db.recordLedger(userID, LedgerAdminAdjustment, amount, strconv.Itoa(len(db.adjustments)))
+        return user.Money, nil
+}
+
+// Bet methods
+func (db *MemoryDB) GetUserBets(ctx context.Context, userID string, playerNickname string, page BetPage) ([]Bet, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        if playerNickname != "" {
+                user, err := db.findUser(func(u *User) bool { return u.Nickname == playerNickname })
+                if err != nil {
+                        return nil, nil
+                }
+                userID = user.ID
+        }
+
+        var bets []Bet
+        for _, bet := range db.bets {
+                if bet.UserID != userID {
+                        continue
+                }
+                if page.After != nil && !betOlderThan(bet, page.After) {
+                        continue
+                }
+                copied := *bet
+                if match, ok := db.matches[bet.MatchID]; ok {
+                        commenceTime := match.CommenceTime
+                        copied.CommenceTime = &commenceTime
+                        if match.Completed && match.HomeScore != nil && match.AwayScore != nil && *match.HomeScore >= 0 && *match.AwayScore >= 0 {
+                                homeScore, awayScore := *match.HomeScore, *match.AwayScore
+                                copied.HomeScore, copied.AwayScore = &homeScore, &awayScore
+                        }
+                        if match.Result != nil {
+                                result := *match.Result
+                                copied.Result = &result
+                        }
+                }
+                bets = append(bets, copied)
+        }
+
+        // ORDER BY created_at DESC, bet_id DESC
+        sort.Slice(bets, func(i, j int) bool {
+                return betOlderThan(&bets[j], &BetCursor{CreatedAt: bets[i].CreatedAt, BetID: bets[i].BetID})
+        })
+
+        if page.Limit > 0 {
+                if page.After == nil {
+                        bets = bets[min(page.Offset, len(bets)):]
+                }
+                bets = bets[:min(page.Limit, len(bets))]
+        }
+        return bets, nil
+}
+
+// betBefore reports whether bet comes after cursor in history order, i.e. is older
+func betOlderThan(bet *Bet, cursor *BetCursor) bool {
+        if !bet.CreatedAt.Equal(cursor.CreatedAt) {
+                return bet.CreatedAt.Before(cursor.CreatedAt)
+        }
+        return bet.BetID < cursor.BetID
+}
+
+func (db *MemoryDB) PlaceBet(ctx context.Context, bet *Bet) (*Bet, float64, error) {
+        placed, balance, err := db.PlaceBets(ctx, []*Bet{bet})
+        if err != nil {
+                return nil, 0, err
+        }
+        return placed[0], balance, nil
+}
+
+func (db *MemoryDB) PlaceBets(ctx context.Context, bets []*Bet) ([]*Bet, float64, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        var total Cents
+        for _, bet := range bets {
+                total += toCents(bet.BetAmount)
+        }
+        user, ok := db.users[bets[0].UserID]
+        if !ok || toCents(user.Money) < total {
+                return nil, 0, ErrInsufficientFunds
+        }
+
+        now := db.clock.Now()
+        user.UpdatedAt = now
+        for _, bet := range bets {
+                user.Money = addMoney(user.Money, -bet.BetAmount)
+
+                stored := *bet
+                stored.BetID = generateTokenID()
+                stored.CreatedAt = now
+                db.bets = append(db.bets, &stored)
+                db.recordLedger(user.ID, LedgerBetStake, -bet.BetAmount, stored.BetID)
+
+                bet.BetID = stored.BetID
+                bet.CreatedAt = now
+        }
+        return bets, user.Money, nil
+}
+
+func (db *MemoryDB) GetMatchByID(ctx context.Context, matchID string) (*Match, error) {
+        return db.GetMatchByAPIID(ctx, matchID)
+}
+
+// isPriced mirrors the SQL upcoming filter: home and away priced, draw priced or absent (two-way sports)
+func isPriced(match *Match) bool {
+        return match.HomeOdds != nil && match.AwayOdds != nil && *match.HomeOdds != 0 && *match.AwayOdds != 0 &&
+                (match.DrawOdds == nil || *match.DrawOdds != 0)
+}
+
+// Match methods
+func (db *MemoryDB) GetMatches(ctx context.Context) ([]Match, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        now := db.clock.Now()
+        var matches []Match
+        for _, match := range db.matches {
+                if !isPriced(match) || !match.CommenceTime.After(now) {
+                        continue
+                }
+                matches = append(matches, *match)
+        }
+
+        sort.Slice(matches, func(i, j int) bool {
+                return matches[i].CommenceTime.Before(matches[j].CommenceTime)
+        })
+
+        return matches, nil
+}
+
+func (db *MemoryDB) ListMatches(ctx context.Context, filter MatchFilter) ([]Match, int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        now := db.clock.Now()
+        var matches []Match
+        for _, match := range db.matches {
+                var include bool
+                switch filter.Status {
+                case MatchStatusUpcoming:
+                        include = isPriced(match) && match.CommenceTime.After(now)
+                case MatchStatusLive:
+                        include = !match.CommenceTime.After(now) && !match.Completed
+                case MatchStatusFinished:
+                        include = match.Completed && match.HomeScore != nil && match.AwayScore != nil
+                default:
+                        return nil, 0, fmt.Errorf("unknown match status: %s", filter.Status)
+                }
+                if filter.From != nil && match.CommenceTime.Before(*filter.From) {
+                        include = false
+                }
+                if filter.To != nil && !match.CommenceTime.Before(*filter.To) {
+                        include = false
+                }
+                if team := strings.ToLower(filter.Team); team != "" &&
+                        !strings.Contains(strings.ToLower(match.HomeTeam), team) && !strings.Contains(strings.ToLower(match.AwayTeam), team) {
+                        include = false
+                }
+                if include {
+                        matches = append(matches, *match)
+                }
+        }
+
+        sort.Slice(matches, func(i, j int) bool {
+                a, b := matches[i], matches[j]
+                if !a.CommenceTime.Equal(b.CommenceTime) {
+                        if filter.SortDesc {
+                                return a.CommenceTime.After(b.CommenceTime)
+                        }
+                        return a.CommenceTime.Before(b.CommenceTime)
+                }
+                if filter.SortDesc {
+                        return a.ID > b.ID
+                }
+                return a.ID < b.ID
+        })
+
+        total := len(matches)
+        if filter.Offset >= total {
+                return []Match{}, total, nil
+        }
+        end := filter.Offset + filter.Limit
+        if end > total {
+                end = total
+        }
+
+        return matches[filter.Offset:end], total, nil
+}
+
+// Players methods
+func (db *MemoryDB) GetPlayers(ctx context.Context, limit, offset int, order string) ([]PlayerDisplay, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        var players []PlayerDisplay
+        for _, user := range db.users {
+                if user.IsGuest {
+                        continue
+                }
+                bets, wonBets, settledBets, pushBets, avgOdds := db.userStats(user.ID)
+                profit, roi := db.userProfit(user.ID)
+                players = append(players, PlayerDisplay{
+                        ID:          user.ID,
+                        Nickname:    user.Nickname,
+                        Money:       user.Money,
+                        Bets:        bets,
+                        WonBets:     wonBets,
+                        SettledBets: settledBets,
+                        PushBets:    pushBets,
+                        AvgOdds:     avgOdds,
+                        Profit:      profit,
+                        ROI:         roi,
+                        Topup:       user.Topup,
+                        Created:     user.CreatedAt.Format(time.RFC3339),
+                        Updated:     user.UpdatedAt.Format(time.RFC3339),
+                })
+        }
+
+        // Same orders as playerSortOrders, id breaking ties
+        sort.Slice(players, func(i, j int) bool {
+                a, b := players[i], players[j]
+                switch order {
+                case PlayerSortProfit:
+                        if a.Profit != b.Profit {
+                                return a.Profit > b.Profit
+                        }
+                        if a.Bets != b.Bets {
+                                return a.Bets > b.Bets
+                        }
+                case PlayerSortROI:
+                        if a.ROI != b.ROI {
+                                return a.ROI > b.ROI
+                        }
+                        if a.Profit != b.Profit {
+                                return a.Profit > b.Profit
+                        }
+                default:
+                        if a.Bets != b.Bets {
+                                return a.Bets > b.Bets
+                        }
+                        if a.Money != b.Money {
+                                return a.Money > b.Money
+                        }
+                }
+                return a.ID < b.ID
+        })
+
+        if offset >= len(players) {
+                return nil, nil
+        }
+        end := offset + limit
+        if end > len(players) {
+                end = len(players)
+        }
+        return players[offset:end], nil
+}
+
+func (db *MemoryDB) GetTotalPlayers(ctx context.Context) (int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        total := 0
+        for _, user := range db.users {
+                if !user.IsGuest {
+                        total++
+                }
+        }
+        return total, nil
+}
+
+// userStats computes betting statistics; caller must hold db.mu
+func (db *MemoryDB) userStats(userID string) (bets int, wonBets int, settledBets int, pushBets int, avgOdds float64) {
+        totalOdds := 0.0
+        for _, bet := range db.bets {
+                if bet.UserID != userID {
+                        continue
+                }
+                bets++
+                totalOdds += bet.Odds
+                if bet.Status == "won" {
+                        wonBets++
+                }
+                if bet.Status == "won" || bet.Status == "lost" {
+                        settledBets++
+                }
+                if bet.Status == "push" {
+                        pushBets++
+                }
+        }
+        if bets > 0 {
+                avgOdds = totalOdds / float64(bets)
+        }
+        return
+}
+
+// userProfit computes a user's net profit and ROI percent over settled (won/lost) bets
+func (db *MemoryDB) userProfit(userID string) (profit float64, roi float64) {
+        staked := 0.0
+        for _, bet := range db.bets {
+                if bet.UserID != userID {
+                        continue
+                }
+                switch bet.Status {
+                case "won":
+                        staked = addMoney(staked, bet.BetAmount)
+                        profit = addMoney(profit, bet.PotentialWin-bet.BetAmount)
+                case "lost":
+                        staked = addMoney(staked, bet.BetAmount)
+                        profit = addMoney(profit, -bet.BetAmount)
+                }
+        }
+        if staked > 0 {
+                roi = profit / staked * 100
+        }
+        return
+}
+
+// GetUserStats returns betting statistics for a user
+func (db *MemoryDB) GetUserStats(ctx context.Context, userID string) (bets int, wonBets int, settledBets int, pushBets int, avgOdds float64, err error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        bets, wonBets, settledBets, pushBets, avgOdds = db.userStats(userID)
+        return
+}
+
+// GetBettingRecords aggregates the bets of several users, keyed by user ID
+func (db *MemoryDB) GetBettingRecords(ctx context.Context, userIDs []string) (map[string]BettingRecord, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        records := make(map[string]BettingRecord)
+        totalOdds := make(map[string]float64)
+        for _, bet := range db.bets {
+                if !slices.Contains(userIDs, bet.UserID) {
+                        continue
+                }
+                record := records[bet.UserID]
+                record.Bets++
+                totalOdds[bet.UserID] += bet.Odds
+                switch bet.Status {
+                case "won":
+                        record.WonBets++
+                        record.SettledBets++
+                        record.Staked = addMoney(record.Staked, bet.BetAmount)
+                        record.Returned = addMoney(record.Returned, bet.PotentialWin)
+                case "lost":
+                        record.SettledBets++
+                        record.Staked = addMoney(record.Staked, bet.BetAmount)
+                }
+                records[bet.UserID] = record
+        }
+        for userID, record := range records {
+                record.AvgOdds = totalOdds[userID] / float64(record.Bets)
+                records[userID] = record
+        }
+        return records, nil
+}
+
+// CountPendingBets returns how many unsettled bets a user has, including bets held for review
+func (db *MemoryDB) CountPendingBets(ctx context.Context, userID string) (int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        count := 0
+        for _, bet := range db.bets {
+                if bet.UserID == userID && (bet.Status == "pending" || bet.Status == "review") {
+                        count++
+                }
+        }
+        return count, nil
+}
+
+// GetUserLimits returns a user's self-limits, empty when none were set
+func (db *MemoryDB) GetUserLimits(ctx context.Context, userID string) (*UserLimits, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if limits, ok := db.userLimits[userID]; ok {
+                copied := *limits
+                return &copied, nil
+        }
+        return &UserLimits{UserID: userID}, nil
+}
+
+// SetUserLimits creates or replaces a user's self-limits
+func (db *MemoryDB) SetUserLimits(ctx context.Context, limits *UserLimits) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        stored := *limits
+        db.userLimits[limits.UserID] = &stored
+        return nil
+}
+
+// GetBetUsageSince sums a user's stakes and settled results for bets placed since the given time
+func (db *MemoryDB) GetBetUsageSince(ctx context.Context, userID string, since time.Time) (*BetUsage, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        var usage BetUsage
+        for _, bet := range db.bets {
+                if bet.UserID != userID || bet.CreatedAt.Before(since) {
+                        continue
+                }
+                switch bet.Status {
+                case "pending", "review":
+                        usage.Wagered = addMoney(usage.Wagered, bet.BetAmount)
+                        usage.Pending = addMoney(usage.Pending, bet.BetAmount)
+                case "lost":
+                        usage.Wagered = addMoney(usage.Wagered, bet.BetAmount)
+                        usage.NetLoss = addMoney(usage.NetLoss, bet.BetAmount)
+                case "won":
+                        usage.Wagered = addMoney(usage.Wagered, bet.BetAmount)
+                        usage.NetLoss = addMoney(usage.NetLoss, bet.BetAmount - bet.PotentialWin)
+                }
+        }
+        return &usage, nil
+}
+
+// GetDatabaseStats returns database statistics
+func (db *MemoryDB) GetDatabaseStats(ctx context.Context) (map[string]int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        return map[string]int{
+                "users":    len(db.users),
+                "sessions": len(db.refreshTokens),
+                "bets":     len(db.bets),
+                "matches":  len(db.matches),
+        }, nil
+}
+
+// GetPlatformStats aggregates users and bets like the Postgres queries
+func (db *MemoryDB) GetExposure(ctx context.Context) ([]ExposureRow, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        type key struct{ matchID, betType string }
+        rows := make(map[key]*ExposureRow)
+        for _, bet := range db.bets {
+                if bet.Status != "pending" {
+                        continue
+                }
+                k := key{bet.MatchID, bet.BetType}
+                row, ok := rows[k]
+                if !ok {
+                        row = &ExposureRow{MatchID: bet.MatchID, HomeTeam: bet.HomeTeam, AwayTeam: bet.AwayTeam}
+                        row.BetType = bet.BetType
+                        if match, ok := db.matches[bet.MatchID]; ok {
+                                commenceTime := match.CommenceTime
+                                row.HomeTeam, row.AwayTeam, row.CommenceTime = match.HomeTeam, match.AwayTeam, &commenceTime
+                        }
+                        rows[k] = row
+                }
+                row.Bets++
+                row.Stake = addMoney(row.Stake, bet.BetAmount)
+                row.PotentialPayout = addMoney(row.PotentialPayout, bet.PotentialWin)
+        }
+
+        // ORDER BY match_id, bet_type
+        exposure := make([]ExposureRow, 0, len(rows))
+        for _, row := range rows {
+                exposure = append(exposure, *row)
+        }
+        sort.Slice(exposure, func(i, j int) bool {
+                if exposure[i].MatchID != exposure[j].MatchID {
+                        return exposure[i].MatchID < exposure[j].MatchID
+                }
+                return exposure[i].BetType < exposure[j].BetType
+        })
+        return exposure, nil
+}
+
+func (db *MemoryDB) GetPlatformStats(ctx context.Context) (*PlatformStats, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        stats := &PlatformStats{TotalUsers: len(db.users)}
+        var biggest *Bet
+        teamBets := make(map[string]int)
+        for _, bet := range db.bets {
+                if bet.Status == "void" {
+                        continue
+                }
+                stats.TotalBets++
+                stats.TotalWagered = addMoney(stats.TotalWagered, bet.BetAmount)
+
+                if bet.Status == "won" && (biggest == nil || bet.PotentialWin > biggest.PotentialWin ||
+                        bet.PotentialWin == biggest.PotentialWin && bet.CreatedAt.Before(biggest.CreatedAt)) {
+                        biggest = bet
+                }
+
+                match, ok := db.matches[bet.MatchID]
+                if !ok {
+                        continue
+                }
+                switch bet.BetType {
+                case "home":
+                        teamBets[match.HomeTeam]++
+                case "away":
+                        teamBets[match.AwayTeam]++
+                }
+        }
+
+        if biggest != nil {
+                win := &BiggestWin{Amount: biggest.PotentialWin, Odds: biggest.Odds, HomeTeam: biggest.HomeTeam, AwayTeam: biggest.AwayTeam}
+                if user, ok := db.users[biggest.UserID]; ok {
+                        win.Nickname = user.Nickname
+                }
+                if match, ok := db.matches[biggest.MatchID]; ok {
+                        win.HomeTeam, win.AwayTeam = match.HomeTeam, match.AwayTeam
+                }
+                stats.BiggestWin = win
+        }
+
+        // ORDER BY bets DESC, team
+        for team, bets := range teamBets {
+                if top := stats.MostPopularTeam; top == nil || bets > top.Bets || bets == top.Bets && team < top.Team {
+                        stats.MostPopularTeam = &PopularTeam{Team: team, Bets: bets}
+                }
+        }
+        return stats, nil
+}
+
+// GetOpenSeason returns the open season, starting one with the given bounds when none is open
+func (db *MemoryDB) GetOpenSeason(ctx context.Context, startsAt, endsAt time.Time) (*Season, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        for _, season := range db.seasons {
+                if season.ClosedAt == nil {
+                        copied := *season
+                        return &copied, nil
+                }
+        }
+        season := &Season{ID: len(db.seasons) + 1, StartsAt: startsAt, EndsAt: endsAt}
+        db.seasons = append(db.seasons, season)
+        copied := *season
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetSeason(ctx context.Context, id int) (*Season, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if id < 1 || id > len(db.seasons) {
+                return nil, ErrSeasonNotFound
+        }
+        copied := *db.seasons[id-1]
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetLastClosedSeason(ctx context.Context) (*Season, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        var last *Season
+        for _, season := range db.seasons {
+                if season.ClosedAt != nil && (last == nil || !season.EndsAt.Before(last.EndsAt)) {
+                        last = season
+                }
+        }
+        if last == nil {
+                return nil, ErrSeasonNotFound
+        }
+        copied := *last
+        return &copied, nil
+}
+
+// seasonStandings ranks players by their non-void bets placed in [startsAt, endsAt)
+func (db *MemoryDB) seasonStandings(startsAt, endsAt time.Time) []SeasonStanding {
+        byUser := make(map[string]*SeasonStanding)
+        for _, bet := range db.bets {
+                if bet.Status == "void" || bet.CreatedAt.Before(startsAt) || !bet.CreatedAt.Before(endsAt) {
+                        continue
+                }
+                if user, ok := db.users[bet.UserID]; ok && user.IsGuest {
+                        continue
+                }
+                standing, ok := byUser[bet.UserID]
+                if !ok {
+                        standing = &SeasonStanding{UserID: bet.UserID}
+                        if user, ok := db.users[bet.UserID]; ok {
+                                standing.Nickname = user.Nickname
+                        }
+                        byUser[bet.UserID] = standing
+                }
+                standing.Bets++
+                standing.Wagered = addMoney(standing.Wagered, bet.BetAmount)
+                switch bet.Status {
+                case "won":
+                        standing.WonBets++
+                        standing.SettledBets++
+                        standing.Profit = addMoney(standing.Profit, bet.PotentialWin - bet.BetAmount)
+                case "lost":
+                        standing.SettledBets++
+                        standing.Profit = addMoney(standing.Profit, -bet.BetAmount)
+                }
+        }
+
+        standings := make([]SeasonStanding, 0, len(byUser))
+        for _, standing := range byUser {
+                standings = append(standings, *standing)
+        }
+        rankStandings(standings)
+        return standings
+}
+
+func (db *MemoryDB) GetSeasonStandings(ctx context.Context, season *Season, limit, offset int) ([]SeasonStanding, int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        standings, closed := db.seasonResults[season.ID]
+        if !closed {
+                standings = db.seasonStandings(season.StartsAt, season.EndsAt)
+        }
+        total := len(standings)
+        if offset >= total {
+                return nil, total, nil
+        }
+        end := min(offset+limit, total)
+        return slices.Clone(standings[offset:end]), total, nil
+}
+
+func (db *MemoryDB) CloseSeason(ctx context.Context, seasonID int, endsAt time.Time, prizes []float64, nextEndsAt time.Time) ([]SeasonStanding, *Season, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        if seasonID < 1 || seasonID > len(db.seasons) {
+                return nil, nil, ErrSeasonNotFound
+        }
+        season := db.seasons[seasonID-1]
+        if season.ClosedAt != nil {
+                return nil, nil, ErrSeasonClosed
+        }
+
+        standings := db.seasonStandings(season.StartsAt, endsAt)
+        for i := range standings {
+                if i >= len(prizes) || prizes[i] <= 0 {
+                        continue
+                }
+                standings[i].Prize = prizes[i]
+                if user, ok := db.users[standings[i].UserID]; ok {
+                        user.Money = addMoney(user.Money, prizes[i])
+                        user.UpdatedAt = db.clock.Now()
+                        db.recordLedger(user.ID, LedgerSeasonPrize, prizes[i], strconv.Itoa(seasonID))
+                }
+        }
+        db.seasonResults[seasonID] = standings
+
+        closedAt := db.clock.Now()
+        season.EndsAt = endsAt
+        season.ClosedAt = &closedAt
+
+        next := &Season{ID: len(db.seasons) + 1, StartsAt: endsAt, EndsAt: nextEndsAt}
+        db.seasons = append(db.seasons, next)
+        copied := *next
+        return slices.Clone(standings), &copied, nil
+}
+
+// Admin methods
+func (db *MemoryDB) GetAdminByUsername(ctx context.Context, username string) (*Admin, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        for _, admin := range db.admins {
+                if admin.Username == username && admin.IsActive {
+                        copied := *admin
+                        return &copied, nil
+                }
+        }
+        return nil, ErrAdminNotFound
+}
+
+func (db *MemoryDB) GetAdminByID(ctx context.Context, id string) (*Admin, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        admin, ok := db.admins[id]
+        if !ok || !admin.IsActive {
+                return nil, ErrAdminNotFound
+        }
+        copied := *admin
+        return &copied, nil
+}
+
+func (db *MemoryDB) UpdateAdminLastLogin(ctx context.Context, adminID string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if admin, ok := db.admins[adminID]; ok {
+                now := db.clock.Now()
+                admin.LastLogin = &now
+        }
+        return nil
+}
+
+// Admin session methods
+func (db *MemoryDB) CreateAdminSession(ctx context.Context, adminID string, token string, expiresAt time.Time) (*AdminSession, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        session := &AdminSession{
+                ID:        generateTokenID(),
+                AdminID:   adminID,
+                Token:     token,
+                ExpiresAt: expiresAt,
+                CreatedAt: db.clock.Now(),
+        }
+        db.adminSessions[token] = session
+        copied := *session
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetAdminSessionByToken(ctx context.Context, token string) (*AdminSession, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        session, ok := db.adminSessions[token]
+        if !ok || !session.ExpiresAt.After(db.clock.Now()) {
+                return nil, ErrAdminSessionNotFound
+        }
+        copied := *session
+        return &copied, nil
+}
+
+func (db *MemoryDB) DeleteAdminSession(ctx context.Context, token string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        delete(db.adminSessions, token)
+        return nil
+}
+
+// Match sync methods
+func (db *MemoryDB) UpsertMatch(ctx context.Context, match *Match) (*Match, error) {
+        db.mu.Lock()
+        if _, exists := db.matches[match.APIID]; exists {
+                db.mu.Unlock()
+                return db.UpdateMatchByAPIID(ctx, match.APIID, match)
+        }
+
+        // Same fixture under another api_id: merge, as the unique fixture index does for PostgresDB
+        for _, existing := range db.matches {
+                if strings.EqualFold(existing.HomeTeam, match.HomeTeam) && strings.EqualFold(existing.AwayTeam, match.AwayTeam) &&
+                        existing.CommenceTime.Format("2006-01-02") == match.CommenceTime.Format("2006-01-02") {
+                        copied := *existing
+                        db.mu.Unlock()
+                        return db.UpdateMatchByAPIID(ctx, copied.APIID, mergedFixture(&copied, match))
+                }
+        }
+        defer db.mu.Unlock()
+
+        stored := *match
+        stored.ID = generateTokenID()
+        stored.CreatedAt = db.clock.Now()
+        stored.UpdatedAt = stored.CreatedAt
+        stored.OddsUpdatedAt = nil
+        if hasOdds(match) {
+                stored.OddsUpdatedAt = &stored.CreatedAt
+        }
+        db.matches[match.APIID] = &stored
+        copied := stored
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetMatchByAPIID(ctx context.Context, apiID string) (*Match, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        match, ok := db.matches[apiID]
+        if !ok {
+                return nil, ErrMatchNotFound
+        }
+        copied := *match
+        return &copied, nil
+}
+
+func (db *MemoryDB) FindMatchByTeams(ctx context.Context, homeTeam, awayTeam string, commenceTime time.Time, window time.Duration) (*Match, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        var best *Match
+        var bestGap time.Duration
+        for _, match := range db.matches {
+                if !strings.EqualFold(match.HomeTeam, homeTeam) || !strings.EqualFold(match.AwayTeam, awayTeam) {
+                        continue
+                }
+                gap := match.CommenceTime.Sub(commenceTime)
+                if gap < 0 {
+                        gap = -gap
+                }
+                if gap <= window && (best == nil || gap < bestGap) {
+                        best, bestGap = match, gap
+                }
+        }
+        if best == nil {
+                return nil, ErrMatchNotFound
+        }
+        copied := *best
+        return &copied, nil
+}
+
+func (db *MemoryDB) UpdateMatchByAPIID(ctx context.Context, apiID string, match *Match) (*Match, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        stored, ok := db.matches[apiID]
+        if !ok {
+                return nil, ErrMatchNotFound
+        }
+
+        // Same partial-update semantics as PostgresDB
+        if match.HomeTeam != "" {
+                stored.HomeTeam = match.HomeTeam
+        }
+        if match.AwayTeam != "" {
+                stored.AwayTeam = match.AwayTeam
+        }
+        if !match.CommenceTime.IsZero() {
+                stored.CommenceTime = match.CommenceTime
+        }
+        if match.HomeOdds != nil {
+                stored.HomeOdds = match.HomeOdds
+        }
+        if match.DrawOdds != nil {
+                stored.DrawOdds = match.DrawOdds
+        }
+        if match.AwayOdds != nil {
+                stored.AwayOdds = match.AwayOdds
+        }
+        if match.BTTSYesOdds != nil {
+                stored.BTTSYesOdds = match.BTTSYesOdds
+        }
+        if match.BTTSNoOdds != nil {
+                stored.BTTSNoOdds = match.BTTSNoOdds
+        }
+        if len(match.CorrectScoreOdds) > 0 {
+                stored.CorrectScoreOdds = match.CorrectScoreOdds
+        }
+        if len(match.TotalsOdds) > 0 {
+                stored.TotalsOdds = match.TotalsOdds
+        }
+        if match.HomeScore != nil {
+                stored.HomeScore = match.HomeScore
+        }
+        if match.AwayScore != nil {
+                stored.AwayScore = match.AwayScore
+        }
+        if match.ShootoutWinner != nil {
+                stored.ShootoutWinner = match.ShootoutWinner
+        }
+        if match.OddsSource != nil {
+                stored.OddsSource = match.OddsSource
+        }
+        stored.Completed = match.Completed
+        stored.UpdatedAt = db.clock.Now()
+        if hasOdds(match) {
+                oddsUpdatedAt := stored.UpdatedAt
+                stored.OddsUpdatedAt = &oddsUpdatedAt
+        }
+
+        copied := *stored
+        return &copied, nil
+}
+
+func (db *MemoryDB) GetCompletedUncalculatedMatches(ctx context.Context, afterAPIID string, limit int) ([]Match, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        var matches []Match
+        for _, match := range db.matches {
+                if !match.Completed || match.Calculated || match.APIID <= afterAPIID {
+                        continue
+                }
+                matches = append(matches, *match)
+        }
+
+        sort.Slice(matches, func(i, j int) bool {
+                return matches[i].APIID < matches[j].APIID
+        })
+        if len(matches) > limit {
+                matches = matches[:limit]
+        }
+        return matches, nil
+}
+
+// checkUnsettled mirrors lockUnsettledMatch; caller holds db.mu
+func (db *MemoryDB) checkUnsettled(matchAPIID string) error {
+        match, ok := db.matches[matchAPIID]
+        if !ok {
+                return ErrMatchNotFound
+        }
+        if match.Calculated {
+                return ErrMatchAlreadySettled
+        }
+        return nil
+}
+
+// TryLockCalc mirrors the PostgresDB advisory lock within this process
+func (db *MemoryDB) TryLockCalc(ctx context.Context) (func(), error) {
+        if !db.calcLock.TryLock() {
+                return nil, ErrCalcInProgress
+        }
+        return db.calcLock.Unlock, nil
+}
+
+// markCalculated mirrors markMatchCalculated; caller holds db.mu
+func (db *MemoryDB) markCalculated(matchAPIID, result string) {
+        match := db.matches[matchAPIID]
+        match.Calculated = true
+        match.Result = &result
+        match.UpdatedAt = db.clock.Now()
+}
+
+func (db *MemoryDB) SettleMatch(ctx context.Context, matchAPIID, result string, winningBetTypes, pushBetTypes []string) error {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        if err := db.checkUnsettled(matchAPIID); err != nil {
+                return err
+        }
+        defer db.markCalculated(matchAPIID, result)
+
+        now := db.clock.Now()
+        for _, bet := range db.bets {
+                if bet.MatchID != matchAPIID || bet.Status != "pending" {
+                        continue
+                }
+                settledAt := now
+                bet.SettledAt = &settledAt
+                notification := db.betNotification(bet)
+                if slices.Contains(winningBetTypes, bet.BetType) {
+                        bet.Status = "won"
+                        notification.Payout = bet.PotentialWin
+                        if user, ok := db.users[bet.UserID]; ok {
+                                user.Money = addMoney(user.Money, bet.PotentialWin)
+                                db.recordLedger(user.ID, LedgerBetPayout, bet.PotentialWin, bet.BetID)
+                                db.recordNotification(user.ID, NotificationWin, notification)
+                        }
+                } else if slices.Contains(pushBetTypes, bet.BetType) {
+                        bet.Status = "push"
+                        notification.Payout = bet.BetAmount
+                        if user, ok := db.users[bet.UserID]; ok {
+                                user.Money = addMoney(user.Money, bet.BetAmount)
+                                db.recordLedger(user.ID, LedgerBetRefund, bet.BetAmount, bet.BetID)
+                                db.recordNotification(user.ID, NotificationPush, notification)
+                        }
+                } else {
+                        bet.Status = "lost"
+                        db.recordNotification(bet.UserID, NotificationLoss, notification)
+                }
+        }
+        return nil
+}
+
+// betNotification builds the settlement notification payload; Payout is left to the caller
+func (db *MemoryDB) betNotification(bet *Bet) BetNotification {
+        return BetNotification{
+                BetID:     bet.BetID,
+                MatchID:   bet.MatchID,
+                BetType:   bet.BetType,
+                BetAmount: bet.BetAmount,
+                Odds:      bet.Odds,
+                HomeTeam:  bet.HomeTeam,
+                AwayTeam:  bet.AwayTeam,
+        }
+}
+
+func (db *MemoryDB) VoidMatch(ctx context.Context, matchAPIID string) (int, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+
+        if err := db.checkUnsettled(matchAPIID); err != nil {
+                return 0, err
+        }
+        defer db.markCalculated(matchAPIID, "void")
+
+        now := db.clock.Now()
+        count := 0
+        for _, bet := range db.bets {
+                if bet.MatchID != matchAPIID || (bet.Status != "pending" && bet.Status != "review") {
+                        continue
+                }
+                bet.Status = "void"
+                settledAt := now
+                bet.SettledAt = &settledAt
+                if user, ok := db.users[bet.UserID]; ok {
+                        user.Money = addMoney(user.Money, bet.BetAmount)
+                        db.recordLedger(user.ID, LedgerBetRefund, bet.BetAmount, bet.BetID)
+                        notification := db.betNotification(bet)
+                        notification.Payout = bet.BetAmount
+                        db.recordNotification(user.ID, NotificationVoid, notification)
+                }
+                count++
+        }
+        return count, nil
+}
+
+// ListBetsInReview returns the bets held for admin review, oldest first
+func (db *MemoryDB) ListBetsInReview(ctx context.Context) ([]Bet, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        var bets []Bet
+        for _, bet := range db.bets {
+                if bet.Status == "review" {
+                        bets = append(bets, *bet)
+                }
+        }
+        sort.SliceStable(bets, func(i, j int) bool { return bets[i].CreatedAt.Before(bets[j].CreatedAt) })
+        return bets, nil
+}
+
+// reviewBet finds a bet held for review; mirrors lockReviewBet
+func (db *MemoryDB) reviewBet(betID string) (*Bet, error) {
+        for _, bet := range db.bets {
+                if bet.BetID != betID {
+                        continue
+                }
+                if bet.Status != "review" {
+                        return nil, ErrBetNotInReview
+                }
+                return bet, nil
+        }
+        return nil, ErrBetNotFound
+}
+
+// ApproveBet makes a bet held for review pending; refused once its match was calculated or kicks off by closesAt
+func (db *MemoryDB) ApproveBet(ctx context.Context, betID string, closesAt time.Time) (*Bet, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        bet, err := db.reviewBet(betID)
+        if err != nil {
+                return nil, err
+        }
+        if err := db.checkUnsettled(bet.MatchID); err != nil {
+                return nil, err
+        }
+        if !db.matches[bet.MatchID].CommenceTime.After(closesAt) {
+                return nil, ErrMatchStarted
+        }
+        bet.Status = "pending"
+        approved := *bet
+        return &approved, nil
+}
+
+// RejectBet voids a bet held for review and refunds its reserved stake
+func (db *MemoryDB) RejectBet(ctx context.Context, betID string) (*Bet, error) {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        bet, err := db.reviewBet(betID)
+        if err != nil {
+                return nil, err
+        }
+        user, ok := db.users[bet.UserID]
+        if !ok {
+                return nil, ErrUserNotFound
+        }
+        bet.Status = "void"
+        settledAt := db.clock.Now()
+        bet.SettledAt = &settledAt
+        user.Money = addMoney(user.Money, bet.BetAmount)
+        db.recordLedger(user.ID, LedgerBetRefund, bet.BetAmount, bet.BetID)
+        notification := db.betNotification(bet)
+        notification.Payout = bet.BetAmount
+        db.recordNotification(user.ID, NotificationVoid, notification)
+        rejected := *bet
+        return &rejected, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
         "context"
         "encoding/base64"
+        "errors"
         "fmt"
         "net/http"
         "regexp"
@@ -11,14 +12,14 @@ import (
         "time"
 
         "github.com/gorilla/handlers"
-        "golang.org/x/crypto/bcrypt"
 )
 
 // contextKey type for context keys
 type contextKey string
 
 const (
-        userContextKey contextKey = "user"
+        userContextKey        contextKey = "user"
+        tokenExpiryContextKey contextKey = "token_expiry" // Access token exp claim, for GET /api/auth/user
 )
 
 // CORS middleware with custom origin checking
@@ -43,7 +44,13 @@ func corsMiddleware(config *Config) func(http.Handler) http.Handler {
         }
 
         // Custom origin checker that supports wildcards
+        // The validator makes gorilla/handlers echo the request origin, so
+        // Access-Control-Allow-Origin is always the concrete origin, never "*"
         originChecker := func(origin string) bool {
+                // Sandboxed iframes and file:// pages send "null"; never trust it with cookies
+                if config.CORSCredentials && origin == "null" {
+                        return false
+                }
                 for _, pattern := range allowedPatterns {
                         if pattern.MatchString(origin) {
                                 return true
@@ -52,12 +59,24 @@ func corsMiddleware(config *Config) func(http.Handler) http.Handler {
                 return false
         }
 
-        return handlers.CORS(
-                handlers.AllowCredentials(), // Allow cookies
+        options := []handlers.CORSOption{
                 handlers.AllowedOriginValidator(originChecker), // Use validator for wildcards
                 handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
                 handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
-        )
+        }
+        if config.CORSCredentials {
+                options = append(options, handlers.AllowCredentials()) // Allow cookies
+        }
+        cors := handlers.CORS(options...)
+
+        return func(next http.Handler) http.Handler {
+                wrapped := cors(next)
+                return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        // The allowed origin depends on the request, so caches must key on it
+                        w.Header().Add("Vary", "Origin")
+                        wrapped.ServeHTTP(w, r)
+                })
+        }
 }
 
 // JWT Auth middleware - checks for valid JWT access token
@@ -68,7 +87,7 @@ func jwtAuthMiddleware(db Database, config *Config, logger *Logger) func(http.Ha
                         authHeader := r.Header.Get("Authorization")
                         if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
                                 logger.LogWarning("[JWT AUTH] No JWT token found in Authorization header")
-                                http.Error(w, `{"success": false, "error": "No access token"}`, http.StatusUnauthorized)
+                                http.Error(w, `{"success": false, "code": "UNAUTHORIZED", "error": "No access token"}`, http.StatusUnauthorized)
                                 return
                         }
 
@@ -78,15 +97,40 @@ func jwtAuthMiddleware(db Database, config *Config, logger *Logger) func(http.Ha
                         claims, err := validateAccessToken(tokenString, config)
                         if err != nil {
                                 logger.LogError("[JWT AUTH] Invalid JWT token: %s", err.Error())
-                                http.Error(w, `{"success": false, "error": "Invalid access token"}`, http.StatusUnauthorized)
+                                http.Error(w, `{"success": false, "code": "UNAUTHORIZED", "error": "Invalid access token"}`, http.StatusUnauthorized)
                                 return
                         }
 
-                        // Get user data
-                        user, err := db.GetUserByID(claims.UserID)
+                        if claims.UserID == "" {
+                                logger.LogWarning("[JWT AUTH] Access token has no user ID")
+                                http.Error(w, `{"success": false, "code": "UNAUTHORIZED", "error": "Invalid access token"}`, http.StatusUnauthorized)
+                                return
+                        }
+
+                        // Get user data; a valid token for a deleted user is unauthorized, not an error
+                        user, err := db.GetUserByID(r.Context(), claims.UserID)
+                        if errors.Is(err, ErrUserNotFound) {
+                                logger.LogWarning("[JWT AUTH] User %s from a valid token no longer exists", claims.UserID)
+                                http.Error(w, `{"success": false, "code": "UNAUTHORIZED", "error": "User not found"}`, http.StatusUnauthorized)
+                                return
+                        }
                         if err != nil {
                                 logger.LogError("[JWT AUTH] Failed to get user data for user %s: %s", claims.UserID, err.Error())
-                                http.Error(w, `{"success": false, "error": "User not found"}`, http.StatusInternalServerError)
+                                http.Error(w, `{"success": false, "code": "INTERNAL_ERROR", "error": "Failed to load user"}`, http.StatusInternalServerError)
+                                return
+                        }
+
+                        // Disabled accounts keep their row (and bets) but can't use the API
+                        if user.Disabled() {
+                                logger.LogWarning("[JWT AUTH] Rejected access token for disabled user %s", user.ID)
+                                http.Error(w, `{"success": false, "code": "ACCOUNT_DISABLED", "error": "`+accountDisabledMessage+`"}`, http.StatusForbidden)
+                                return
+                        }
+
+                        // Session binding - token must carry the user's current token version
+                        if config.JWTSessionBinding && claims.TokenVersion != user.TokenVersion {
+                                logger.LogWarning("[JWT AUTH] Revoked access token for user %s (version %d, current %d)", user.ID, claims.TokenVersion, user.TokenVersion)
+                                http.Error(w, `{"success": false, "code": "UNAUTHORIZED", "error": "Access token revoked"}`, http.StatusUnauthorized)
                                 return
                         }
 
@@ -94,6 +138,9 @@ func jwtAuthMiddleware(db Database, config *Config, logger *Logger) func(http.Ha
 
                         // Add user to request context
                         ctx := context.WithValue(r.Context(), userContextKey, user)
+                        if claims.ExpiresAt != nil {
+                                ctx = context.WithValue(ctx, tokenExpiryContextKey, claims.ExpiresAt.Time)
+                        }
                         next.ServeHTTP(w, r.WithContext(ctx))
                 })
         }
@@ -105,74 +152,137 @@ func getUserFromContext(ctx context.Context) (*User, bool) {
         return user, ok
 }
 
+// getTokenExpiryFromContext returns the expiry of the access token that authenticated the request
+func getTokenExpiryFromContext(ctx context.Context) (time.Time, bool) {
+        expiresAt, ok := ctx.Value(tokenExpiryContextKey).(time.Time)
+        return expiresAt, ok
+}
+
 // Admin context key
 const (
         adminContextKey contextKey = "admin"
 )
 
-// Admin auth middleware - checks for valid Basic Auth admin credentials
-func adminAuthMiddleware(db Database, logger *Logger) func(http.Handler) http.Handler {
+// Admin auth middleware - accepts a Bearer admin token (issued by /api/admin/login)
+// or Basic Auth admin credentials (kept for compatibility with existing cron jobs)
+func adminAuthMiddleware(db Database, config *Config, logger *Logger) func(http.Handler) http.Handler {
         return func(next http.Handler) http.Handler {
                 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-                        // Get Basic Auth header
                         authHeader := r.Header.Get("Authorization")
-                        if authHeader == "" || !strings.HasPrefix(authHeader, "Basic ") {
-                                logger.LogWarning("[ADMIN AUTH] Missing Basic Auth header")
-                                http.Error(w, `{"ok": false, "error": "Unauthorized", "message": "Basic authentication required"}`, http.StatusUnauthorized)
-                                return
-                        }
 
-                        // Decode Basic Auth
-                        encoded := strings.TrimPrefix(authHeader, "Basic ")
-                        decoded, err := base64.StdEncoding.DecodeString(encoded)
-                        if err != nil {
-                                logger.LogWarning("[ADMIN AUTH] Invalid base64 encoding: %s", err.Error())
-                                http.Error(w, `{"ok": false, "error": "Unauthorized", "message": "Invalid authentication encoding"}`, http.StatusUnauthorized)
+                        var admin *Admin
+                        switch {
+                        case strings.HasPrefix(authHeader, "Bearer "):
+                                admin = authenticateAdminToken(r.Context(), w, strings.TrimPrefix(authHeader, "Bearer "), db, config, logger)
+                        case strings.HasPrefix(authHeader, "Basic "):
+                                admin = authenticateAdminBasic(r.Context(), w, strings.TrimPrefix(authHeader, "Basic "), db, config, logger)
+                        default:
+                                logger.LogWarning("[ADMIN AUTH] Missing admin credentials")
+                                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Admin token or basic authentication required"}`, http.StatusUnauthorized)
                                 return
                         }
-
-                        // Parse username:password
-                        parts := strings.SplitN(string(decoded), ":", 2)
-                        if len(parts) != 2 {
-                                logger.LogWarning("[ADMIN AUTH] Invalid Basic Auth format")
-                                http.Error(w, `{"ok": false, "error": "Unauthorized", "message": "Invalid authentication format"}`, http.StatusUnauthorized)
+                        if admin == nil {
                                 return
                         }
 
-                        username := parts[0]
-                        password := parts[1]
+                        // Add admin to request context
+                        ctx := context.WithValue(r.Context(), adminContextKey, admin)
+                        next.ServeHTTP(w, r.WithContext(ctx))
+                })
+        }
+}
 
-                        logger.LogAuth("[ADMIN AUTH] Attempting authentication for admin: %s", username)
+// authenticateAdminToken validates a Bearer admin token against the admin_sessions table
+// Writes the error response and returns nil if authentication fails
+func authenticateAdminToken(ctx context.Context, w http.ResponseWriter, tokenString string, db Database, config *Config, logger *Logger) *Admin {
+        claims, err := validateAdminToken(tokenString, config)
+        if err != nil {
+                logger.LogWarning("[ADMIN AUTH] Invalid admin token: %s", err.Error())
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Invalid admin token"}`, http.StatusUnauthorized)
+                return nil
+        }
 
-                        // Get admin from database
-                        admin, err := db.GetAdminByUsername(username)
-                        if err != nil {
-                                logger.LogWarning("[ADMIN AUTH] Admin not found: %s", username)
-                                http.Error(w, `{"ok": false, "error": "Unauthorized", "message": "Invalid username or password"}`, http.StatusUnauthorized)
-                                return
-                        }
+        // Token must not have been revoked
+        if _, err := db.GetAdminSessionByToken(ctx, tokenString); errors.Is(err, ErrAdminSessionNotFound) {
+                logger.LogWarning("[ADMIN AUTH] Admin token revoked or expired for admin: %s", claims.Username)
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Admin token revoked or expired"}`, http.StatusUnauthorized)
+                return nil
+        } else if err != nil {
+                logger.LogError("[ADMIN AUTH] Failed to look up admin session: %s", err.Error())
+                http.Error(w, `{"ok": false, "code": "INTERNAL_ERROR", "error": "Internal server error"}`, http.StatusInternalServerError)
+                return nil
+        }
 
-                        // Verify password
-                        err = bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password))
-                        if err != nil {
-                                logger.LogWarning("[ADMIN AUTH] Invalid password for admin: %s", username)
-                                http.Error(w, `{"ok": false, "error": "Unauthorized", "message": "Invalid username or password"}`, http.StatusUnauthorized)
-                                return
-                        }
+        admin, err := db.GetAdminByID(ctx, claims.AdminID)
+        if err != nil && !errors.Is(err, ErrAdminNotFound) {
+                logger.LogError("[ADMIN AUTH] Failed to look up admin %s: %s", claims.Username, err.Error())
+                http.Error(w, `{"ok": false, "code": "INTERNAL_ERROR", "error": "Internal server error"}`, http.StatusInternalServerError)
+                return nil
+        }
+        if err != nil {
+                logger.LogWarning("[ADMIN AUTH] Admin not found or inactive: %s", claims.Username)
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Admin not found"}`, http.StatusUnauthorized)
+                return nil
+        }
 
-                        // Update last login
-                        if err := db.UpdateAdminLastLogin(admin.ID); err != nil {
-                                logger.LogWarning("[ADMIN AUTH] Failed to update last login: %s", err.Error())
-                                // Don't fail the request, just log
-                        }
+        return admin
+}
 
-                        logger.LogSuccess("[ADMIN AUTH] Admin authenticated: %s", admin.Username)
+// authenticateAdminBasic verifies Basic Auth admin credentials (bcrypt on every call)
+// Writes the error response and returns nil if authentication fails
+func authenticateAdminBasic(ctx context.Context, w http.ResponseWriter, encoded string, db Database, config *Config, logger *Logger) *Admin {
+        // Decode Basic Auth
+        decoded, err := base64.StdEncoding.DecodeString(encoded)
+        if err != nil {
+                logger.LogWarning("[ADMIN AUTH] Invalid base64 encoding: %s", err.Error())
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Invalid authentication encoding"}`, http.StatusUnauthorized)
+                return nil
+        }
 
-                        // Add admin to request context
-                        ctx := context.WithValue(r.Context(), adminContextKey, admin)
-                        next.ServeHTTP(w, r.WithContext(ctx))
-                })
+        // Parse username:password
+        parts := strings.SplitN(string(decoded), ":", 2)
+        if len(parts) != 2 {
+                logger.LogWarning("[ADMIN AUTH] Invalid Basic Auth format")
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Invalid authentication format"}`, http.StatusUnauthorized)
+                return nil
+        }
+
+        username := parts[0]
+        password := parts[1]
+
+        logger.LogAuth("[ADMIN AUTH] Attempting authentication for admin: %s", username)
+
+        // Get admin from database
+        admin, err := db.GetAdminByUsername(ctx, username)
+        if err != nil && !errors.Is(err, ErrAdminNotFound) {
+                logger.LogError("[ADMIN AUTH] Failed to look up admin %s: %s", username, err.Error())
+                http.Error(w, `{"ok": false, "code": "INTERNAL_ERROR", "error": "Internal server error"}`, http.StatusInternalServerError)
+                return nil
+        }
+        if err != nil {
+                compareDummyPassword(password, config) // Unknown usernames pay the same bcrypt cost
+                logger.LogWarning("[ADMIN AUTH] Admin not found: %s", username)
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Invalid username or password"}`, http.StatusUnauthorized)
+                return nil
+        }
+
+        // Verify password
+        err = comparePasswordHash([]byte(admin.PasswordHash), []byte(password))
+        if err != nil {
+                logger.LogWarning("[ADMIN AUTH] Invalid password for admin: %s", username)
+                http.Error(w, `{"ok": false, "code": "UNAUTHORIZED", "error": "Unauthorized", "message": "Invalid username or password"}`, http.StatusUnauthorized)
+                return nil
         }
+
+        // Update last login
+        if err := db.UpdateAdminLastLogin(ctx, admin.ID); err != nil {
+                logger.LogWarning("[ADMIN AUTH] Failed to update last login: %s", err.Error())
+                // Don't fail the request, just log
+        }
+
+        logger.LogSuccess("[ADMIN AUTH] Admin authenticated: %s", admin.Username)
+
+        return admin
 }
 
 // Get admin from context (helper function)
@@ -189,14 +299,39 @@ func contentTypeMiddleware(next http.Handler) http.Handler {
         })
 }
 
+// Body limit middleware - caps request bodies at MaxRequestBodyBytes
+// Reads past the limit fail with *http.MaxBytesError, which handlers turn into 413
+func bodyLimitMiddleware(config *Config) func(http.Handler) http.Handler {
+        return func(next http.Handler) http.Handler {
+                return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                        limit := config.MaxRequestBodyBytes
+                        // Picture uploads are bounded by PICTURE_MAX_BYTES in their handler
+                        if r.URL.Path == pictureUploadPath {
+                                limit = max(limit, pictureUploadLimit(config))
+                        }
+
+                        // Reject declared oversized bodies without reading them
+                        if r.ContentLength > limit {
+                                writeMiddlewareError(w, r, config, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, fmt.Sprintf("Request body too large (max %d bytes)", limit))
+                                return
+                        }
+
+                        if r.Body != nil {
+                                r.Body = http.MaxBytesReader(w, r.Body, limit)
+                        }
+                        next.ServeHTTP(w, r)
+                })
+        }
+}
+
 // Recovery middleware - catches panics and returns 500
 func recoveryMiddleware(logger *Logger) func(http.Handler) http.Handler {
         return func(next http.Handler) http.Handler {
                 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
                         defer func() {
                                 if err := recover(); err != nil {
-                                        logger.LogError("[RECOVERY] Panic recovered:", err)
-                                        http.Error(w, `{"success": false, "error": "Internal server error"}`, http.StatusInternalServerError)
+                                        logger.LogError("[RECOVERY] Panic recovered: %v", err)
+                                        http.Error(w, `{"success": false, "code": "INTERNAL_ERROR", "error": "Internal server error"}`, http.StatusInternalServerError)
                                 }
                         }()
                         next.ServeHTTP(w, r)
@@ -238,16 +373,12 @@ func rateLimitMiddleware(config *Config, logger *Logger) func(http.Handler) http
         var mu sync.RWMutex
         requests := make(map[string]int)
         resetTime := make(map[string]int64)
+        trustedProxies := config.trustedProxyCIDRs()
 
         return func(next http.Handler) http.Handler {
                 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-                        // Get client IP
-                        clientIP := r.RemoteAddr
-                        if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-                                clientIP = forwarded
-                        } else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
-                                clientIP = realIP
-                        }
+                        // Get client IP (forwarded headers only count from trusted proxies)
+                        clientIP := ClientIP(r, trustedProxies)
 
                         // Rate limiting with configurable window and requests
                         now := time.Now().Unix()
@@ -263,8 +394,8 @@ func rateLimitMiddleware(config *Config, logger *Logger) func(http.Handler) http
                         // Check rate limit
                         if requests[clientIP] >= config.RateLimitRequests {
                                 mu.Unlock()
-                                logger.LogWarning("[RATE LIMIT] Rate limit exceeded for IP:", clientIP)
-                                http.Error(w, `{"success": false, "error": "Rate limit exceeded"}`, http.StatusTooManyRequests)
+                                logger.LogWarning("[RATE LIMIT] Rate limit exceeded for IP: %s", clientIP)
+                                writeMiddlewareError(w, r, config, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
                                 return
                         }
 
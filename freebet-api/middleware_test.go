@@ -0,0 +1,100 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "io"
+        "net/http"
+        "net/http/httptest"
+        "testing"
+        "time"
+)
+
+func TestCORSCredentials(t *testing.T) {
+        ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(http.StatusOK)
+        })
+
+        tests := []struct {
+                name        string
+                credentials bool
+                origin      string
+                wantOrigin  string
+                wantCreds   string
+        }{
+                {"wildcard-matched origin with credentials", true, "https://app.playfree.bet", "https://app.playfree.bet", "true"},
+                {"wildcard-matched origin without credentials", false, "https://app.playfree.bet", "https://app.playfree.bet", ""},
+                {"exact origin with credentials", true, "http://localhost:3000", "http://localhost:3000", "true"},
+                {"unlisted origin", true, "https://evil.example.com", "", ""},
+                {"wildcard stays anchored to the domain", true, "https://playfree.bet.evil.com", "", ""},
+                {"null origin with credentials", true, "null", "", ""},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        config := validTestConfig(t)
+                        config.CORSAllowedOrigins = []string{"https://*.playfree.bet", "http://localhost:3000"}
+                        config.CORSCredentials = tt.credentials
+
+                        req := httptest.NewRequest("GET", "/api/matches", nil)
+                        req.Header.Set("Origin", tt.origin)
+                        w := httptest.NewRecorder()
+                        corsMiddleware(config)(ok).ServeHTTP(w, req)
+
+                        if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+                                t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+                        }
+                        if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+                                t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCreds)
+                        }
+                        if got := w.Header().Get("Vary"); got == "" {
+                                t.Error("Vary header missing, want Origin")
+                        }
+                })
+        }
+}
+
+// userLookupFailingDB fails every GetUserByID with err
+type userLookupFailingDB struct {
+        Database
+        err error
+}
+
+func (db *userLookupFailingDB) GetUserByID(ctx context.Context, id string) (*User, error) {
+        return nil, db.err
+}
+
+func TestJWTAuthUserLookupErrors(t *testing.T) {
+        clock := NewFakeClock(time.Now().UTC())
+        config := newTestConfig(t, clock)
+        logger := NewLogger("ERROR", io.Discard)
+        ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(http.StatusOK)
+        })
+
+        tests := []struct {
+                name   string
+                db     Database
+                userID string
+                status int
+        }{
+                {"deleted user", NewMemoryDB(clock), "deleted-user", http.StatusUnauthorized},
+                {"no subject", NewMemoryDB(clock), "", http.StatusUnauthorized},
+                {"user not found error", &userLookupFailingDB{err: ErrUserNotFound}, "some-user", http.StatusUnauthorized},
+                {"database failure", &userLookupFailingDB{err: errors.New("connection refused")}, "some-user", http.StatusInternalServerError},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        token, err := generateAccessToken(&User{ID: tt.userID, Nickname: "ghost"}, config)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        req := httptest.NewRequest("GET", "/api/auth/user", nil)
+                        req.Header.Set("Authorization", bearer(token))
+                        w := httptest.NewRecorder()
+                        jwtAuthMiddleware(tt.db, config, logger)(ok).ServeHTTP(w, req)
+                        if w.Code != tt.status {
+                                t.Errorf("status = %d, want %d; body: %s", w.Code, tt.status, w.Body.String())
+                        }
+                })
+        }
+}
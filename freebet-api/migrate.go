@@ -0,0 +1,152 @@
+package main
+
+import (
+        "context"
+        "embed"
+        "fmt"
+        "io/fs"
+        "path"
+        "sort"
+        "strconv"
+        "strings"
+        "time"
+)
+
+// Embedded SQL migrations, applied in version order
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key that serializes concurrent runners
+const migrationLockID = 720451
+
+// Migration represents a single versioned schema change
+type Migration struct {
+        Version int
+        Name    string
+        SQL     string
+}
+
+// loadMigrations reads the embedded migrations sorted by version
+// File names must look like 0001_description.sql
+func loadMigrations() ([]Migration, error) {
+        entries, err := fs.ReadDir(migrationFiles, "migrations")
+        if err != nil {
+                return nil, fmt.Errorf("failed to read migrations: %w", err)
+        }
+
+        var migrations []Migration
+        seen := make(map[int]string)
+        for _, entry := range entries {
+                if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+                        continue
+                }
+
+                name := strings.TrimSuffix(entry.Name(), ".sql")
+                prefix, _, found := strings.Cut(name, "_")
+                version, err := strconv.Atoi(prefix)
+                if !found || err != nil || version <= 0 {
+                        return nil, fmt.Errorf("invalid migration file name %q (expected 0001_description.sql)", entry.Name())
+                }
+                if other, exists := seen[version]; exists {
+                        return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+                }
+                seen[version] = entry.Name()
+
+                content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+                if err != nil {
+                        return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+                }
+
+                migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(content)})
+        }
+
+        sort.Slice(migrations, func(i, j int) bool {
+                return migrations[i].Version < migrations[j].Version
+        })
+
+        return migrations, nil
+}
+
+// RunMigrations applies pending migrations and records them in schema_migrations
+// Each migration runs in its own transaction; returns the number applied
+func (db *PostgresDB) RunMigrations() (int, error) {
+        migrations, err := loadMigrations()
+        if err != nil {
+                return 0, err
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+        defer cancel()
+
+        conn, err := db.pool.Acquire(ctx)
+        if err != nil {
+                return 0, fmt.Errorf("failed to acquire connection: %w", err)
+        }
+        defer conn.Release()
+
+        // Only one instance migrates at a time
+        if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+                return 0, fmt.Errorf("failed to acquire migration lock: %w", err)
+        }
+        defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+        _, err = conn.Exec(ctx, `
+                CREATE TABLE IF NOT EXISTS schema_migrations (
+                  version INTEGER PRIMARY KEY,
+                  name VARCHAR(255) NOT NULL,
+                  applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                )`)
+        if err != nil {
+                return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+        }
+
+        rows, err := conn.Query(ctx, `SELECT version FROM schema_migrations`)
+        if err != nil {
+                return 0, fmt.Errorf("failed to read applied migrations: %w", err)
+        }
+        applied := make(map[int]bool)
+        for rows.Next() {
+                var version int
+                if err := rows.Scan(&version); err != nil {
+                        rows.Close()
+                        return 0, fmt.Errorf("failed to scan migration version: %w", err)
+                }
+                applied[version] = true
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+                return 0, fmt.Errorf("failed to read applied migrations: %w", err)
+        }
+
+        count := 0
+        for _, m := range migrations {
+                if applied[m.Version] {
+                        continue
+                }
+
+                start := time.Now()
+                db.logger.LogDB("Applying migration %s", m.Name)
+
+                tx, err := conn.Begin(ctx)
+                if err != nil {
+                        return count, fmt.Errorf("failed to begin migration %s: %w", m.Name, err)
+                }
+                if _, err := tx.Exec(ctx, m.SQL); err != nil {
+                        tx.Rollback(ctx)
+                        return count, fmt.Errorf("migration %s failed: %w", m.Name, err)
+                }
+                if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+                        tx.Rollback(ctx)
+                        return count, fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+                }
+                if err := tx.Commit(ctx); err != nil {
+                        return count, fmt.Errorf("failed to commit migration %s: %w", m.Name, err)
+                }
+
+                db.logger.LogDB("Applied migration %s in %v", m.Name, time.Since(start).Round(time.Millisecond))
+                count++
+        }
+
+        return count, nil
+}
@@ -0,0 +1,3 @@
+-- Session binding: bumped on logout-all / password change to invalidate access tokens
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- Responsible-gambling self-limits (GET/PUT /api/auth/limits, POST /api/auth/self-exclusion)
+
+CREATE TABLE IF NOT EXISTS user_limits (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  daily_wager_limit DECIMAL(15, 2),             -- NULL = no limit
+  weekly_wager_limit DECIMAL(15, 2),
+  daily_loss_limit DECIMAL(15, 2),
+  weekly_loss_limit DECIMAL(15, 2),
+  self_excluded_until TIMESTAMP,                -- Betting blocked until this time
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bets_user_id_created_at ON bets(user_id, created_at);
@@ -0,0 +1,3 @@
+-- Nickname change cooldown (POST /api/account/nickname)
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS nickname_changed_at TIMESTAMP;
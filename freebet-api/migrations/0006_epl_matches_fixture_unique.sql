@@ -0,0 +1,45 @@
+-- One row per fixture: merge duplicates created when the odds and scores feeds used
+-- different api_ids, then guard against new ones
+
+-- Per fixture keep the row bets point at, else the one with odds, else the oldest
+CREATE TEMP TABLE fixture_duplicates ON COMMIT DROP AS
+SELECT id, api_id, keeper_id, keeper_api_id
+FROM (
+  SELECT m.id, m.api_id,
+         first_value(m.id) OVER w AS keeper_id,
+         first_value(m.api_id) OVER w AS keeper_api_id,
+         row_number() OVER w AS rank
+  FROM (
+    SELECT m.*, EXISTS (SELECT 1 FROM bets b WHERE b.match_id = m.api_id) AS has_bets
+    FROM epl_matches m
+  ) m
+  WINDOW w AS (
+    PARTITION BY lower(m.home_team), lower(m.away_team), m.commence_time::date
+    ORDER BY m.has_bets DESC, (m.home_odds IS NOT NULL) DESC, m.created_at, m.id
+  )
+) ranked
+WHERE rank > 1;
+
+-- Carry scores and completion over from the duplicates (-1 = no score yet)
+UPDATE epl_matches k
+SET home_score = CASE WHEN COALESCE(k.home_score, -1) = -1 THEN d.home_score ELSE k.home_score END,
+    away_score = CASE WHEN COALESCE(k.away_score, -1) = -1 THEN d.away_score ELSE k.away_score END,
+    completed = k.completed OR d.completed,
+    updated_at = CURRENT_TIMESTAMP
+FROM fixture_duplicates f
+JOIN epl_matches d ON d.id = f.id
+WHERE k.id = f.keeper_id
+  AND COALESCE(d.home_score, -1) <> -1;
+
+-- Bets on a duplicate move to the kept row
+UPDATE bets b
+SET match_id = f.keeper_api_id, updated_at = CURRENT_TIMESTAMP
+FROM fixture_duplicates f
+WHERE b.match_id = f.api_id;
+
+DELETE FROM epl_matches m
+USING fixture_duplicates f
+WHERE m.id = f.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_epl_matches_fixture
+  ON epl_matches (lower(home_team), lower(away_team), (commence_time::date));
@@ -0,0 +1,5 @@
+-- Both teams to score and correct-score markets (bet types btts_yes, btts_no, cs_<home>-<away>)
+
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS btts_yes_odds DECIMAL(10, 2);
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS btts_no_odds DECIMAL(10, 2);
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS correct_score_odds JSONB;
@@ -0,0 +1,29 @@
+-- Leaderboard seasons (GET /api/leaderboard, POST /api/admin/seasons/close)
+
+CREATE TABLE IF NOT EXISTS seasons (
+  id SERIAL PRIMARY KEY,
+  starts_at TIMESTAMP NOT NULL,                 -- Bets placed in [starts_at, ends_at) count
+  ends_at TIMESTAMP NOT NULL,
+  closed_at TIMESTAMP,                          -- NULL while the season is open
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one open season
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seasons_open ON seasons ((closed_at IS NULL)) WHERE closed_at IS NULL;
+
+-- Final standings snapshotted when a season is closed
+CREATE TABLE IF NOT EXISTS season_results (
+  season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+  rank INTEGER NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  nickname VARCHAR(10) NOT NULL,                -- Nickname at closing time
+  bets INTEGER NOT NULL,
+  won_bets INTEGER NOT NULL,
+  settled_bets INTEGER NOT NULL,
+  wagered DECIMAL(15, 2) NOT NULL,
+  profit DECIMAL(15, 2) NOT NULL,               -- Won payouts minus settled stakes
+  prize DECIMAL(15, 2) NOT NULL DEFAULT 0,      -- Credited to the user's balance
+  PRIMARY KEY (season_id, rank)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bets_created_at ON bets(created_at);
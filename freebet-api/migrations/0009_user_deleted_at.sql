@@ -0,0 +1,3 @@
+-- Soft-deleted (disabled) accounts keep their bets; POST /api/admin/users/{id}/disable and /enable
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,13 @@
+-- Admin balance corrections (POST /api/admin/users/{id}/adjust-balance), one row per adjustment
+
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  admin_id UUID REFERENCES admins(id) ON DELETE SET NULL,
+  amount DECIMAL(15, 2) NOT NULL,               -- Signed: credit > 0, debit < 0
+  reason VARCHAR(255) NOT NULL,
+  balance_after DECIMAL(15, 2) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_user_id ON balance_adjustments(user_id);
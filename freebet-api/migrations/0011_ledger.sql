@@ -0,0 +1,18 @@
+-- Per-user balance history (GET /api/account/ledger), one row per change to users.money
+
+CREATE TABLE IF NOT EXISTS ledger (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(20) NOT NULL,                    -- initial_grant, topup, bet_stake, bet_payout, bet_refund, admin_adjustment, season_prize, opening_balance
+  amount DECIMAL(15, 2) NOT NULL,               -- Signed: credit > 0, debit < 0
+  balance_after DECIMAL(15, 2) NOT NULL,
+  reference VARCHAR(255),                       -- bet_id, season id or balance_adjustments id
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_user_id ON ledger(user_id, id);
+
+-- Existing balances become the opening entry so running balances add up from here
+INSERT INTO ledger (user_id, type, amount, balance_after)
+SELECT id, 'opening_balance', money, money FROM users
+WHERE money <> 0 AND NOT EXISTS (SELECT 1 FROM ledger WHERE ledger.user_id = users.id);
@@ -0,0 +1,7 @@
+-- When a bet was settled (won, lost or void); NULL while pending
+
+ALTER TABLE bets ADD COLUMN IF NOT EXISTS settled_at TIMESTAMP;
+
+-- Best available settlement time for bets settled before this column existed
+UPDATE bets SET settled_at = updated_at
+WHERE status IN ('won', 'lost', 'void') AND settled_at IS NULL;
@@ -0,0 +1,7 @@
+-- Guest play (POST /api/auth/guest): accounts without an email until upgraded
+
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_guest BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_active_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_guest_last_active ON users(last_active_at) WHERE is_guest;
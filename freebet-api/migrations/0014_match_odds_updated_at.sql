@@ -0,0 +1,7 @@
+-- When an odds sync last priced the match; bets are refused once older than MAX_ODDS_AGE
+
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS odds_updated_at TIMESTAMP;
+
+-- Best available odds time for matches priced before this column existed
+UPDATE epl_matches SET odds_updated_at = updated_at
+WHERE home_odds IS NOT NULL AND odds_updated_at IS NULL;
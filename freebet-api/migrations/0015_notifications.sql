@@ -0,0 +1,13 @@
+-- In-app inbox (GET /api/notifications): one row per event for a user, e.g. a settled bet
+
+CREATE TABLE IF NOT EXISTS notifications (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(20) NOT NULL,                    -- win, loss, void, topup_available
+  payload JSONB NOT NULL DEFAULT '{}',          -- Type-specific details, e.g. bet_id and payout
+  read_at TIMESTAMP,                            -- NULL while unread
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id, id) WHERE read_at IS NULL;
@@ -0,0 +1,4 @@
+-- Penalty shootout winner of a level score; settles 1X2 when SHOOTOUT_DECIDES_H2H is on
+
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS shootout_winner VARCHAR(4)
+    CHECK (shootout_winner IN ('home', 'away'));
@@ -0,0 +1,3 @@
+-- Bookmaker the stored 1X2 prices came from, for diagnosing odd-looking prices
+
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS odds_source VARCHAR(64);
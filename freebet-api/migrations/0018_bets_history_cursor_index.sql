@@ -0,0 +1,5 @@
+-- Bet history is paged by (created_at, bet_id) cursors; bet_id breaks created_at ties
+-- The new index covers every query the old (user_id, created_at) one served
+
+CREATE INDEX IF NOT EXISTS idx_bets_user_id_created_at_bet_id ON bets(user_id, created_at, bet_id);
+DROP INDEX IF EXISTS idx_bets_user_id_created_at;
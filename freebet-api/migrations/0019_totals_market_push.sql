@@ -0,0 +1,4 @@
+-- Total goals over/under market; a total landing exactly on a whole line settles the bet
+-- as 'push' with the stake refunded (bets.status and notifications.type are plain text)
+
+ALTER TABLE epl_matches ADD COLUMN IF NOT EXISTS totals_odds JSONB;
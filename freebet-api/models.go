@@ -1,7 +1,9 @@
 package main
 
 import (
+        "context"
         "database/sql"
+        "encoding/json"
         "time"
 
         "github.com/golang-jwt/jwt/v5"
@@ -15,12 +17,37 @@ type User struct {
         PasswordHash  sql.NullString `json:"-" db:"password_hash"` // Never expose in JSON (legacy)
         GoogleID      sql.NullString `json:"-" db:"google_id"`      // Google OAuth ID
         PictureURL    sql.NullString `json:"picture_url" db:"picture_url"` // Profile picture URL
-        AuthProvider  string         `json:"auth_provider" db:"auth_provider"` // 'email' or 'google'
+        AuthProvider  string         `json:"auth_provider" db:"auth_provider"` // 'email', 'google' or 'guest'
         Money         float64        `json:"money" db:"money"`
         Topup         int            `json:"topup" db:"topup"`
         LastTopupAt   *time.Time     `json:"last_topup_at,omitempty" db:"last_topup_at"`
+        TokenVersion  int            `json:"-" db:"token_version"`          // Bumped to invalidate access tokens
+        DeletedAt     *time.Time     `json:"-" db:"deleted_at"`             // Set while the account is disabled
         CreatedAt     time.Time      `json:"created_at" db:"created_at"`
         UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+        IsGuest       bool           `json:"is_guest" db:"is_guest"` // No email or credentials until upgraded
+}
+
+// Disabled reports whether an admin has disabled (soft-deleted) the account
+func (u *User) Disabled() bool {
+        return u.DeletedAt != nil
+}
+
+// UserLimits holds a user's responsible-gambling self-limits (nil = no limit)
+type UserLimits struct {
+        UserID            string     `json:"-" db:"user_id"`
+        DailyWagerLimit   *float64   `json:"daily_wager_limit" db:"daily_wager_limit"`
+        WeeklyWagerLimit  *float64   `json:"weekly_wager_limit" db:"weekly_wager_limit"`
+        DailyLossLimit    *float64   `json:"daily_loss_limit" db:"daily_loss_limit"`
+        WeeklyLossLimit   *float64   `json:"weekly_loss_limit" db:"weekly_loss_limit"`
+        SelfExcludedUntil *time.Time `json:"self_excluded_until" db:"self_excluded_until"`
+}
+
+// BetUsage is a user's betting activity since a point in time
+type BetUsage struct {
+        Wagered float64 // Stakes placed, excluding void (refunded) bets
+        NetLoss float64 // Lost stakes minus winnings on settled bets; negative when ahead
+        Pending float64 // Stakes still at risk
 }
 
 // RefreshToken represents a stored refresh token (for logout functionality)
@@ -34,9 +61,10 @@ type RefreshToken struct {
 
 // JWT Claims structures
 type AccessTokenClaims struct {
-        UserID   string `json:"user_id"`
-        Email    string `json:"email"`
-        Nickname string `json:"nickname"`
+        UserID       string `json:"user_id"`
+        Email        string `json:"email"`
+        Nickname     string `json:"nickname"`
+        TokenVersion int    `json:"token_version"`
         jwt.RegisteredClaims
 }
 
@@ -45,6 +73,12 @@ type RefreshTokenClaims struct {
         jwt.RegisteredClaims
 }
 
+type AdminTokenClaims struct {
+        AdminID  string `json:"admin_id"`
+        Username string `json:"username"`
+        jwt.RegisteredClaims
+}
+
 // Google OAuth structures
 type GoogleUser struct {
         ID            string `json:"id"`
@@ -60,10 +94,25 @@ type GoogleUser struct {
 type OAuthState struct {
         State       string    `json:"state"`
         RedirectURL string    `json:"redirect_url"`
+        LinkUserID  string    `json:"link_user_id,omitempty"` // Guest to link the Google account to, instead of logging in
         CreatedAt   time.Time `json:"created_at"`
         ExpiresAt   time.Time `json:"expires_at"`
 }
 
+// OAuthLoginCode holds the tokens issued by the OAuth callback until the client
+// swaps the one-time code for them at POST /api/auth/exchange
+type OAuthLoginCode struct {
+        AccessToken  string
+        RefreshToken string
+        User         *User
+        ExpiresAt    time.Time
+}
+
+// ExchangeCodeRequest is the body of POST /api/auth/exchange
+type ExchangeCodeRequest struct {
+        Code string `json:"code"`
+}
+
 
 // Admin represents an admin user
 type Admin struct {
@@ -76,20 +125,33 @@ type Admin struct {
         CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// AdminSession represents an issued admin token (for revocation)
+type AdminSession struct {
+        ID        string    `json:"id" db:"id"`
+        AdminID   string    `json:"admin_id" db:"admin_id"`
+        Token     string    `json:"token" db:"token"`
+        ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+        CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
 // Bet represents a betting transaction
 type Bet struct {
         BetID        string     `json:"bet_id" db:"bet_id"`
         UserID       string     `json:"user_id" db:"user_id"`
         MatchID      string     `json:"match_id" db:"match_id"`
-        BetType      string     `json:"bet_type" db:"bet_type"` // "home", "draw", "away"
+        BetType      string     `json:"bet_type" db:"bet_type"` // "home", "draw", "away", "btts_yes", "btts_no", "cs_<home>-<away>"
         BetAmount    float64    `json:"bet_amount" db:"bet_amount"`
         Odds         float64    `json:"odds" db:"odds"`
         PotentialWin float64    `json:"potential_win" db:"potential_win"`
-        Status       string     `json:"status" db:"status"` // "pending", "won", "lost"
+        Status       string     `json:"status" db:"status"` // "review" (held for admin approval), "pending", "won", "lost", "push" (stake refunded), "void"
         HomeTeam     string     `json:"home_team" db:"home_team"`
         AwayTeam     string     `json:"away_team" db:"away_team"`
         CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+        SettledAt    *time.Time `json:"settled_at,omitempty" db:"settled_at"` // Set when won, lost or voided
         CommenceTime *time.Time `json:"commence_time,omitempty" db:"commence_time"`
+        HomeScore    *int       `json:"home_score,omitempty" db:"home_score"` // Final score of the match, once completed
+        AwayScore    *int       `json:"away_score,omitempty" db:"away_score"`
+        Result       *string    `json:"result,omitempty" db:"result"` // Match 1X2 result ("home", "draw", "away" or "void"), once settled
 }
 
 // Match represents a football match with odds
@@ -102,11 +164,59 @@ type Match struct {
         HomeOdds    *float64  `json:"home_odds" db:"home_odds"`
         DrawOdds    *float64  `json:"draw_odds" db:"draw_odds"`
         AwayOdds    *float64  `json:"away_odds" db:"away_odds"`
+        BTTSYesOdds *float64  `json:"btts_yes_odds" db:"btts_yes_odds"` // Both teams to score
+        BTTSNoOdds  *float64  `json:"btts_no_odds" db:"btts_no_odds"`
+        CorrectScoreOdds map[string]float64 `json:"correct_score_odds" db:"correct_score_odds"` // Keyed by "home-away" score, e.g. "2-1"
+        TotalsOdds  map[string]float64 `json:"totals_odds" db:"totals_odds"` // Keyed by bet type, e.g. "over_2.5"
         Completed   bool      `json:"completed" db:"completed"`
         HomeScore   *int      `json:"home_score" db:"home_score"`
         AwayScore   *int      `json:"away_score" db:"away_score"`
         Calculated  bool      `json:"calculated" db:"calculated"`
         Result      *string   `json:"result" db:"result"` // "home", "draw", "away"
+        CreatedAt   time.Time `json:"created_at" db:"created_at"`
+        UpdatedAt   time.Time `json:"updated_at" db:"updated_at"` // Last odds, score or settlement change
+        OddsUpdatedAt *time.Time `json:"odds_updated_at" db:"odds_updated_at"` // Last odds sync that priced the match
+        ShootoutWinner *string `json:"shootout_winner" db:"shootout_winner"` // "home" or "away" when a level score went to penalties
+        OddsSource *string `json:"odds_source" db:"odds_source"` // Bookmaker key the stored 1X2 prices came from
+}
+
+// Match list statuses for GET /api/matches?status=
+const (
+        MatchStatusUpcoming = "upcoming" // Not started, complete odds
+        MatchStatusLive     = "live"     // Started, not completed
+        MatchStatusFinished = "finished" // Completed with scores
+)
+
+// Player list orders for GET /api/players?sort=, all descending
+const (
+        PlayerSortBets   = "bets"   // Most bets, then most money (default)
+        PlayerSortProfit = "profit" // Highest net profit
+        PlayerSortROI    = "roi"    // Highest return on settled stakes
+)
+
+// BetPage pages a bet history for GetUserBets, newest first
+// Limit 0 returns the whole history; with After set, Offset is ignored
+type BetPage struct {
+        Limit  int
+        Offset int
+        After  *BetCursor // Only bets older than this one
+}
+
+// BetCursor is the position of a bet in history order (created_at, then bet_id, both descending)
+type BetCursor struct {
+        CreatedAt time.Time
+        BetID     string
+}
+
+// MatchFilter selects and pages matches for ListMatches
+type MatchFilter struct {
+        Status   string
+        From     *time.Time // commence_time at or after
+        To       *time.Time // commence_time before
+        Team     string     // Case-insensitive substring of the home or away team
+        Limit    int
+        Offset   int
+        SortDesc bool // commence_time descending
 }
 
 // API Response DTOs (Data Transfer Objects)
@@ -117,6 +227,7 @@ type RegisterResponse struct {
         Message      string       `json:"message"`
         AccessToken  string       `json:"access_token"`
         RefreshToken string       `json:"refresh_token"`
+        ExpiresIn    int          `json:"expires_in"` // Seconds until the access token expires
         User         UserResponse `json:"user"`
 }
 
@@ -124,6 +235,8 @@ type LoginResponse struct {
         Success      bool         `json:"success"`
         AccessToken  string       `json:"access_token"`
         RefreshToken string       `json:"refresh_token"`
+        ExpiresIn    int          `json:"expires_in,omitempty"` // Seconds until the access token expires
+        ExpiresAt    *time.Time   `json:"expires_at,omitempty"` // GET /api/auth/user: expiry of the presented access token
         User         UserResponse `json:"user"`
 }
 
@@ -131,20 +244,64 @@ type LoginResponse struct {
 type RefreshResponse struct {
         Success     bool   `json:"success"`
         AccessToken string `json:"access_token"`
+        ExpiresIn   int    `json:"expires_in"` // Seconds until the access token expires
 }
 
 type UserResponse struct {
-        ID           string     `json:"id"`
-        Email        string     `json:"email"`
-        Nickname     string     `json:"nickname"`
-        Money        float64    `json:"money"`
-        Topup        int        `json:"topup"`
-        LastTopupAt  *time.Time `json:"last_topup_at,omitempty"`
-        Bets         int        `json:"bets"`
-        WonBets      int        `json:"won_bets"`
-        SettledBets  int        `json:"settled_bets"`
-        AvgOdds      float64    `json:"avg_odds"`
-        AuthProvider string     `json:"auth_provider,omitempty"`
+        ID           string         `json:"id"`
+        Email        string         `json:"email"`
+        Nickname     string         `json:"nickname"`
+        Money        float64        `json:"money"`
+        Topup        int            `json:"topup"`
+        LastTopupAt  *time.Time     `json:"last_topup_at,omitempty"`
+        NextTopupAt  *time.Time     `json:"next_topup_at,omitempty"` // End of the top-up cooldown, while it runs
+        Bets         int            `json:"bets"`
+        WonBets      int            `json:"won_bets"`
+        SettledBets  int            `json:"settled_bets"`
+        PushBets     int            `json:"push_bets"` // Refunded on the line; not counted as settled
+        AvgOdds      float64        `json:"avg_odds"`
+        AuthProvider string         `json:"auth_provider,omitempty"`
+        IsGuest      bool           `json:"is_guest,omitempty"` // Upgrade via POST /api/account/upgrade
+        Limits       *LimitsSummary `json:"limits,omitempty"`
+}
+
+// Guest upgrade responses
+type GuestUpgradeResponse struct {
+        Success     bool         `json:"success"`
+        Message     string       `json:"message"`
+        AccessToken string       `json:"access_token"` // Carries the new email; the refresh token stays valid
+        ExpiresIn   int          `json:"expires_in"`
+        User        UserResponse `json:"user"`
+}
+
+type GuestGoogleLinkResponse struct {
+        Success bool   `json:"success"`
+        AuthURL string `json:"auth_url"` // Open in the browser; the callback links the Google account
+}
+
+// LimitUsage is one self-limit with its usage over the rolling window
+type LimitUsage struct {
+        Limit     float64 `json:"limit"`
+        Used      float64 `json:"used"`
+        Remaining float64 `json:"remaining"`
+}
+
+// LimitsSummary reports a user's self-limits and what is left of them
+type LimitsSummary struct {
+        DailyWager        *LimitUsage `json:"daily_wager,omitempty"`
+        WeeklyWager       *LimitUsage `json:"weekly_wager,omitempty"`
+        DailyLoss         *LimitUsage `json:"daily_loss,omitempty"`
+        WeeklyLoss        *LimitUsage `json:"weekly_loss,omitempty"`
+        SelfExcludedUntil *time.Time  `json:"self_excluded_until,omitempty"`
+        MaxStake          *float64    `json:"max_stake,omitempty"` // Largest bet allowed now; omitted when unlimited
+}
+
+// LimitsResponse is returned by the self-limit endpoints
+type LimitsResponse struct {
+        Success bool          `json:"success"`
+        Message string        `json:"message,omitempty"`
+        Limits  UserLimits    `json:"limits"`
+        Summary LimitsSummary `json:"summary"`
 }
 
 type TopupResponse struct {
@@ -153,6 +310,20 @@ type TopupResponse struct {
         NewBalance float64 `json:"new_balance"`
 }
 
+// TopupStatus is the daily top-up eligibility, as enforced by POST /api/auth/topup
+type TopupStatus struct {
+        Eligible        bool       `json:"eligible"`
+        NextAvailableAt *time.Time `json:"next_available_at"` // End of the cooldown; null when it is not running
+        Streak          int        `json:"streak"`            // Consecutive top-ups, each claimed within a cooldown of becoming available
+        Reason          string     `json:"reason,omitempty"`  // "balance" or "cooldown" when not eligible
+}
+
+// Top-up status response (GET /api/account/topup-status)
+type TopupStatusResponse struct {
+        Success bool `json:"success"`
+        TopupStatus
+}
+
 // Bet responses
 type BetResponse struct {
         Success bool `json:"success"`
@@ -161,6 +332,7 @@ type BetResponse struct {
 
 type BetInfo struct {
         ID           string  `json:"id"`
+        Status       string  `json:"status"` // "pending", or "review" while a large bet awaits admin approval
         Amount       float64 `json:"amount"`
         Odds         float64 `json:"odds"`
         PotentialWin float64 `json:"potential_win"`
@@ -168,8 +340,10 @@ type BetInfo struct {
 }
 
 type BetsResponse struct {
-        Success bool  `json:"success"`
-        Bets    []BetDisplay `json:"bets"`
+        Success    bool         `json:"success"`
+        OddsFormat string       `json:"odds_format,omitempty"` // Set for non-decimal ?oddsFormat
+        Bets       []BetDisplay `json:"bets"`
+        NextCursor string       `json:"next_cursor,omitempty"` // Set while a paged request has older bets
 }
 
 type BetDisplay struct {
@@ -183,13 +357,20 @@ type BetDisplay struct {
         HomeTeam     string    `json:"home_team"`
         AwayTeam     string    `json:"away_team"`
         CreatedAt    time.Time `json:"created_at"`
+        SettledAt    *time.Time `json:"settled_at,omitempty"` // Omitted while pending
         CommenceTime *time.Time `json:"commence_time,omitempty"`
+        HomeScore    *int       `json:"home_score,omitempty"` // Final score, once the match is completed
+        AwayScore    *int       `json:"away_score,omitempty"`
+        Result       *string    `json:"result,omitempty"` // Match 1X2 result once settled; status is the bet's own outcome
+        OddsDisplay  *string    `json:"odds_display,omitempty"` // Odds in the requested ?oddsFormat (non-decimal only)
 }
 
 // Match responses
 type MatchesResponse struct {
-        Success bool           `json:"success"`
-        Matches []MatchDisplay `json:"matches"`
+        Success    bool            `json:"success"`
+        OddsFormat string          `json:"odds_format,omitempty"` // Set for non-decimal ?oddsFormat
+        Matches    []MatchDisplay  `json:"matches"`
+        Pagination *PaginationInfo `json:"pagination,omitempty"` // Only for filtered/paginated requests
 }
 
 type MatchDisplay struct {
@@ -200,6 +381,24 @@ type MatchDisplay struct {
         HomeOdds     *float64  `json:"home_odds"`
         DrawOdds     *float64  `json:"draw_odds"`
         AwayOdds     *float64  `json:"away_odds"`
+        HomeOddsDisplay *string `json:"home_odds_display,omitempty"` // Odds in the requested ?oddsFormat (non-decimal only)
+        DrawOddsDisplay *string `json:"draw_odds_display,omitempty"`
+        AwayOddsDisplay *string `json:"away_odds_display,omitempty"`
+        BTTSYesOdds  *float64  `json:"btts_yes_odds,omitempty"` // Both teams to score, when offered
+        BTTSNoOdds   *float64  `json:"btts_no_odds,omitempty"`
+        CorrectScoreOdds map[string]float64 `json:"correct_score_odds,omitempty"` // Keyed by "home-away" score; bet with bet_type "cs_<score>"
+        TotalsOdds   map[string]float64 `json:"totals_odds,omitempty"` // Keyed by bet type, e.g. "over_2.5"; a total on a whole line pushes
+        BTTSYesOddsDisplay *string `json:"btts_yes_odds_display,omitempty"`
+        BTTSNoOddsDisplay  *string `json:"btts_no_odds_display,omitempty"`
+        CorrectScoreOddsDisplay map[string]string `json:"correct_score_odds_display,omitempty"`
+        TotalsOddsDisplay  map[string]string `json:"totals_odds_display,omitempty"`
+        Completed    *bool     `json:"completed,omitempty"`  // Live/finished listings only
+        Calculated   *bool     `json:"calculated,omitempty"` // Bets on the match have been settled
+        HomeScore    *int      `json:"home_score,omitempty"` // Null until scores are known
+        AwayScore    *int      `json:"away_score,omitempty"`
+        Result       *string   `json:"result,omitempty"` // "home", "draw", "away"
+        UpdatedAt    time.Time `json:"updated_at"` // Last odds, score or settlement change
+        OddsUpdatedAt *time.Time `json:"odds_updated_at,omitempty"` // Last odds sync; bets are refused once older than MAX_ODDS_AGE
 }
 
 // Players responses
@@ -216,12 +415,43 @@ type PlayerDisplay struct {
         Bets         int     `json:"bets"`
         WonBets      int     `json:"won_bets"`
         SettledBets  int     `json:"settled_bets"`
+        PushBets     int     `json:"push_bets"` // Refunded on the line; not settled, so outside win rate and ROI
         AvgOdds      float64 `json:"avg_odds"`
+        Profit       float64 `json:"profit"` // Won payouts minus settled stakes
+        ROI          float64 `json:"roi"`    // Percent profit on settled stakes
         Topup        int     `json:"topup"`
         Created      string  `json:"created"` // ISO string
         Updated      string  `json:"updated"` // ISO string
 }
 
+// Player comparison (GET /api/players/compare?a=&b=)
+type PlayerComparisonResponse struct {
+        Success bool             `json:"success"`
+        A       PlayerComparison `json:"a"`
+        B       PlayerComparison `json:"b"`
+}
+
+type PlayerComparison struct {
+        Nickname    string  `json:"nickname"`
+        Money       float64 `json:"money"`
+        Bets        int     `json:"bets"`
+        WonBets     int     `json:"won_bets"`
+        SettledBets int     `json:"settled_bets"`
+        WinRate     float64 `json:"win_rate"` // Percent of settled bets won
+        AvgOdds     float64 `json:"avg_odds"`
+        ROI         float64 `json:"roi"` // Percent profit on settled stakes
+}
+
+// BettingRecord is one user's aggregated bets, as used by the player comparison
+type BettingRecord struct {
+        Bets        int
+        WonBets     int
+        SettledBets int
+        AvgOdds     float64
+        Staked      float64 // Stakes of settled (won/lost) bets
+        Returned    float64 // Payouts of won bets
+}
+
 type PaginationInfo struct {
         Limit    int  `json:"limit"`
         Offset   int  `json:"offset"`
@@ -229,6 +459,197 @@ type PaginationInfo struct {
         HasMore  bool `json:"has_more"`
 }
 
+// Platform stats (GET /api/stats)
+type StatsResponse struct {
+        Success     bool          `json:"success"`
+        Stats       PlatformStats `json:"stats"`
+        GeneratedAt time.Time     `json:"generated_at"` // When the (cached) stats were computed
+}
+
+// PlatformStats aggregates activity across all users; void bets are not counted
+type PlatformStats struct {
+        TotalUsers      int           `json:"total_users"`
+        TotalBets       int           `json:"total_bets"`
+        TotalWagered    float64       `json:"total_wagered"`
+        BiggestWin      *BiggestWin   `json:"biggest_win"`       // nil until a bet has been won
+        MostPopularTeam *PopularTeam  `json:"most_popular_team"` // nil until someone backs a team
+}
+
+// BiggestWin is the largest payout of a single won bet
+type BiggestWin struct {
+        Nickname string  `json:"nickname"`
+        Amount   float64 `json:"amount"` // Payout (stake x odds)
+        Odds     float64 `json:"odds"`
+        HomeTeam string  `json:"home_team"`
+        AwayTeam string  `json:"away_team"`
+}
+
+// PopularTeam is the team backed to win (home/away bets) most often
+type PopularTeam struct {
+        Team string `json:"team"`
+        Bets int    `json:"bets"`
+}
+
+// Season is a leaderboard period; bets count towards the season they were placed in
+type Season struct {
+        ID       int        `json:"id"`
+        StartsAt time.Time  `json:"starts_at"`
+        EndsAt   time.Time  `json:"ends_at"` // Exclusive; moved up when a season is closed early
+        ClosedAt *time.Time `json:"closed_at,omitempty"`
+}
+
+// SeasonStanding is one player's place in a season, live or from the closing snapshot
+type SeasonStanding struct {
+        Rank        int     `json:"rank"`
+        UserID      string  `json:"-"`
+        Nickname    string  `json:"nickname"`
+        Bets        int     `json:"bets"`
+        WonBets     int     `json:"won_bets"`
+        SettledBets int     `json:"settled_bets"`
+        Wagered     float64 `json:"wagered"`
+        Profit      float64 `json:"profit"` // Won payouts minus settled stakes
+        Prize       float64 `json:"prize,omitempty"`
+}
+
+// Leaderboard response (GET /api/leaderboard?season=)
+type LeaderboardResponse struct {
+        Success    bool             `json:"success"`
+        Season     Season           `json:"season"`
+        Standings  []SeasonStanding `json:"standings"`
+        Pagination PaginationInfo   `json:"pagination"`
+}
+
+// BalanceAdjustment is an audited admin change to a user's balance
+type BalanceAdjustment struct {
+        UserID       string    `json:"user_id" db:"user_id"`
+        AdminID      string    `json:"admin_id" db:"admin_id"`
+        Amount       float64   `json:"amount" db:"amount"`
+        Reason       string    `json:"reason" db:"reason"`
+        BalanceAfter float64   `json:"balance_after" db:"balance_after"`
+        CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// ExposureRow aggregates the pending bets on one outcome (bet type) of a match
+type ExposureRow struct {
+        MatchID      string
+        HomeTeam     string
+        AwayTeam     string
+        CommenceTime *time.Time // nil when the match row is missing
+        OutcomeExposure
+}
+
+// OutcomeExposure is the pending stake and payout on one bet type of a match
+type OutcomeExposure struct {
+        BetType         string  `json:"bet_type"`
+        Bets            int     `json:"bets"`
+        Stake           float64 `json:"stake"`
+        PotentialPayout float64 `json:"potential_payout"`
+}
+
+// MatchExposure is the platform's liability on one match (GET /api/admin/exposure)
+type MatchExposure struct {
+        MatchID          string            `json:"match_id"`
+        HomeTeam         string            `json:"home_team"`
+        AwayTeam         string            `json:"away_team"`
+        CommenceTime     *time.Time        `json:"commence_time,omitempty"`
+        Bets             int               `json:"bets"`
+        Stake            float64           `json:"stake"`
+        PotentialPayout  float64           `json:"potential_payout"`   // Every pending bet winning (upper bound)
+        MaxOutcomePayout float64           `json:"max_outcome_payout"` // Largest payout on a single bet type
+        Outcomes         []OutcomeExposure `json:"outcomes"`           // Largest payout first
+}
+
+// Ledger entry types: every change to users.money is recorded as one of these
+const (
+        LedgerInitialGrant    = "initial_grant"    // Starting balance at registration
+        LedgerTopup           = "topup"            // Daily top-up
+        LedgerBetStake        = "bet_stake"        // Stake debited when a bet is placed; reference = bet_id
+        LedgerBetPayout       = "bet_payout"       // Winning bet settled; reference = bet_id
+        LedgerBetRefund       = "bet_refund"       // Stake returned for a void or pushed bet; reference = bet_id
+        LedgerAdminAdjustment = "admin_adjustment" // Reference = balance_adjustments id
+        LedgerSeasonPrize     = "season_prize"     // Reference = season id
+        LedgerOpeningBalance  = "opening_balance"  // Balance of accounts created before the ledger existed
+)
+
+// LedgerEntry is one signed change to a user's balance
+type LedgerEntry struct {
+        ID           int64     `json:"id" db:"id"`
+        UserID       string    `json:"-" db:"user_id"`
+        Type         string    `json:"type" db:"type"`
+        Amount       float64   `json:"amount" db:"amount"`               // Credit > 0, debit < 0
+        BalanceAfter float64   `json:"balance_after" db:"balance_after"` // Running balance
+        Reference    string    `json:"reference,omitempty" db:"reference"`
+        CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// Ledger response (GET /api/account/ledger), newest entries first
+type LedgerResponse struct {
+        Success    bool           `json:"success"`
+        Entries    []LedgerEntry  `json:"entries"`
+        Pagination PaginationInfo `json:"pagination"`
+}
+
+// Notification types
+const (
+        NotificationWin            = "win"             // Bet settled as won; payload is a BetNotification
+        NotificationLoss           = "loss"            // Bet settled as lost
+        NotificationVoid           = "void"            // Bet voided and the stake refunded
+        NotificationPush           = "push"            // Bet pushed on the line and the stake refunded
+        NotificationTopupAvailable = "topup_available" // Daily top-up can be claimed again
+)
+
+// Notification is one entry in a user's in-app inbox
+type Notification struct {
+        ID        int64           `json:"id" db:"id"`
+        UserID    string          `json:"-" db:"user_id"`
+        Type      string          `json:"type" db:"type"`
+        Payload   json.RawMessage `json:"payload" db:"payload"`
+        ReadAt    *time.Time      `json:"read_at" db:"read_at"` // Null while unread
+        CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// BetNotification is the payload of win, loss and void notifications
+type BetNotification struct {
+        BetID     string  `json:"bet_id"`
+        MatchID   string  `json:"match_id"`
+        BetType   string  `json:"bet_type"`
+        BetAmount float64 `json:"bet_amount"`
+        Odds      float64 `json:"odds"`
+        Payout    float64 `json:"payout"` // Credited amount: winnings, the refunded stake, or 0 for a loss
+        HomeTeam  string  `json:"home_team,omitempty"`
+        AwayTeam  string  `json:"away_team,omitempty"`
+}
+
+// Notifications response (GET /api/notifications), newest first
+type NotificationsResponse struct {
+        Success       bool           `json:"success"`
+        Notifications []Notification `json:"notifications"`
+        Unread        int            `json:"unread"` // Unread notifications in total, for a badge
+        Pagination    PaginationInfo `json:"pagination"`
+}
+
+// AdjustBalanceRequest is the body of POST /api/admin/users/{id}/adjust-balance
+type AdjustBalanceRequest struct {
+        Amount float64 `json:"amount"` // Signed: credit > 0, debit < 0
+        Reason string  `json:"reason"`
+}
+
+// CloseSeasonRequest is the optional body of POST /api/admin/seasons/close
+type CloseSeasonRequest struct {
+        SeasonID    int   `json:"season_id"`    // Guards against closing the wrong season; 0 = current
+        AwardPrizes *bool `json:"award_prizes"` // Credit SEASON_PRIZES to the top players (default true)
+}
+
+// LogLevelRequest is the body of POST /api/admin/log-level
+type LogLevelRequest struct {
+        Level string `json:"level"` // DEBUG, INFO, WARN or ERROR
+}
+
+// MaintenanceRequest is the body of POST /api/admin/maintenance
+type MaintenanceRequest struct {
+        Enabled *bool `json:"enabled"`
+}
+
 // Request DTOs
 type RegisterRequest struct {
         Email        string `json:"email"`
@@ -237,32 +658,108 @@ type RegisterRequest struct {
         AgeConfirmed bool   `json:"age_confirmed"`
 }
 
+type GuestRequest struct {
+        AgeConfirmed bool `json:"age_confirmed"`
+}
+
+type UpgradeGuestRequest struct {
+        Email    string `json:"email"`
+        Password string `json:"password"`
+        Nickname string `json:"nickname"` // Optional; keeps the guest nickname when empty
+}
+
 type LoginRequest struct {
         Identifier string `json:"identifier"` // email or nickname
         Password   string `json:"password"`
 }
 
+type AdminLoginRequest struct {
+        Username string `json:"username"`
+        Password string `json:"password"`
+}
+
 type ChangePasswordRequest struct {
         CurrentPassword string `json:"current_password"`
         NewPassword     string `json:"new_password"`
 }
 
+type ChangeNicknameRequest struct {
+        Nickname string `json:"nickname"`
+}
+
+type ChangeNicknameResponse struct {
+        Success      bool         `json:"success"`
+        AccessToken  string       `json:"access_token,omitempty"` // Reissued so its nickname claim is current
+        User         UserResponse `json:"user"`
+        NextChangeAt *time.Time   `json:"next_change_at,omitempty"`
+}
+
+// SetLimitsRequest replaces the wager/loss limits; null or 0 removes a limit
+type SetLimitsRequest struct {
+        DailyWagerLimit  *float64 `json:"daily_wager_limit"`
+        WeeklyWagerLimit *float64 `json:"weekly_wager_limit"`
+        DailyLossLimit   *float64 `json:"daily_loss_limit"`
+        WeeklyLossLimit  *float64 `json:"weekly_loss_limit"`
+}
+
+// SelfExclusionRequest starts or extends a self-exclusion period
+type SelfExclusionRequest struct {
+        Days int `json:"days"`
+}
+
 type PlaceBetRequest struct {
         MatchID    string  `json:"match_id"`
-        BetType    string  `json:"bet_type"` // "home", "draw", "away"
+        BetType    string  `json:"bet_type"` // "home", "draw", "away", "btts_yes", "btts_no", "cs_<home>-<away>" (e.g. "cs_2-1"), "over_<line>"/"under_<line>" (e.g. "over_2.5")
         BetAmount  float64 `json:"bet_amount"`
-        Odds       float64 `json:"odds"`
+        Odds       float64 `json:"odds"` // Odds the user was shown; must equal the current stored odds
+        AcceptOddsChange bool `json:"accept_odds_change"` // Place at the current odds even if they moved from Odds
         HomeTeam   string  `json:"home_team"`
         AwayTeam   string  `json:"away_team"`
 }
 
+// PlaceBetsBatchRequest is the body of POST /api/bets/batch: independent single bets
+type PlaceBetsBatchRequest struct {
+        Bets []PlaceBetRequest `json:"bets"`
+}
+
+// BatchBetResult is the outcome of one bet of a batch, in request order
+type BatchBetResult struct {
+        Index   int                    `json:"index"`
+        Success bool                   `json:"success"`
+        Bet     *BetInfo               `json:"bet,omitempty"` // new_balance is the balance after this bet
+        Code    string                 `json:"code,omitempty"`
+        Error   string                 `json:"error,omitempty"`
+        Details map[string]interface{} `json:"details,omitempty"` // e.g. current_odds when the odds changed
+}
+
+// BatchBetResponse is the response of POST /api/bets/batch
+type BatchBetResponse struct {
+        Success    bool             `json:"success"`
+        Code       string           `json:"code,omitempty"`
+        Error      string           `json:"error,omitempty"`
+        Results    []BatchBetResult `json:"results"`
+        Placed     int              `json:"placed"`
+        Rejected   int              `json:"rejected"`
+        TotalStake float64          `json:"total_stake"`
+        NewBalance float64          `json:"new_balance"`
+}
+
 // Generic API response
 type APIResponse struct {
         Success bool        `json:"success"`
         Data    interface{} `json:"data,omitempty"`
+        Code    string      `json:"code,omitempty"` // Machine-readable error code (error_codes.go)
         Error   string      `json:"error,omitempty"`
 }
 
+// ValidationErrorResponse reports every invalid request field at once
+type ValidationErrorResponse struct {
+        Success bool              `json:"success"`
+        Code    string            `json:"code"` // VALIDATION_FAILED
+        Error   string            `json:"error"`  // First field error, for clients that show a single message
+        Errors  map[string]string `json:"errors"` // JSON field name -> message
+}
+
 // Health check response
 type HealthResponse struct {
         // Mobile app format (основной формат)
@@ -277,6 +774,38 @@ type HealthResponse struct {
         MatchesCount  int    `json:"matches_count"`
         DatabaseStatus string `json:"database_status"`
         Port          int    `json:"port"`          // Для информации
+        Pool          *PoolStats `json:"pool,omitempty"` // Only on /api/health/detailed with PostgreSQL
+}
+
+// PublicHealthResponse is the minimal unauthenticated GET /api/health response
+type PublicHealthResponse struct {
+        Ok      bool   `json:"ok"`
+        Status  string `json:"status"` // "ok" or "unavailable" (database unreachable, HTTP 503)
+        Version string `json:"version"`
+}
+
+// VersionResponse is the build metadata served by GET /api/version
+type VersionResponse struct {
+        Version   string `json:"version"`    // "dev" unless set with -ldflags
+        Commit    string `json:"commit"`     // Git commit; empty when built outside a checkout
+        BuildTime string `json:"build_time"` // RFC 3339
+        Modified  bool   `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+        GoVersion string `json:"go_version"`
+}
+
+// PoolStats is a snapshot of the database connection pool
+type PoolStats struct {
+        TotalConns        int32 `json:"total_conns"`
+        IdleConns         int32 `json:"idle_conns"`
+        AcquiredConns     int32 `json:"acquired_conns"`
+        MaxConns          int32 `json:"max_conns"`
+        AcquireCount      int64 `json:"acquire_count"`
+        EmptyAcquireCount int64 `json:"empty_acquire_count"` // Acquires that had to wait for a connection
+}
+
+// poolStatsProvider is implemented by databases backed by a connection pool
+type poolStatsProvider interface {
+        PoolStats() *PoolStats
 }
 
 // Root endpoint response
@@ -288,47 +817,96 @@ type RootResponse struct {
 // Database connection interface for dependency injection
 type Database interface {
         // User management
-        GetUserByEmail(email string) (*User, error)
-        GetUserByNickname(nickname string) (*User, error)
-        GetUserByGoogleID(googleID string) (*User, error)
-        GetUserByID(id string) (*User, error)
-        CreateUser(email, passwordHash, nickname string, initialBalance float64) (*User, error)
-        CreateUserWithGoogle(googleID, email, nickname, pictureURL string, initialBalance float64) (*User, error)
-        UpdateUserMoney(userID string, newMoney float64) error
-        IncrementUserTopup(userID string) error
-        GetUserLastTopupTime(userID string) (*time.Time, error)
-        UpdateUserPassword(userID string, newPasswordHash string) error
+        GetUserByEmail(ctx context.Context, email string) (*User, error)
+        GetUserByNickname(ctx context.Context, nickname string) (*User, error)
+        GetUserByEmailOrNickname(ctx context.Context, identifier string) (*User, error)
+        GetUserByGoogleID(ctx context.Context, googleID string) (*User, error)
+        GetUserByID(ctx context.Context, id string) (*User, error)
+        CreateUser(ctx context.Context, email, passwordHash, nickname string, initialBalance float64) (*User, error)
+        CreateUserWithGoogle(ctx context.Context, googleID, email, nickname, pictureURL string, initialBalance float64) (*User, error) // ErrNicknameTaken on a nickname conflict
+        TopupUser(ctx context.Context, userID string, amount float64) (float64, error) // Credits amount, bumps topup/last_topup_at and records the ledger entry; returns the new balance
+        GetUserLastTopupTime(ctx context.Context, userID string) (*time.Time, error)
+        GetTopupTimes(ctx context.Context, userID string, limit int) ([]time.Time, error) // From the ledger, newest first
+        GetUserNicknameChangedAt(ctx context.Context, userID string) (*time.Time, error)
+        UpdateUserNickname(ctx context.Context, userID, nickname string) (*User, error) // ErrNicknameTaken on conflict
+        UpdateUserPicture(ctx context.Context, userID, pictureURL string) error
+        UpdateUserPassword(ctx context.Context, userID string, newPasswordHash string) error
+
+        // Guest accounts
+        CreateGuestUser(ctx context.Context, nickname string, initialBalance float64) (*User, error)           // ErrNicknameTaken on conflict
+        UpgradeGuestUser(ctx context.Context, userID, email, passwordHash, nickname string) (*User, error)     // ErrNotGuest, ErrAccountExists on a taken email/nickname
+        LinkGuestGoogle(ctx context.Context, userID, googleID, email, pictureURL string) (*User, error)        // ErrNotGuest, ErrAccountExists on a taken Google ID/email
+        TouchGuest(ctx context.Context, userID string) error                                                   // Records guest activity; no-op for full accounts
+        DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int, error)                        // Keeps guests with pending bets
 
         // JWT refresh token methods
-        CreateRefreshToken(userID string, token string, expiresAt time.Time) (*RefreshToken, error)
-        GetRefreshTokenByToken(token string) (*RefreshToken, error)
-        DeleteRefreshToken(token string) error
-        DeleteAllUserRefreshTokens(userID string) error // For logout from all devices
-
-        GetUserBets(userID string, playerNickname string) ([]Bet, error)
-        PlaceBet(bet *Bet) (*Bet, error)
-        GetMatchByID(matchID string) (*Match, error)
-        GetMatchByAPIID(apiID string) (*Match, error)
-
-        GetMatches() ([]Match, error)
-        GetPlayers(limit, offset int) ([]PlayerDisplay, error)
-        GetTotalPlayers() (int, error)
-        GetUserStats(userID string) (bets int, wonBets int, settledBets int, avgOdds float64, err error)
-
-        GetDatabaseStats() (map[string]int, error)
+        CreateRefreshToken(ctx context.Context, userID string, token string, expiresAt time.Time) (*RefreshToken, error)
+        GetRefreshTokenByToken(ctx context.Context, token string) (*RefreshToken, error)
+        DeleteRefreshToken(ctx context.Context, token string) error
+        DeleteAllUserRefreshTokens(ctx context.Context, userID string) error // For logout from all devices
+        IncrementUserTokenVersion(ctx context.Context, userID string) error  // Invalidates outstanding access tokens
+        SetUserDisabled(ctx context.Context, userID string, disabled bool) error // Soft delete or restore; bets are kept
+        AdjustUserBalance(ctx context.Context, userID, adminID string, amount float64, reason string) (float64, error) // Records a balance_adjustments row; ErrInsufficientFunds if it would go negative
+        GetLedger(ctx context.Context, userID string, limit, offset int) ([]LedgerEntry, int, error)                   // Newest first, with the total count
+        GetNotifications(ctx context.Context, userID string, unreadOnly bool, limit, offset int) ([]Notification, int, int, error) // Newest first, with the matching and unread counts
+        MarkNotificationRead(ctx context.Context, userID string, notificationID int64) (*Notification, error)                   // ErrNotificationNotFound unless it is the user's
+
+        GetUserBets(ctx context.Context, userID string, playerNickname string, page BetPage) ([]Bet, error) // ErrInvalidBetCursor if page.After names no possible bet
+        PlaceBet(ctx context.Context, bet *Bet) (*Bet, float64, error) // Debits stake atomically, returns new balance
+        PlaceBets(ctx context.Context, bets []*Bet) ([]*Bet, float64, error) // All-or-nothing: debits the total stake and inserts every bet in one transaction
+        GetMatchByID(ctx context.Context, matchID string) (*Match, error)
+        GetMatchByAPIID(ctx context.Context, apiID string) (*Match, error)
+        FindMatchByTeams(ctx context.Context, homeTeam, awayTeam string, commenceTime time.Time, window time.Duration) (*Match, error) // Closest kickoff within window
+
+        GetMatches(ctx context.Context) ([]Match, error)
+        ListMatches(ctx context.Context, filter MatchFilter) ([]Match, int, error) // Page of matches and total count
+        GetPlayers(ctx context.Context, limit, offset int, sort string) ([]PlayerDisplay, error) // sort is a PlayerSort* order
+        GetTotalPlayers(ctx context.Context) (int, error)
+        GetUserStats(ctx context.Context, userID string) (bets int, wonBets int, settledBets int, pushBets int, avgOdds float64, err error) // pushBets are refunded on the line and outside settledBets
+        GetBettingRecords(ctx context.Context, userIDs []string) (map[string]BettingRecord, error) // Users without bets are missing from the map
+        CountPendingBets(ctx context.Context, userID string) (int, error)
+
+        // Responsible-gambling self-limits
+        GetUserLimits(ctx context.Context, userID string) (*UserLimits, error) // Empty limits when none were set
+        SetUserLimits(ctx context.Context, limits *UserLimits) error
+        GetBetUsageSince(ctx context.Context, userID string, since time.Time) (*BetUsage, error)
+
+        GetDatabaseStats(ctx context.Context) (map[string]int, error)
+        GetPlatformStats(ctx context.Context) (*PlatformStats, error)
+        GetExposure(ctx context.Context) ([]ExposureRow, error) // Pending bets grouped by match and bet type
 
         // Admin methods
-        GetAdminByUsername(username string) (*Admin, error)
-        UpdateAdminLastLogin(adminID string) error
+        GetAdminByUsername(ctx context.Context, username string) (*Admin, error)
+        CreateAdmin(ctx context.Context, username, email, passwordHash string) (*Admin, error)
+        GetAdminByID(ctx context.Context, id string) (*Admin, error)
+        UpdateAdminLastLogin(ctx context.Context, adminID string) error
+
+        // Admin session token methods
+        CreateAdminSession(ctx context.Context, adminID string, token string, expiresAt time.Time) (*AdminSession, error)
+        GetAdminSessionByToken(ctx context.Context, token string) (*AdminSession, error)
+        DeleteAdminSession(ctx context.Context, token string) error
+
+        // Season methods
+        GetOpenSeason(ctx context.Context, startsAt, endsAt time.Time) (*Season, error) // Starts a season with these bounds when none is open
+        GetSeason(ctx context.Context, id int) (*Season, error)
+        GetLastClosedSeason(ctx context.Context) (*Season, error)
+        GetSeasonStandings(ctx context.Context, season *Season, limit, offset int) ([]SeasonStanding, int, error) // Live while open, the snapshot once closed
+        CloseSeason(ctx context.Context, seasonID int, endsAt time.Time, prizes []float64, nextEndsAt time.Time) ([]SeasonStanding, *Season, error)
 
         // Match sync methods
-        UpsertMatch(match *Match) (*Match, error)
-        UpdateMatchByAPIID(apiID string, match *Match) (*Match, error)
-        GetCompletedUncalculatedMatches() ([]Match, error)
-        UpdateMatchCalculated(apiID string, result string) error
-        UpdateBetsStatusAndUserMoney(matchAPIID string, result string) error
-
-        Ping() error
+        UpsertMatch(ctx context.Context, match *Match) (*Match, error) // Merges into an existing row for the same fixture; the result keeps that row's api_id
+        UpdateMatchByAPIID(ctx context.Context, apiID string, match *Match) (*Match, error)
+        GetCompletedUncalculatedMatches(ctx context.Context, afterAPIID string, limit int) ([]Match, error) // Ordered by api_id, includes scoreless matches
+        SettleMatch(ctx context.Context, matchAPIID, result string, winningBetTypes, pushBetTypes []string) error // Settles bets and marks the match calculated atomically; ErrMatchAlreadySettled once calculated
+        VoidMatch(ctx context.Context, matchAPIID string) (int, error) // Refunds pending and in-review bets and marks the match void atomically, returns how many; ErrMatchAlreadySettled once calculated
+
+        // Large bets held for admin review (BET_REVIEW_THRESHOLD)
+        ListBetsInReview(ctx context.Context) ([]Bet, error)
+        ApproveBet(ctx context.Context, betID string, closesAt time.Time) (*Bet, error) // Review -> pending; ErrBetNotFound, ErrBetNotInReview, ErrMatchAlreadySettled, ErrMatchStarted once kickoff is not after closesAt
+        RejectBet(ctx context.Context, betID string) (*Bet, error)  // Review -> void with the stake refunded; ErrBetNotFound, ErrBetNotInReview
+        TryLockCalc(ctx context.Context) (unlock func(), err error) // ErrCalcInProgress while another calculation run holds it
+
+        Ping(ctx context.Context) error
         Close() error
 }
 
@@ -0,0 +1,49 @@
+package main
+
+import "math"
+
+// Cents is an amount of virtual currency in minor units
+// Balances and stakes are stored as DECIMAL(15, 2) and travel as JSON numbers, so structs
+// keep float64 fields; every sum or product computed in Go goes through Cents instead, so
+// thousands of debits and credits never drift off whole cents
+type Cents int64
+
+// toCents converts an amount to cents, rounding half away from zero like Postgres NUMERIC
+func toCents(amount float64) Cents {
+        return Cents(math.Round(amount * 100))
+}
+
+// Float64 converts back to the amount used in structs and JSON
+func (c Cents) Float64() float64 {
+        return float64(c) / 100
+}
+
+// roundMoney rounds to cents
+func roundMoney(amount float64) float64 {
+        return toCents(amount).Float64()
+}
+
+// isWholeCents reports whether amount has at most 2 decimal places
+func isWholeCents(amount float64) bool {
+        return roundMoney(amount) == amount
+}
+
+// addMoney adds two amounts in cents; subtract by passing -b
+func addMoney(a, b float64) float64 {
+        return (toCents(a) + toCents(b)).Float64()
+}
+
+// potentialWin is the payout of a winning stake at decimal odds, rounded to cents the
+// same way the potential_win column stores it
+func potentialWin(stake, odds float64) float64 {
+        return Cents(math.Round(float64(toCents(stake)) * odds)).Float64()
+}
+
+// maxStakeForPayout is the largest stake whose potentialWin at odds stays within maxPayout
+func maxStakeForPayout(maxPayout, odds float64) float64 {
+        stake := Cents(math.Floor(float64(toCents(maxPayout)) / odds))
+        for stake > 0 && potentialWin(stake.Float64(), odds) > maxPayout {
+                stake--
+        }
+        return stake.Float64()
+}
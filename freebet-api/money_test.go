@@ -0,0 +1,118 @@
+package main
+
+import (
+        "context"
+        "net/http"
+        "testing"
+)
+
+func TestAddMoneyIsExact(t *testing.T) {
+        // Float64 sums of 0.10 drift: ten thousand of them come to 1000.0000000001588
+        balance := 0.0
+        for i := 0; i < 10000; i++ {
+                balance = addMoney(balance, 0.10)
+        }
+        if balance != 1000 {
+                t.Fatalf("10000 x 0.10 = %v, want 1000", balance)
+        }
+
+        // Debits and credits of awkward amounts come back to the starting balance
+        amounts := []float64{0.01, 0.07, 19.99, 33.33, 0.3, 1234.56}
+        for i := 0; i < 1000; i++ {
+                for _, amount := range amounts {
+                        balance = addMoney(balance, -amount)
+                }
+                for _, amount := range amounts {
+                        balance = addMoney(balance, amount)
+                }
+        }
+        if balance != 1000 {
+                t.Fatalf("balance after matched debits and credits = %v, want 1000", balance)
+        }
+}
+
+func TestPotentialWin(t *testing.T) {
+        tests := []struct {
+                stake, odds, want float64
+        }{
+                {10, 2.5, 25},
+                {33.33, 3, 99.99},
+                {0.10, 1.15, 0.12}, // 11.5 cents rounds half away from zero
+                {19.99, 1.91, 38.18},
+                {1, 1.01, 1.01},
+        }
+        for _, tt := range tests {
+                if got := potentialWin(tt.stake, tt.odds); got != tt.want {
+                        t.Errorf("potentialWin(%v, %v) = %v, want %v", tt.stake, tt.odds, got, tt.want)
+                }
+        }
+}
+
+func TestMaxStakeForPayout(t *testing.T) {
+        for _, tt := range []struct {
+                maxPayout, odds float64
+        }{
+                {1000, 3}, {1000, 1.91}, {50000, 7.77}, {0.05, 2.5},
+        } {
+                stake := maxStakeForPayout(tt.maxPayout, tt.odds)
+                if !isWholeCents(stake) {
+                        t.Errorf("maxStakeForPayout(%v, %v) = %v, not whole cents", tt.maxPayout, tt.odds, stake)
+                }
+                if potentialWin(stake, tt.odds) > tt.maxPayout {
+                        t.Errorf("maxStakeForPayout(%v, %v) = %v pays over the maximum", tt.maxPayout, tt.odds, stake)
+                }
+                if next := addMoney(stake, 0.01); potentialWin(next, tt.odds) <= tt.maxPayout {
+                        t.Errorf("maxStakeForPayout(%v, %v) = %v, but %v also fits", tt.maxPayout, tt.odds, stake, next)
+                }
+        }
+}
+
+func TestIsWholeCents(t *testing.T) {
+        for amount, want := range map[float64]bool{
+                1:      true,
+                1.23:   true,
+                0.3:    true,
+                1.234:  false,
+                0.005:  false,
+                100.10: true,
+        } {
+                if got := isWholeCents(amount); got != want {
+                        t.Errorf("isWholeCents(%v) = %v, want %v", amount, got, want)
+                }
+        }
+}
+
+func TestBalanceStaysExactAcrossManyBets(t *testing.T) {
+        s := newTestServer(t)
+        s.config.MaxPendingBetsPerUser = 0
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 1.1, 5.0, 9.0)
+        s.addMatch("match-2", 1.1, 5.0, 9.0)
+
+        // 1.10 at 1.10 pays 1.21; 1.30 on the away side is lost
+        const bets = 100
+        for i := 0; i < bets; i++ {
+                decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 1.10, 1.1), http.StatusOK, nil)
+                decodeResponse(t, s.placeBet(registered.AccessToken, "match-2", "away", 1.30, 9.0), http.StatusOK, nil)
+        }
+        user, err := s.db.GetUserByID(context.Background(), registered.User.ID)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if want := s.config.InitialBalance - 240; user.Money != want {
+                t.Fatalf("money after staking = %v, want %v", user.Money, want)
+        }
+
+        s.finishMatch("match-1", 1, 0)
+        s.finishMatch("match-2", 1, 0)
+        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                t.Fatal(err)
+        }
+        user, err = s.db.GetUserByID(context.Background(), registered.User.ID)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if want := s.config.InitialBalance - 240 + 121; user.Money != want {
+                t.Fatalf("money after settlement = %v, want exactly %v", user.Money, want)
+        }
+}
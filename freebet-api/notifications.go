@@ -0,0 +1,84 @@
+package main
+
+import (
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gorilla/mux"
+)
+
+// GetNotificationsHandler handles GET /api/notifications?unread=true&limit=&offset=
+// Lists the user's in-app notifications, newest first
+func (h *Handler) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        unreadOnly := false
+        if unreadParam := r.URL.Query().Get("unread"); unreadParam != "" {
+                parsed, err := strconv.ParseBool(unreadParam)
+                if err != nil {
+                        h.writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, "unread must be true or false")
+                        return
+                }
+                unreadOnly = parsed
+        }
+
+        limit, offset := h.pageParams(r)
+        notifications, total, unread, err := h.db.GetNotifications(r.Context(), user.ID, unreadOnly, limit, offset)
+        if err != nil {
+                h.logger.LogError("Failed to get notifications: %s", err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get notifications")
+                return
+        }
+        if notifications == nil {
+                notifications = []Notification{}
+        }
+
+        h.writeJSON(w, http.StatusOK, NotificationsResponse{
+                Success:       true,
+                Notifications: notifications,
+                Unread:        unread,
+                Pagination: PaginationInfo{
+                        Limit:   limit,
+                        Offset:  offset,
+                        Total:   total,
+                        HasMore: offset+limit < total,
+                },
+        })
+}
+
+// MarkNotificationReadHandler handles POST /api/notifications/{id}/read
+// Marking an already read notification again is a no-op
+func (h *Handler) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        notificationID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+        if err != nil || notificationID <= 0 {
+                h.writeError(w, r, http.StatusNotFound, CodeNotFound, "Notification not found")
+                return
+        }
+
+        notification, err := h.db.MarkNotificationRead(r.Context(), user.ID, notificationID)
+        if errors.Is(err, ErrNotificationNotFound) {
+                h.writeError(w, r, http.StatusNotFound, CodeNotFound, "Notification not found")
+                return
+        }
+        if err != nil {
+                h.logger.LogError("Failed to mark notification %d read: %s", notificationID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update notification")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, map[string]interface{}{
+                "success":      true,
+                "notification": notification,
+        })
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "html"
+        "io"
+        "net/http"
+        "strings"
+        "time"
+)
+
+// SettledMatch is one settled or voided match in a calculation run
+type SettledMatch struct {
+        HomeTeam string `json:"home_team"`
+        AwayTeam string `json:"away_team"`
+        Score    string `json:"score"`  // "2-1", or "void"
+        Result   string `json:"result"` // "home", "away", "draw" or "void"
+        Refunded *int   `json:"refunded,omitempty"` // Bets refunded; voided matches only
+}
+
+// Notifier announces settlement runs to an external channel
+type Notifier interface {
+        Name() string
+        NotifySettlement(ctx context.Context, matches []SettledMatch) error
+}
+
+// MultiNotifier fans a settlement out to every configured notifier
+// One failing notifier does not stop the others; their errors are joined
+type MultiNotifier []Notifier
+
+// NewNotifiers builds the notifiers enabled in config (Telegram, Discord); nil if none are
+func NewNotifiers(config *Config, logger *Logger) Notifier {
+        var notifiers MultiNotifier
+        if config.TelegramBotToken != "" && config.TelegramChannelID != "" {
+                notifiers = append(notifiers, &TelegramNotifier{
+                        client:    &http.Client{Timeout: config.TelegramTimeout},
+                        logger:    logger,
+                        botToken:  config.TelegramBotToken,
+                        channelID: config.TelegramChannelID,
+                })
+        }
+        if config.DiscordWebhookURL != "" {
+                notifiers = append(notifiers, &DiscordNotifier{
+                        client:     &http.Client{Timeout: config.DiscordTimeout},
+                        logger:     logger,
+                        webhookURL: config.DiscordWebhookURL,
+                })
+        }
+        if len(notifiers) == 0 {
+                return nil
+        }
+        return notifiers
+}
+
+// Name lists the wrapped notifiers
+func (m MultiNotifier) Name() string {
+        names := make([]string, len(m))
+        for i, notifier := range m {
+                names[i] = notifier.Name()
+        }
+        return strings.Join(names, ", ")
+}
+
+// NotifySettlement sends to every notifier, even after one fails
+func (m MultiNotifier) NotifySettlement(ctx context.Context, matches []SettledMatch) error {
+        var errs []error
+        for _, notifier := range m {
+                if err := notifier.NotifySettlement(ctx, matches); err != nil {
+                        errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
+                }
+        }
+        return errors.Join(errs...)
+}
+
+// settlementTime is the run time shown in settlement messages
+func settlementTime() string {
+        return time.Now().Format("02/01/2006 15:04:05")
+}
+
+// TelegramNotifier posts settlement summaries to a Telegram channel
+type TelegramNotifier struct {
+        client    *http.Client // Bounded by TelegramTimeout
+        logger    *Logger
+        botToken  string
+        channelID string
+}
+
+// Name identifies the notifier in logs
+func (t *TelegramNotifier) Name() string {
+        return "telegram"
+}
+
+// NotifySettlement sends the HTML summary to the channel
+func (t *TelegramNotifier) NotifySettlement(ctx context.Context, matches []SettledMatch) error {
+        t.logger.LogSystem("TELEGRAM", "Sending notification to channel %s with %d matches", t.channelID, len(matches))
+
+        message := fmt.Sprintf("🎯 <b>Matches Calculated!</b>\n\n📅 %s\n\n⚽ <b>Match Results:</b>\n", settlementTime())
+        for i, match := range matches {
+                message += fmt.Sprintf("%d. %s %s %s\n", i+1, html.EscapeString(match.HomeTeam), match.Score, html.EscapeString(match.AwayTeam))
+        }
+        message += "\n💰 <i>Dear clients, bets have been calculated automatically!</i>"
+
+        if err := sendTelegramMessage(ctx, t.client, t.logger, t.botToken, t.channelID, message); err != nil {
+                return err
+        }
+
+        t.logger.LogSystem("TELEGRAM", "Notification sent to channel %s", t.channelID)
+        return nil
+}
+
+// discordMessageLimit is Discord's maximum message content length
+const discordMessageLimit = 2000
+
+// DiscordNotifier posts settlement summaries to a Discord channel webhook
+type DiscordNotifier struct {
+        client     *http.Client // Bounded by DiscordTimeout
+        logger     *Logger
+        webhookURL string
+}
+
+// Name identifies the notifier in logs
+func (d *DiscordNotifier) Name() string {
+        return "discord"
+}
+
+// NotifySettlement sends the Markdown summary to the webhook
+func (d *DiscordNotifier) NotifySettlement(ctx context.Context, matches []SettledMatch) error {
+        d.logger.LogSystem("DISCORD", "Sending notification with %d matches", len(matches))
+
+        var message strings.Builder
+        fmt.Fprintf(&message, "🎯 **Matches Calculated!**\n\n📅 %s\n\n⚽ **Match Results:**\n", settlementTime())
+        for i, match := range matches {
+                fmt.Fprintf(&message, "%d. %s %s %s\n", i+1, match.HomeTeam, match.Score, match.AwayTeam)
+        }
+        message.WriteString("\n💰 *Dear clients, bets have been calculated automatically!*")
+
+        content := message.String()
+        if runes := []rune(content); len(runes) > discordMessageLimit {
+                content = string(runes[:discordMessageLimit-1]) + "…"
+        }
+
+        jsonData, err := json.Marshal(map[string]interface{}{
+                "content":          content,
+                "allowed_mentions": map[string]interface{}{"parse": []string{}}, // Team names never ping anyone
+        })
+        if err != nil {
+                return fmt.Errorf("failed to marshal payload: %w", err)
+        }
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhookURL, bytes.NewBuffer(jsonData))
+        if err != nil {
+                return fmt.Errorf("failed to create request: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+
+        resp, err := d.client.Do(req)
+        if err != nil {
+                // The webhook URL embeds its token; keep it out of the error
+                return fmt.Errorf("failed to send request: %w", errors.Unwrap(err))
+        }
+        defer resp.Body.Close()
+
+        // 204 by default, 200 with ?wait=true
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                body, _ := io.ReadAll(resp.Body)
+                return fmt.Errorf("Discord API returned status %d: %s", resp.StatusCode, string(body))
+        }
+
+        d.logger.LogSystem("DISCORD", "Notification sent")
+        return nil
+}
@@ -5,10 +5,16 @@ import (
         "crypto/rand"
         "encoding/base64"
         "encoding/json"
+        "errors"
         "fmt"
+        "math/big"
         "net/http"
+        "net/url"
+        "strconv"
         "strings"
+        "sync"
         "time"
+        "unicode/utf8"
 
 	"golang.org/x/oauth2"
 )
@@ -16,8 +22,18 @@ import (
 // OAuth state storage (in production, use Redis or database)
 var oauthStates = make(map[string]*OAuthState)
 
+// One-time login codes handed to the redirect URL instead of the tokens themselves
+var (
+        oauthLoginCodes   = make(map[string]*OAuthLoginCode)
+        oauthLoginCodesMu sync.Mutex
+)
+
+// oauthLoginCodeTTL bounds how long a redirected client has to exchange its code
+const oauthLoginCodeTTL = time.Minute
+
 // GenerateOAuthState generates a random state parameter for OAuth
-func generateOAuthState(redirectURL string) (string, error) {
+// linkUserID is set when a guest is linking a Google account instead of logging in
+func generateOAuthState(redirectURL, linkUserID string, config *Config) (string, error) {
         // Generate random bytes
         bytes := make([]byte, 32)
         if _, err := rand.Read(bytes); err != nil {
@@ -28,25 +44,123 @@ func generateOAuthState(redirectURL string) (string, error) {
         state := base64.URLEncoding.EncodeToString(bytes)
 
         // Store state with expiration
+        now := config.now()
         oauthStates[state] = &OAuthState{
                 State:       state,
                 RedirectURL: redirectURL,
-                CreatedAt:   time.Now(),
-                ExpiresAt:   time.Now().Add(10 * time.Minute), // 10 minutes
+                LinkUserID:  linkUserID,
+                CreatedAt:   now,
+                ExpiresAt:   now.Add(10 * time.Minute), // 10 minutes
         }
 
         return state, nil
 }
 
+// sanitizeOAuthRedirectURL returns the post-login redirect URL, or "" when it is not allowed
+// Same-site paths and http(s) URLs on an allowed host (or its subdomains) are allowed
+func sanitizeOAuthRedirectURL(redirectURL string, allowedHosts []string) string {
+        if redirectURL == "" {
+                return ""
+        }
+        parsedURL, err := url.Parse(redirectURL)
+        if err != nil {
+                return ""
+        }
+        if !parsedURL.IsAbs() {
+                // "//host" and "/\host" are treated as other sites by browsers
+                if parsedURL.Host != "" || !strings.HasPrefix(redirectURL, "/") ||
+                        strings.HasPrefix(redirectURL, "//") || strings.HasPrefix(redirectURL, "/\\") {
+                        return ""
+                }
+                return redirectURL
+        }
+        if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+                return ""
+        }
+        if !redirectHostAllowed(parsedURL.Hostname(), allowedHosts) {
+                return ""
+        }
+        return redirectURL
+}
+
+// redirectHostAllowed matches host against the allowlist: the exact host or a subdomain of it
+// "freebet.guru" allows "app.freebet.guru" but not "evil-freebet.guru" or "freebet.guru.attacker.com"
+func redirectHostAllowed(host string, allowedHosts []string) bool {
+        host = strings.TrimSuffix(strings.ToLower(host), ".")
+        if host == "" {
+                return false
+        }
+        for _, allowed := range allowedHosts {
+                allowed = strings.TrimPrefix(strings.ToLower(allowed), ".")
+                if host == allowed || strings.HasSuffix(host, "."+allowed) {
+                        return true
+                }
+        }
+        return false
+}
+
+// issueOAuthLoginCode stores the issued tokens under a random one-time code
+func issueOAuthLoginCode(accessToken, refreshToken string, user *User, config *Config) (string, error) {
+        bytes := make([]byte, 32)
+        if _, err := rand.Read(bytes); err != nil {
+                return "", err
+        }
+        code := base64.RawURLEncoding.EncodeToString(bytes)
+
+        now := config.now()
+        oauthLoginCodesMu.Lock()
+        defer oauthLoginCodesMu.Unlock()
+        // Drop codes that were never exchanged
+        for key, entry := range oauthLoginCodes {
+                if now.After(entry.ExpiresAt) {
+                        delete(oauthLoginCodes, key)
+                }
+        }
+        oauthLoginCodes[code] = &OAuthLoginCode{
+                AccessToken:  accessToken,
+                RefreshToken: refreshToken,
+                User:         user,
+                ExpiresAt:    now.Add(oauthLoginCodeTTL),
+        }
+        return code, nil
+}
+
+// redeemOAuthLoginCode returns the tokens for a code and invalidates it; false if unknown or expired
+func redeemOAuthLoginCode(code string, config *Config) (*OAuthLoginCode, bool) {
+        oauthLoginCodesMu.Lock()
+        defer oauthLoginCodesMu.Unlock()
+        entry, exists := oauthLoginCodes[code]
+        if !exists {
+                return nil, false
+        }
+        delete(oauthLoginCodes, code)
+        if config.now().After(entry.ExpiresAt) {
+                return nil, false
+        }
+        return entry, true
+}
+
+// oauthRedirectWithCode appends the login code to the post-login redirect URL
+func oauthRedirectWithCode(redirectURL, code string) (string, error) {
+        parsedURL, err := url.Parse(redirectURL)
+        if err != nil {
+                return "", err
+        }
+        query := parsedURL.Query()
+        query.Set("code", code)
+        parsedURL.RawQuery = query.Encode()
+        return parsedURL.String(), nil
+}
+
 // ValidateOAuthState validates the OAuth state parameter
-func validateOAuthState(state string) (*OAuthState, bool) {
+func validateOAuthState(state string, config *Config) (*OAuthState, bool) {
         oauthState, exists := oauthStates[state]
         if !exists {
                 return nil, false
         }
 
         // Check if expired
-        if time.Now().After(oauthState.ExpiresAt) {
+        if config.now().After(oauthState.ExpiresAt) {
                 delete(oauthStates, state)
                 return nil, false
         }
@@ -71,15 +185,26 @@ func getGoogleOAuthConfig(config *Config) *oauth2.Config {
         }
 }
 
+// googleOAuthContext makes the oauth2 package use a client bounded by GoogleOAuthTimeout
+func googleOAuthContext(ctx context.Context, config *Config) context.Context {
+        return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: config.GoogleOAuthTimeout})
+}
+
 // GetGoogleUserInfo fetches user information from Google
-func getGoogleUserInfo(token *oauth2.Token, config *Config) (*GoogleUser, error) {
+func getGoogleUserInfo(ctx context.Context, token *oauth2.Token, config *Config) (*GoogleUser, error) {
         oauthConfig := getGoogleOAuthConfig(config)
 
         // Create HTTP client with the token
-        client := oauthConfig.Client(context.Background(), token)
+        client := oauthConfig.Client(ctx, token)
+        client.Timeout = config.GoogleOAuthTimeout
 
         // Fetch user info from Google
-        resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.googleapis.com/oauth2/v2/userinfo", nil)
+        if err != nil {
+                return nil, fmt.Errorf("failed to create user info request: %w", err)
+        }
+
+        resp, err := client.Do(req)
         if err != nil {
                 return nil, fmt.Errorf("failed to fetch user info: %w", err)
         }
@@ -116,9 +241,59 @@ func generateNicknameFromGoogleEmail(email string) string {
         if len(nickname) < 3 {
                 nickname = nickname + "user"
         }
-        if len(nickname) > 10 {
-                nickname = nickname[:10]
+        return truncateNickname(nickname, 10)
+}
+
+// googleNicknameAttempts bounds the search for a free nickname for a new Google user
+const googleNicknameAttempts = 10
+
+// truncateNickname cuts nickname to at most max bytes without splitting a UTF-8 character
+func truncateNickname(nickname string, max int) string {
+        if len(nickname) <= max {
+                return nickname
         }
+        for max > 0 && !utf8.RuneStart(nickname[max]) {
+                max--
+        }
+        return nickname[:max]
+}
 
-        return nickname
+// nicknameWithRandomSuffix appends a random number to base, shortening base so the result
+// stays within 10 bytes; base is at least 3 bytes, so the result is never shorter than that
+func nicknameWithRandomSuffix(base string) (string, error) {
+        n, err := rand.Int(rand.Reader, big.NewInt(10000))
+        if err != nil {
+                return "", err
+        }
+        suffix := strconv.FormatInt(n.Int64(), 10)
+        return truncateNickname(base, 10-len(suffix)) + suffix, nil
+}
+
+// createGoogleUser creates the account for a new Google user under base or, once that is
+// taken, base with a random suffix; a nickname claimed by a concurrent signup between the
+// check and the insert is retried the same way
+func (h *Handler) createGoogleUser(ctx context.Context, googleUser *GoogleUser, base string) (*User, error) {
+        nickname := base
+        for attempt := 1; ; attempt++ {
+                _, err := h.db.GetUserByNickname(ctx, nickname)
+                if errors.Is(err, ErrUserNotFound) {
+                        user, err := h.db.CreateUserWithGoogle(ctx, googleUser.ID, googleUser.Email, nickname, googleUser.Picture, h.config.InitialBalance)
+                        if err == nil {
+                                h.userCreated(user, signupGoogle)
+                        }
+                        if !errors.Is(err, ErrNicknameTaken) {
+                                return user, err
+                        }
+                } else if err != nil {
+                        return nil, err
+                }
+
+                if attempt == googleNicknameAttempts {
+                        return nil, fmt.Errorf("no free nickname for %q after %d attempts", base, attempt)
+                }
+                if nickname, err = nicknameWithRandomSuffix(base); err != nil {
+                        return nil, err
+                }
+                h.logger.LogAuth("Nickname taken, trying %s", nickname)
+        }
 }
\ No newline at end of file
@@ -0,0 +1,167 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "io"
+        "strings"
+        "testing"
+        "unicode/utf8"
+)
+
+func TestSanitizeOAuthRedirectURL(t *testing.T) {
+        allowed := []string{"localhost", "127.0.0.1", "freebet.guru"}
+
+        tests := []struct {
+                redirectURL string
+                want        string
+        }{
+                {"", ""},
+                {"/dashboard", "/dashboard"},
+                {"https://freebet.guru/dashboard", "https://freebet.guru/dashboard"},
+                {"https://app.freebet.guru/", "https://app.freebet.guru/"},
+                {"https://FREEBET.GURU./", "https://FREEBET.GURU./"},
+                {"http://localhost:3000/auth", "http://localhost:3000/auth"},
+                {"http://127.0.0.1:5173/", "http://127.0.0.1:5173/"},
+
+                // Spoofing attempts
+                {"https://evil-freebet.guru/", ""},
+                {"https://freebet.guru.attacker.com/", ""},
+                {"https://attacker.com/freebet.guru", ""},
+                {"https://attacker.com/?next=https://freebet.guru", ""},
+                {"https://freebet.guru@attacker.com/", ""},
+                {"https://localhost.attacker.com/", ""},
+                {"//attacker.com/", ""},
+                {"/\\attacker.com/", ""},
+                {"javascript:alert(1)", ""},
+                {"ftp://freebet.guru/", ""},
+                {"dashboard", ""},
+        }
+        for _, tt := range tests {
+                if got := sanitizeOAuthRedirectURL(tt.redirectURL, allowed); got != tt.want {
+                        t.Errorf("sanitizeOAuthRedirectURL(%q) = %q, want %q", tt.redirectURL, got, tt.want)
+                }
+        }
+}
+
+func TestRedirectHostAllowedUsesConfiguredHosts(t *testing.T) {
+        if redirectHostAllowed("freebet.guru", []string{"playfree.bet"}) {
+                t.Error("freebet.guru allowed by a list without it")
+        }
+        if !redirectHostAllowed("app.playfree.bet", []string{".playfree.bet"}) {
+                t.Error("subdomain of a leading-dot entry not allowed")
+        }
+        if redirectHostAllowed("", []string{""}) {
+                t.Error("empty host allowed")
+        }
+}
+
+func TestGenerateNicknameFromGoogleEmail(t *testing.T) {
+        for email, want := range map[string]string{
+                "alice@gmail.com":             "alice",
+                "John.Smith@gmail.com":        "johnsmith",
+                "averyveryverylongname@x.com": "averyveryv",
+                "al@gmail.com":                "aluser",
+                "a_b-c@gmail.com":             "abc",
+                "._@gmail.com":                "user",
+        } {
+                if got := generateNicknameFromGoogleEmail(email); got != want {
+                        t.Errorf("generateNicknameFromGoogleEmail(%q) = %q, want %q", email, got, want)
+                }
+        }
+}
+
+func TestNicknameWithRandomSuffix(t *testing.T) {
+        for _, base := range []string{"abc", "alice", "johnsmith", "averyveryv", "zoëzoëzoë"} {
+                for i := 0; i < 100; i++ {
+                        nickname, err := nicknameWithRandomSuffix(base)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if len(nickname) < 3 || len(nickname) > 10 || !utf8.ValidString(nickname) {
+                                t.Fatalf("nicknameWithRandomSuffix(%q) = %q, want 3-10 bytes of valid UTF-8", base, nickname)
+                        }
+                        if err := validateNickname(nickname); err != nil {
+                                t.Fatalf("nicknameWithRandomSuffix(%q) = %q: %v", base, nickname, err)
+                        }
+                }
+        }
+}
+
+// nicknameLookupDB overrides nickname lookups on top of a MemoryDB
+type nicknameLookupDB struct {
+        *MemoryDB
+        taken func(nickname string) bool
+}
+
+func (db *nicknameLookupDB) GetUserByNickname(ctx context.Context, nickname string) (*User, error) {
+        if db.taken(nickname) {
+                return &User{Nickname: nickname}, nil
+        }
+        return nil, ErrUserNotFound
+}
+
+// takenFirst reports the first n nicknames looked up as taken
+func takenFirst(n int) func(string) bool {
+        return func(string) bool {
+                n--
+                return n >= 0
+        }
+}
+
+func TestCreateGoogleUserNicknameCollisions(t *testing.T) {
+        s := newTestServer(t)
+        logger := NewLogger("ERROR", io.Discard)
+        ctx := context.Background()
+        for _, nickname := range []string{"alice", "averyveryv"} {
+                if _, err := s.db.CreateUserWithGoogle(ctx, "existing-"+nickname, nickname+"@example.com", nickname, "", 0); err != nil {
+                        t.Fatal(err)
+                }
+        }
+
+        tests := []struct {
+                name  string
+                base  string
+                db    Database
+                fresh bool // Expect the base itself
+        }{
+                {"free base", "bob", s.db, true},
+                {"taken base", "alice", s.db, false},
+                {"taken full-length base", "averyveryv", s.db, false},
+                // The lookup misses a nickname a concurrent signup just claimed; the insert catches it
+                {"claimed between check and insert", "alice", &nicknameLookupDB{MemoryDB: s.db, taken: func(string) bool { return false }}, false},
+                {"base and several suffixes taken", "carol", &nicknameLookupDB{MemoryDB: s.db, taken: takenFirst(5)}, false},
+        }
+        for i, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        h := NewHandler(tt.db, s.config, logger, s.sync)
+                        user, err := h.createGoogleUser(ctx, &GoogleUser{ID: fmt.Sprintf("google-%d", i), Email: fmt.Sprintf("user%d@example.com", i)}, tt.base)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if tt.fresh != (user.Nickname == tt.base) {
+                                t.Errorf("nickname = %q for base %q", user.Nickname, tt.base)
+                        }
+                        if err := validateNickname(user.Nickname); err != nil {
+                                t.Errorf("nickname %q: %v", user.Nickname, err)
+                        }
+                        if !strings.HasPrefix(user.Nickname, tt.base[:3]) {
+                                t.Errorf("nickname %q does not start from base %q", user.Nickname, tt.base)
+                        }
+                })
+        }
+}
+
+func TestCreateGoogleUserGivesUpWhenEveryNicknameIsTaken(t *testing.T) {
+        s := newTestServer(t)
+        lookups := 0
+        db := &nicknameLookupDB{MemoryDB: s.db, taken: func(string) bool { lookups++; return true }}
+        h := NewHandler(db, s.config, NewLogger("ERROR", io.Discard), s.sync)
+
+        if _, err := h.createGoogleUser(context.Background(), &GoogleUser{ID: "google-1", Email: "alice@example.com"}, "alice"); err == nil {
+                t.Fatal("createGoogleUser succeeded with every nickname taken")
+        }
+        if lookups != googleNicknameAttempts {
+                t.Fatalf("%d nicknames tried, want %d", lookups, googleNicknameAttempts)
+        }
+}
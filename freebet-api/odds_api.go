@@ -2,34 +2,46 @@ package main
 
 import (
         "bytes"
+        "context"
         "encoding/json"
         "fmt"
         "io"
         "net/http"
         "net/url"
         "strconv"
+        "strings"
         "time"
 )
 
 // OddsAPIEvent represents an event from Odds API
 type OddsAPIEvent struct {
-        ID           string    `json:"id"`
-        SportKey     string    `json:"sport_key"`
-        CommenceTime time.Time `json:"commence_time"`
-        HomeTeam     string    `json:"home_team"`
-        AwayTeam     string    `json:"away_team"`
-        Bookmakers   []struct {
-                Key         string    `json:"key"`
-                Title       string    `json:"title"`
-                LastUpdate  time.Time `json:"last_update"`
-                Markets     []struct {
-                        Key      string `json:"key"`
-                        Outcomes []struct {
-                                Name  string  `json:"name"`
-                                Price float64 `json:"price"`
-                        } `json:"outcomes"`
-                } `json:"markets"`
-        } `json:"bookmakers"`
+        ID           string             `json:"id"`
+        SportKey     string             `json:"sport_key"`
+        CommenceTime time.Time          `json:"commence_time"`
+        HomeTeam     string             `json:"home_team"`
+        AwayTeam     string             `json:"away_team"`
+        Bookmakers   []OddsAPIBookmaker `json:"bookmakers"`
+}
+
+// OddsAPIBookmaker is one bookmaker's markets for an event
+type OddsAPIBookmaker struct {
+        Key        string          `json:"key"`
+        Title      string          `json:"title"`
+        LastUpdate time.Time       `json:"last_update"`
+        Markets    []OddsAPIMarket `json:"markets"`
+}
+
+// OddsAPIMarket is one market (h2h, btts, correct_score, totals) of a bookmaker
+type OddsAPIMarket struct {
+        Key      string           `json:"key"`
+        Outcomes []OddsAPIOutcome `json:"outcomes"`
+}
+
+// OddsAPIOutcome is a priced outcome of a market
+type OddsAPIOutcome struct {
+        Name  string   `json:"name"`
+        Price float64  `json:"price"`
+        Point *float64 `json:"point,omitempty"` // Line of a totals outcome
 }
 
 // ScoresAPIEvent represents a score event from Odds API
@@ -40,10 +52,13 @@ type ScoresAPIEvent struct {
         HomeTeam     string    `json:"home_team"`
         AwayTeam     string    `json:"away_team"`
         Completed    bool      `json:"completed"`
-        Scores       []struct {
-                Name  string `json:"name"`
-                Score string `json:"score"`
-        } `json:"scores"`
+        Scores       []ScoresAPIScore `json:"scores"`
+}
+
+// ScoresAPIScore is one team's score in a ScoresAPIEvent, as the API sends it
+type ScoresAPIScore struct {
+        Name  string `json:"name"`
+        Score string `json:"score"`
 }
 
 // APIStats represents API usage statistics
@@ -52,13 +67,25 @@ type APIStats struct {
         RequestsUsed      string `json:"requests_used"`
 }
 
+// redactQueryParam renders u with the named query parameter (an API key) masked for logging
+func redactQueryParam(u *url.URL, param string) string {
+        redacted := *u
+        q := redacted.Query()
+        if value := q.Get(param); value != "" {
+                q.Set(param, maskToken(value))
+        }
+        redacted.RawQuery = q.Encode()
+        return redacted.String()
+}
+
 // fetchOddsFromAPI fetches odds from The Odds API
-func fetchOddsFromAPI(apiKey string) ([]OddsAPIEvent, *APIStats, error) {
+// Each market in markets counts against the request quota
+func fetchOddsFromAPI(ctx context.Context, client *http.Client, logger *Logger, apiKey, sport string, markets []string) ([]OddsAPIEvent, *APIStats, error) {
         if apiKey == "" {
                 return nil, nil, fmt.Errorf("ODDS_API_KEY is not configured")
         }
 
-        baseURL := "https://api.the-odds-api.com/v4/sports/soccer_epl/odds"
+        baseURL := "https://api.the-odds-api.com/v4/sports/" + sport + "/odds"
         u, err := url.Parse(baseURL)
         if err != nil {
                 return nil, nil, err
@@ -67,21 +94,30 @@ func fetchOddsFromAPI(apiKey string) ([]OddsAPIEvent, *APIStats, error) {
         q := u.Query()
         q.Set("apiKey", apiKey)
         q.Set("regions", "us")
-        q.Set("markets", "h2h")
+        q.Set("markets", strings.Join(markets, ","))
         q.Set("oddsFormat", "decimal")
         q.Set("dateFormat", "iso")
         q.Set("bookmakers", "marathonbet")
         u.RawQuery = q.Encode()
 
         fullURL := u.String()
-        fmt.Printf("EXTERNAL API REQUEST (ODDS): %s\n", fullURL)
+        logger.LogSystem("ODDS API", "External request (odds): %s", redactQueryParam(u, "apiKey"))
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+        if err != nil {
+                return nil, nil, err
+        }
 
-        resp, err := http.Get(fullURL)
+        resp, err := client.Do(req)
         if err != nil {
                 return nil, nil, fmt.Errorf("failed to fetch odds: %w", err)
         }
         defer resp.Body.Close()
 
+        if resp.StatusCode == http.StatusTooManyRequests {
+                return nil, nil, &OddsAPIRateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+        }
+
         if resp.StatusCode != http.StatusOK {
                 body, _ := io.ReadAll(resp.Body)
                 return nil, nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
@@ -98,18 +134,18 @@ func fetchOddsFromAPI(apiKey string) ([]OddsAPIEvent, *APIStats, error) {
         }
 
         // Log API stats for debugging
-        fmt.Printf("ODDS API: requests_used=%s, requests_remaining=%s\n", apiStats.RequestsUsed, apiStats.RequestsRemaining)
+        logger.LogSystem("ODDS API", "Odds: requests_used=%s, requests_remaining=%s", apiStats.RequestsUsed, apiStats.RequestsRemaining)
 
         return events, apiStats, nil
 }
 
 // fetchScoresFromAPI fetches scores from The Odds API
-func fetchScoresFromAPI(apiKey string) ([]ScoresAPIEvent, *APIStats, error) {
+func fetchScoresFromAPI(ctx context.Context, client *http.Client, logger *Logger, apiKey, sport string) ([]ScoresAPIEvent, *APIStats, error) {
         if apiKey == "" {
                 return nil, nil, fmt.Errorf("ODDS_API_KEY is not configured")
         }
 
-        baseURL := "https://api.the-odds-api.com/v4/sports/soccer_epl/scores/"
+        baseURL := "https://api.the-odds-api.com/v4/sports/" + sport + "/scores/"
         u, err := url.Parse(baseURL)
         if err != nil {
                 return nil, nil, err
@@ -121,14 +157,23 @@ func fetchScoresFromAPI(apiKey string) ([]ScoresAPIEvent, *APIStats, error) {
         u.RawQuery = q.Encode()
 
         fullURL := u.String()
-        fmt.Printf("EXTERNAL API REQUEST (SCORES): %s\n", fullURL)
+        logger.LogSystem("ODDS API", "External request (scores): %s", redactQueryParam(u, "apiKey"))
 
-        resp, err := http.Get(fullURL)
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+        if err != nil {
+                return nil, nil, err
+        }
+
+        resp, err := client.Do(req)
         if err != nil {
                 return nil, nil, fmt.Errorf("failed to fetch scores: %w", err)
         }
         defer resp.Body.Close()
 
+        if resp.StatusCode == http.StatusTooManyRequests {
+                return nil, nil, &OddsAPIRateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+        }
+
         if resp.StatusCode != http.StatusOK {
                 body, _ := io.ReadAll(resp.Body)
                 return nil, nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
@@ -145,101 +190,302 @@ func fetchScoresFromAPI(apiKey string) ([]ScoresAPIEvent, *APIStats, error) {
         }
 
         // Log API stats for debugging
-        fmt.Printf("SCORES API: requests_used=%s, requests_remaining=%s\n", apiStats.RequestsUsed, apiStats.RequestsRemaining)
+        logger.LogSystem("ODDS API", "Scores: requests_used=%s, requests_remaining=%s", apiStats.RequestsUsed, apiStats.RequestsRemaining)
 
         return events, apiStats, nil
 }
 
-// processOddsEvent converts OddsAPIEvent to Match
-func processOddsEvent(event OddsAPIEvent) (*Match, error) {
-        match := &Match{
-                APIID:       event.ID,
-                HomeTeam:    event.HomeTeam,
-                AwayTeam:    event.AwayTeam,
-                CommenceTime: event.CommenceTime,
-                Completed:   false,
-                Calculated:  false,
-        }
-
-        // Extract odds from bookmaker
-        if len(event.Bookmakers) > 0 && len(event.Bookmakers[0].Markets) > 0 {
-                outcomes := event.Bookmakers[0].Markets[0].Outcomes
-                for _, outcome := range outcomes {
-                        if outcome.Name == event.HomeTeam {
-                                match.HomeOdds = &outcome.Price
-                        } else if outcome.Name == event.AwayTeam {
-                                match.AwayOdds = &outcome.Price
-                        } else if outcome.Name == "Draw" {
-                                match.DrawOdds = &outcome.Price
+// 1X2 outcomes named in OddsParseReport.MissingOutcomes
+const (
+        outcomeHome = "home"
+        outcomeDraw = "draw"
+        outcomeAway = "away"
+)
+
+// OddsParseReport describes what processOddsEvent found in an event
+type OddsParseReport struct {
+        Bookmaker         string   // Bookmaker the 1X2 prices came from; empty when none offered them
+        MissingOutcomes   []string // 1X2 outcomes without a price ("home", "draw", "away"; no "draw" for two-way sports)
+        UnmatchedOutcomes []string // 1X2 outcome names matching neither team nor "Draw"
+        OutOfRange        []string // Prices dropped for lying outside MIN_ODDS..MAX_ODDS ("home 1.00"); filled by the sync
+}
+
+// HasOdds reports whether every 1X2 outcome the sport offers was priced
+func (r OddsParseReport) HasOdds() bool {
+        return len(r.MissingOutcomes) == 0
+}
+
+// String summarizes missing and unmatched outcomes for logs and skip details
+func (r OddsParseReport) String() string {
+        var parts []string
+        if r.Bookmaker == "" {
+                parts = append(parts, "no bookmaker offers 1X2 odds")
+        } else if len(r.MissingOutcomes) > 0 {
+                parts = append(parts, fmt.Sprintf("%s has no %s price", r.Bookmaker, strings.Join(r.MissingOutcomes, "/")))
+        }
+        if len(r.UnmatchedOutcomes) > 0 {
+                parts = append(parts, fmt.Sprintf("unmatched outcomes %q", r.UnmatchedOutcomes))
+        }
+        if len(r.OutOfRange) > 0 {
+                parts = append(parts, "odds out of range: "+strings.Join(r.OutOfRange, ", "))
+        }
+        return strings.Join(parts, "; ")
+}
+
+// h2hOdds reads home/draw/away prices from a 1X2 market; outcome names are matched to
+// the already normalized team names. A two-way market has no "Draw" outcome
+func h2hOdds(market OddsAPIMarket, homeTeam, awayTeam string, teams *TeamNames) (home, draw, away *float64, unmatched []string) {
+        for _, outcome := range market.Outcomes {
+                price := outcome.Price
+                switch name := teams.Normalize(outcome.Name); {
+                case name == homeTeam:
+                        home = &price
+                case name == awayTeam:
+                        away = &price
+                case outcome.Name == "Draw":
+                        draw = &price
+                default:
+                        unmatched = append(unmatched, outcome.Name)
+                }
+        }
+        return home, draw, away, unmatched
+}
+
+// bttsOdds reads yes/no prices from a both teams to score market
+func bttsOdds(market OddsAPIMarket) (yes, no *float64) {
+        for _, outcome := range market.Outcomes {
+                price := outcome.Price
+                switch outcome.Name {
+                case "Yes":
+                        yes = &price
+                case "No":
+                        no = &price
+                }
+        }
+        return yes, no
+}
+
+// correctScoreOdds reads a correct score market keyed by "home-away" score
+// Outcomes we can't read as a home-away score are left out
+func correctScoreOdds(market OddsAPIMarket) map[string]float64 {
+        var odds map[string]float64
+        for _, outcome := range market.Outcomes {
+                if homeScore, awayScore, ok := parseScoreKey(outcome.Name); ok && outcome.Price > 1 {
+                        if odds == nil {
+                                odds = make(map[string]float64)
                         }
+                        odds[scoreKey(homeScore, awayScore)] = outcome.Price
                 }
         }
+        return odds
+}
 
-        return match, nil
+// totalsOdds collects the priced "Over"/"Under" outcomes of a totals market, keyed by bet type
+// Outcomes without a point or off the half-goal lines are skipped
+func totalsOdds(market OddsAPIMarket) map[string]float64 {
+        var odds map[string]float64
+        for _, outcome := range market.Outcomes {
+                if outcome.Point == nil || !validTotalsLine(*outcome.Point) || outcome.Price <= 1 {
+                        continue
+                }
+                var prefix string
+                switch strings.ToLower(strings.TrimSpace(outcome.Name)) {
+                case "over":
+                        prefix = totalsOverPrefix
+                case "under":
+                        prefix = totalsUnderPrefix
+                default:
+                        continue
+                }
+                if odds == nil {
+                        odds = make(map[string]float64)
+                }
+                odds[totalsBetType(prefix, *outcome.Point)] = outcome.Price
+        }
+        return odds
 }
 
-// processScoreEvent converts ScoresAPIEvent to Match
-func processScoreEvent(event ScoresAPIEvent) (*Match, error) {
+// processOddsEvent converts OddsAPIEvent to Match with normalized team names
+// Each market comes whole from the first bookmaker that prices every outcome of it, falling
+// back to the first bookmaker offering it at all; prices are never mixed across bookmakers
+// Without hasDraw (two-way sports) the 1X2 market is complete with home and away alone
+func processOddsEvent(event OddsAPIEvent, teams *TeamNames, hasDraw bool) (*Match, OddsParseReport, error) {
         match := &Match{
                 APIID:        event.ID,
-                HomeTeam:     event.HomeTeam,
-                AwayTeam:     event.AwayTeam,
+                HomeTeam:     teams.Normalize(event.HomeTeam),
+                AwayTeam:     teams.Normalize(event.AwayTeam),
                 CommenceTime: event.CommenceTime,
-                Completed:    event.Completed,
+                Completed:    false,
                 Calculated:   false,
         }
+        outcomes := []string{outcomeHome, outcomeDraw, outcomeAway}
+        if !hasDraw {
+                outcomes = []string{outcomeHome, outcomeAway}
+        }
+        report := OddsParseReport{MissingOutcomes: outcomes}
+        if err := validateTeams(match.HomeTeam, match.AwayTeam); err != nil {
+                return nil, report, fmt.Errorf("event %s: %w", event.ID, err)
+        }
 
-        // Extract scores
-        homeScore := -1
-        awayScore := -1
-        for _, score := range event.Scores {
-                if score.Name == event.HomeTeam {
-                        if score.Score != "" {
-                                if s, err := strconv.Atoi(score.Score); err == nil {
-                                        homeScore = s
+        h2hComplete, bttsComplete := false, false
+        for _, bookmaker := range event.Bookmakers {
+                for _, market := range bookmaker.Markets {
+                        switch market.Key {
+                        case MarketH2H:
+                                if h2hComplete {
+                                        continue
                                 }
-                        }
-                } else if score.Name == event.AwayTeam {
-                        if score.Score != "" {
-                                if s, err := strconv.Atoi(score.Score); err == nil {
-                                        awayScore = s
+                                home, draw, away, unmatched := h2hOdds(market, match.HomeTeam, match.AwayTeam, teams)
+                                if !hasDraw {
+                                        draw = nil // Never offer a draw the sport cannot settle
+                                }
+                                h2hComplete = home != nil && away != nil && (draw != nil || !hasDraw)
+                                if report.Bookmaker != "" && !h2hComplete {
+                                        continue // Keep the first partial market unless this one is complete
+                                }
+                                match.HomeOdds, match.DrawOdds, match.AwayOdds = home, draw, away
+                                report.Bookmaker = bookmaker.Key
+                                report.UnmatchedOutcomes = unmatched
+                                report.MissingOutcomes = nil
+                                prices := map[string]*float64{outcomeHome: home, outcomeDraw: draw, outcomeAway: away}
+                                for _, outcome := range outcomes {
+                                        if prices[outcome] == nil {
+                                                report.MissingOutcomes = append(report.MissingOutcomes, outcome)
+                                        }
+                                }
+                        case MarketBTTS:
+                                if bttsComplete {
+                                        continue
+                                }
+                                yes, no := bttsOdds(market)
+                                bttsComplete = yes != nil && no != nil
+                                if (match.BTTSYesOdds != nil || match.BTTSNoOdds != nil) && !bttsComplete {
+                                        continue
+                                }
+                                match.BTTSYesOdds, match.BTTSNoOdds = yes, no
+                        case MarketCorrectScore:
+                                if match.CorrectScoreOdds == nil {
+                                        match.CorrectScoreOdds = correctScoreOdds(market)
+                                }
+                        case MarketTotals:
+                                if match.TotalsOdds == nil {
+                                        match.TotalsOdds = totalsOdds(market)
                                 }
                         }
                 }
         }
 
-        if homeScore != -1 {
-                match.HomeScore = &homeScore
-        }
-        if awayScore != -1 {
-                match.AwayScore = &awayScore
+        if report.Bookmaker != "" {
+                source := report.Bookmaker
+                match.OddsSource = &source
         }
 
-        return match, nil
+        return match, report, nil
+}
+
+// ScoreParseReport describes what processScoreEvent found in an event
+type ScoreParseReport struct {
+        Unparsed  []string // "Team: score" entries without a leading integer
+        Unmatched []string // Score entries naming neither team
+}
+
+// String summarizes unparsed and unmatched scores for logs
+func (r ScoreParseReport) String() string {
+        var parts []string
+        if len(r.Unparsed) > 0 {
+                parts = append(parts, fmt.Sprintf("unparsed scores %q", r.Unparsed))
+        }
+        if len(r.Unmatched) > 0 {
+                parts = append(parts, fmt.Sprintf("unmatched teams %q", r.Unmatched))
+        }
+        return strings.Join(parts, "; ")
 }
 
-// sendTelegramNotification sends a notification to Telegram
-func sendTelegramNotification(botToken, channelID string, matches []map[string]interface{}) error {
-        if botToken == "" || channelID == "" {
-                return fmt.Errorf("Telegram credentials not configured")
+// parseScore reads a team's score string: the leading integer is the score after regulation
+// (and extra time), a parenthesized integer after it the penalty shootout, as in "3 (5)".
+// Other trailing text ("2 AET", "1 (aet)") is ignored. shootout is -1 when absent; false
+// when there is no leading integer
+func parseScore(raw string) (score, shootout int, ok bool) {
+        raw = strings.TrimSpace(raw)
+        digits := len(raw) - len(strings.TrimLeft(raw, "0123456789"))
+        if digits == 0 {
+                return 0, -1, false
+        }
+        score, err := strconv.Atoi(raw[:digits])
+        if err != nil {
+                return 0, -1, false
         }
 
-        // Log attempt to send notification
-        fmt.Printf("TELEGRAM: Attempting to send notification to channel %s with %d matches\n", channelID, len(matches))
+        shootout = -1
+        if _, rest, found := strings.Cut(raw[digits:], "("); found {
+                rest = strings.TrimSpace(rest)
+                if n := len(rest) - len(strings.TrimLeft(rest, "0123456789")); n > 0 {
+                        if penalties, err := strconv.Atoi(rest[:n]); err == nil {
+                                shootout = penalties
+                        }
+                }
+        }
+        return score, shootout, true
+}
 
-        now := time.Now()
-        dateTime := now.Format("02/01/2006 15:04:05")
+// processScoreEvent converts ScoresAPIEvent to Match with normalized team names
+// A score that can't be read leaves that side nil and is listed in the report. Scores hold
+// the regulation (and extra time) goals; a shootout only sets ShootoutWinner
+func processScoreEvent(event ScoresAPIEvent, teams *TeamNames) (*Match, ScoreParseReport, error) {
+        match := &Match{
+                APIID:        event.ID,
+                HomeTeam:     teams.Normalize(event.HomeTeam),
+                AwayTeam:     teams.Normalize(event.AwayTeam),
+                CommenceTime: event.CommenceTime,
+                Completed:    event.Completed,
+                Calculated:   false,
+        }
+        var report ScoreParseReport
+        if err := validateTeams(match.HomeTeam, match.AwayTeam); err != nil {
+                return nil, report, fmt.Errorf("event %s: %w", event.ID, err)
+        }
 
-        message := fmt.Sprintf("🎯 <b>Matches Calculated!</b>\n\n📅 %s\n\n⚽ <b>Match Results:</b>\n", dateTime)
+        homeShootout, awayShootout := -1, -1
+        for _, entry := range event.Scores {
+                var side **int
+                var sideShootout *int
+                switch teams.Normalize(entry.Name) {
+                case match.HomeTeam:
+                        side, sideShootout = &match.HomeScore, &homeShootout
+                case match.AwayTeam:
+                        side, sideShootout = &match.AwayScore, &awayShootout
+                default:
+                        report.Unmatched = append(report.Unmatched, entry.Name)
+                        continue
+                }
+                score, shootout, ok := parseScore(entry.Score)
+                if !ok {
+                        if entry.Score != "" {
+                                report.Unparsed = append(report.Unparsed, fmt.Sprintf("%s: %s", entry.Name, entry.Score))
+                        }
+                        continue
+                }
+                *side = &score
+                *sideShootout = shootout
+        }
 
-        for i, match := range matches {
-                message += fmt.Sprintf("%d. %s %s %s\n", i+1, match["home_team"], match["score"], match["away_team"])
+        // A shootout only decides a level score, and needs both sides' penalties
+        if match.HomeScore != nil && match.AwayScore != nil && *match.HomeScore == *match.AwayScore &&
+                homeShootout >= 0 && awayShootout >= 0 && homeShootout != awayShootout {
+                winner := "home"
+                if awayShootout > homeShootout {
+                        winner = "away"
+                }
+                match.ShootoutWinner = &winner
         }
 
-        message += "\n💰 <i>Dear clients, bets have been calculated automatically!</i>"
+        return match, report, nil
+}
 
+// sendTelegramMessage posts an HTML message to a Telegram channel
+func sendTelegramMessage(ctx context.Context, client *http.Client, logger *Logger, botToken, channelID, message string) error {
         apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
-        fmt.Printf("EXTERNAL API REQUEST (TELEGRAM): %s\n", apiURL)
+        logger.LogSystem("TELEGRAM", "External request: https://api.telegram.org/bot%s/sendMessage", maskToken(botToken))
 
         payload := map[string]interface{}{
                 "chat_id":    channelID,
@@ -252,7 +498,13 @@ func sendTelegramNotification(botToken, channelID string, matches []map[string]i
                 return fmt.Errorf("failed to marshal payload: %w", err)
         }
 
-        resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(jsonData))
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(jsonData))
+        if err != nil {
+                return fmt.Errorf("failed to create request: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+
+        resp, err := client.Do(req)
         if err != nil {
                 return fmt.Errorf("failed to send request: %w", err)
         }
@@ -263,8 +515,6 @@ func sendTelegramNotification(botToken, channelID string, matches []map[string]i
                 return fmt.Errorf("Telegram API returned status %d: %s", resp.StatusCode, string(body))
         }
 
-        // Log successful send
-        fmt.Printf("TELEGRAM: Notification sent successfully to channel %s\n", channelID)
         return nil
 }
 
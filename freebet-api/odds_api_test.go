@@ -0,0 +1,280 @@
+package main
+
+import (
+        "reflect"
+        "testing"
+        "time"
+)
+
+// h2hMarket builds a 1X2 market from outcome name/price pairs
+func h2hMarket(prices map[string]float64) OddsAPIMarket {
+        market := OddsAPIMarket{Key: MarketH2H}
+        for name, price := range prices {
+                market.Outcomes = append(market.Outcomes, OddsAPIOutcome{Name: name, Price: price})
+        }
+        return market
+}
+
+// oddsEvent builds an event with one bookmaker ("first", "second", ...) per market list
+func oddsEvent(home, away string, bookmakers ...[]OddsAPIMarket) OddsAPIEvent {
+        event := OddsAPIEvent{ID: "event-1", HomeTeam: home, AwayTeam: away, CommenceTime: time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)}
+        for i, markets := range bookmakers {
+                event.Bookmakers = append(event.Bookmakers, OddsAPIBookmaker{Key: []string{"first", "second", "third"}[i], Markets: markets})
+        }
+        return event
+}
+
+// priceOf reads an optional price; -1 when absent
+func priceOf(price *float64) float64 {
+        if price == nil {
+                return -1
+        }
+        return *price
+}
+
+func TestProcessOddsEvent(t *testing.T) {
+        teams := NewTeamNames(&Config{})
+        complete := h2hMarket(map[string]float64{"Arsenal": 2.1, "Draw": 3.4, "Chelsea": 3.2})
+        noDraw := h2hMarket(map[string]float64{"Arsenal": 2.1, "Chelsea": 3.2})
+
+        tests := []struct {
+                name      string
+                event     OddsAPIEvent
+                hasDraw   bool
+                home      float64 // -1 = no price
+                draw      float64
+                away      float64
+                bookmaker string
+                missing   []string
+                unmatched []string
+        }{
+                {
+                        name:      "complete market",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{complete}),
+                        hasDraw:   true,
+                        home:      2.1, draw: 3.4, away: 3.2,
+                        bookmaker: "first",
+                },
+                {
+                        name:      "missing draw outcome",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{noDraw}),
+                        hasDraw:   true,
+                        home:      2.1, draw: -1, away: 3.2,
+                        bookmaker: "first",
+                        missing:   []string{outcomeDraw},
+                },
+                {
+                        name:      "missing draw falls through to a complete bookmaker",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{noDraw}, []OddsAPIMarket{complete}),
+                        hasDraw:   true,
+                        home:      2.1, draw: 3.4, away: 3.2,
+                        bookmaker: "second",
+                },
+                {
+                        name:      "two-way sport needs no draw",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{noDraw}),
+                        hasDraw:   false,
+                        home:      2.1, draw: -1, away: 3.2,
+                        bookmaker: "first",
+                },
+                {
+                        name:      "two-way sport drops an offered draw",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{complete}),
+                        hasDraw:   false,
+                        home:      2.1, draw: -1, away: 3.2,
+                        bookmaker: "first",
+                },
+                {
+                        name:    "empty bookmakers",
+                        event:   oddsEvent("Arsenal", "Chelsea"),
+                        hasDraw: true,
+                        home:    -1, draw: -1, away: -1,
+                        missing: []string{outcomeHome, outcomeDraw, outcomeAway},
+                },
+                {
+                        name:    "bookmaker without markets",
+                        event:   oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{}),
+                        hasDraw: true,
+                        home:    -1, draw: -1, away: -1,
+                        missing: []string{outcomeHome, outcomeDraw, outcomeAway},
+                },
+                {
+                        name:      "mismatched team name",
+                        event:     oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{h2hMarket(map[string]float64{"Arsenal FC": 2.1, "Draw": 3.4, "Chelsea": 3.2})}),
+                        hasDraw:   true,
+                        home:      -1, draw: 3.4, away: 3.2,
+                        bookmaker: "first",
+                        missing:   []string{outcomeHome},
+                        unmatched: []string{"Arsenal FC"},
+                },
+                {
+                        name:      "aliases match across spellings",
+                        event:     oddsEvent("Man City", "Spurs", []OddsAPIMarket{h2hMarket(map[string]float64{"Manchester City": 1.5, "Draw": 4.5, "Tottenham": 6.0})}),
+                        hasDraw:   true,
+                        home:      1.5, draw: 4.5, away: 6.0,
+                        bookmaker: "first",
+                },
+        }
+
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        match, report, err := processOddsEvent(tt.event, teams, tt.hasDraw)
+                        if err != nil {
+                                t.Fatalf("unexpected error: %v", err)
+                        }
+                        if got := [3]float64{priceOf(match.HomeOdds), priceOf(match.DrawOdds), priceOf(match.AwayOdds)}; got != [3]float64{tt.home, tt.draw, tt.away} {
+                                t.Errorf("odds = %v, want %v", got, [3]float64{tt.home, tt.draw, tt.away})
+                        }
+                        if report.Bookmaker != tt.bookmaker {
+                                t.Errorf("bookmaker = %q, want %q", report.Bookmaker, tt.bookmaker)
+                        }
+                        if !reflect.DeepEqual(report.MissingOutcomes, tt.missing) {
+                                t.Errorf("missing = %q, want %q", report.MissingOutcomes, tt.missing)
+                        }
+                        if !reflect.DeepEqual(report.UnmatchedOutcomes, tt.unmatched) {
+                                t.Errorf("unmatched = %q, want %q", report.UnmatchedOutcomes, tt.unmatched)
+                        }
+                        if report.HasOdds() != (tt.missing == nil) {
+                                t.Errorf("HasOdds() = %v with missing %q", report.HasOdds(), tt.missing)
+                        }
+                        if (match.OddsSource == nil) != (tt.bookmaker == "") {
+                                t.Errorf("odds_source = %v, want %q", match.OddsSource, tt.bookmaker)
+                        }
+                })
+        }
+}
+
+func TestProcessOddsEventRejectsBadTeams(t *testing.T) {
+        teams := NewTeamNames(&Config{})
+        for _, tt := range []struct{ home, away string }{
+                {"", "Chelsea"},
+                {"Arsenal", "  "},
+                {"Tottenham", "Spurs"},
+        } {
+                if _, _, err := processOddsEvent(oddsEvent(tt.home, tt.away), teams, true); err == nil {
+                        t.Errorf("processOddsEvent(%q, %q) accepted the teams", tt.home, tt.away)
+                }
+        }
+}
+
+func TestProcessOddsEventSideMarkets(t *testing.T) {
+        teams := NewTeamNames(&Config{})
+        point := func(line float64) *float64 { return &line }
+        event := oddsEvent("Arsenal", "Chelsea", []OddsAPIMarket{
+                h2hMarket(map[string]float64{"Arsenal": 2.1, "Draw": 3.4, "Chelsea": 3.2}),
+                {Key: MarketBTTS, Outcomes: []OddsAPIOutcome{{Name: "Yes", Price: 1.8}, {Name: "No", Price: 2.0}}},
+                {Key: MarketCorrectScore, Outcomes: []OddsAPIOutcome{{Name: "2-1", Price: 9}, {Name: "Any other", Price: 15}, {Name: "1:1", Price: 6}}},
+                {Key: MarketTotals, Outcomes: []OddsAPIOutcome{
+                        {Name: "Over", Price: 1.9, Point: point(2.5)},
+                        {Name: "Under", Price: 1.9, Point: point(2.5)},
+                        {Name: "Under", Price: 1.6, Point: point(3)},
+                        {Name: "Over", Price: 2.2, Point: point(2.25)}, // Quarter lines are not offered
+                        {Name: "Over", Price: 1.4},                      // No line
+                }},
+        })
+
+        match, _, err := processOddsEvent(event, teams, true)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if priceOf(match.BTTSYesOdds) != 1.8 || priceOf(match.BTTSNoOdds) != 2.0 {
+                t.Errorf("btts = %v/%v, want 1.8/2.0", priceOf(match.BTTSYesOdds), priceOf(match.BTTSNoOdds))
+        }
+        if want := map[string]float64{"2-1": 9, "1-1": 6}; !reflect.DeepEqual(match.CorrectScoreOdds, want) {
+                t.Errorf("correct score = %v, want %v", match.CorrectScoreOdds, want)
+        }
+        if want := map[string]float64{"over_2.5": 1.9, "under_2.5": 1.9, "under_3.0": 1.6}; !reflect.DeepEqual(match.TotalsOdds, want) {
+                t.Errorf("totals = %v, want %v", match.TotalsOdds, want)
+        }
+}
+
+func TestParseScore(t *testing.T) {
+        tests := []struct {
+                raw      string
+                score    int
+                shootout int
+                ok       bool
+        }{
+                {"2", 2, -1, true},
+                {" 0 ", 0, -1, true},
+                {"2 AET", 2, -1, true},
+                {"1 (aet)", 1, -1, true},
+                {"3 (5)", 3, 5, true},
+                {"1 ( 4 )", 1, 4, true},
+                {"", 0, -1, false},
+                {"abc", 0, -1, false},
+                {"-1", 0, -1, false},
+        }
+        for _, tt := range tests {
+                score, shootout, ok := parseScore(tt.raw)
+                if score != tt.score || shootout != tt.shootout || ok != tt.ok {
+                        t.Errorf("parseScore(%q) = %d, %d, %v; want %d, %d, %v", tt.raw, score, shootout, ok, tt.score, tt.shootout, tt.ok)
+                }
+        }
+}
+
+func TestProcessScoreEvent(t *testing.T) {
+        teams := NewTeamNames(&Config{})
+        scores := func(pairs ...string) []ScoresAPIScore {
+                var entries []ScoresAPIScore
+                for i := 0; i < len(pairs); i += 2 {
+                        entries = append(entries, ScoresAPIScore{Name: pairs[i], Score: pairs[i+1]})
+                }
+                return entries
+        }
+
+        tests := []struct {
+                name      string
+                home      string
+                away      string
+                scores    []ScoresAPIScore
+                homeScore int // -1 = nil
+                awayScore int
+                shootout  string
+                unparsed  []string
+                unmatched []string
+        }{
+                {name: "final score", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "2", "Chelsea", "1"), homeScore: 2, awayScore: 1},
+                {name: "extra time", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "2 AET", "Chelsea", "1 (aet)"), homeScore: 2, awayScore: 1},
+                {name: "shootout decides a level score", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "1 (3)", "Chelsea", "1 (4)"), homeScore: 1, awayScore: 1, shootout: "away"},
+                {name: "shootout ignored when not level", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "2 (3)", "Chelsea", "1 (4)"), homeScore: 2, awayScore: 1},
+                {name: "shootout needs both sides", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "1 (5)", "Chelsea", "1"), homeScore: 1, awayScore: 1},
+                {name: "non-integer score", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "n/a", "Chelsea", "1"), homeScore: -1, awayScore: 1, unparsed: []string{"Arsenal: n/a"}},
+                {name: "empty score is not reported", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal", "", "Chelsea", ""), homeScore: -1, awayScore: -1},
+                {name: "no scores", home: "Arsenal", away: "Chelsea", homeScore: -1, awayScore: -1},
+                {name: "mismatched team name", home: "Arsenal", away: "Chelsea", scores: scores("Arsenal FC", "2", "Chelsea", "1"), homeScore: -1, awayScore: 1, unmatched: []string{"Arsenal FC"}},
+                {name: "aliases match across spellings", home: "Man Utd", away: "Wolves", scores: scores("Manchester United", "3", "Wolverhampton", "0"), homeScore: 3, awayScore: 0},
+        }
+
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        event := ScoresAPIEvent{ID: "event-1", HomeTeam: tt.home, AwayTeam: tt.away, Completed: true, Scores: tt.scores}
+                        match, report, err := processScoreEvent(event, teams)
+                        if err != nil {
+                                t.Fatalf("unexpected error: %v", err)
+                        }
+                        score := func(value *int) int {
+                                if value == nil {
+                                        return -1
+                                }
+                                return *value
+                        }
+                        if score(match.HomeScore) != tt.homeScore || score(match.AwayScore) != tt.awayScore {
+                                t.Errorf("score = %d-%d, want %d-%d", score(match.HomeScore), score(match.AwayScore), tt.homeScore, tt.awayScore)
+                        }
+                        shootout := ""
+                        if match.ShootoutWinner != nil {
+                                shootout = *match.ShootoutWinner
+                        }
+                        if shootout != tt.shootout {
+                                t.Errorf("shootout winner = %q, want %q", shootout, tt.shootout)
+                        }
+                        if !reflect.DeepEqual(report.Unparsed, tt.unparsed) {
+                                t.Errorf("unparsed = %q, want %q", report.Unparsed, tt.unparsed)
+                        }
+                        if !reflect.DeepEqual(report.Unmatched, tt.unmatched) {
+                                t.Errorf("unmatched = %q, want %q", report.Unmatched, tt.unmatched)
+                        }
+                })
+        }
+}
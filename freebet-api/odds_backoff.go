@@ -0,0 +1,91 @@
+package main
+
+import (
+        "fmt"
+        "net/http"
+        "strconv"
+        "sync"
+        "time"
+)
+
+// OddsAPIRateLimitError is returned by the fetch functions when The Odds API answers 429
+type OddsAPIRateLimitError struct {
+        RetryAfter time.Duration // Zero when the response had no usable Retry-After header
+}
+
+func (e *OddsAPIRateLimitError) Error() string {
+        if e.RetryAfter > 0 {
+                return fmt.Sprintf("Odds API rate limit exceeded (retry after %v)", e.RetryAfter)
+        }
+        return "Odds API rate limit exceeded"
+}
+
+// OddsAPIBackoffError is returned instead of calling The Odds API while a backoff window is open
+type OddsAPIBackoffError struct {
+        Until time.Time
+}
+
+func (e *OddsAPIBackoffError) Error() string {
+        return fmt.Sprintf("Odds API calls paused after rate limit until %s", e.Until.Format(time.RFC3339))
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+        if value == "" {
+                return 0
+        }
+        if seconds, err := strconv.Atoi(value); err == nil {
+                if seconds < 0 {
+                        return 0
+                }
+                return time.Duration(seconds) * time.Second
+        }
+        if date, err := http.ParseTime(value); err == nil && date.After(now) {
+                return date.Sub(now)
+        }
+        return 0
+}
+
+// OddsAPIBackoff short-circuits outbound Odds API calls after a 429
+// Odds and scores share one API key, so one window covers both
+type OddsAPIBackoff struct {
+        mu     sync.Mutex
+        config *Config
+        logger *Logger
+        until  time.Time
+}
+
+// NewOddsAPIBackoff creates a closed (allowing calls) backoff
+func NewOddsAPIBackoff(config *Config, logger *Logger) *OddsAPIBackoff {
+        return &OddsAPIBackoff{config: config, logger: logger}
+}
+
+// Check returns an OddsAPIBackoffError while the backoff window is open
+func (b *OddsAPIBackoff) Check() error {
+        b.mu.Lock()
+        defer b.mu.Unlock()
+
+        if b.config.now().Before(b.until) {
+                return &OddsAPIBackoffError{Until: b.until}
+        }
+        return nil
+}
+
+// Trip opens the backoff window, honoring Retry-After when the API sent one
+func (b *OddsAPIBackoff) Trip(source string, retryAfter time.Duration) {
+        wait := retryAfter
+        if wait <= 0 {
+                wait = b.config.OddsAPIRateLimitBackoff
+        }
+
+        b.mu.Lock()
+        until := b.config.now().Add(wait)
+        if until.After(b.until) {
+                b.until = until
+        }
+        until = b.until
+        b.mu.Unlock()
+
+        b.logger.LogWarning("[ODDS BACKOFF] %s sync rate limited by Odds API, skipping API calls for %v (until %s)",
+                source, wait.Round(time.Second), until.Format(time.RFC3339))
+}
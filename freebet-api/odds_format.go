@@ -0,0 +1,112 @@
+package main
+
+import (
+        "fmt"
+        "math"
+        "strings"
+)
+
+// Odds presentation formats (?oddsFormat=); odds are always stored and accepted as decimal
+const (
+        OddsFormatDecimal    = "decimal"    // 2.50
+        OddsFormatFractional = "fractional" // 3/2
+        OddsFormatAmerican   = "american"   // +150 / -200
+)
+
+// maxFractionDenominator bounds the "traditional" fractions tried for fractional odds
+// (10/11, 2/3, 13/8); other prices fall back to exact hundredths (101/100)
+const maxFractionDenominator = 20
+
+// parseOddsFormat validates an oddsFormat query value; empty means decimal
+func parseOddsFormat(value string) (string, error) {
+        switch format := strings.ToLower(strings.TrimSpace(value)); format {
+        case "", OddsFormatDecimal:
+                return OddsFormatDecimal, nil
+        case OddsFormatFractional, OddsFormatAmerican:
+                return format, nil
+        default:
+                return "", fmt.Errorf("Invalid oddsFormat. Use decimal, fractional or american")
+        }
+}
+
+// formatOdds renders decimal odds in the given format
+// Returns "" for odds that cannot be priced (1.00 or less)
+func formatOdds(decimal float64, format string) string {
+        if decimal <= 1 || math.IsNaN(decimal) || math.IsInf(decimal, 0) {
+                return ""
+        }
+
+        switch format {
+        case OddsFormatFractional:
+                return fractionalOdds(decimal)
+        case OddsFormatAmerican:
+                return americanOdds(decimal)
+        default:
+                return fmt.Sprintf("%.2f", decimal)
+        }
+}
+
+// fractionalOdds returns the profit per unit staked as the simplest fraction (denominator
+// up to maxFractionDenominator) that rounds back to the same decimal price, else exact
+// hundredths: 2.50 -> 3/2, 1.91 -> 10/11, 2.00 -> 1/1, 2.01 -> 101/100
+func fractionalOdds(decimal float64) string {
+        profit := math.Round((decimal-1)*100) / 100
+        for denominator := 1; denominator <= maxFractionDenominator; denominator++ {
+                numerator := math.Round(profit * float64(denominator))
+                if numerator < 1 {
+                        continue
+                }
+                if math.Round(numerator/float64(denominator)*100)/100 == profit {
+                        return fmt.Sprintf("%d/%d", int(numerator), denominator)
+                }
+        }
+
+        // Exact at two-decimal precision
+        cents := int(math.Round(profit * 100))
+        divisor := gcd(cents, 100)
+        return fmt.Sprintf("%d/%d", cents/divisor, 100/divisor)
+}
+
+// americanOdds returns the moneyline: the win on a 100 stake for underdogs (+150),
+// or the stake needed to win 100 for favourites (-200). Even money (2.00) is +100
+func americanOdds(decimal float64) string {
+        if decimal >= 2 {
+                return fmt.Sprintf("+%d", int(math.Round((decimal-1)*100)))
+        }
+        return fmt.Sprintf("-%d", int(math.Round(100/(decimal-1))))
+}
+
+// formatOddsPtr formats optional odds; nil when the odds are missing or for decimal
+// (decimal responses carry only the numeric fields)
+func formatOddsPtr(odds *float64, format string) *string {
+        if odds == nil || format == OddsFormatDecimal {
+                return nil
+        }
+        formatted := formatOdds(*odds, format)
+        if formatted == "" {
+                return nil
+        }
+        return &formatted
+}
+
+// formatOddsMap formats keyed odds (correct score); nil for decimal or when empty
+func formatOddsMap(odds map[string]float64, format string) map[string]string {
+        if len(odds) == 0 || format == OddsFormatDecimal {
+                return nil
+        }
+        formatted := make(map[string]string, len(odds))
+        for key, price := range odds {
+                if value := formatOdds(price, format); value != "" {
+                        formatted[key] = value
+                }
+        }
+        return formatted
+}
+
+// gcd returns the greatest common divisor of two positive integers
+func gcd(a, b int) int {
+        for b != 0 {
+                a, b = b, a%b
+        }
+        return a
+}
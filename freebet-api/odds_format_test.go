@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestFormatOdds(t *testing.T) {
+        tests := []struct {
+                decimal    float64
+                fractional string
+                american   string
+        }{
+                {2.0, "1/1", "+100"}, // Even money
+                {1.99, "99/100", "-101"},
+                {2.01, "101/100", "+101"},
+                {1.91, "10/11", "-110"},
+                {2.5, "3/2", "+150"},
+                {1.5, "1/2", "-200"},
+                {3.0, "2/1", "+200"},
+                {1.01, "1/100", "-10000"},
+                {11.0, "10/1", "+1000"},
+        }
+        for _, tt := range tests {
+                if got := formatOdds(tt.decimal, OddsFormatFractional); got != tt.fractional {
+                        t.Errorf("fractional %.2f = %q, want %q", tt.decimal, got, tt.fractional)
+                }
+                if got := formatOdds(tt.decimal, OddsFormatAmerican); got != tt.american {
+                        t.Errorf("american %.2f = %q, want %q", tt.decimal, got, tt.american)
+                }
+        }
+
+        // Prices that pay nothing back have no representation
+        for _, decimal := range []float64{1.0, 0.5, 0} {
+                for _, format := range []string{OddsFormatDecimal, OddsFormatFractional, OddsFormatAmerican} {
+                        if got := formatOdds(decimal, format); got != "" {
+                                t.Errorf("%s %.2f = %q, want empty", format, decimal, got)
+                        }
+                }
+        }
+        if got := formatOdds(2.5, OddsFormatDecimal); got != "2.50" {
+                t.Errorf("decimal 2.5 = %q, want 2.50", got)
+        }
+}
+
+func TestParseOddsFormat(t *testing.T) {
+        tests := []struct {
+                value string
+                want  string
+                ok    bool
+        }{
+                {"", OddsFormatDecimal, true},
+                {"decimal", OddsFormatDecimal, true},
+                {" American ", OddsFormatAmerican, true},
+                {"FRACTIONAL", OddsFormatFractional, true},
+                {"moneyline", "", false},
+        }
+        for _, tt := range tests {
+                got, err := parseOddsFormat(tt.value)
+                if got != tt.want || (err == nil) != tt.ok {
+                        t.Errorf("parseOddsFormat(%q) = %q, %v; want %q, ok %v", tt.value, got, err, tt.want, tt.ok)
+                }
+        }
+}
+
+func TestFormatOddsOnlyOutsideDecimal(t *testing.T) {
+        odds := 2.5
+        if got := formatOddsPtr(&odds, OddsFormatDecimal); got != nil {
+                t.Errorf("formatOddsPtr decimal = %q, want nil", *got)
+        }
+        if got := formatOddsPtr(nil, OddsFormatAmerican); got != nil {
+                t.Errorf("formatOddsPtr(nil) = %q, want nil", *got)
+        }
+        if got := formatOddsPtr(&odds, OddsFormatAmerican); got == nil || *got != "+150" {
+                t.Errorf("formatOddsPtr american = %v, want +150", got)
+        }
+        if got := formatOddsMap(map[string]float64{"2-1": 9, "bad": 1}, OddsFormatFractional); len(got) != 1 || got["2-1"] != "8/1" {
+                t.Errorf("formatOddsMap = %v, want only 2-1 at 8/1", got)
+        }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+        "context"
+        "fmt"
+        "net/http"
+        "strconv"
+        "sync"
+        "time"
+)
+
+// OddsQuota is the latest The Odds API quota seen in a sync response
+type OddsQuota struct {
+        RequestsRemaining string    `json:"requests_remaining"`
+        RequestsUsed      string    `json:"requests_used"`
+        Source            string    `json:"source"` // "odds" or "scores"
+        ObservedAt        time.Time `json:"observed_at"`
+}
+
+// OddsQuotaTracker keeps the latest quota and warns once when remaining requests run low
+type OddsQuotaTracker struct {
+        mu     sync.Mutex
+        config *Config
+        logger *Logger
+        client *http.Client // Telegram client for low quota warnings
+        latest *OddsQuota
+        warned bool // Reset once remaining is back above the threshold
+}
+
+// NewOddsQuotaTracker creates an empty quota tracker
+func NewOddsQuotaTracker(config *Config, logger *Logger, client *http.Client) *OddsQuotaTracker {
+        return &OddsQuotaTracker{config: config, logger: logger, client: client}
+}
+
+// Record stores the quota from an API response and warns if it dropped below OddsAPIQuotaWarning
+func (t *OddsQuotaTracker) Record(ctx context.Context, source string, stats *APIStats) {
+        if stats == nil || (stats.RequestsRemaining == "" && stats.RequestsUsed == "") {
+                return
+        }
+
+        t.mu.Lock()
+        t.latest = &OddsQuota{
+                RequestsRemaining: stats.RequestsRemaining,
+                RequestsUsed:      stats.RequestsUsed,
+                Source:            source,
+                ObservedAt:        t.config.now(),
+        }
+
+        remaining, err := strconv.ParseFloat(stats.RequestsRemaining, 64)
+        low := err == nil && t.isLow(remaining)
+        notify := low && !t.warned
+        t.warned = low
+        t.mu.Unlock()
+
+        if !notify {
+                return
+        }
+
+        t.logger.LogWarning("[ODDS QUOTA] Only %s Odds API requests remaining (used %s, threshold %d)",
+                stats.RequestsRemaining, stats.RequestsUsed, t.config.OddsAPIQuotaWarning)
+
+        if t.config.TelegramBotToken != "" && t.config.TelegramChannelID != "" {
+                message := fmt.Sprintf("⚠️ <b>Odds API quota low</b>\n\nRemaining: %s\nUsed: %s", stats.RequestsRemaining, stats.RequestsUsed)
+                if err := sendTelegramMessage(ctx, t.client, t.logger, t.config.TelegramBotToken, t.config.TelegramChannelID, message); err != nil {
+                        t.logger.LogError("Failed to send quota warning to Telegram: %s", err.Error())
+                }
+        }
+}
+
+// Latest returns the most recently observed quota
+func (t *OddsQuotaTracker) Latest() (OddsQuota, bool) {
+        t.mu.Lock()
+        defer t.mu.Unlock()
+
+        if t.latest == nil {
+                return OddsQuota{}, false
+        }
+        return *t.latest, true
+}
+
+// isLow reports whether remaining is below the warning threshold (0 disables warnings)
+func (t *OddsQuotaTracker) isLow(remaining float64) bool {
+        return t.config.OddsAPIQuotaWarning > 0 && remaining < float64(t.config.OddsAPIQuotaWarning)
+}
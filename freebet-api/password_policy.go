@@ -0,0 +1,64 @@
+package main
+
+import (
+        _ "embed"
+        "fmt"
+        "strings"
+        "unicode"
+)
+
+// commonPasswordsList is a newline-separated list of passwords rejected by the policy
+//
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords is the lowercase lookup set built from commonPasswordsList
+var commonPasswords = func() map[string]bool {
+        set := make(map[string]bool)
+        for _, line := range strings.Split(commonPasswordsList, "\n") {
+                if line = strings.TrimSpace(line); line != "" {
+                        set[strings.ToLower(line)] = true
+                }
+        }
+        return set
+}()
+
+// ValidatePassword checks a password against the configured strength policy
+// Returns an error naming the first rule that failed
+func ValidatePassword(password string, config *Config) error {
+        if len(password) < config.MinPasswordLength {
+                return fmt.Errorf("Password must be at least %d characters long", config.MinPasswordLength)
+        }
+
+        var hasDigit, hasUpper, hasLower, hasSymbol bool
+        for _, c := range password {
+                switch {
+                case unicode.IsDigit(c):
+                        hasDigit = true
+                case unicode.IsUpper(c):
+                        hasUpper = true
+                case unicode.IsLower(c):
+                        hasLower = true
+                case unicode.IsPunct(c) || unicode.IsSymbol(c):
+                        hasSymbol = true
+                }
+        }
+
+        if config.PasswordRequireDigit && !hasDigit {
+                return fmt.Errorf("Password must contain at least one digit")
+        }
+        if config.PasswordRequireUpper && !hasUpper {
+                return fmt.Errorf("Password must contain at least one uppercase letter")
+        }
+        if config.PasswordRequireLower && !hasLower {
+                return fmt.Errorf("Password must contain at least one lowercase letter")
+        }
+        if config.PasswordRequireSymbol && !hasSymbol {
+                return fmt.Errorf("Password must contain at least one symbol")
+        }
+        if config.PasswordRejectCommon && commonPasswords[strings.ToLower(password)] {
+                return fmt.Errorf("Password is too common, please choose another one")
+        }
+
+        return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "crypto/hmac"
+        "crypto/sha256"
+        "encoding/hex"
+        "fmt"
+        "io"
+        "net/http"
+        "os"
+        "path"
+        "path/filepath"
+        "strings"
+        "time"
+)
+
+// Local pictures are served by the API under this path
+const localPicturePath = "/uploads/pictures/"
+
+// pictureUploadPath is the upload route; its bodies are limited by PICTURE_MAX_BYTES, not MAX_REQUEST_BODY_BYTES
+const pictureUploadPath = "/api/account/picture"
+
+// pictureUploadLimit is the largest accepted upload body: the picture plus room for the multipart envelope
+func pictureUploadLimit(config *Config) int64 {
+        return int64(config.PictureMaxBytes) + 64*1024
+}
+
+// pictureTypes maps accepted (sniffed) image types to file extensions
+var pictureTypes = map[string]string{
+        "image/jpeg": ".jpg",
+        "image/png":  ".png",
+        "image/gif":  ".gif",
+        "image/webp": ".webp",
+}
+
+// PictureStore saves uploaded profile pictures and returns their public URL
+type PictureStore interface {
+        Save(ctx context.Context, key, contentType string, data []byte) (string, error)
+        Owns(pictureURL string) bool // Whether the URL points at a picture this store saved
+}
+
+// NewPictureStore returns the backend selected by PICTURE_STORAGE
+func NewPictureStore(config *Config) PictureStore {
+        if config.PictureStorage == "s3" {
+                return &s3PictureStore{
+                        endpoint:  strings.TrimRight(config.PictureS3Endpoint, "/"),
+                        region:    config.PictureS3Region,
+                        bucket:    config.PictureS3Bucket,
+                        accessKey: config.PictureS3AccessKey,
+                        secretKey: config.PictureS3SecretKey,
+                        publicURL: pictureBaseURL(config.PicturePublicURL, strings.TrimRight(config.PictureS3Endpoint, "/")+"/"+config.PictureS3Bucket),
+                        client:    &http.Client{Timeout: 30 * time.Second},
+                }
+        }
+        return &localPictureStore{
+                dir:       config.PictureLocalDir,
+                publicURL: pictureBaseURL(config.PicturePublicURL, strings.TrimSuffix(localPicturePath, "/")),
+        }
+}
+
+// pictureBaseURL returns the configured public URL, or the backend default, without a trailing slash
+func pictureBaseURL(configured, fallback string) string {
+        if configured == "" {
+                configured = fallback
+        }
+        return strings.TrimRight(configured, "/")
+}
+
+// localPictureStore writes pictures to a directory served at localPicturePath
+type localPictureStore struct {
+        dir       string
+        publicURL string
+}
+
+func (s *localPictureStore) Save(ctx context.Context, key, contentType string, data []byte) (string, error) {
+        target := filepath.Join(s.dir, filepath.FromSlash(key))
+        if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+                return "", fmt.Errorf("failed to create picture directory: %w", err)
+        }
+        if err := os.WriteFile(target, data, 0o644); err != nil {
+                return "", fmt.Errorf("failed to write picture: %w", err)
+        }
+        return s.publicURL + "/" + key, nil
+}
+
+func (s *localPictureStore) Owns(pictureURL string) bool {
+        return strings.HasPrefix(pictureURL, s.publicURL+"/")
+}
+
+// s3PictureStore uploads pictures to an S3-compatible bucket (path-style, SigV4)
+type s3PictureStore struct {
+        endpoint  string
+        region    string
+        bucket    string
+        accessKey string
+        secretKey string
+        publicURL string
+        client    *http.Client
+}
+
+func (s *s3PictureStore) Save(ctx context.Context, key, contentType string, data []byte) (string, error) {
+        objectURL := s.endpoint + "/" + s.bucket + "/" + key
+        req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, bytes.NewReader(data))
+        if err != nil {
+                return "", fmt.Errorf("failed to create upload request: %w", err)
+        }
+        req.Header.Set("Content-Type", contentType)
+        req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+        s.sign(req, data, time.Now().UTC())
+
+        resp, err := s.client.Do(req)
+        if err != nil {
+                return "", fmt.Errorf("failed to upload picture: %w", err)
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK {
+                body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+                return "", fmt.Errorf("storage returned status %d: %s", resp.StatusCode, string(body))
+        }
+
+        return s.publicURL + "/" + key, nil
+}
+
+func (s *s3PictureStore) Owns(pictureURL string) bool {
+        return strings.HasPrefix(pictureURL, s.publicURL+"/")
+}
+
+// sign adds AWS Signature Version 4 headers for a single-chunk payload
+func (s *s3PictureStore) sign(req *http.Request, payload []byte, now time.Time) {
+        amzDate := now.Format("20060102T150405Z")
+        day := now.Format("20060102")
+        payloadHash := sha256Hex(payload)
+
+        req.Header.Set("X-Amz-Date", amzDate)
+        req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+        signedHeaders := []string{"cache-control", "content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+        var canonicalHeaders strings.Builder
+        for _, name := range signedHeaders {
+                value := req.Header.Get(name)
+                if name == "host" {
+                        value = req.URL.Host
+                }
+                canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+        }
+
+        canonicalRequest := strings.Join([]string{
+                req.Method,
+                req.URL.EscapedPath(),
+                req.URL.RawQuery,
+                canonicalHeaders.String(),
+                strings.Join(signedHeaders, ";"),
+                payloadHash,
+        }, "\n")
+
+        scope := day + "/" + s.region + "/s3/aws4_request"
+        stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+        signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+        signingKey = hmacSHA256(signingKey, s.region)
+        signingKey = hmacSHA256(signingKey, "s3")
+        signingKey = hmacSHA256(signingKey, "aws4_request")
+        signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+        req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+                s.accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func sha256Hex(data []byte) string {
+        sum := sha256.Sum256(data)
+        return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+        mac := hmac.New(sha256.New, key)
+        mac.Write([]byte(data))
+        return mac.Sum(nil)
+}
+
+// localPictureHandler serves pictures saved by localPictureStore
+// Files only (no directory listings), with the content type taken from the extension
+func localPictureHandler(dir string) http.Handler {
+        contentTypes := make(map[string]string, len(pictureTypes))
+        for contentType, ext := range pictureTypes {
+                contentTypes[ext] = contentType
+        }
+
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, localPicturePath))
+                contentType, ok := contentTypes[path.Ext(name)]
+                if !ok {
+                        http.Error(w, `{"success": false, "code": "NOT_FOUND", "error": "Not found"}`, http.StatusNotFound)
+                        return
+                }
+
+                file, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+                if err != nil {
+                        http.Error(w, `{"success": false, "code": "NOT_FOUND", "error": "Not found"}`, http.StatusNotFound)
+                        return
+                }
+                defer file.Close()
+
+                info, err := file.Stat()
+                if err != nil || info.IsDir() {
+                        http.Error(w, `{"success": false, "code": "NOT_FOUND", "error": "Not found"}`, http.StatusNotFound)
+                        return
+                }
+
+                // File names are random per upload, so they never change
+                w.Header().Set("Content-Type", contentType)
+                w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+                http.ServeContent(w, r, name, info.ModTime(), file)
+        })
+}
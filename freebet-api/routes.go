@@ -2,60 +2,119 @@ package main
 
 import (
         "net/http"
+        "strings"
 
         "github.com/gorilla/mux"
 )
 
 // SetupRoutes configures all routes and middleware
-func SetupRoutes(db Database, config *Config, logger *Logger) *mux.Router {
+func SetupRoutes(db Database, config *Config, logger *Logger, syncService *SyncService) *mux.Router {
         // Create router
         router := mux.NewRouter()
 
         // Create handler instance
-        handler := NewHandler(db, config, logger)
+        handler := NewHandler(db, config, logger, syncService)
 
         // Apply global middleware (excluding logging which is handled in main.go)
         router.Use(mux.MiddlewareFunc(contentTypeMiddleware)) // JSON content type
         router.Use(mux.MiddlewareFunc(securityHeadersMiddleware(config))) // Security headers
         router.Use(mux.MiddlewareFunc(corsMiddleware(config))) // CORS
+        router.Use(mux.MiddlewareFunc(compressionMiddleware(config))) // Gzip compression
         router.Use(mux.MiddlewareFunc(recoveryMiddleware(logger))) // Panic recovery
+        router.Use(mux.MiddlewareFunc(localeMiddleware(config))) // Content-Language from Accept-Language
+        router.Use(mux.MiddlewareFunc(maintenanceMiddleware(handler.maintenance, config))) // 503 for users during maintenance
         router.Use(mux.MiddlewareFunc(rateLimitMiddleware(config, logger))) // Rate limiting
+        router.Use(mux.MiddlewareFunc(bodyLimitMiddleware(config))) // Request body size limit
 
         // Root endpoint (no auth required)
         router.HandleFunc("/", handler.rootHandler).Methods("GET")
 
+        // Uploaded profile pictures (local storage only; S3 serves its own)
+        if config.PictureStorage == "local" {
+                router.PathPrefix(localPicturePath).Handler(localPictureHandler(config.PictureLocalDir)).Methods("GET")
+        }
+
         // API routes
         api := router.PathPrefix("/api").Subrouter()
         api.HandleFunc("/health", handler.healthHandler).Methods("GET")
+        api.HandleFunc("/health/live", handler.healthLiveHandler).Methods("GET") // Liveness, no database check
+        api.HandleFunc("/version", handler.versionHandler).Methods("GET")
+        api.HandleFunc("/openapi.json", handler.openAPIHandler).Methods("GET") // OpenAPI 3 spec
+        api.HandleFunc("/docs", handler.docsHandler).Methods("GET")            // Swagger UI
         // api.HandleFunc("/analytics", handler.analyticsHandler).Methods("GET") // Temporarily disabled
 
-        // Auth routes (no auth required - handle JWT validation internally)
+        // Auth routes (no auth required)
         auth := api.PathPrefix("/auth").Subrouter()
         auth.HandleFunc("/register", handler.registerHandler).Methods("POST")
         auth.HandleFunc("/login", handler.loginHandler).Methods("POST")
-        auth.HandleFunc("/user", handler.userHandler).Methods("GET")          // Validates JWT access token
         auth.HandleFunc("/logout", handler.logoutHandler).Methods("POST")     // Clears refresh token cookie
         auth.HandleFunc("/refresh", handler.refreshTokenHandler).Methods("POST") // Refreshes access token
-        auth.HandleFunc("/topup", handler.topupHandler).Methods("POST")       // Validates JWT access token
-        auth.HandleFunc("/change-password", handler.changePasswordHandler).Methods("POST") // Validates JWT access token
+        auth.HandleFunc("/guest", handler.guestHandler).Methods("POST")          // Guest account, upgrade via /api/account/upgrade
 
         // Google OAuth routes
         auth.HandleFunc("/google", handler.googleLoginHandler).Methods("GET")      // Initiates OAuth flow
         auth.HandleFunc("/google/callback", handler.googleCallbackHandler).Methods("GET") // OAuth callback
-
-        // Bets routes (handle session check internally like Node.js)
-        api.HandleFunc("/bets", handler.getBetsHandler).Methods("GET")
-        api.HandleFunc("/bets", handler.placeBetHandler).Methods("POST")
+        auth.HandleFunc("/exchange", handler.exchangeCodeHandler).Methods("POST")         // One-time code from the callback redirect -> tokens
 
         // Matches routes (no auth required)
         api.HandleFunc("/matches", handler.getMatchesHandler).Methods("GET")
 
         // Players routes (no auth required)
         api.HandleFunc("/players", handler.getPlayersHandler).Methods("GET")
+        api.HandleFunc("/players/compare", handler.comparePlayersHandler).Methods("GET") // ?a=nick1&b=nick2
+        api.HandleFunc("/players/{nickname}/bets", handler.getPlayerBetsHandler).Methods("GET")
+
+        // Seasonal leaderboard (no auth required)
+        api.HandleFunc("/leaderboard", handler.leaderboardHandler).Methods("GET") // ?season=current|previous|<id>
+
+        // Platform stats (no auth required, cached for STATS_CACHE_TTL)
+        api.HandleFunc("/stats", handler.statsHandler).Methods("GET")
+
+        // User routes (require JWT access token)
+        userAuth := api.PathPrefix("").Subrouter()
+        userAuth.Use(mux.MiddlewareFunc(jwtAuthMiddleware(db, config, logger)))
+        userAuth.HandleFunc("/auth/user", handler.userHandler).Methods("GET")
+        userAuth.HandleFunc("/auth/logout-all", handler.logoutAllHandler).Methods("POST") // Revokes all sessions
+        userAuth.HandleFunc("/auth/topup", handler.topupHandler).Methods("POST")
+        userAuth.HandleFunc("/auth/change-password", handler.changePasswordHandler).Methods("POST")
+        userAuth.HandleFunc("/auth/limits", handler.getLimitsHandler).Methods("GET")
+        userAuth.HandleFunc("/auth/limits", handler.setLimitsHandler).Methods("PUT")
+        userAuth.HandleFunc("/auth/self-exclusion", handler.selfExclusionHandler).Methods("POST") // Cannot be shortened
+        userAuth.HandleFunc("/account/nickname", handler.changeNicknameHandler).Methods("POST")
+        userAuth.HandleFunc("/account/picture", handler.uploadPictureHandler).Methods("PUT") // Multipart "picture" field
+        userAuth.HandleFunc("/account/ledger", handler.getLedgerHandler).Methods("GET")    // ?limit=&offset=
+        userAuth.HandleFunc("/account/topup-status", handler.topupStatusHandler).Methods("GET") // Eligibility, next_available_at, streak
+        userAuth.HandleFunc("/account/upgrade", handler.upgradeGuestHandler).Methods("POST")             // Guest -> email account
+        userAuth.HandleFunc("/account/upgrade/google", handler.upgradeGuestGoogleHandler).Methods("POST") // Guest -> Google account; returns auth_url
+        userAuth.HandleFunc("/notifications", handler.getNotificationsHandler).Methods("GET")            // ?unread=true&limit=&offset=
+        userAuth.HandleFunc("/notifications/{id}/read", handler.markNotificationReadHandler).Methods("POST")
+        userAuth.HandleFunc("/bets", handler.getBetsHandler).Methods("GET")
+        userAuth.HandleFunc("/bets", handler.placeBetHandler).Methods("POST")
+        userAuth.HandleFunc("/bets/batch", handler.placeBetsBatchHandler).Methods("POST") // Independent singles, one transaction
 
-        // Admin sync routes (require admin auth)
+        // Admin login (issues admin token, no auth required)
+        api.HandleFunc("/admin/login", handler.adminLoginHandler).Methods("POST")
+
+        // Admin sync routes (require admin auth: Bearer admin token or Basic Auth)
+        // Paths outside /api/admin/ must also be listed in maintenanceExemptPrefixes
         adminSync := api.PathPrefix("").Subrouter()
-        adminSync.Use(mux.MiddlewareFunc(adminAuthMiddleware(db, logger)))
+        adminSync.Use(mux.MiddlewareFunc(adminAuthMiddleware(db, config, logger)))
+        adminSync.HandleFunc("/health/detailed", handler.healthDetailedHandler).Methods("GET") // Counts, client IP, pool stats
+        adminSync.HandleFunc("/admin/revoke", handler.adminRevokeHandler).Methods("POST")
+        adminSync.HandleFunc("/admin/odds-quota", handler.adminOddsQuotaHandler).Methods("GET")
+        adminSync.HandleFunc("/admin/exposure", handler.adminExposureHandler).Methods("GET") // Liability on pending bets
+        adminSync.HandleFunc("/admin/matches/{id}", handler.adminMatchHandler).Methods("GET") // Stored match incl. odds_source
+        adminSync.HandleFunc("/admin/bets/review", handler.adminReviewBetsHandler).Methods("GET") // Large bets awaiting approval
+        adminSync.HandleFunc("/admin/bets/{id}/approve", handler.adminApproveBetHandler).Methods("POST")
+        adminSync.HandleFunc("/admin/bets/{id}/reject", handler.adminRejectBetHandler).Methods("POST") // Refunds the reserved stake
+        adminSync.HandleFunc("/admin/log-level", handler.adminLogLevelHandler).Methods("GET")
+        adminSync.HandleFunc("/admin/maintenance", handler.adminMaintenanceHandler).Methods("GET")
+        adminSync.HandleFunc("/admin/maintenance", handler.adminSetMaintenanceHandler).Methods("POST") // {"enabled": true|false}
+        adminSync.HandleFunc("/admin/log-level", handler.adminSetLogLevelHandler).Methods("POST") // Until restart; LOG_LEVEL is the default
+        adminSync.HandleFunc("/admin/seasons/close", handler.adminCloseSeasonHandler).Methods("POST")
+        adminSync.HandleFunc("/admin/users/{id}/disable", handler.adminDisableUserHandler).Methods("POST")
+        adminSync.HandleFunc("/admin/users/{id}/enable", handler.adminEnableUserHandler).Methods("POST")
+        adminSync.HandleFunc("/admin/users/{id}/adjust-balance", handler.adminAdjustBalanceHandler).Methods("POST")
         adminSync.HandleFunc("/odds/sync", handler.oddsSyncHandler).Methods("POST")
         adminSync.HandleFunc("/scores/sync", handler.scoresSyncHandler).Methods("POST")
         adminSync.HandleFunc("/calc", handler.calcHandler).Methods("POST")
@@ -65,5 +124,35 @@ func SetupRoutes(db Database, config *Config, logger *Logger) *mux.Router {
                 w.WriteHeader(http.StatusOK)
         })
 
+        // JSON errors for unmatched routes (instead of mux's plain text 404/405)
+        unmatched := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                if allowed := allowedMethods(router, r); len(allowed) > 0 {
+                        w.Header().Set("Allow", strings.Join(allowed, ", "))
+                        handler.methodNotAllowedHandler(w, r)
+                        return
+                }
+                handler.notFoundHandler(w, r)
+        })
+        router.NotFoundHandler = unmatched
+        router.MethodNotAllowedHandler = unmatched
+
         return router
 }
+
+// allowedMethods returns the methods that would match the request's path
+// mux's own method-mismatch tracking is lost across subrouters, so probe each method
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+        var allowed []string
+        for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+                if method == r.Method {
+                        continue
+                }
+                probe := r.Clone(r.Context())
+                probe.Method = method
+                var match mux.RouteMatch
+                if router.Match(probe, &match) && match.MatchErr == nil {
+                        allowed = append(allowed, method)
+                }
+        }
+        return allowed
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+        "net/http"
+        "testing"
+)
+
+func TestUnmatchedRoutesReturnJSON(t *testing.T) {
+        s := newTestServer(t)
+
+        tests := []struct {
+                name   string
+                method string
+                path   string
+                status int
+                code   string
+                allow  string
+        }{
+                {"unknown path", "GET", "/api/does-not-exist", http.StatusNotFound, CodeNotFound, ""},
+                {"unknown path outside /api", "POST", "/nowhere", http.StatusNotFound, CodeNotFound, ""},
+                {"wrong method on /api/bets", "DELETE", "/api/bets", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET, POST"},
+                {"wrong method on /api/bets without auth", "PUT", "/api/bets", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET, POST"},
+                {"wrong method on a public route", "POST", "/api/matches", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET"},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        w := s.do(tt.method, tt.path, "", nil)
+                        var response map[string]interface{}
+                        decodeResponse(t, w, tt.status, &response)
+                        if got := w.Header().Get("Content-Type"); got != "application/json" {
+                                t.Errorf("Content-Type = %q, want application/json", got)
+                        }
+                        if response["success"] != false || response["code"] != tt.code || response["error"] == "" {
+                                t.Errorf("body = %v, want the error envelope with code %s", response, tt.code)
+                        }
+                        if got := w.Header().Get("Allow"); got != tt.allow {
+                                t.Errorf("Allow = %q, want %q", got, tt.allow)
+                        }
+                })
+        }
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "sync"
+        "time"
+)
+
+// Scheduler periodically runs odds sync, scores sync, bet calculation and guest cleanup
+// Each job is enabled by its own config flag and runs on its own ticker
+type Scheduler struct {
+        sync   *SyncService
+        db     Database
+        config *Config
+        logger *Logger
+}
+
+// NewScheduler creates a new scheduler instance
+func NewScheduler(syncService *SyncService, db Database, config *Config, logger *Logger) *Scheduler {
+        return &Scheduler{
+                sync:   syncService,
+                db:     db,
+                config: config,
+                logger: logger,
+        }
+}
+
+// Start launches the enabled jobs; they stop when ctx is cancelled
+// Each job is tracked on wg so shutdown can wait for in-flight runs
+func (s *Scheduler) Start(ctx context.Context, wg *sync.WaitGroup) {
+        if s.config.EnableOddsSyncCron {
+                s.startJob(ctx, wg, "ODDS_SYNC", s.config.OddsSyncInterval, func(ctx context.Context) error {
+                        result, err := s.sync.SyncOdds(ctx, false)
+                        if err != nil {
+                                return err
+                        }
+                        s.logger.LogSuccess("Odds sync completed: created=%d, updated=%d, merged=%d, skipped=%d", result.Created, result.Updated, result.Merged, result.Skipped)
+                        return nil
+                })
+        }
+
+        if s.config.EnableScoresSyncCron {
+                s.startJob(ctx, wg, "SCORES_SYNC", s.config.ScoresSyncInterval, func(ctx context.Context) error {
+                        result, err := s.sync.SyncScores(ctx, false)
+                        if err != nil {
+                                return err
+                        }
+                        s.logger.LogSuccess("Scores sync completed: created=%d, updated=%d, merged=%d, skipped=%d", result.Created, result.Updated, result.Merged, result.Skipped)
+                        return nil
+                })
+        }
+
+        if s.config.EnableCalcCron {
+                s.startJob(ctx, wg, "CALC", s.config.CalcInterval, func(ctx context.Context) error {
+                        result, err := s.sync.CalculateMatches(ctx)
+                        if errors.Is(err, ErrCalcInProgress) {
+                                s.logger.LogSystem("CALC", "Skipping scheduled run: another calculation is in progress")
+                                return nil
+                        }
+                        if err != nil {
+                                return err
+                        }
+                        s.logger.LogSuccess("Calculation completed: %d matches processed, %d voided", result.Updated, result.Voided)
+                        return nil
+                })
+        }
+
+        if s.config.GuestInactivityTTL > 0 {
+                s.startJob(ctx, wg, "GUEST_CLEANUP", s.config.GuestCleanupInterval, func(ctx context.Context) error {
+                        deleted, err := s.db.DeleteInactiveGuests(ctx, s.config.now().Add(-s.config.GuestInactivityTTL))
+                        if err != nil {
+                                return err
+                        }
+                        if deleted > 0 {
+                                s.logger.LogSuccess("Deleted %d inactive guest accounts", deleted)
+                        }
+                        return nil
+                })
+        }
+}
+
+// startJob runs fn every interval until ctx is cancelled
+func (s *Scheduler) startJob(ctx context.Context, wg *sync.WaitGroup, category string, interval time.Duration, fn func(ctx context.Context) error) {
+        if interval <= 0 {
+                s.logger.LogWarning("[%s] Scheduled job disabled: invalid interval %v", category, interval)
+                return
+        }
+
+        s.logger.LogSystem(category, "Scheduled job enabled, interval: %v", interval)
+
+        wg.Add(1)
+        go func() {
+                defer wg.Done()
+
+                ticker := time.NewTicker(interval)
+                defer ticker.Stop()
+
+                for {
+                        select {
+                        case <-ctx.Done():
+                                s.logger.LogSystem(category, "Scheduled job stopped")
+                                return
+                        case <-ticker.C:
+                                start := time.Now()
+                                if err := fn(ctx); err != nil {
+                                        s.logger.LogError("[%s] Scheduled run failed: %s", category, err.Error())
+                                        continue
+                                }
+                                s.logger.LogSystem(category, "Scheduled run finished in %v", time.Since(start).Round(time.Millisecond))
+                        }
+                }
+        }()
+}
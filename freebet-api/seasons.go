@@ -0,0 +1,36 @@
+package main
+
+import (
+        "sort"
+        "time"
+)
+
+// seasonBounds returns the season containing t: SeasonMonths calendar months (UTC),
+// aligned to January so monthly seasons start on the 1st and quarterly ones on Jan/Apr/Jul/Oct
+func seasonBounds(t time.Time, months int) (time.Time, time.Time) {
+        t = t.UTC()
+        firstMonth := int(t.Month()-1)/months*months + 1
+        start := time.Date(t.Year(), time.Month(firstMonth), 1, 0, 0, 0, 0, time.UTC)
+        return start, start.AddDate(0, months, 0)
+}
+
+// rankStandings orders standings like the leaderboard query (profit, wins, bets, nickname)
+// and numbers them from 1
+func rankStandings(standings []SeasonStanding) {
+        sort.Slice(standings, func(i, j int) bool {
+                a, b := standings[i], standings[j]
+                if a.Profit != b.Profit {
+                        return a.Profit > b.Profit
+                }
+                if a.WonBets != b.WonBets {
+                        return a.WonBets > b.WonBets
+                }
+                if a.Bets != b.Bets {
+                        return a.Bets > b.Bets
+                }
+                return a.Nickname < b.Nickname
+        })
+        for i := range standings {
+                standings[i].Rank = i + 1
+        }
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+        "bytes"
+        "context"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+        "strings"
+        "time"
+)
+
+// How a new account signed up
+const (
+        signupPassword = "password" // POST /api/auth/register
+        signupGoogle   = "google"   // First Google login
+)
+
+// Hook names for USER_CREATED_HOOKS
+const (
+        userCreatedHookLog     = "log"
+        userCreatedHookWebhook = "webhook"
+)
+
+// userCreatedHookNames are the hooks USER_CREATED_HOOKS may list
+var userCreatedHookNames = []string{userCreatedHookLog, userCreatedHookWebhook}
+
+// UserCreatedHook reacts to a new account (onboarding email, analytics, ...)
+// Hooks run after the response is on its way; an error is only logged
+type UserCreatedHook interface {
+        Name() string
+        UserCreated(ctx context.Context, user User, method string) error
+}
+
+// MultiUserCreatedHook fans a signup out to every configured hook
+// One failing hook does not stop the others; their errors are joined
+type MultiUserCreatedHook []UserCreatedHook
+
+// NewUserCreatedHooks builds the hooks listed in USER_CREATED_HOOKS; nil if none are
+func NewUserCreatedHooks(config *Config, logger *Logger) UserCreatedHook {
+        var hooks MultiUserCreatedHook
+        for _, name := range config.UserCreatedHooks {
+                switch name {
+                case userCreatedHookLog:
+                        hooks = append(hooks, &LogUserCreatedHook{logger: logger})
+                case userCreatedHookWebhook:
+                        hooks = append(hooks, &WebhookUserCreatedHook{
+                                client: &http.Client{Timeout: config.UserCreatedWebhookTimeout},
+                                url:    config.UserCreatedWebhookURL,
+                        })
+                }
+        }
+        if len(hooks) == 0 {
+                return nil
+        }
+        return hooks
+}
+
+// Name lists the wrapped hooks
+func (m MultiUserCreatedHook) Name() string {
+        names := make([]string, len(m))
+        for i, hook := range m {
+                names[i] = hook.Name()
+        }
+        return strings.Join(names, ", ")
+}
+
+// UserCreated runs every hook, even after one fails
+func (m MultiUserCreatedHook) UserCreated(ctx context.Context, user User, method string) error {
+        var errs []error
+        for _, hook := range m {
+                if err := hook.UserCreated(ctx, user, method); err != nil {
+                        errs = append(errs, fmt.Errorf("%s: %w", hook.Name(), err))
+                }
+        }
+        return errors.Join(errs...)
+}
+
+// LogUserCreatedHook records each signup in the server log
+type LogUserCreatedHook struct {
+        logger *Logger
+}
+
+// Name identifies the hook in logs
+func (l *LogUserCreatedHook) Name() string {
+        return userCreatedHookLog
+}
+
+// UserCreated logs the new account
+func (l *LogUserCreatedHook) UserCreated(ctx context.Context, user User, method string) error {
+        l.logger.LogSystem("SIGNUP", "New user %s (%s) signed up via %s", user.Nickname, user.ID, method)
+        return nil
+}
+
+// WebhookUserCreatedHook POSTs each signup as JSON to USER_CREATED_WEBHOOK_URL
+type WebhookUserCreatedHook struct {
+        client *http.Client // Bounded by UserCreatedWebhookTimeout
+        url    string
+}
+
+// userCreatedEvent is the webhook body; never the password hash or balance
+type userCreatedEvent struct {
+        Event     string    `json:"event"` // Always "user.created"
+        UserID    string    `json:"user_id"`
+        Email     string    `json:"email"`
+        Nickname  string    `json:"nickname"`
+        Method    string    `json:"method"` // "password" or "google"
+        CreatedAt time.Time `json:"created_at"`
+}
+
+// Name identifies the hook in logs
+func (wh *WebhookUserCreatedHook) Name() string {
+        return userCreatedHookWebhook
+}
+
+// UserCreated posts the event; any non-2xx status is an error
+func (wh *WebhookUserCreatedHook) UserCreated(ctx context.Context, user User, method string) error {
+        jsonData, err := json.Marshal(userCreatedEvent{
+                Event:     "user.created",
+                UserID:    user.ID,
+                Email:     user.Email,
+                Nickname:  user.Nickname,
+                Method:    method,
+                CreatedAt: user.CreatedAt.UTC(),
+        })
+        if err != nil {
+                return fmt.Errorf("failed to marshal payload: %w", err)
+        }
+
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewBuffer(jsonData))
+        if err != nil {
+                return fmt.Errorf("failed to create request: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+
+        resp, err := wh.client.Do(req)
+        if err != nil {
+                // The URL may embed a token; keep it out of the error
+                return fmt.Errorf("failed to send request: %w", errors.Unwrap(err))
+        }
+        defer resp.Body.Close()
+
+        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+                return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(body))
+        }
+        return nil
+}
+
+// userCreated runs the signup hooks in the background, so a slow or failing hook can
+// neither delay nor fail the registration that triggered it
+func (h *Handler) userCreated(user *User, method string) {
+        if h.userCreatedHook == nil {
+                return
+        }
+        created := *user
+        go func() {
+                defer func() {
+                        if recovered := recover(); recovered != nil {
+                                h.logger.LogError("Signup hook panicked for user %s: %v", created.ID, recovered)
+                        }
+                }()
+                if err := h.userCreatedHook.UserCreated(context.Background(), created, method); err != nil {
+                        h.logger.LogError("Signup hook failed for user %s: %s", created.ID, err.Error())
+                }
+        }()
+}
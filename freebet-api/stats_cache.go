@@ -0,0 +1,41 @@
+package main
+
+import (
+        "context"
+        "sync"
+        "time"
+)
+
+// StatsCache holds the platform stats for StatsCacheTTL, since the aggregate queries
+// scan every bet. Concurrent misses wait for a single reload instead of each querying
+type StatsCache struct {
+        mu          sync.Mutex
+        config      *Config
+        stats       *PlatformStats
+        generatedAt time.Time
+}
+
+// NewStatsCache creates an empty stats cache
+func NewStatsCache(config *Config) *StatsCache {
+        return &StatsCache{config: config}
+}
+
+// Get returns the cached stats and when they were computed, reloading them with load
+// once the entry is older than StatsCacheTTL (every call reloads when the TTL is 0)
+func (c *StatsCache) Get(ctx context.Context, load func(ctx context.Context) (*PlatformStats, error)) (*PlatformStats, time.Time, error) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        now := c.config.now()
+        if c.stats != nil && now.Before(c.generatedAt.Add(c.config.StatsCacheTTL)) {
+                return c.stats, c.generatedAt, nil
+        }
+
+        stats, err := load(ctx)
+        if err != nil {
+                return nil, time.Time{}, err
+        }
+        c.stats = stats
+        c.generatedAt = now
+        return stats, now, nil
+}
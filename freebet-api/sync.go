@@ -0,0 +1,688 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "fmt"
+        "net/http"
+        "reflect"
+        "strings"
+        "time"
+)
+
+// SyncService runs odds sync, scores sync and bet calculation
+// Shared by the admin HTTP handlers and the background scheduler
+type SyncService struct {
+        db      Database
+        config  *Config
+        logger  *Logger
+        matches *MatchesCache     // Invalidated after syncs that touch matches
+        quota   *OddsQuotaTracker // Latest Odds API quota from sync responses
+        backoff *OddsAPIBackoff   // Pauses Odds API calls after a 429
+        teams   *TeamNames        // Team name aliases shared by odds and scores
+        notifier Notifier         // Settlement announcements (Telegram, Discord, ...)
+
+        oddsClient     *http.Client // Odds API requests, bounded by OddsAPITimeout
+        telegramClient *http.Client // Telegram requests, bounded by TelegramTimeout
+}
+
+// NewSyncService creates a new sync service instance
+func NewSyncService(db Database, config *Config, logger *Logger, notifier Notifier) *SyncService {
+        telegramClient := &http.Client{Timeout: config.TelegramTimeout}
+        return &SyncService{
+                db:             db,
+                config:         config,
+                logger:         logger,
+                matches:        NewMatchesCache(config),
+                quota:          NewOddsQuotaTracker(config, logger, telegramClient),
+                backoff:        NewOddsAPIBackoff(config, logger),
+                teams:          NewTeamNames(config),
+                notifier:       notifier,
+                oddsClient:     &http.Client{Timeout: config.OddsAPITimeout},
+                telegramClient: telegramClient,
+        }
+}
+
+// OddsSyncResult holds the outcome of an odds sync run
+type OddsSyncResult struct {
+        SyncChanges
+        SyncSkips
+        APIStats *APIStats
+        DryRun   bool // Changes were computed but not written
+}
+
+// ScoresSyncResult holds the outcome of a scores sync run
+type ScoresSyncResult struct {
+        SyncChanges
+        SyncSkips
+        APIStats *APIStats
+        DryRun   bool // Changes were computed but not written
+}
+
+// Actions a sync run takes on a match
+const (
+        syncCreate = "create"
+        syncUpdate = "update"
+        syncMerge  = "merge" // Update of the same fixture stored under another api_id (e.g. a scores-only row)
+)
+
+// FieldChange is the old and new value of one match column
+type FieldChange struct {
+        From interface{} `json:"from"`
+        To   interface{} `json:"to"`
+}
+
+// SyncChange is a write a sync run applied, or would apply in a dry run
+type SyncChange struct {
+        Action       string                 `json:"action"`
+        EventID      string                 `json:"event_id"` // API event the change comes from
+        APIID        string                 `json:"api_id"`   // Match row written; differs from event_id on merges
+        HomeTeam     string                 `json:"home_team"`
+        AwayTeam     string                 `json:"away_team"`
+        CommenceTime time.Time              `json:"commence_time"`
+        Fields       map[string]FieldChange `json:"fields,omitempty"` // Columns that change; every written column on create
+
+        match *Match // Row to write
+}
+
+// SyncChanges counts and lists the writes of a sync run
+type SyncChanges struct {
+        Created int
+        Updated int
+        Merged  int
+        Changes []SyncChange
+}
+
+// record counts a change under its action
+func (c *SyncChanges) record(change *SyncChange) {
+        switch change.Action {
+        case syncCreate:
+                c.Created++
+        case syncUpdate:
+                c.Updated++
+        case syncMerge:
+                c.Merged++
+        }
+        c.Changes = append(c.Changes, *change)
+}
+
+// Reasons a sync run skips an event
+const (
+        skipInvalidEvent  = "invalid event"  // Missing or identical team names
+        skipNoOdds        = "no odds"        // New match without home/draw/away prices
+        skipKickoffPassed = "kickoff passed" // New match that has already started
+        skipLookupFailed  = "lookup failed"
+        skipSaveFailed    = "save failed"
+)
+
+// SkippedEvent is an API event a sync run did not apply
+type SkippedEvent struct {
+        APIID  string `json:"api_id"`
+        Reason string `json:"reason"`
+        Detail string `json:"detail,omitempty"`
+}
+
+// SyncSkips collects the events a sync run skipped and why
+type SyncSkips struct {
+        Skipped       int
+        SkippedEvents []SkippedEvent
+}
+
+// skip records a skipped event; err, when set, becomes the detail
+func (s *SyncSkips) skip(apiID, reason string, err error) {
+        event := SkippedEvent{APIID: apiID, Reason: reason}
+        if err != nil {
+                event.Detail = err.Error()
+        }
+        s.Skipped++
+        s.SkippedEvents = append(s.SkippedEvents, event)
+}
+
+// summary counts skipped events per reason, e.g. "no odds=2, kickoff passed=1"
+func (s *SyncSkips) summary() string {
+        counts := map[string]int{}
+        var reasons []string
+        for _, event := range s.SkippedEvents {
+                if counts[event.Reason] == 0 {
+                        reasons = append(reasons, event.Reason)
+                }
+                counts[event.Reason]++
+        }
+        parts := make([]string, len(reasons))
+        for i, reason := range reasons {
+                parts[i] = fmt.Sprintf("%s=%d", reason, counts[reason])
+        }
+        return strings.Join(parts, ", ")
+}
+
+// logSkips writes the skip summary for a finished sync run
+func (s *SyncService) logSkips(category string, skips *SyncSkips) {
+        if skips.Skipped > 0 {
+                s.logger.LogSystem(category, "Skipped %d events: %s", skips.Skipped, skips.summary())
+        }
+}
+
+// CalcResult holds the outcome of a bet calculation run
+type CalcResult struct {
+        Updated        int
+        Voided         int                      // Scoreless matches voided after the grace period
+        Matches        []SettledMatch           // Settled and voided matches
+        AwaitingScores []map[string]interface{} // Completed matches still missing scores
+}
+
+// findExistingMatch looks a synced match up by api_id, falling back to the same fixture
+// (normalized teams, kickoff within sameFixtureWindow) stored under another api_id
+// On a fallback hit match.APIID is switched to the stored one so updates land on it,
+// and merged is true. Returns nil when the match is new
+func (s *SyncService) findExistingMatch(ctx context.Context, category string, match *Match) (existing *Match, merged bool, err error) {
+        existing, err = s.db.GetMatchByAPIID(ctx, match.APIID)
+        if err == nil {
+                return existing, false, nil
+        }
+        if !errors.Is(err, ErrMatchNotFound) {
+                return nil, false, err
+        }
+
+        existing, err = s.db.FindMatchByTeams(ctx, match.HomeTeam, match.AwayTeam, match.CommenceTime, sameFixtureWindow)
+        if errors.Is(err, ErrMatchNotFound) {
+                return nil, false, nil
+        }
+        if err != nil {
+                return nil, false, err
+        }
+
+        s.logger.LogSystem(category, "Merging %s vs %s (api_id %s) into existing match %s by teams and kickoff",
+                match.HomeTeam, match.AwayTeam, match.APIID, existing.APIID)
+        match.APIID = existing.APIID
+        match.Completed = match.Completed || existing.Completed
+        return existing, true, nil
+}
+
+// newSyncChange describes writing match over existing (nil when the match is new)
+func newSyncChange(eventID string, match, existing *Match, merged bool) *SyncChange {
+        change := &SyncChange{
+                Action:       syncCreate,
+                EventID:      eventID,
+                APIID:        match.APIID,
+                HomeTeam:     match.HomeTeam,
+                AwayTeam:     match.AwayTeam,
+                CommenceTime: match.CommenceTime,
+                Fields:       diffMatch(existing, match),
+                match:        match,
+        }
+        if existing != nil {
+                change.Action = syncUpdate
+                if merged {
+                        change.Action = syncMerge
+                }
+        }
+        return change
+}
+
+// planOddsChange decides how an odds event applies to the stored match (nil when new)
+// Missing prices keep the stored ones; a new match without prices (no draw needed without
+// hasDraw) or past kickoff is skipped with the returned reason
+func planOddsChange(eventID string, match, existing *Match, merged, hasDraw bool, now time.Time) (*SyncChange, string) {
+        if existing != nil {
+                if match.HomeOdds == nil {
+                        match.HomeOdds = existing.HomeOdds
+                }
+                if match.DrawOdds == nil {
+                        match.DrawOdds = existing.DrawOdds
+                }
+                if match.AwayOdds == nil {
+                        match.AwayOdds = existing.AwayOdds
+                }
+                return newSyncChange(eventID, match, existing, merged), ""
+        }
+
+        // Create new match - only if has odds and is still to be played
+        if match.HomeOdds == nil || match.AwayOdds == nil || (hasDraw && match.DrawOdds == nil) {
+                return nil, skipNoOdds
+        }
+        if !match.CommenceTime.After(now) {
+                return nil, skipKickoffPassed
+        }
+        return newSyncChange(eventID, match, nil, false), ""
+}
+
+// planScoresChange decides how a score event applies to the stored match (nil when new)
+// Scores never touch odds; new matches are created without them
+func planScoresChange(eventID string, match, existing *Match, merged bool) *SyncChange {
+        if existing != nil {
+                match.HomeOdds = existing.HomeOdds
+                match.DrawOdds = existing.DrawOdds
+                match.AwayOdds = existing.AwayOdds
+        } else {
+                match.HomeOdds = nil
+                match.DrawOdds = nil
+                match.AwayOdds = nil
+        }
+        return newSyncChange(eventID, match, existing, merged)
+}
+
+// diffMatch lists the columns UpdateMatchByAPIID would change on existing; with existing nil,
+// every column a create writes
+func diffMatch(existing, match *Match) map[string]FieldChange {
+        var stored Match
+        if existing != nil {
+                stored = *existing
+        }
+
+        fields := map[string]FieldChange{}
+        add := func(name string, from, to interface{}) {
+                if existing == nil {
+                        from = nil
+                } else if reflect.DeepEqual(from, to) {
+                        return
+                }
+                fields[name] = FieldChange{From: from, To: to}
+        }
+
+        if match.HomeTeam != "" {
+                add("home_team", stored.HomeTeam, match.HomeTeam)
+        }
+        if match.AwayTeam != "" {
+                add("away_team", stored.AwayTeam, match.AwayTeam)
+        }
+        if !match.CommenceTime.IsZero() {
+                add("commence_time", stored.CommenceTime.UTC(), match.CommenceTime.UTC())
+        }
+        for _, odds := range []struct {
+                name     string
+                from, to *float64
+        }{
+                {"home_odds", stored.HomeOdds, match.HomeOdds},
+                {"draw_odds", stored.DrawOdds, match.DrawOdds},
+                {"away_odds", stored.AwayOdds, match.AwayOdds},
+                {"btts_yes_odds", stored.BTTSYesOdds, match.BTTSYesOdds},
+                {"btts_no_odds", stored.BTTSNoOdds, match.BTTSNoOdds},
+        } {
+                if odds.to != nil {
+                        add(odds.name, floatValue(odds.from), *odds.to)
+                }
+        }
+        if len(match.CorrectScoreOdds) > 0 {
+                add("correct_score_odds", stored.CorrectScoreOdds, match.CorrectScoreOdds)
+        }
+        if len(match.TotalsOdds) > 0 {
+                add("totals_odds", stored.TotalsOdds, match.TotalsOdds)
+        }
+        if match.HomeScore != nil {
+                add("home_score", intValue(stored.HomeScore), *match.HomeScore)
+        }
+        if match.AwayScore != nil {
+                add("away_score", intValue(stored.AwayScore), *match.AwayScore)
+        }
+        if match.ShootoutWinner != nil {
+                var from interface{}
+                if stored.ShootoutWinner != nil {
+                        from = *stored.ShootoutWinner
+                }
+                add("shootout_winner", from, *match.ShootoutWinner)
+        }
+        if match.OddsSource != nil {
+                add("odds_source", stringValue(stored.OddsSource), *match.OddsSource)
+        }
+        add("completed", stored.Completed, match.Completed)
+
+        if len(fields) == 0 {
+                return nil
+        }
+        return fields
+}
+
+// floatValue returns *p, or nil for a nil pointer
+func floatValue(p *float64) interface{} {
+        if p == nil {
+                return nil
+        }
+        return *p
+}
+
+// stringValue returns *p, or nil for a nil pointer
+func stringValue(p *string) interface{} {
+        if p == nil {
+                return nil
+        }
+        return *p
+}
+
+// intValue returns *p, or nil for a nil pointer
+func intValue(p *int) interface{} {
+        if p == nil {
+                return nil
+        }
+        return *p
+}
+
+// applyChange writes a planned change; a create the unique fixture guard merged into an
+// existing row becomes a merge
+func (s *SyncService) applyChange(ctx context.Context, change *SyncChange) error {
+        if change.Action != syncCreate {
+                _, err := s.db.UpdateMatchByAPIID(ctx, change.APIID, change.match)
+                return err
+        }
+        stored, err := s.db.UpsertMatch(ctx, change.match)
+        if err != nil {
+                return err
+        }
+        if stored.APIID != change.APIID {
+                change.Action = syncMerge
+                change.APIID = stored.APIID
+        }
+        return nil
+}
+
+// tripOnRateLimit opens the Odds API backoff window when err is a 429
+func (s *SyncService) tripOnRateLimit(source string, err error) {
+        var rateLimited *OddsAPIRateLimitError
+        if errors.As(err, &rateLimited) {
+                s.backoff.Trip(source, rateLimited.RetryAfter)
+        }
+}
+
+// SyncOdds fetches upcoming odds and creates/updates matches
+// With dryRun it plans the same changes but writes nothing
+func (s *SyncService) SyncOdds(ctx context.Context, dryRun bool) (*OddsSyncResult, error) {
+        if err := s.backoff.Check(); err != nil {
+                return nil, err
+        }
+
+        // Fetch odds from API
+        events, apiStats, err := fetchOddsFromAPI(ctx, s.oddsClient, s.logger, s.config.OddsAPIKey, s.config.OddsAPISport, s.config.OddsAPIMarkets)
+        if err != nil {
+                s.tripOnRateLimit("Odds", err)
+                return nil, fmt.Errorf("failed to fetch odds: %w", err)
+        }
+
+        s.quota.Record(ctx, "odds", apiStats)
+
+        result := &OddsSyncResult{
+                SyncChanges: SyncChanges{Changes: []SyncChange{}},
+                SyncSkips:   SyncSkips{SkippedEvents: []SkippedEvent{}},
+                APIStats:    apiStats,
+                DryRun:      dryRun,
+        }
+        if !dryRun {
+                defer s.matches.Invalidate()
+        }
+        defer s.logSkips("ODDS_SYNC", &result.SyncSkips)
+
+        for _, event := range events {
+                if err := ctx.Err(); err != nil {
+                        return result, err
+                }
+
+                match, report, err := processOddsEvent(event, s.teams, s.config.sportHasDraw())
+                if err != nil {
+                        s.logger.LogError("Failed to process event: %s", err.Error())
+                        result.skip(event.ID, skipInvalidEvent, err)
+                        continue
+                }
+                applyHouseMargin(match, s.config.HouseMarginPercent)
+                report.OutOfRange = dropOutOfRangeOdds(match, s.config)
+                if summary := report.String(); summary != "" {
+                        s.logger.LogSystem("ODDS_SYNC", "Event %s (%s vs %s): %s", event.ID, match.HomeTeam, match.AwayTeam, summary)
+                }
+
+                // Check if match exists
+                existingMatch, merged, err := s.findExistingMatch(ctx, "ODDS_SYNC", match)
+                if err != nil {
+                        s.logger.LogError("Failed to look up match %s: %s", match.APIID, err.Error())
+                        result.skip(event.ID, skipLookupFailed, err)
+                        continue
+                }
+
+                change, skipReason := planOddsChange(event.ID, match, existingMatch, merged, s.config.sportHasDraw(), s.config.now())
+                if change == nil {
+                        var detail error
+                        if skipReason == skipNoOdds {
+                                detail = errors.New(report.String())
+                        }
+                        result.skip(event.ID, skipReason, detail)
+                        continue
+                }
+                if !dryRun {
+                        if err := s.applyChange(ctx, change); err != nil {
+                                s.logger.LogError("Failed to %s match: %s", change.Action, err.Error())
+                                result.skip(event.ID, skipSaveFailed, err)
+                                continue
+                        }
+                }
+                if source, ok := change.Fields["odds_source"]; ok {
+                        s.logger.LogSystem("ODDS_SYNC", "Match %s (%s vs %s): odds now from %v (was %v)",
+                                change.APIID, change.HomeTeam, change.AwayTeam, source.To, source.From)
+                }
+                result.record(change)
+        }
+
+        return result, nil
+}
+
+// SyncScores fetches recent scores and creates/updates matches
+// With dryRun it plans the same changes but writes nothing
+func (s *SyncService) SyncScores(ctx context.Context, dryRun bool) (*ScoresSyncResult, error) {
+        if err := s.backoff.Check(); err != nil {
+                return nil, err
+        }
+
+        // Fetch scores from API
+        scores, apiStats, err := fetchScoresFromAPI(ctx, s.oddsClient, s.logger, s.config.OddsAPIKey, s.config.OddsAPISport)
+        if err != nil {
+                s.tripOnRateLimit("Scores", err)
+                return nil, fmt.Errorf("failed to fetch scores: %w", err)
+        }
+
+        s.quota.Record(ctx, "scores", apiStats)
+
+        result := &ScoresSyncResult{
+                SyncChanges: SyncChanges{Changes: []SyncChange{}},
+                SyncSkips:   SyncSkips{SkippedEvents: []SkippedEvent{}},
+                APIStats:    apiStats,
+                DryRun:      dryRun,
+        }
+        if !dryRun {
+                defer s.matches.Invalidate()
+        }
+        defer s.logSkips("SCORES_SYNC", &result.SyncSkips)
+
+        for _, score := range scores {
+                if err := ctx.Err(); err != nil {
+                        return result, err
+                }
+
+                match, report, err := processScoreEvent(score, s.teams)
+                if err != nil {
+                        s.logger.LogError("Failed to process score: %s", err.Error())
+                        result.skip(score.ID, skipInvalidEvent, err)
+                        continue
+                }
+                if summary := report.String(); summary != "" {
+                        s.logger.LogWarning("[SCORES_SYNC] Event %s (%s vs %s): %s", score.ID, match.HomeTeam, match.AwayTeam, summary)
+                }
+
+                // Check if match exists
+                existingMatch, merged, err := s.findExistingMatch(ctx, "SCORES_SYNC", match)
+                if err != nil {
+                        s.logger.LogError("Failed to look up match %s: %s", match.APIID, err.Error())
+                        result.skip(score.ID, skipLookupFailed, err)
+                        continue
+                }
+
+                change := planScoresChange(score.ID, match, existingMatch, merged)
+                if !dryRun {
+                        if err := s.applyChange(ctx, change); err != nil {
+                                s.logger.LogError("Failed to %s match: %s", change.Action, err.Error())
+                                result.skip(score.ID, skipSaveFailed, err)
+                                continue
+                        }
+                }
+                result.record(change)
+        }
+
+        return result, nil
+}
+
+// CalculateMatches settles bets for completed matches and sends the settlement summary
+// Only one run at a time, across instances: ErrCalcInProgress while another one holds the lock
+func (s *SyncService) CalculateMatches(ctx context.Context) (*CalcResult, error) {
+        unlock, err := s.db.TryLockCalc(ctx)
+        if err != nil {
+                return nil, err
+        }
+        defer unlock()
+        defer s.matches.Invalidate() // Listings show calculated and result; voids land here too
+
+        result := &CalcResult{
+                Matches:        []SettledMatch{},
+                AwaitingScores: []map[string]interface{}{},
+        }
+
+        // Walk completed uncalculated matches in batches so a large backlog never loads in one query
+        afterAPIID := ""
+        for {
+                if err := ctx.Err(); err != nil {
+                        return result, err
+                }
+
+                matches, err := s.db.GetCompletedUncalculatedMatches(ctx, afterAPIID, s.config.CalcBatchSize)
+                if err != nil {
+                        return result, fmt.Errorf("failed to get uncalculated matches: %w", err)
+                }
+
+                for _, match := range matches {
+                        if err := ctx.Err(); err != nil {
+                                return result, err
+                        }
+                        s.settleMatch(ctx, match, result)
+                }
+
+                if len(matches) < s.config.CalcBatchSize {
+                        break
+                }
+                afterAPIID = matches[len(matches)-1].APIID
+        }
+
+        if result.Updated == 0 && len(result.AwaitingScores) == 0 {
+                s.logger.LogSystem("CALC", "No matches to calculate")
+        }
+
+        s.notifyCalculated(ctx, result)
+
+        return result, nil
+}
+
+// matchOutcome returns "home", "away" or "draw", or false while scores are missing (nil or -1)
+// With shootoutDecides a level score won on penalties settles 1X2 for the shootout winner
+func matchOutcome(match Match, shootoutDecides bool) (string, bool) {
+        if match.HomeScore == nil || match.AwayScore == nil || *match.HomeScore == -1 || *match.AwayScore == -1 {
+                return "", false
+        }
+        switch {
+        case *match.HomeScore > *match.AwayScore:
+                return "home", true
+        case *match.HomeScore < *match.AwayScore:
+                return "away", true
+        case shootoutDecides && match.ShootoutWinner != nil:
+                return *match.ShootoutWinner, true
+        default:
+                // Only "draw" bets win; home and away bets lose
+                return "draw", true
+        }
+}
+
+// settleMatch settles one completed match (or voids/reports it when scores are missing) into result
+func (s *SyncService) settleMatch(ctx context.Context, match Match, result *CalcResult) {
+        outcome, ok := matchOutcome(match, s.config.ShootoutDecidesH2H)
+        if !ok {
+                s.handleScorelessMatch(ctx, match, result)
+                return
+        }
+        if outcome == "draw" && !s.config.sportHasDraw() {
+                // A two-way sport has no draw to pay out; a level final score means the feed
+                // left out overtime, so every bet is refunded rather than settled on a guess
+                s.voidMatch(ctx, match, result, "level score in a two-way sport")
+                return
+        }
+
+        // Update bets and user money; every market in betMarkets contributes its winning and pushed bet types
+        winners, pushes := settledBetTypes(outcome, *match.HomeScore, *match.AwayScore)
+        if err := s.db.SettleMatch(ctx, match.APIID, outcome, winners, pushes); errors.Is(err, ErrMatchAlreadySettled) {
+                s.logger.LogWarning("[CALC] Match %s was already settled, skipping", match.APIID)
+                return
+        } else if err != nil {
+                s.logger.LogError("Failed to settle match %s: %s", match.APIID, err.Error())
+                return
+        }
+
+        result.Updated++
+        result.Matches = append(result.Matches, SettledMatch{
+                HomeTeam: match.HomeTeam,
+                AwayTeam: match.AwayTeam,
+                Score:    fmt.Sprintf("%d-%d", *match.HomeScore, *match.AwayScore),
+                Result:   outcome,
+        })
+
+        s.logger.LogSuccess("Match calculated: %s %d-%d %s | Winner: %s",
+                match.HomeTeam, *match.HomeScore, *match.AwayScore, match.AwayTeam, outcome)
+}
+
+// handleScorelessMatch voids a completed match without scores once CalcVoidGracePeriod has passed
+// Before that (or with voiding disabled) it is only reported
+func (s *SyncService) handleScorelessMatch(ctx context.Context, match Match, result *CalcResult) {
+        grace := s.config.CalcVoidGracePeriod
+        if grace <= 0 || s.config.now().Before(match.CommenceTime.Add(grace)) {
+                s.logger.LogWarning("[CALC] Match %s (%s vs %s) is completed but has no scores yet",
+                        match.APIID, match.HomeTeam, match.AwayTeam)
+                result.AwaitingScores = append(result.AwaitingScores, map[string]interface{}{
+                        "id":            match.APIID,
+                        "home_team":     match.HomeTeam,
+                        "away_team":     match.AwayTeam,
+                        "commence_time": match.CommenceTime,
+                })
+                return
+        }
+
+        s.voidMatch(ctx, match, result, "no scores")
+}
+
+// voidMatch refunds every pending bet on match and records it as voided in result
+func (s *SyncService) voidMatch(ctx context.Context, match Match, result *CalcResult, reason string) {
+        refunded, err := s.db.VoidMatch(ctx, match.APIID)
+        if errors.Is(err, ErrMatchAlreadySettled) {
+                s.logger.LogWarning("[CALC] Match %s was already settled, skipping", match.APIID)
+                return
+        }
+        if err != nil {
+                s.logger.LogError("Failed to void match %s: %s", match.APIID, err.Error())
+                return
+        }
+
+        result.Voided++
+        result.Matches = append(result.Matches, SettledMatch{
+                HomeTeam: match.HomeTeam,
+                AwayTeam: match.AwayTeam,
+                Score:    "void",
+                Result:   "void",
+                Refunded: &refunded,
+        })
+
+        s.logger.LogWarning("[CALC] Match voided (%s): %s vs %s | %d bets refunded",
+                reason, match.HomeTeam, match.AwayTeam, refunded)
+}
+
+// notifyCalculated sends the settlement summary to the configured notifiers (always, even with no matches)
+func (s *SyncService) notifyCalculated(ctx context.Context, result *CalcResult) {
+        if s.notifier == nil {
+                s.logger.LogSystem("CALC", "Skipping settlement notification: no notifier configured")
+                return
+        }
+
+        s.logger.LogSystem("CALC", "Sending settlement notification for %d matches via %s", len(result.Matches), s.notifier.Name())
+        if err := s.notifier.NotifySettlement(ctx, result.Matches); err != nil {
+                s.logger.LogError("Failed to send settlement notification: %s", err.Error())
+                return
+        }
+        s.logger.LogSuccess("Settlement notification sent via %s", s.notifier.Name())
+}
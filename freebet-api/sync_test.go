@@ -0,0 +1,279 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "net/http"
+        "slices"
+        "sync"
+        "testing"
+        "time"
+)
+
+func TestShootoutSettlement(t *testing.T) {
+        tests := []struct {
+                name            string
+                shootoutDecides bool
+                winners         []string
+        }{
+                {"shootout ignored", false, []string{"draw"}},
+                {"shootout decides 1X2", true, []string{"away"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        s := newTestServer(t)
+                        s.config.ShootoutDecidesH2H = tt.shootoutDecides
+                        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+                        s.addMatch("cup-final", 2.0, 3.0, 4.0)
+                        for betType, odds := range map[string]float64{"home": 2.0, "draw": 3.0, "away": 4.0} {
+                                decodeResponse(t, s.placeBet(registered.AccessToken, "cup-final", betType, 10, odds), http.StatusOK, nil)
+                        }
+
+                        // 1-1 after extra time, away won the shootout: "1 (3)" - "1 (5)"
+                        homeScore, awayScore, winner := 1, 1, "away"
+                        if _, err := s.db.UpdateMatchByAPIID(context.Background(), "cup-final", &Match{HomeScore: &homeScore, AwayScore: &awayScore, ShootoutWinner: &winner, Completed: true}); err != nil {
+                                t.Fatal(err)
+                        }
+                        if _, err := s.sync.CalculateMatches(context.Background()); err != nil {
+                                t.Fatal(err)
+                        }
+
+                        var bets BetsResponse
+                        decodeResponse(t, s.do("GET", "/api/bets", bearer(registered.AccessToken), nil), http.StatusOK, &bets)
+                        if len(bets.Bets) != 3 {
+                                t.Fatalf("%d bets, want 3", len(bets.Bets))
+                        }
+                        for _, bet := range bets.Bets {
+                                want := "lost"
+                                if slices.Contains(tt.winners, bet.BetType) {
+                                        want = "won"
+                                }
+                                if bet.Status != want {
+                                        t.Errorf("%s bet %s, want %s", bet.BetType, bet.Status, want)
+                                }
+                        }
+                })
+        }
+}
+
+func TestSettledBetTypesUseRegulationScore(t *testing.T) {
+        // A 1-1 draw won on penalties: only the 1X2 outcome follows the shootout
+        won, _ := settledBetTypes("away", 1, 1)
+        for _, betType := range []string{"away", "btts_yes", "cs_1-1", "over_1.5", "under_2.5"} {
+                if !slices.Contains(won, betType) {
+                        t.Errorf("won = %v, missing %s", won, betType)
+                }
+        }
+        for _, betType := range []string{"home", "draw", "btts_no", "cs_1-0", "over_2.5"} {
+                if slices.Contains(won, betType) {
+                        t.Errorf("won = %v, should not include %s", won, betType)
+                }
+        }
+}
+
+func TestMatchOutcome(t *testing.T) {
+        score := func(n int) *int { return &n }
+        away := "away"
+
+        tests := []struct {
+                name            string
+                match           Match
+                shootoutDecides bool
+                want            string
+                ok              bool
+        }{
+                {"home win", Match{HomeScore: score(2), AwayScore: score(1)}, false, "home", true},
+                {"away win", Match{HomeScore: score(0), AwayScore: score(3)}, false, "away", true},
+                {"draw", Match{HomeScore: score(1), AwayScore: score(1)}, true, "draw", true},
+                {"shootout, draw by default", Match{HomeScore: score(1), AwayScore: score(1), ShootoutWinner: &away}, false, "draw", true},
+                {"shootout decides", Match{HomeScore: score(1), AwayScore: score(1), ShootoutWinner: &away}, true, "away", true},
+                {"missing score", Match{HomeScore: score(1)}, false, "", false},
+                {"unparsed score", Match{HomeScore: score(-1), AwayScore: score(0)}, false, "", false},
+        }
+        for _, tt := range tests {
+                got, ok := matchOutcome(tt.match, tt.shootoutDecides)
+                if got != tt.want || ok != tt.ok {
+                        t.Errorf("%s: matchOutcome = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+                }
+        }
+}
+
+func TestConcurrentCalcPaysOnce(t *testing.T) {
+        s := newTestServer(t)
+        var users []RegisterResponse
+        for _, name := range []string{"alice", "bob", "carol"} {
+                registered := s.register(name+"@example.com", name, "correct-horse-42")
+                users = append(users, registered)
+        }
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+        for _, user := range users {
+                decodeResponse(t, s.placeBet(user.AccessToken, "match-1", "home", 100, 2.0), http.StatusOK, nil)
+        }
+        s.finishMatch("match-1", 2, 0)
+
+        const runs = 20
+        codes := make(chan int, runs)
+        var wg sync.WaitGroup
+        for i := 0; i < runs; i++ {
+                wg.Add(1)
+                go func() {
+                        defer wg.Done()
+                        codes <- s.do("POST", "/api/calc", adminAuth(), nil).Code
+                }()
+        }
+        wg.Wait()
+        close(codes)
+        succeeded := 0
+        for code := range codes {
+                switch code {
+                case http.StatusOK:
+                        succeeded++
+                case http.StatusConflict: // Another run held the lock
+                default:
+                        t.Errorf("calc status = %d, want 200 or 409", code)
+                }
+        }
+        if succeeded == 0 {
+                t.Fatal("no calculation run succeeded")
+        }
+
+        // A later run finds nothing left to settle
+        decodeResponse(t, s.do("POST", "/api/calc", adminAuth(), nil), http.StatusOK, nil)
+
+        want := s.config.InitialBalance - 100 + 200
+        for _, registered := range users {
+                user, err := s.db.GetUserByID(context.Background(), registered.User.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                if user.Money != want {
+                        t.Errorf("%s money = %v, want %v (paid once)", user.Nickname, user.Money, want)
+                }
+        }
+}
+
+func TestSettleMatchOnlyOnce(t *testing.T) {
+        s := newTestServer(t)
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.0, 3.0, 4.0)
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 100, 2.0), http.StatusOK, nil)
+
+        // Without the calculation lock, the match row alone stops a second payout
+        const settlers = 20
+        errs := make(chan error, settlers)
+        var wg sync.WaitGroup
+        for i := 0; i < settlers; i++ {
+                wg.Add(1)
+                go func() {
+                        defer wg.Done()
+                        errs <- s.db.SettleMatch(context.Background(), "match-1", "home", []string{"home"}, nil)
+                }()
+        }
+        wg.Wait()
+        close(errs)
+        settled := 0
+        for err := range errs {
+                switch {
+                case err == nil:
+                        settled++
+                case !errors.Is(err, ErrMatchAlreadySettled):
+                        t.Errorf("SettleMatch error = %v, want ErrMatchAlreadySettled", err)
+                }
+        }
+        if settled != 1 {
+                t.Fatalf("%d settlements succeeded, want 1", settled)
+        }
+        if _, err := s.db.VoidMatch(context.Background(), "match-1"); !errors.Is(err, ErrMatchAlreadySettled) {
+                t.Errorf("VoidMatch after settlement = %v, want ErrMatchAlreadySettled", err)
+        }
+
+        user, err := s.db.GetUserByID(context.Background(), registered.User.ID)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if want := s.config.InitialBalance + 100; user.Money != want {
+                t.Fatalf("money = %v, want %v (paid once)", user.Money, want)
+        }
+}
+
+func TestTryLockCalc(t *testing.T) {
+        db := NewMemoryDB(NewFakeClock(time.Now()))
+
+        unlock, err := db.TryLockCalc(context.Background())
+        if err != nil {
+                t.Fatal(err)
+        }
+        if _, err := db.TryLockCalc(context.Background()); !errors.Is(err, ErrCalcInProgress) {
+                t.Fatalf("second TryLockCalc = %v, want ErrCalcInProgress", err)
+        }
+        unlock()
+        unlock, err = db.TryLockCalc(context.Background())
+        if err != nil {
+                t.Fatalf("TryLockCalc after unlock = %v", err)
+        }
+        unlock()
+}
+
+func TestPlanOddsChange(t *testing.T) {
+        now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+        price := func(odds float64) *float64 { return &odds }
+        synced := func(home, draw, away *float64, kickoff time.Time) *Match {
+                return &Match{APIID: "event-1", HomeTeam: "Arsenal", AwayTeam: "Chelsea", CommenceTime: kickoff, HomeOdds: home, DrawOdds: draw, AwayOdds: away}
+        }
+        later := now.Add(time.Hour)
+        existing := synced(price(2.0), price(3.0), price(4.0), later)
+
+        tests := []struct {
+                name     string
+                match    *Match
+                existing *Match
+                merged   bool
+                hasDraw  bool
+                action   string
+                skip     string
+                fields   map[string]FieldChange
+        }{
+                {"new match", synced(price(2.0), price(3.0), price(4.0), later), nil, false, true, syncCreate, "", nil},
+                {"new match without a draw price", synced(price(2.0), nil, price(4.0), later), nil, false, true, "", skipNoOdds, nil},
+                {"new two-way match", synced(price(2.0), nil, price(4.0), later), nil, false, false, syncCreate, "", nil},
+                {"new match past kickoff", synced(price(2.0), price(3.0), price(4.0), now), nil, false, true, "", skipKickoffPassed, nil},
+                {"unchanged", synced(price(2.0), price(3.0), price(4.0), later), existing, false, true, syncUpdate, "", map[string]FieldChange{}},
+                {"moved price", synced(price(2.1), price(3.0), price(4.0), later), existing, false, true, syncUpdate, "",
+                        map[string]FieldChange{"home_odds": {From: 2.0, To: 2.1}}},
+                {"missing prices keep the stored ones", synced(nil, nil, price(3.8), later), existing, false, true, syncUpdate, "",
+                        map[string]FieldChange{"away_odds": {From: 4.0, To: 3.8}}},
+                {"merge", synced(price(2.0), price(3.0), price(4.0), later), existing, true, true, syncMerge, "", map[string]FieldChange{}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        change, skip := planOddsChange("event-1", tt.match, tt.existing, tt.merged, tt.hasDraw, now)
+                        if skip != tt.skip {
+                                t.Fatalf("skip = %q, want %q", skip, tt.skip)
+                        }
+                        if tt.action == "" {
+                                if change != nil {
+                                        t.Fatalf("change = %+v, want none", change)
+                                }
+                                return
+                        }
+                        if change == nil || change.Action != tt.action {
+                                t.Fatalf("change = %+v, want %s", change, tt.action)
+                        }
+                        // A create lists every written column; updates only what changes
+                        if tt.fields == nil {
+                                if change.Fields["home_team"] != (FieldChange{To: "Arsenal"}) {
+                                        t.Errorf("create fields = %v, want every column", change.Fields)
+                                }
+                                return
+                        }
+                        if len(change.Fields) != len(tt.fields) {
+                                t.Errorf("fields = %v, want %v", change.Fields, tt.fields)
+                        }
+                        for name, want := range tt.fields {
+                                if change.Fields[name] != want {
+                                        t.Errorf("%s = %+v, want %+v", name, change.Fields[name], want)
+                                }
+                        }
+                })
+        }
+}
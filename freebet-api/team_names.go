@@ -0,0 +1,96 @@
+package main
+
+import (
+        "fmt"
+        "strings"
+        "time"
+)
+
+// sameFixtureWindow is how far apart two kickoffs can be and still be the same fixture
+// when matching by team names (feeds occasionally disagree on the exact time)
+const sameFixtureWindow = 12 * time.Hour
+
+// defaultTeamAliases maps spellings seen in the odds and scores feeds to one canonical name
+// Keys are compared case-insensitively; TEAM_ALIASES entries are applied on top
+var defaultTeamAliases = map[string]string{
+        "Man City":               "Manchester City",
+        "Man Utd":                "Manchester United",
+        "Man United":             "Manchester United",
+        "Spurs":                  "Tottenham Hotspur",
+        "Tottenham":              "Tottenham Hotspur",
+        "Wolves":                 "Wolverhampton Wanderers",
+        "Wolverhampton":          "Wolverhampton Wanderers",
+        "Newcastle":              "Newcastle United",
+        "West Ham":               "West Ham United",
+        "Brighton":               "Brighton and Hove Albion",
+        "Brighton & Hove Albion": "Brighton and Hove Albion",
+        "Nott'm Forest":          "Nottingham Forest",
+        "Nottm Forest":           "Nottingham Forest",
+        "Leicester":              "Leicester City",
+        "Leeds":                  "Leeds United",
+        "Sheffield Utd":          "Sheffield United",
+        "Ipswich":                "Ipswich Town",
+        "Luton":                  "Luton Town",
+        "AFC Bournemouth":        "Bournemouth",
+}
+
+// TeamNames normalizes team names so both feeds agree on one spelling
+type TeamNames struct {
+        aliases map[string]string // Lower-cased, whitespace-collapsed alias -> canonical name
+}
+
+// NewTeamNames builds the alias map from the defaults plus config.TeamAliases
+// Entries that fail parseTeamAlias are skipped; Validate reports them at startup
+func NewTeamNames(config *Config) *TeamNames {
+        t := &TeamNames{aliases: make(map[string]string, len(defaultTeamAliases)+len(config.TeamAliases))}
+        for alias, canonical := range defaultTeamAliases {
+                t.aliases[teamNameKey(alias)] = canonical
+        }
+        for _, entry := range config.TeamAliases {
+                if alias, canonical, err := parseTeamAlias(entry); err == nil {
+                        t.aliases[teamNameKey(alias)] = canonical
+                }
+        }
+        return t
+}
+
+// parseTeamAlias splits a TEAM_ALIASES entry of the form "Alias=Canonical Name"
+func parseTeamAlias(entry string) (string, string, error) {
+        alias, canonical, ok := strings.Cut(entry, "=")
+        alias = collapseSpaces(alias)
+        canonical = collapseSpaces(canonical)
+        if !ok || alias == "" || canonical == "" {
+                return "", "", fmt.Errorf("expected \"Alias=Canonical Name\", got %q", entry)
+        }
+        return alias, canonical, nil
+}
+
+// Normalize trims and collapses whitespace, then maps known aliases to the canonical name
+func (t *TeamNames) Normalize(name string) string {
+        name = collapseSpaces(name)
+        if canonical, ok := t.aliases[teamNameKey(name)]; ok {
+                return canonical
+        }
+        return name
+}
+
+// validateTeams rejects events whose team names are unusable after normalization
+func validateTeams(homeTeam, awayTeam string) error {
+        if homeTeam == "" || awayTeam == "" {
+                return fmt.Errorf("missing team name (home %q, away %q)", homeTeam, awayTeam)
+        }
+        if strings.EqualFold(homeTeam, awayTeam) {
+                return fmt.Errorf("home and away teams are both %q", homeTeam)
+        }
+        return nil
+}
+
+// collapseSpaces trims a name and reduces inner whitespace runs to single spaces
+func collapseSpaces(name string) string {
+        return strings.Join(strings.Fields(name), " ")
+}
+
+// teamNameKey is the lookup key for an alias
+func teamNameKey(name string) string {
+        return strings.ToLower(collapseSpaces(name))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+        "context"
+        "io"
+        "testing"
+        "time"
+)
+
+func TestTeamNamesNormalize(t *testing.T) {
+        teams := NewTeamNames(&Config{TeamAliases: []string{"Gunners=Arsenal", " Spurs = Tottenham  Hotspur FC ", "broken entry"}})
+
+        tests := []struct {
+                name string
+                want string
+        }{
+                {"Man City", "Manchester City"},
+                {"man  city", "Manchester City"},
+                {" MAN UTD ", "Manchester United"},
+                {"Brighton & Hove Albion", "Brighton and Hove Albion"},
+                {"Nott'm Forest", "Nottingham Forest"},
+                {"Manchester City", "Manchester City"},
+                {"Gunners", "Arsenal"},
+                {"Spurs", "Tottenham Hotspur FC"},   // TEAM_ALIASES overrides a default
+                {"  Aston   Villa ", "Aston Villa"}, // Unknown names only get their whitespace collapsed
+                {"broken entry", "broken entry"},
+        }
+        for _, tt := range tests {
+                if got := teams.Normalize(tt.name); got != tt.want {
+                        t.Errorf("Normalize(%q) = %q, want %q", tt.name, got, tt.want)
+                }
+        }
+}
+
+func TestParseTeamAlias(t *testing.T) {
+        for _, entry := range []string{"", "Gunners", "=Arsenal", "Gunners=", " = "} {
+                if _, _, err := parseTeamAlias(entry); err == nil {
+                        t.Errorf("parseTeamAlias(%q) accepted the entry", entry)
+                }
+        }
+        if alias, canonical, err := parseTeamAlias(" Boro = Middlesbrough "); err != nil || alias != "Boro" || canonical != "Middlesbrough" {
+                t.Errorf("parseTeamAlias = %q, %q, %v; want Boro, Middlesbrough, nil", alias, canonical, err)
+        }
+}
+
+func TestScoresMergeIntoOddsMatchByAlias(t *testing.T) {
+        ctx := context.Background()
+        config := &Config{}
+        db := NewMemoryDB(nil)
+        sync := NewSyncService(db, config, NewLogger("ERROR", io.Discard), nil)
+        kickoff := time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)
+
+        oddsMatch, _, err := processOddsEvent(OddsAPIEvent{ID: "odds-1", HomeTeam: "Manchester City", AwayTeam: "Tottenham Hotspur", CommenceTime: kickoff}, sync.teams, true)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if _, err := db.UpsertMatch(ctx, oddsMatch); err != nil {
+                t.Fatal(err)
+        }
+
+        tests := []struct {
+                name   string
+                event  ScoresAPIEvent
+                merged bool
+        }{
+                {"alias spellings, kickoff moved", ScoresAPIEvent{ID: "scores-1", HomeTeam: "Man City", AwayTeam: "Spurs", CommenceTime: kickoff.Add(30 * time.Minute)}, true},
+                {"same api_id", ScoresAPIEvent{ID: "odds-1", HomeTeam: "Man City", AwayTeam: "Spurs", CommenceTime: kickoff}, false},
+                {"home and away swapped", ScoresAPIEvent{ID: "scores-2", HomeTeam: "Spurs", AwayTeam: "Man City", CommenceTime: kickoff}, false},
+                {"kickoff outside the window", ScoresAPIEvent{ID: "scores-3", HomeTeam: "Man City", AwayTeam: "Spurs", CommenceTime: kickoff.Add(sameFixtureWindow + time.Minute)}, false},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        match, _, err := processScoreEvent(tt.event, sync.teams)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        existing, merged, err := sync.findExistingMatch(ctx, "SCORES", match)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if merged != tt.merged {
+                                t.Fatalf("merged = %v, want %v", merged, tt.merged)
+                        }
+                        if tt.merged && (existing == nil || match.APIID != "odds-1") {
+                                t.Fatalf("merged into %v with api_id %q, want odds-1", existing, match.APIID)
+                        }
+                })
+        }
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+        "context"
+        "math"
+        "net/http"
+        "time"
+)
+
+// Reasons a top-up is not available right now
+const (
+        topupBlockedBalance  = "balance"  // Balance is at or above MaxTopupBalance
+        topupBlockedCooldown = "cooldown" // TopupCooldown since the last top-up has not passed
+)
+
+// topupStreakLookback bounds how many past top-ups are read to compute the streak
+const topupStreakLookback = 366
+
+// nextTopupAt is when the cooldown after the last top-up ends; nil if the user never topped up
+func nextTopupAt(lastTopupAt *time.Time, config *Config) *time.Time {
+        if lastTopupAt == nil {
+                return nil
+        }
+        next := lastTopupAt.Add(config.TopupCooldown)
+        return &next
+}
+
+// evaluateTopup decides whether the user may top up at now; POST /api/auth/topup enforces
+// exactly this, so GET /api/account/topup-status never disagrees with it
+func evaluateTopup(user *User, lastTopupAt *time.Time, config *Config, now time.Time) TopupStatus {
+        status := TopupStatus{Eligible: true}
+        if next := nextTopupAt(lastTopupAt, config); next != nil && now.Before(*next) {
+                status.Eligible = false
+                status.Reason = topupBlockedCooldown
+                status.NextAvailableAt = next
+        }
+        if user.Money >= config.MaxTopupBalance {
+                status.Eligible = false
+                status.Reason = topupBlockedBalance
+        }
+        return status
+}
+
+// topupStreak counts consecutive top-ups (newest first), each claimed within one cooldown of
+// becoming available; the streak is 0 once the latest one is older than that
+func topupStreak(topups []time.Time, config *Config, now time.Time) int {
+        window := 2 * config.TopupCooldown
+        if len(topups) == 0 || now.Sub(topups[0]) > window {
+                return 0
+        }
+        streak := 1
+        for i := 1; i < len(topups) && topups[i-1].Sub(topups[i]) <= window; i++ {
+                streak++
+        }
+        return streak
+}
+
+// formatWait renders the remaining cooldown as hours and minutes, rounding minutes up
+func formatWait(remaining time.Duration) (int, int) {
+        minutes := int(math.Ceil(remaining.Minutes()))
+        return minutes / 60, minutes % 60
+}
+
+// topupStatus loads what evaluateTopup needs and adds the streak
+func (h *Handler) topupStatus(ctx context.Context, user *User) (TopupStatus, error) {
+        lastTopupAt, err := h.db.GetUserLastTopupTime(ctx, user.ID)
+        if err != nil {
+                return TopupStatus{}, err
+        }
+        topups, err := h.db.GetTopupTimes(ctx, user.ID, topupStreakLookback)
+        if err != nil {
+                return TopupStatus{}, err
+        }
+        now := h.config.now()
+        status := evaluateTopup(user, lastTopupAt, h.config, now)
+        status.Streak = topupStreak(topups, h.config, now)
+        return status, nil
+}
+
+// TopupStatusHandler handles GET /api/account/topup-status
+// Whether the daily top-up can be claimed now, and if not, when
+func (h *Handler) topupStatusHandler(w http.ResponseWriter, r *http.Request) {
+        user, ok := getUserFromContext(r.Context())
+        if !ok {
+                h.writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "No access token")
+                return
+        }
+
+        status, err := h.topupStatus(r.Context(), user)
+        if err != nil {
+                h.logger.LogError("Failed to get top-up status for user %s: %s", user.ID, err.Error())
+                h.writeError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get top-up status")
+                return
+        }
+
+        h.writeJSON(w, http.StatusOK, TopupStatusResponse{Success: true, TopupStatus: status})
+}
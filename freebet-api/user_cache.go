@@ -0,0 +1,186 @@
+package main
+
+import (
+        "context"
+        "sync"
+        "time"
+)
+
+// userCacheMaxEntries bounds the cache; when full, expired entries are swept and then everything is dropped
+const userCacheMaxEntries = 10000
+
+// cachedUser is one GetUserByID result and when it stops being served
+type cachedUser struct {
+        user    User
+        expires time.Time
+}
+
+// UserCacheDB is a read-through cache for GetUserByID, which the auth middleware runs on
+// every request. Every method that changes a user drops that user's entry, and the ones
+// that can touch many users (settlement, voids, season prizes, guest cleanup) drop them all.
+// The cache is per process: with several instances, a change made through another one shows
+// up here after at most UserCacheTTL
+type UserCacheDB struct {
+        Database
+        config *Config
+
+        mu         sync.Mutex
+        entries    map[string]cachedUser
+        generation uint64 // Bumped on every invalidation so a load that raced one is not stored
+}
+
+// NewUserCacheDB wraps db with the user cache; db itself when UserCacheTTL is 0
+func NewUserCacheDB(db Database, config *Config) Database {
+        if config.UserCacheTTL <= 0 {
+                return db
+        }
+        return &UserCacheDB{Database: db, config: config, entries: make(map[string]cachedUser)}
+}
+
+// GetUserByID serves a fresh entry or loads and caches the user; errors are never cached
+func (c *UserCacheDB) GetUserByID(ctx context.Context, id string) (*User, error) {
+        c.mu.Lock()
+        now := c.config.now()
+        if entry, ok := c.entries[id]; ok && now.Before(entry.expires) {
+                c.mu.Unlock()
+                user := entry.user
+                return &user, nil
+        }
+        generation := c.generation
+        c.mu.Unlock()
+
+        user, err := c.Database.GetUserByID(ctx, id)
+        if err != nil {
+                return nil, err
+        }
+
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        if c.generation == generation {
+                if len(c.entries) >= userCacheMaxEntries {
+                        c.sweep(now)
+                }
+                c.entries[id] = cachedUser{user: *user, expires: now.Add(c.config.UserCacheTTL)}
+        }
+        copied := *user
+        return &copied, nil
+}
+
+// sweep drops expired entries, or all of them if that frees nothing; caller holds mu
+func (c *UserCacheDB) sweep(now time.Time) {
+        for id, entry := range c.entries {
+                if !now.Before(entry.expires) {
+                        delete(c.entries, id)
+                }
+        }
+        if len(c.entries) >= userCacheMaxEntries {
+                clear(c.entries)
+        }
+}
+
+// invalidate drops the entries of userIDs
+func (c *UserCacheDB) invalidate(userIDs ...string) {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        for _, id := range userIDs {
+                delete(c.entries, id)
+        }
+        c.generation++
+}
+
+// invalidateAll drops every entry
+func (c *UserCacheDB) invalidateAll() {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+
+        clear(c.entries)
+        c.generation++
+}
+
+func (c *UserCacheDB) TopupUser(ctx context.Context, userID string, amount float64) (float64, error) {
+        defer c.invalidate(userID)
+        return c.Database.TopupUser(ctx, userID, amount)
+}
+
+func (c *UserCacheDB) UpdateUserNickname(ctx context.Context, userID, nickname string) (*User, error) {
+        defer c.invalidate(userID)
+        return c.Database.UpdateUserNickname(ctx, userID, nickname)
+}
+
+func (c *UserCacheDB) UpdateUserPicture(ctx context.Context, userID, pictureURL string) error {
+        defer c.invalidate(userID)
+        return c.Database.UpdateUserPicture(ctx, userID, pictureURL)
+}
+
+func (c *UserCacheDB) UpdateUserPassword(ctx context.Context, userID string, newPasswordHash string) error {
+        defer c.invalidate(userID)
+        return c.Database.UpdateUserPassword(ctx, userID, newPasswordHash)
+}
+
+func (c *UserCacheDB) UpgradeGuestUser(ctx context.Context, userID, email, passwordHash, nickname string) (*User, error) {
+        defer c.invalidate(userID)
+        return c.Database.UpgradeGuestUser(ctx, userID, email, passwordHash, nickname)
+}
+
+func (c *UserCacheDB) LinkGuestGoogle(ctx context.Context, userID, googleID, email, pictureURL string) (*User, error) {
+        defer c.invalidate(userID)
+        return c.Database.LinkGuestGoogle(ctx, userID, googleID, email, pictureURL)
+}
+
+func (c *UserCacheDB) DeleteInactiveGuests(ctx context.Context, inactiveSince time.Time) (int, error) {
+        defer c.invalidateAll()
+        return c.Database.DeleteInactiveGuests(ctx, inactiveSince)
+}
+
+func (c *UserCacheDB) IncrementUserTokenVersion(ctx context.Context, userID string) error {
+        defer c.invalidate(userID)
+        return c.Database.IncrementUserTokenVersion(ctx, userID)
+}
+
+func (c *UserCacheDB) SetUserDisabled(ctx context.Context, userID string, disabled bool) error {
+        defer c.invalidate(userID)
+        return c.Database.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (c *UserCacheDB) AdjustUserBalance(ctx context.Context, userID, adminID string, amount float64, reason string) (float64, error) {
+        defer c.invalidate(userID)
+        return c.Database.AdjustUserBalance(ctx, userID, adminID, amount, reason)
+}
+
+func (c *UserCacheDB) PlaceBet(ctx context.Context, bet *Bet) (*Bet, float64, error) {
+        defer c.invalidate(bet.UserID)
+        return c.Database.PlaceBet(ctx, bet)
+}
+
+func (c *UserCacheDB) PlaceBets(ctx context.Context, bets []*Bet) ([]*Bet, float64, error) {
+        userIDs := make([]string, len(bets))
+        for i, bet := range bets {
+                userIDs[i] = bet.UserID
+        }
+        defer c.invalidate(userIDs...)
+        return c.Database.PlaceBets(ctx, bets)
+}
+
+func (c *UserCacheDB) CloseSeason(ctx context.Context, seasonID int, endsAt time.Time, prizes []float64, nextEndsAt time.Time) ([]SeasonStanding, *Season, error) {
+        defer c.invalidateAll()
+        return c.Database.CloseSeason(ctx, seasonID, endsAt, prizes, nextEndsAt)
+}
+
+func (c *UserCacheDB) SettleMatch(ctx context.Context, matchAPIID, result string, winningBetTypes, pushBetTypes []string) error {
+        defer c.invalidateAll()
+        return c.Database.SettleMatch(ctx, matchAPIID, result, winningBetTypes, pushBetTypes)
+}
+
+func (c *UserCacheDB) VoidMatch(ctx context.Context, matchAPIID string) (int, error) {
+        defer c.invalidateAll()
+        return c.Database.VoidMatch(ctx, matchAPIID)
+}
+
+func (c *UserCacheDB) RejectBet(ctx context.Context, betID string) (*Bet, error) {
+        bet, err := c.Database.RejectBet(ctx, betID)
+        if bet != nil {
+                c.invalidate(bet.UserID)
+        }
+        return bet, err
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+        "context"
+        "errors"
+        "io"
+        "net/http"
+        "testing"
+        "time"
+)
+
+// countingDB counts GetUserByID loads and can run a hook in the middle of one
+type countingDB struct {
+        *MemoryDB
+        loads  int
+        onLoad func()
+}
+
+func (db *countingDB) GetUserByID(ctx context.Context, id string) (*User, error) {
+        db.loads++
+        user, err := db.MemoryDB.GetUserByID(ctx, id)
+        if db.onLoad != nil {
+                db.onLoad()
+        }
+        return user, err
+}
+
+// newUserCacheTest builds a cache over a MemoryDB holding one user
+func newUserCacheTest(t *testing.T) (*UserCacheDB, *countingDB, *FakeClock, *User) {
+        t.Helper()
+
+        clock := NewFakeClock(time.Now().UTC())
+        config := newTestConfig(t, clock)
+        config.UserCacheTTL = 10 * time.Second
+        db := &countingDB{MemoryDB: NewMemoryDB(clock)}
+        user, err := db.CreateUser(context.Background(), "alice@example.com", "hash", "alice", 1000)
+        if err != nil {
+                t.Fatal(err)
+        }
+        return NewUserCacheDB(db, config).(*UserCacheDB), db, clock, user
+}
+
+func TestUserCacheServesFreshEntries(t *testing.T) {
+        cache, db, clock, user := newUserCacheTest(t)
+        ctx := context.Background()
+
+        for i := 0; i < 3; i++ {
+                cached, err := cache.GetUserByID(ctx, user.ID)
+                if err != nil {
+                        t.Fatal(err)
+                }
+                cached.Money = 0 // Callers get copies; the entry is untouched
+        }
+        if db.loads != 1 {
+                t.Fatalf("%d loads for 3 lookups, want 1", db.loads)
+        }
+        if cached, _ := cache.GetUserByID(ctx, user.ID); cached.Money != 1000 {
+                t.Fatalf("cached money = %v after a caller changed its copy, want 1000", cached.Money)
+        }
+
+        clock.Advance(10 * time.Second)
+        if _, err := cache.GetUserByID(ctx, user.ID); err != nil {
+                t.Fatal(err)
+        }
+        if db.loads != 2 {
+                t.Fatalf("%d loads after the TTL, want 2", db.loads)
+        }
+
+        // Errors are not cached
+        for i := 0; i < 2; i++ {
+                if _, err := cache.GetUserByID(ctx, "missing"); !errors.Is(err, ErrUserNotFound) {
+                        t.Fatalf("missing user error = %v, want ErrUserNotFound", err)
+                }
+        }
+        if db.loads != 4 {
+                t.Fatalf("%d loads after two misses, want 4", db.loads)
+        }
+}
+
+func TestUserCacheInvalidatesOnUpdates(t *testing.T) {
+        ctx := context.Background()
+        tests := []struct {
+                name   string
+                update func(cache *UserCacheDB, user *User) error
+                check  func(user *User) bool
+        }{
+                {"topup", func(cache *UserCacheDB, user *User) error {
+                        _, err := cache.TopupUser(ctx, user.ID, 500)
+                        return err
+                }, func(user *User) bool { return user.Money == 1500 }},
+                {"balance adjustment", func(cache *UserCacheDB, user *User) error {
+                        _, err := cache.AdjustUserBalance(ctx, user.ID, "admin", -250, "correction")
+                        return err
+                }, func(user *User) bool { return user.Money == 750 }},
+                {"nickname", func(cache *UserCacheDB, user *User) error {
+                        _, err := cache.UpdateUserNickname(ctx, user.ID, "alicia")
+                        return err
+                }, func(user *User) bool { return user.Nickname == "alicia" }},
+                {"password", func(cache *UserCacheDB, user *User) error {
+                        return cache.UpdateUserPassword(ctx, user.ID, "new-hash")
+                }, func(user *User) bool { return user.PasswordHash.String == "new-hash" }},
+                {"disable", func(cache *UserCacheDB, user *User) error {
+                        return cache.SetUserDisabled(ctx, user.ID, true)
+                }, func(user *User) bool { return user.Disabled() }},
+                {"token version", func(cache *UserCacheDB, user *User) error {
+                        return cache.IncrementUserTokenVersion(ctx, user.ID)
+                }, func(user *User) bool { return user.TokenVersion == 1 }},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        cache, _, _, user := newUserCacheTest(t)
+                        if _, err := cache.GetUserByID(ctx, user.ID); err != nil {
+                                t.Fatal(err)
+                        }
+                        if err := tt.update(cache, user); err != nil {
+                                t.Fatal(err)
+                        }
+                        cached, err := cache.GetUserByID(ctx, user.ID)
+                        if err != nil {
+                                t.Fatal(err)
+                        }
+                        if !tt.check(cached) {
+                                t.Fatalf("cached user %+v is stale after the update", cached)
+                        }
+                })
+        }
+}
+
+func TestUserCacheDropsLoadsThatRacedAnInvalidation(t *testing.T) {
+        cache, db, _, user := newUserCacheTest(t)
+        ctx := context.Background()
+
+        // A topup lands after the load read the old balance but before it is stored
+        db.onLoad = func() {
+                db.onLoad = nil
+                if _, err := cache.TopupUser(ctx, user.ID, 500); err != nil {
+                        t.Fatal(err)
+                }
+        }
+        if _, err := cache.GetUserByID(ctx, user.ID); err != nil {
+                t.Fatal(err)
+        }
+        cached, err := cache.GetUserByID(ctx, user.ID)
+        if err != nil {
+                t.Fatal(err)
+        }
+        if cached.Money != 1500 {
+                t.Fatalf("money = %v, want 1500; the raced load was cached", cached.Money)
+        }
+}
+
+func TestUserCacheShowsBetsAndSettlement(t *testing.T) {
+        s := newTestServer(t)
+        s.config.UserCacheTTL = time.Hour
+        cache := NewUserCacheDB(s.db, s.config)
+        s.sync = NewSyncService(cache, s.config, NewLogger("ERROR", io.Discard), nil)
+        s.router = SetupRoutes(cache, s.config, NewLogger("ERROR", io.Discard), s.sync)
+
+        registered := s.register("alice@example.com", "alice", "correct-horse-42")
+        s.addMatch("match-1", 2.5, 3.2, 2.8)
+        money := func() float64 {
+                t.Helper()
+                var user LoginResponse
+                decodeResponse(t, s.do("GET", "/api/auth/user", bearer(registered.AccessToken), nil), http.StatusOK, &user)
+                return user.User.Money
+        }
+
+        if got := money(); got != s.config.InitialBalance {
+                t.Fatalf("money = %v, want %v", got, s.config.InitialBalance)
+        }
+        decodeResponse(t, s.placeBet(registered.AccessToken, "match-1", "home", 100, 2.5), http.StatusOK, nil)
+        if got, want := money(), s.config.InitialBalance-100; got != want {
+                t.Fatalf("money after the bet = %v, want %v", got, want)
+        }
+
+        s.finishMatch("match-1", 1, 0)
+        decodeResponse(t, s.do("POST", "/api/calc", adminAuth(), nil), http.StatusOK, nil)
+        if got, want := money(), s.config.InitialBalance+150; got != want {
+                t.Fatalf("money after settlement = %v, want %v", got, want)
+        }
+}
+
+func TestUserCacheDisabledWithoutTTL(t *testing.T) {
+        clock := NewFakeClock(time.Now())
+        config := newTestConfig(t, clock)
+        config.UserCacheTTL = 0
+        db := NewMemoryDB(clock)
+
+        if got := NewUserCacheDB(db, config); got != Database(db) {
+                t.Fatalf("NewUserCacheDB with USER_CACHE_TTL=0 = %T, want the database itself", got)
+        }
+}
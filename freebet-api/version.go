@@ -0,0 +1,54 @@
+package main
+
+import (
+        "net/http"
+        "runtime"
+        "runtime/debug"
+)
+
+// Build metadata, injected at build time:
+//
+//      go build -ldflags "-X main.version=1.2.0 -X main.gitCommit=$(git rev-parse HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without ldflags the commit and time fall back to the VCS stamp Go embeds in the binary
+var (
+        version   = "dev"
+        gitCommit = ""
+        buildTime = ""
+)
+
+// buildInfo is the metadata of the running binary, resolved once at startup
+var buildInfo = resolveBuildInfo()
+
+// resolveBuildInfo combines the ldflags values with the embedded VCS stamp
+func resolveBuildInfo() VersionResponse {
+        info := VersionResponse{
+                Version:   version,
+                Commit:    gitCommit,
+                BuildTime: buildTime,
+                GoVersion: runtime.Version(),
+        }
+        if embedded, ok := debug.ReadBuildInfo(); ok {
+                for _, setting := range embedded.Settings {
+                        switch setting.Key {
+                        case "vcs.revision":
+                                if info.Commit == "" {
+                                        info.Commit = setting.Value
+                                }
+                        case "vcs.time":
+                                if info.BuildTime == "" {
+                                        info.BuildTime = setting.Value // Commit time; the closest to a build time Go records
+                                }
+                        case "vcs.modified":
+                                info.Modified = setting.Value == "true"
+                        }
+                }
+        }
+        return info
+}
+
+// VersionHandler handles GET /api/version
+// Which build is deployed: version, commit, build time and Go runtime
+func (h *Handler) versionHandler(w http.ResponseWriter, r *http.Request) {
+        h.writeJSON(w, http.StatusOK, buildInfo)
+}
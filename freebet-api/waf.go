@@ -1,27 +1,26 @@
 package main
 
 import (
-	"net"
 	"net/http"
 	"regexp"
 	"strings"
 )
 
 // WAFMiddleware - веб-брандмауэр на уровне приложения
-func WAFMiddleware(logger *Logger) func(http.Handler) http.Handler {
+func WAFMiddleware(logger *Logger, trustedProxies []CIDR) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Проверяем заголовки на подозрительные паттерны
 			if isThreatInHeaders(r.Header) {
-				logger.LogWarning("[WAF] Suspicious headers detected from IP: %s", getClientIP(r))
-				http.Error(w, `{"success": false, "error": "Request blocked by WAF"}`, http.StatusForbidden)
+				logger.LogWarning("[WAF] Suspicious headers detected from IP: %s", ClientIP(r, trustedProxies))
+				http.Error(w, `{"success": false, "code": "FORBIDDEN", "error": "Request blocked by WAF"}`, http.StatusForbidden)
 				return
 			}
 
 			// Проверяем URL-параметры
 			if isThreatInURL(r.URL.RawQuery) {
-				logger.LogWarning("[WAF] Suspicious URL parameters detected from IP: %s", getClientIP(r))
-				http.Error(w, `{"success": false, "error": "Request blocked by WAF"}`, http.StatusForbidden)
+				logger.LogWarning("[WAF] Suspicious URL parameters detected from IP: %s", ClientIP(r, trustedProxies))
+				http.Error(w, `{"success": false, "code": "FORBIDDEN", "error": "Request blocked by WAF"}`, http.StatusForbidden)
 				return
 			}
 
@@ -29,8 +28,8 @@ func WAFMiddleware(logger *Logger) func(http.Handler) http.Handler {
 			if r.ContentLength > 0 {
 				bodyThreat := isThreatInBody(r)
 				if bodyThreat {
-					logger.LogWarning("[WAF] Suspicious content in request body detected from IP: %s", getClientIP(r))
-					http.Error(w, `{"success": false, "error": "Request blocked by WAF"}`, http.StatusForbidden)
+					logger.LogWarning("[WAF] Suspicious content in request body detected from IP: %s", ClientIP(r, trustedProxies))
+					http.Error(w, `{"success": false, "code": "FORBIDDEN", "error": "Request blocked by WAF"}`, http.StatusForbidden)
 					return
 				}
 			}
@@ -38,8 +37,8 @@ func WAFMiddleware(logger *Logger) func(http.Handler) http.Handler {
 			// Проверяем User-Agent
 			userAgent := r.Header.Get("User-Agent")
 			if isThreatInUserAgent(userAgent) {
-				logger.LogWarning("[WAF] Suspicious User-Agent detected from IP: %s", getClientIP(r))
-				http.Error(w, `{"success": false, "error": "Request blocked by WAF"}`, http.StatusForbidden)
+				logger.LogWarning("[WAF] Suspicious User-Agent detected from IP: %s", ClientIP(r, trustedProxies))
+				http.Error(w, `{"success": false, "code": "FORBIDDEN", "error": "Request blocked by WAF"}`, http.StatusForbidden)
 				return
 			}
 
@@ -400,47 +399,4 @@ func isThreatInUserAgent(userAgent string) bool {
 	}
 
 	return false
-}
-
-// getClientIP extracts the real client IP from request headers
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (can contain multiple IPs)
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// Take the first IP in the chain (original client)
-		ips := strings.Split(xForwardedFor, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			if ip != "" && ip != "unknown" {
-				return ip
-			}
-		}
-	}
-
-	// Check X-Real-IP header
-	xRealIP := r.Header.Get("X-Real-IP")
-	if xRealIP != "" && xRealIP != "unknown" {
-		return xRealIP
-	}
-
-	// Check CF-Connecting-IP (Cloudflare)
-	cfConnectingIP := r.Header.Get("CF-Connecting-IP")
-	if cfConnectingIP != "" {
-		return cfConnectingIP
-	}
-
-	// Check X-Client-IP
-	xClientIP := r.Header.Get("X-Client-IP")
-	if xClientIP != "" {
-		return xClientIP
-	}
-
-	// Fallback to RemoteAddr (remove port if present)
-	remoteAddr := r.RemoteAddr
-	host, _, err := net.SplitHostPort(remoteAddr)
-	if err == nil {
-		return host
-	}
-
-	return remoteAddr
 }
\ No newline at end of file
@@ -317,25 +317,52 @@ export function Navigation() {
 
   // Handle Google OAuth callback
   useEffect(() => {
-    const handleGoogleCallback = () => {
+    const handleGoogleCallback = async () => {
       const urlParams = new URLSearchParams(window.location.search);
-      const accessToken = urlParams.get('access_token');
-      const refreshToken = urlParams.get('refresh_token');
+      const code = urlParams.get('code');
+      if (!code) {
+        return;
+      }
 
-      if (accessToken) {
-        setAccessToken(accessToken);
-        if (refreshToken) {
-          setRefreshToken(refreshToken);
+      // Strip the one-time code before redeeming it so it never lingers in the URL or history
+      urlParams.delete('code');
+      const query = urlParams.toString();
+      const newUrl = window.location.pathname + (query ? `?${query}` : '');
+      window.history.replaceState({}, document.title, newUrl);
+
+      try {
+        const res = await fetch('/api/auth/exchange', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          credentials: 'include',
+          body: JSON.stringify({ code }),
+        });
+        const data = await res.json();
+
+        if (data.success && data.access_token) {
+          setAccessToken(data.access_token);
+          if (data.refresh_token) {
+            setRefreshToken(data.refresh_token);
+          }
+
+          queryClient.invalidateQueries({ queryKey: ['user'] });
+          queryClient.invalidateQueries({ queryKey: ['user-bets'] });
+          toast({
+            title: "Success",
+            description: "Successfully logged in with Google!",
+          });
+        } else {
+          toast({
+            title: "Error",
+            variant: "destructive",
+            description: data.error || "Google login failed",
+          });
         }
-
-        // Clear tokens from URL
-        const newUrl = window.location.pathname;
-        window.history.replaceState({}, document.title, newUrl);
-
-        // User data will be automatically refreshed due to token change in queryKey
+      } catch (error) {
         toast({
-          title: "Success",
-          description: "Successfully logged in with Google!",
+          title: "Error",
+          variant: "destructive",
+          description: "Network error. Please try again.",
         });
       }
     };
@@ -27,9 +27,9 @@ export function useGames() {
           away_odds: match.away_odds?.toString() || "1.00",
           completed: match.completed || false,
           calculated: match.calculated || false,
-          result: match.result || null,
-          home_score: match.home_score || null,
-          away_score: match.away_score || null,
+          result: match.result ?? null,
+          home_score: match.home_score ?? null,
+          away_score: match.away_score ?? null,
           created_at: match.created_at || new Date().toISOString(),
           updated_at: match.updated_at || new Date().toISOString(),
         })) as Game[];
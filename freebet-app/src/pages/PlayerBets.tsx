@@ -51,9 +51,9 @@ export default function PlayerBets() {
   const playerNickname = params.nickname;
 
   const { data, isLoading, error } = useQuery<PlayerBetsResponse>({
-    queryKey: [`/api/bets?player=${encodeURIComponent(playerNickname || '')}`],
+    queryKey: [`/api/players/${encodeURIComponent(playerNickname || '')}/bets`],
     queryFn: async () => {
-      const res = await fetch(`/api/bets?player=${encodeURIComponent(playerNickname || '')}`);
+      const res = await fetch(`/api/players/${encodeURIComponent(playerNickname || '')}/bets`);
       if (!res.ok) throw new Error("Failed to fetch player bets");
       return res.json();
     },
@@ -256,6 +256,10 @@ export default function PlayerBets() {
         return <Badge className="bg-green-500/10 text-green-600 dark:text-green-400 border-green-500/20 font-semibold text-xs">Won</Badge>;
       case "lost":
         return <Badge className="bg-red-500/10 text-red-600 dark:text-red-400 border-red-500/20 font-semibold text-xs">Lost</Badge>;
+      case "push":
+        return <Badge className="bg-gray-500/10 text-gray-600 dark:text-gray-400 border-gray-500/20 font-semibold text-xs">Push</Badge>;
+      case "void":
+        return <Badge className="bg-gray-500/10 text-gray-600 dark:text-gray-400 border-gray-500/20 font-semibold text-xs">Refunded</Badge>;
       default:
         return <Badge className="bg-amber-500/10 text-amber-600 dark:text-amber-400 border-amber-500/20 font-semibold text-xs">Pending</Badge>;
     }
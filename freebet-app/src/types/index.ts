@@ -6,7 +6,7 @@ export interface Bet {
   bet_amount: number;
   odds: number;
   potential_win: number;
-  status: 'pending' | 'won' | 'lost';
+  status: 'pending' | 'won' | 'lost' | 'push' | 'void';
   home_team: string;
   away_team: string;
   created_at: string;
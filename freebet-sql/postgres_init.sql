@@ -5,13 +5,24 @@
 -- Usage:
 --   psql -U your_username -d your_database -f postgres_init.sql
 --
+-- NOTE: drops and recreates all tables. For incremental, versioned schema changes
+-- use the API migrations instead (freebet-api/migrations, run: freebet-api migrate)
+--
 -- After running this script:
 -- 1. Set up Google OAuth credentials in your .env file
 -- 2. Configure JWT_SECRET for token signing
 -- 3. Start the API server
 
 -- Drop all tables in correct order (respecting foreign keys)
+DROP TABLE IF EXISTS notifications CASCADE;
+DROP TABLE IF EXISTS ledger CASCADE;
+DROP TABLE IF EXISTS balance_adjustments CASCADE;
+DROP TABLE IF EXISTS season_results CASCADE;
+DROP TABLE IF EXISTS seasons CASCADE;
 DROP TABLE IF EXISTS bets CASCADE;
+DROP TABLE IF EXISTS user_limits CASCADE;
+DROP TABLE IF EXISTS admin_sessions CASCADE;
+DROP TABLE IF EXISTS admins CASCADE;
 DROP TABLE IF EXISTS refresh_tokens CASCADE;
 DROP TABLE IF EXISTS epl_matches CASCADE;
 DROP TABLE IF EXISTS users CASCADE;
@@ -19,15 +30,20 @@ DROP TABLE IF EXISTS users CASCADE;
 -- Users table - supports both email/password and Google OAuth authentication
 CREATE TABLE users (
   id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-  email VARCHAR(255) UNIQUE NOT NULL,
+  email VARCHAR(255) UNIQUE,                     -- NULL for guests
   nickname VARCHAR(10) UNIQUE NOT NULL,
   password_hash VARCHAR(255),                    -- NULL for OAuth users
   google_id VARCHAR(255) UNIQUE,                 -- Google OAuth ID
   picture_url VARCHAR(500),                      -- Profile picture URL
-  auth_provider VARCHAR(20) DEFAULT 'email',     -- 'email' or 'google'
+  auth_provider VARCHAR(20) DEFAULT 'email',     -- 'email', 'google' or 'guest'
   money DECIMAL(15, 2) DEFAULT 0,               -- Virtual currency balance
   topup INTEGER DEFAULT 0,                       -- Number of balance top-ups
   last_topup_at TIMESTAMP,                       -- Last top-up timestamp
+  token_version INTEGER NOT NULL DEFAULT 0,      -- Bumped to invalidate access tokens
+  deleted_at TIMESTAMP,                          -- Set while an admin has disabled the account
+  nickname_changed_at TIMESTAMP,                 -- Last nickname change (cooldown)
+  is_guest BOOLEAN NOT NULL DEFAULT FALSE,       -- Guest account (POST /api/auth/guest) until upgraded
+  last_active_at TIMESTAMP,                      -- Guest activity, for expiring inactive guests
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -41,6 +57,26 @@ CREATE TABLE refresh_tokens (
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Admins table - credentials for admin sync/calc endpoints
+CREATE TABLE admins (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  username VARCHAR(50) UNIQUE NOT NULL,
+  email VARCHAR(255),
+  password_hash VARCHAR(255) NOT NULL,          -- bcrypt hash
+  is_active BOOLEAN DEFAULT TRUE,
+  last_login TIMESTAMP,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Admin sessions table - issued admin tokens (deleted on revoke)
+CREATE TABLE admin_sessions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  token VARCHAR(512) UNIQUE NOT NULL,           -- JWT admin token
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Football matches table - stores match data and betting odds
 CREATE TABLE epl_matches (
   id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -51,13 +87,20 @@ CREATE TABLE epl_matches (
   home_odds DECIMAL(10, 2),               -- Betting odds for home win
   draw_odds DECIMAL(10, 2),               -- Betting odds for draw
   away_odds DECIMAL(10, 2),               -- Betting odds for away win
+  btts_yes_odds DECIMAL(10, 2),           -- Both teams to score: yes
+  btts_no_odds DECIMAL(10, 2),            -- Both teams to score: no
+  correct_score_odds JSONB,               -- Correct score odds keyed by "home-away", e.g. {"2-1": 9.5}
+  totals_odds JSONB,                      -- Total goals odds keyed by bet type, e.g. {"over_2.5": 1.9}
   completed BOOLEAN DEFAULT FALSE,         -- Whether match has finished
   calculated BOOLEAN DEFAULT FALSE,        -- Whether bets have been processed
-  result VARCHAR(10),                      -- 'home', 'draw', 'away' - match outcome
+  result VARCHAR(10),                      -- 'home', 'draw', 'away' - match outcome, 'void' if never scored
   home_score INTEGER,                      -- Final score for home team
   away_score INTEGER,                      -- Final score for away team
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  odds_updated_at TIMESTAMP,               -- Last odds sync that priced the match
+  shootout_winner VARCHAR(4) CHECK (shootout_winner IN ('home', 'away')), -- Penalty shootout winner of a level score
+  odds_source VARCHAR(64)                  -- Bookmaker the stored 1X2 prices came from
 );
 
 -- User bets table - stores all betting transactions
@@ -65,32 +108,112 @@ CREATE TABLE bets (
   bet_id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
   match_id VARCHAR(255) NOT NULL,           -- Reference to epl_matches.api_id
-  bet_type VARCHAR(50) NOT NULL,            -- 'home', 'draw', 'away'
+  bet_type VARCHAR(50) NOT NULL,            -- 'home', 'draw', 'away', 'btts_yes', 'btts_no', 'cs_<home>-<away>', 'over_<line>', 'under_<line>'
   bet_amount DECIMAL(15, 2) NOT NULL,       -- Amount bet by user
   odds DECIMAL(10, 2) NOT NULL,             -- Odds at time of bet
   potential_win DECIMAL(15, 2) NOT NULL,    -- Potential payout
-  status VARCHAR(50) DEFAULT 'pending',     -- 'pending', 'won', 'lost'
+  status VARCHAR(50) DEFAULT 'pending',     -- 'review' (awaiting admin approval), 'pending', 'won', 'lost', 'push' (refunded on the line), 'void' (refunded)
   home_team VARCHAR(255),                   -- Cached team names
   away_team VARCHAR(255),
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  settled_at TIMESTAMP                      -- When the bet was won, lost or voided
+);
+
+-- Responsible-gambling self-limits, one row per user who set any
+CREATE TABLE user_limits (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  daily_wager_limit DECIMAL(15, 2),             -- NULL = no limit
+  weekly_wager_limit DECIMAL(15, 2),
+  daily_loss_limit DECIMAL(15, 2),
+  weekly_loss_limit DECIMAL(15, 2),
+  self_excluded_until TIMESTAMP,                -- Betting blocked until this time
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Admin balance corrections, one row per adjustment
+CREATE TABLE balance_adjustments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  admin_id UUID REFERENCES admins(id) ON DELETE SET NULL,
+  amount DECIMAL(15, 2) NOT NULL,               -- Signed: credit > 0, debit < 0
+  reason VARCHAR(255) NOT NULL,
+  balance_after DECIMAL(15, 2) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Balance history, one row per change to users.money
+CREATE TABLE ledger (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(20) NOT NULL,                    -- initial_grant, topup, bet_stake, bet_payout, bet_refund, admin_adjustment, season_prize, opening_balance
+  amount DECIMAL(15, 2) NOT NULL,               -- Signed: credit > 0, debit < 0
+  balance_after DECIMAL(15, 2) NOT NULL,
+  reference VARCHAR(255),                       -- bet_id, season id or balance_adjustments id
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- In-app inbox, one row per event for a user (e.g. a settled bet)
+CREATE TABLE notifications (
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type VARCHAR(20) NOT NULL,                    -- win, loss, push, void, topup_available
+  payload JSONB NOT NULL DEFAULT '{}',          -- Type-specific details, e.g. bet_id and payout
+  read_at TIMESTAMP,                            -- NULL while unread
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Leaderboard seasons; bets placed in [starts_at, ends_at) count towards a season
+CREATE TABLE seasons (
+  id SERIAL PRIMARY KEY,
+  starts_at TIMESTAMP NOT NULL,
+  ends_at TIMESTAMP NOT NULL,
+  closed_at TIMESTAMP,                          -- NULL while the season is open
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Final standings snapshotted when a season is closed
+CREATE TABLE season_results (
+  season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+  rank INTEGER NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  nickname VARCHAR(10) NOT NULL,                -- Nickname at closing time
+  bets INTEGER NOT NULL,
+  won_bets INTEGER NOT NULL,
+  settled_bets INTEGER NOT NULL,
+  wagered DECIMAL(15, 2) NOT NULL,
+  profit DECIMAL(15, 2) NOT NULL,               -- Won payouts minus settled stakes
+  prize DECIMAL(15, 2) NOT NULL DEFAULT 0,      -- Credited to the user's balance
+  PRIMARY KEY (season_id, rank)
+);
+
 -- Create indexes for performance
 CREATE INDEX idx_users_email ON users(email);
 CREATE UNIQUE INDEX idx_users_nickname ON users(nickname);
 CREATE UNIQUE INDEX idx_users_google_id ON users(google_id);
 CREATE INDEX idx_users_auth_provider ON users(auth_provider);
+CREATE INDEX idx_users_guest_last_active ON users(last_active_at) WHERE is_guest;
 CREATE INDEX idx_refresh_tokens_token ON refresh_tokens(token);
 CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_admin_sessions_admin_id ON admin_sessions(admin_id);
 CREATE INDEX idx_bets_user_id ON bets(user_id);
 CREATE INDEX idx_bets_match_id ON bets(match_id);
 CREATE INDEX idx_bets_status ON bets(status);
+CREATE INDEX idx_bets_user_id_created_at_bet_id ON bets(user_id, created_at, bet_id);
+CREATE INDEX idx_bets_created_at ON bets(created_at);
+CREATE INDEX idx_balance_adjustments_user_id ON balance_adjustments(user_id);
+CREATE INDEX idx_ledger_user_id ON ledger(user_id, id);
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, id);
+CREATE INDEX idx_notifications_unread ON notifications(user_id, id) WHERE read_at IS NULL;
 CREATE INDEX idx_epl_matches_api_id ON epl_matches(api_id);
 CREATE INDEX idx_epl_matches_commence_time ON epl_matches(commence_time);
 CREATE INDEX idx_epl_matches_result ON epl_matches(result);
 CREATE INDEX idx_epl_matches_completed ON epl_matches(completed);
 CREATE INDEX idx_epl_matches_calculated ON epl_matches(calculated);
+-- One row per fixture, even when the odds and scores feeds use different api_ids
+CREATE UNIQUE INDEX idx_epl_matches_fixture ON epl_matches (lower(home_team), lower(away_team), (commence_time::date));
+-- At most one open season
+CREATE UNIQUE INDEX idx_seasons_open ON seasons ((closed_at IS NULL)) WHERE closed_at IS NULL;
 
 -- Database initialization complete
 -- Ready for user registration via email/password or Google OAuth
\ No newline at end of file